@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -19,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -36,18 +36,23 @@ const (
 
 // App struct
 type App struct {
-	ctx context.Context
-	db  *sql.DB
+	ctx            context.Context
+	db             atomic.Pointer[sql.DB] // 仅 SQLite 存储时非 nil；恢复备份时会替换（见 backup.go）
 	fileServerOnce sync.Once
 	fileServerPort int
-}
-
-func isProPlan(planType string) bool {
-	planType = strings.ToLower(strings.TrimSpace(planType))
-	if planType == "" {
-		return false
-	}
-	return strings.Contains(planType, "pro")
+	engine         *videoTaskEngine
+	scheduler      *tokenScheduler
+	secrets        atomic.Pointer[secretBox]
+	store          Store        // 账号/任务/下载/设置存储，SQLite 或 JSON 文件；初始化成功后为 *swapStore，之后不再改写
+	dbInitErr      atomic.Value // string，最近一次数据库初始化/迁移失败的原因，供 /api/admin/migrations 展示
+	dataDir        string       // 数据目录（见 data_dir.go）
+	dataDirSource  string
+	legacyDataDir  string                    // 本次启动从哪个旧目录迁移了数据，为空表示未迁移
+	backupMu       sync.Mutex                // 备份与恢复互斥（见 backup.go）
+	dataMu         sync.RWMutex              // 恢复备份替换存储时持写锁，后台定时任务每一轮持读锁
+	config         atomic.Pointer[AppConfig] // 解析后的系统配置缓存（见 app_config.go）
+	refresher      *atRefresher              // AT 自动刷新状态（见 token_refresh.go）
+	health         *healthChecker            // 定时健康检查状态（见 health_check.go）
 }
 
 // Config 用于当 SQLite 不可用时的文件配置回退
@@ -62,17 +67,37 @@ type HealthResult struct {
 }
 
 // NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+func NewApp(dataDir, dataDirSource string) *App {
+	return &App{dataDir: dataDir, dataDirSource: dataDirSource, refresher: newATRefresher(), health: newHealthChecker()}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.prepareDataDir()
 	// 尝试初始化 SQLite，失败时自动降级为文件存储
 	if err := a.initDB(); err != nil {
-		runtime.LogWarning(a.ctx, fmt.Sprintf("初始化数据库失败，将使用文件配置: %v", err))
+		a.dbInitErr.Store(err.Error())
+		a.logError(fmt.Sprintf("初始化数据库失败，将使用文件配置: %v", err))
+	}
+	a.scheduler = newTokenScheduler(a)
+	if a.store != nil {
+		go a.runCooldownWatcher()
+		go a.runATRefresher()
+		go a.runHealthChecker()
+		a.pruneTokenSnapshots()
+		a.pruneFailureEvents()
+	}
+	// 启动后台视频任务引擎，并恢复上次未完成的任务
+	a.engine = newVideoTaskEngine(a)
+	a.engine.start()
+}
+
+// shutdown 在应用退出时调用，停止所有后台任务
+func (a *App) shutdown(ctx context.Context) {
+	if a.engine != nil {
+		a.engine.stop()
 	}
 }
 
@@ -83,120 +108,102 @@ func (a *App) Greet(name string) string {
 
 // LogDebug 供前端打印调试信息，从命令行运行 exe 时会在终端看到
 func (a *App) LogDebug(msg string) {
-	runtime.LogInfo(a.ctx, "[前端] "+msg)
+	a.logInfo("[前端] " + msg)
 }
 
-// initDB 初始化 SQLite 数据库和表
+// initDB 初始化本地存储：优先使用 SQLite，CGO 被禁用时退回 JSON 文件存储（见 store.go）
 func (a *App) initDB() error {
-	// 所有本地数据统一写入当前工作目录下的 accounts.db
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	dbPath := filepath.Join(baseDir, "accounts.db")
+	// 所有本地数据统一写入数据目录下的 accounts.db
+	dbPath := a.dataPath(dbFileName)
 	db, err := sql.Open("sqlite3", dbPath)
+	var ran []int
+	if err == nil {
+		// 按版本执行 schema 迁移（见 migrations.go），失败时不启用数据库
+		// sql.Open 不会真正连接，CGO 被禁用时的 stub 错误也在这里返回
+		ran, err = runSchemaMigrations(db)
+		if err != nil {
+			db.Close()
+		}
+	}
 	if err != nil {
-		// 在 CGO_DISABLED 环境下，go-sqlite3 会返回 stub 错误，这里直接忽略并退回文件存储
+		// 在 CGO_DISABLED 环境下，go-sqlite3 会返回 stub 错误，改用纯 Go 的 JSON 文件存储
 		if strings.Contains(err.Error(), "requires cgo") {
-			runtime.LogWarning(a.ctx, "CGO 被禁用，SQLite 将不可用，使用文件配置代替")
-			return nil
+			return a.initJSONStore()
 		}
+		return fmt.Errorf("数据库迁移失败: %v", err)
+	}
+	if len(ran) > 0 {
+		a.logInfo(fmt.Sprintf("[DB] 已执行 schema 迁移 %v，当前版本 %d", ran, latestSchemaVersion()))
+	}
+	store := newSQLiteStore(db)
+	if err := a.initSecrets(store); err != nil {
+		db.Close()
 		return err
 	}
+	a.db.Store(db)
+	a.publishStore(store)
+	a.afterStoreOpened()
+	return nil
+}
 
-	// 创建表（如不存在）
-	schema := `
-CREATE TABLE IF NOT EXISTS accounts (
-	id INTEGER PRIMARY KEY AUTOINCREMENT,
-	bearer_token TEXT NOT NULL,
-	host TEXT,
-	port INTEGER,
-	status_json TEXT,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS settings (
-	key TEXT PRIMARY KEY,
-	value TEXT NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS tokens (
-	id INTEGER PRIMARY KEY AUTOINCREMENT,
-	token TEXT NOT NULL,
-	st TEXT,
-	rt TEXT,
-	client_id TEXT,
-	is_active INTEGER DEFAULT 1,
-	remark TEXT,
-	proxy_url TEXT,
-	image_enabled INTEGER DEFAULT 1,
-	video_enabled INTEGER DEFAULT 1,
-	image_concurrency INTEGER DEFAULT -1,
-	video_concurrency INTEGER DEFAULT 3,
-	status_json TEXT,
-	plan_type TEXT,
-	error_message TEXT DEFAULT '',
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS video_task_results (
-	id INTEGER PRIMARY KEY AUTOINCREMENT,
-	task_id TEXT UNIQUE NOT NULL,
-	token_id INTEGER,
-	result_json TEXT,
-	progress_pct REAL DEFAULT 0,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	prompt TEXT DEFAULT ''
-);
-
-CREATE TABLE IF NOT EXISTS video_downloads (
-	id INTEGER PRIMARY KEY AUTOINCREMENT,
-	generation_id TEXT UNIQUE NOT NULL,
-	task_id TEXT,
-	post_id TEXT,
-	downloadable_url TEXT,
-	local_path TEXT NOT NULL,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS task_list (
-	key TEXT PRIMARY KEY,
-	value TEXT NOT NULL
-);
-`
-	if _, err := db.Exec(schema); err != nil {
-		db.Close()
-		// 同样处理可能的 stub 错误
-		if strings.Contains(err.Error(), "requires cgo") {
-			runtime.LogWarning(a.ctx, "CGO 被禁用，SQLite 将不可用，使用文件配置代替")
-			return nil
-		}
+// initJSONStore 打开数据目录下的 store.json 作为存储
+func (a *App) initJSONStore() error {
+	store, err := openJSONStore(a.dataPath(jsonStoreFile))
+	if err != nil {
+		return fmt.Errorf("打开 JSON 存储失败: %v", err)
+	}
+	if err := a.initSecrets(store); err != nil {
 		return err
 	}
-	// 兼容旧库：若无 plan_type 列则添加（忽略已存在错误）
-	_, _ = db.Exec("ALTER TABLE tokens ADD COLUMN plan_type TEXT DEFAULT ''")
-	_, _ = db.Exec("ALTER TABLE tokens ADD COLUMN error_message TEXT DEFAULT ''")
-	// 兼容旧库：video_task_results 若无 progress_pct 则添加
-	_, _ = db.Exec("ALTER TABLE video_task_results ADD COLUMN progress_pct REAL DEFAULT 0")
-	// 兼容旧库：video_task_results 若无 prompt 列则添加
-	_, _ = db.Exec("ALTER TABLE video_task_results ADD COLUMN prompt TEXT DEFAULT ''")
-	// 兼容旧库：video_task_results 若无 token_id 列则添加（允许 NULL，因为旧数据可能没有）
-	_, _ = db.Exec("ALTER TABLE video_task_results ADD COLUMN token_id INTEGER")
-	// 兼容旧库：video_downloads 若无 post_id 列则添加
-	_, _ = db.Exec("ALTER TABLE video_downloads ADD COLUMN post_id TEXT DEFAULT ''")
-
-	a.db = db
+	a.logWarning("CGO 被禁用，SQLite 不可用，使用 JSON 文件存储: " + store.path)
+	a.db.Store(nil)
+	a.publishStore(store)
+	a.afterStoreOpened()
 	return nil
 }
 
-// loadConfig 从本地 config.json 读取配置（回退方案）
-func (a *App) loadConfig() (*Config, error) {
-	baseDir, err := os.Getwd()
+// publishStore 发布新打开的存储：首次直接设置 a.store，之后（恢复备份）只替换 swapStore 的底层实现并关闭旧存储
+func (a *App) publishStore(s Store) {
+	defer a.invalidateAppConfig()
+	if w, ok := a.store.(*swapStore); ok {
+		if old := w.swap(s); old != nil {
+			_ = old.Close()
+		}
+		return
+	}
+	a.store = newSwapStore(s)
+}
+
+// dbInitError 最近一次数据库初始化/迁移失败的原因，成功时为空
+func (a *App) dbInitError() string {
+	s, _ := a.dbInitErr.Load().(string)
+	return s
+}
+
+// initSecrets 凭证加密：密钥不可用时不启用存储，避免把新明文写进已加密的库
+func (a *App) initSecrets(store settingsStore) error {
+	box, source, err := loadSecretBox(store, a.dataPath(), func(msg string) {
+		a.logWarning("[Secrets] " + msg)
+	})
 	if err != nil {
-		baseDir = "."
+		return fmt.Errorf("初始化凭证加密失败: %v", err)
+	}
+	a.secrets.Store(box)
+	a.logInfo("[Secrets] 凭证加密已启用，密钥来源: " + source)
+	return nil
+}
+
+// afterStoreOpened 存储就绪后执行一次性的数据迁移
+func (a *App) afterStoreOpened() {
+	if err := a.migrateSecretsAtRest(); err != nil {
+		a.logError("加密迁移旧凭证失败: " + err.Error())
 	}
-	path := filepath.Join(baseDir, "config.json")
+	a.rewriteLegacyDownloadPaths()
+}
+
+// loadConfig 从本地 config.json 读取配置（回退方案）
+func (a *App) loadConfig() (*Config, error) {
+	path := a.dataPath("config.json")
 
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
@@ -215,11 +222,7 @@ func (a *App) loadConfig() (*Config, error) {
 
 // saveConfig 将配置写入本地 config.json（回退方案）
 func (a *App) saveConfig(cfg *Config) error {
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	path := filepath.Join(baseDir, "config.json")
+	path := a.dataPath("config.json")
 
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -232,17 +235,16 @@ func (a *App) saveConfig(cfg *Config) error {
 func (a *App) GetBaseURL() string {
 	const defaultURL = "http://127.0.0.1:8000"
 
-	// 1) 优先从 SQLite 读取（若可用）
-	if a.db != nil {
-		var val string
-		err := a.db.QueryRow(`SELECT value FROM settings WHERE key = 'base_url'`).Scan(&val)
+	// 1) 优先从本地存储读取（若可用）
+	if a.store != nil {
+		val, err := a.store.GetSetting("base_url")
 		if err == nil {
 			val = strings.TrimSpace(val)
 			if val != "" {
 				return val
 			}
-		} else if err != sql.ErrNoRows {
-			runtime.LogError(a.ctx, fmt.Sprintf("读取 base_url 失败: %v", err))
+		} else if err != errNotFound {
+			a.logError(fmt.Sprintf("读取 base_url 失败: %v", err))
 		}
 	}
 
@@ -250,7 +252,7 @@ func (a *App) GetBaseURL() string {
 	if cfg, err := a.loadConfig(); err == nil && strings.TrimSpace(cfg.BaseURL) != "" {
 		return strings.TrimSpace(cfg.BaseURL)
 	} else if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("读取文件配置失败: %v", err))
+		a.logError(fmt.Sprintf("读取文件配置失败: %v", err))
 	}
 
 	// 3) 最终使用默认值
@@ -258,37 +260,27 @@ func (a *App) GetBaseURL() string {
 }
 
 func (a *App) getSettingValue(key string) string {
-	if a.db == nil {
+	if a.store == nil {
 		return ""
 	}
-	var val string
-	if err := a.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&val); err == nil {
-		return val
-	}
-	return ""
+	return settingValue(a.store, key)
 }
 
 func (a *App) setSettingValue(key, value string) {
-	if a.db == nil {
+	if a.store == nil {
 		return
 	}
-	_, _ = a.db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
-		ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
+	_ = a.store.SetSetting(key, value)
 }
 
 // SetBaseURL 将 BaseURL 写入 SQLite settings 表，若 SQLite 不可用则写入 config.json
 func (a *App) SetBaseURL(url string) error {
 	trimmed := strings.TrimSpace(url)
 
-	// 1) 若 SQLite 可用，先写入 settings 表
-	if a.db != nil {
-		_, err := a.db.Exec(
-			`INSERT INTO settings (key, value) VALUES ('base_url', ?) 
-			 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
-			trimmed,
-		)
-		if err != nil {
-			runtime.LogError(a.ctx, fmt.Sprintf("保存 base_url 到 SQLite 失败: %v", err))
+	// 1) 若本地存储可用，先写入 settings
+	if a.store != nil {
+		if err := a.store.SetSetting("base_url", trimmed); err != nil {
+			a.logError(fmt.Sprintf("保存 base_url 到 SQLite 失败: %v", err))
 		}
 	}
 
@@ -300,7 +292,7 @@ func (a *App) SetBaseURL(url string) error {
 	}
 	cfg.BaseURL = trimmed
 	if err := a.saveConfig(cfg); err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("保存 base_url 到文件失败: %v", err))
+		a.logError(fmt.Sprintf("保存 base_url 到文件失败: %v", err))
 		return err
 	}
 
@@ -309,6 +301,51 @@ func (a *App) SetBaseURL(url string) error {
 
 // CheckAccountAndSave 调用 /account/status 并将账号信息写入本地 SQLite
 func (a *App) CheckAccountAndSave(bearerToken string) (string, error) {
+	respBody, err := a.fetchAccountStatus(a.tokenIDByBearer(bearerToken), bearerToken)
+	if err != nil {
+		return "", err
+	}
+	base := strings.TrimRight(a.GetBaseURL(), "/")
+
+	// 解析 host/port 方便后续查询
+	u, err := url.Parse(base)
+	var host string
+	var port int
+	if err == nil {
+		host = u.Hostname()
+		if p := u.Port(); p != "" {
+			fmt.Sscanf(p, "%d", &port)
+		}
+	}
+
+	// 将结果写入 SQLite（bearer_token 加密存储）
+	db := a.db.Load()
+	if db != nil {
+		encBearer, err := a.sealSecret(bearerToken)
+		if err != nil {
+			return "", fmt.Errorf("加密 bearer_token 失败: %v", err)
+		}
+		_, err = db.Exec(
+			`INSERT INTO accounts (bearer_token, host, port, status_json, created_at) VALUES (?, ?, ?, ?, ?)`,
+			encBearer,
+			host,
+			port,
+			string(respBody),
+			time.Now(),
+		)
+		if err != nil {
+			a.logError(fmt.Sprintf("写入账号到 SQLite 失败: %v", err))
+		}
+	} else if a.store == nil {
+		a.logError("SQLite 数据库未初始化，无法写入账号信息")
+	}
+
+	return string(respBody), nil
+}
+
+// fetchAccountStatus 调用 /account/status 返回响应体，不写入 accounts 表（账号测试与定时健康检查使用）；
+// tokenID 为该 bearer 所属账号（用于选择代理与记录日志），未知时传 0
+func (a *App) fetchAccountStatus(tokenID int64, bearerToken string) (string, error) {
 	if bearerToken == "" {
 		return "", fmt.Errorf("bearer_token 不能为空")
 	}
@@ -324,9 +361,7 @@ func (a *App) CheckAccountAndSave(bearerToken string) (string, error) {
 		return "", err
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	client := a.httpClientForToken(tokenID, 30*time.Second)
 
 	req, err := http.NewRequest(http.MethodPost, statusURL, bytes.NewReader(bodyBytes))
 	if err != nil {
@@ -334,55 +369,26 @@ func (a *App) CheckAccountAndSave(bearerToken string) (string, error) {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	runtime.LogInfo(a.ctx, fmt.Sprintf("请求账号状态: %s", statusURL))
+	a.logInfo(fmt.Sprintf("请求账号状态: %s", statusURL))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("请求账号状态失败: %v", err))
+		a.logError(fmt.Sprintf("请求账号状态失败: %v", err))
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("读取账号状态响应失败: %v", err))
+		a.logError(fmt.Sprintf("读取账号状态响应失败: %v", err))
 		return "", err
 	}
 
-	runtime.LogInfo(a.ctx, fmt.Sprintf("账号状态响应: HTTP %d, Body: %s", resp.StatusCode, string(respBody)))
+	a.logInfo(fmt.Sprintf("账号状态响应: HTTP %d, Body: %s", resp.StatusCode, string(respBody)))
 
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("账号状态检查失败: HTTP %d: %s", resp.StatusCode, string(respBody))
 	}
-
-	// 解析 host/port 方便后续查询
-	u, err := url.Parse(base)
-	var host string
-	var port int
-	if err == nil {
-		host = u.Hostname()
-		if p := u.Port(); p != "" {
-			fmt.Sscanf(p, "%d", &port)
-		}
-	}
-
-	// 将结果写入 SQLite
-	if a.db != nil {
-		_, err = a.db.Exec(
-			`INSERT INTO accounts (bearer_token, host, port, status_json, created_at) VALUES (?, ?, ?, ?, ?)`,
-			bearerToken,
-			host,
-			port,
-			string(respBody),
-			time.Now(),
-		)
-		if err != nil {
-			runtime.LogError(a.ctx, fmt.Sprintf("写入账号到 SQLite 失败: %v", err))
-		}
-	} else {
-		runtime.LogError(a.ctx, "SQLite 数据库未初始化，无法写入账号信息")
-	}
-
 	return string(respBody), nil
 }
 
@@ -399,16 +405,16 @@ func (a *App) AccountMe(bearerToken string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := a.httpClientForBearer(bearerToken, 30*time.Second)
 	req, err := http.NewRequest(http.MethodPost, meURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	runtime.LogInfo(a.ctx, fmt.Sprintf("请求账号资料: %s", meURL))
+	a.logInfo(fmt.Sprintf("请求账号资料: %s", meURL))
 	resp, err := client.Do(req)
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("请求 /account/me 失败: %v", err))
+		a.logError(fmt.Sprintf("请求 /account/me 失败: %v", err))
 		return "", err
 	}
 	defer resp.Body.Close()
@@ -416,7 +422,7 @@ func (a *App) AccountMe(bearerToken string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("/account/me 响应: HTTP %d", resp.StatusCode))
+	a.logInfo(fmt.Sprintf("/account/me 响应: HTTP %d", resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("account/me 失败, HTTP %d: %s", resp.StatusCode, string(respBody))
 	}
@@ -425,6 +431,11 @@ func (a *App) AccountMe(bearerToken string) (string, error) {
 
 // AccountSubscriptions 调用远程 POST /account/subscriptions，传 bearer_token，返回 data[].plan.id 用于判断 free/plus
 func (a *App) AccountSubscriptions(bearerToken string) (string, error) {
+	return a.accountSubscriptions(a.tokenIDByBearer(bearerToken), bearerToken)
+}
+
+// accountSubscriptions 同 AccountSubscriptions，已知账号 id 时直接使用该账号的代理
+func (a *App) accountSubscriptions(tokenID int64, bearerToken string) (string, error) {
 	if bearerToken == "" {
 		return "", fmt.Errorf("bearer_token 不能为空")
 	}
@@ -435,16 +446,16 @@ func (a *App) AccountSubscriptions(bearerToken string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := a.httpClientForToken(tokenID, 30*time.Second)
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	runtime.LogInfo(a.ctx, fmt.Sprintf("请求订阅: %s", url))
+	a.logInfo(fmt.Sprintf("请求订阅: %s", url))
 	resp, err := client.Do(req)
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("请求 /account/subscriptions 失败: %v", err))
+		a.logError(fmt.Sprintf("请求 /account/subscriptions 失败: %v", err))
 		return "", err
 	}
 	defer resp.Body.Close()
@@ -452,7 +463,7 @@ func (a *App) AccountSubscriptions(bearerToken string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("/account/subscriptions 响应: HTTP %d", resp.StatusCode))
+	a.logInfo(fmt.Sprintf("/account/subscriptions 响应: HTTP %d", resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("account/subscriptions 失败, HTTP %d: %s", resp.StatusCode, string(respBody))
 	}
@@ -518,6 +529,9 @@ func isLocalApiPath(path string) bool {
 	if strings.HasPrefix(path, "/api/token-refresh") {
 		return true
 	}
+	if strings.HasPrefix(path, "/api/health-check") {
+		return true
+	}
 	if strings.HasPrefix(path, "/api/tasks/") {
 		return true
 	}
@@ -541,7 +555,7 @@ func logApiLabel(path string) string {
 // ApiRequest 通用 API 代理：本地管理接口在 Go 内处理，其余转发到远程服务器
 func (a *App) ApiRequest(method string, path string, body string, token string) (string, error) {
 	if isLocalApiPath(path) {
-		runtime.LogInfo(a.ctx, fmt.Sprintf("ApiRequest 本地处理: %s %s", method, path))
+		a.logInfo(fmt.Sprintf("ApiRequest 本地处理: %s %s", method, path))
 		return a.handleLocalApi(method, path, body, token)
 	}
 
@@ -550,18 +564,18 @@ func (a *App) ApiRequest(method string, path string, body string, token string)
 
 	label := logApiLabel(path)
 	if label != "" {
-		runtime.LogInfo(a.ctx, "========== "+label+" 请求 ==========")
-		runtime.LogInfo(a.ctx, fmt.Sprintf("  %s %s", method, fullURL))
+		a.logInfo("========== " + label + " 请求 ==========")
+		a.logInfo(fmt.Sprintf("  %s %s", method, fullURL))
 		if body != "" {
 			if len(body) > 2000 {
-				runtime.LogInfo(a.ctx, "  body: "+body[:2000]+"...(truncated)")
+				a.logInfo("  body: " + body[:2000] + "...(truncated)")
 			} else {
-				runtime.LogInfo(a.ctx, "  body: "+body)
+				a.logInfo("  body: " + body)
 			}
 		}
-		runtime.LogInfo(a.ctx, "----------------------------------------")
+		a.logInfo("----------------------------------------")
 	} else {
-		runtime.LogInfo(a.ctx, fmt.Sprintf("ApiRequest 转发远程: %s %s", method, fullURL))
+		a.logInfo(fmt.Sprintf("ApiRequest 转发远程: %s %s", method, fullURL))
 	}
 
 	var bodyReader io.Reader
@@ -571,7 +585,7 @@ func (a *App) ApiRequest(method string, path string, body string, token string)
 
 	req, err := http.NewRequest(method, fullURL, bodyReader)
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("ApiRequest 创建请求失败: %v", err))
+		a.logError(fmt.Sprintf("ApiRequest 创建请求失败: %v", err))
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
@@ -579,35 +593,29 @@ func (a *App) ApiRequest(method string, path string, body string, token string)
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	tokenID := a.tokenIDByBearer(token)
+	client := a.httpClientForToken(tokenID, 60*time.Second)
+	statusCode, respBody, err := a.doLoggedRequest(client, req, "ApiRequest", tokenID, []byte(body))
 	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("ApiRequest 请求失败: %v", err))
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		runtime.LogError(a.ctx, fmt.Sprintf("ApiRequest 读取响应失败: %v", err))
+		a.logError(fmt.Sprintf("ApiRequest 请求失败: %v", err))
 		return "", err
 	}
 
 	if label != "" {
-		runtime.LogInfo(a.ctx, "========== "+label+" 响应 (HTTP "+strconv.Itoa(resp.StatusCode)+") ==========")
+		a.logInfo("========== " + label + " 响应 (HTTP " + strconv.Itoa(statusCode) + ") ==========")
 		respStr := string(respBody)
 		if len(respStr) > 3000 {
-			runtime.LogInfo(a.ctx, respStr[:3000]+"...(truncated)")
+			a.logInfo(respStr[:3000] + "...(truncated)")
 		} else {
-			runtime.LogInfo(a.ctx, respStr)
+			a.logInfo(respStr)
 		}
-		runtime.LogInfo(a.ctx, "========================================")
+		a.logInfo("========================================")
 	} else {
-		runtime.LogInfo(a.ctx, fmt.Sprintf("ApiRequest 响应: HTTP %d, Body: %s", resp.StatusCode, string(respBody)))
+		a.logInfo(fmt.Sprintf("ApiRequest 响应: HTTP %d, Body: %s", statusCode, string(respBody)))
 	}
 
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+	if statusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
 	}
 
 	return string(respBody), nil
@@ -636,16 +644,16 @@ func (a *App) handleLocalApi(method string, path string, body string, _ string)
 	}
 	// /api/logs
 	if len(parts) >= 1 && parts[0] == "logs" {
-		return a.handleLocalLogs(method, path, body)
+		return a.handleLocalLogs(method, fullPath, body)
 	}
 	// /api/stats
 	if len(parts) >= 1 && parts[0] == "stats" {
-		return a.handleLocalStats(method, path)
+		return a.handleLocalStats(method, fullPath)
 	}
-	// /api/proxy, /api/watermark-free, /api/cache, /api/generation, /api/token-refresh
+	// /api/proxy, /api/watermark-free, /api/cache, /api/generation, /api/token-refresh, /api/health-check
 	if len(parts) >= 1 {
 		switch parts[0] {
-		case "proxy", "watermark-free", "cache", "generation", "token-refresh":
+		case "proxy", "watermark-free", "cache", "generation", "token-refresh", "health-check":
 			return a.handleLocalConfigDefault(parts[0], method, path, body)
 		}
 	}
@@ -659,8 +667,8 @@ func (a *App) handleLocalApi(method string, path string, body string, _ string)
 
 // handleLocalTokens 处理 /api/tokens 的本地 CRUD
 func (a *App) handleLocalTokens(method string, rawPath string, parts []string, body string) (string, error) {
-	if a.db == nil {
-		return jsonFail("SQLite 未初始化，无法使用 Token 管理")
+	if a.store == nil {
+		return jsonFail("本地存储未初始化，无法使用 Token 管理")
 	}
 
 	// GET /api/tokens?page=1&limit=20
@@ -671,17 +679,25 @@ func (a *App) handleLocalTokens(method string, rawPath string, parts []string, b
 	if method == http.MethodPost && len(parts) == 1 {
 		return a.localTokenCreate(body)
 	}
+	// GET /api/tokens/export?format=json|csv|txt
+	if method == http.MethodGet && len(parts) == 2 && parts[1] == "export" {
+		return a.localTokensExport(rawPath)
+	}
 	// POST /api/tokens/import
 	if method == http.MethodPost && len(parts) == 2 && parts[1] == "import" {
 		return a.localTokensImport(body)
 	}
-	// POST /api/tokens/st2at
-	if method == http.MethodPost && len(parts) == 2 && parts[1] == "st2at" {
-		return jsonMarshal(map[string]interface{}{"success": false, "message": "本地存储模式下不支持 ST→AT 转换，请直接使用 Access Token"})
+	// POST /api/tokens/st2at、/api/tokens/rt2at
+	if method == http.MethodPost && len(parts) == 2 && (parts[1] == "st2at" || parts[1] == "rt2at") {
+		return a.localTokenConvert(parts[1][:2], body)
+	}
+	// POST /api/tokens/dedupe
+	if method == http.MethodPost && len(parts) == 2 && parts[1] == "dedupe" {
+		return a.localTokensDedupe(body)
 	}
-	// POST /api/tokens/rt2at
-	if method == http.MethodPost && len(parts) == 2 && parts[1] == "rt2at" {
-		return jsonMarshal(map[string]interface{}{"success": false, "message": "本地存储模式下不支持 RT→AT 转换，请直接使用 Access Token"})
+	// GET /api/tokens/groups
+	if method == http.MethodGet && len(parts) == 2 && parts[1] == "groups" {
+		return a.localTokenGroups()
 	}
 	// batch
 	if len(parts) >= 2 && parts[1] == "batch" {
@@ -707,8 +723,18 @@ func (a *App) handleLocalTokens(method string, rawPath string, parts []string, b
 				return a.localTokenSetActive(id, true)
 			case "disable":
 				return a.localTokenSetActive(id, false)
+			case "refresh-at":
+				return a.localTokenRefreshAT(id)
 			}
 		}
+		// GET /api/tokens/:id/health
+		if method == http.MethodGet && len(parts) == 3 && parts[2] == "health" {
+			return a.localTokenHealthHistory(id)
+		}
+		// GET /api/tokens/:id/snapshots?days=14
+		if method == http.MethodGet && len(parts) == 3 && parts[2] == "snapshots" {
+			return a.localTokenSnapshots(id, rawPath)
+		}
 		if (method == http.MethodPut || method == http.MethodPost) && len(parts) == 3 && parts[2] == "status" {
 			return a.localTokenSetStatus(id, body)
 		}
@@ -730,109 +756,20 @@ func jsonMarshal(v interface{}) (string, error) {
 	return string(b), nil
 }
 
-func (a *App) localTokensList(rawPath string) (string, error) {
-	u, _ := url.Parse(rawPath)
-	q := u.Query()
-	page, _ := strconv.Atoi(q.Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(q.Get("limit"))
-	if limit < 1 {
-		limit = 20
-	}
-	offset := (page - 1) * limit
-
-	var total int
-	if err := a.db.QueryRow(`SELECT COUNT(*) FROM tokens`).Scan(&total); err != nil {
-		return jsonFail("查询总数失败: " + err.Error())
-	}
-
-	rows, err := a.db.Query(
-		`SELECT id, token, st, rt, client_id, is_active, remark, proxy_url, image_enabled, video_enabled, image_concurrency, video_concurrency, status_json, plan_type, error_message, created_at FROM tokens ORDER BY id LIMIT ? OFFSET ?`,
-		limit, offset)
-	if err != nil {
-		return jsonFail("查询列表失败: " + err.Error())
-	}
-	defer rows.Close()
-
-	list := []map[string]interface{}{}
-	for rows.Next() {
-		var id int64
-		var token, st, rt, clientID, remark, proxyURL, statusJSON, planType, errorMessage, createdAt sql.NullString
-		var isActive, imageEnabled, videoEnabled int
-		var imageConcurrency, videoConcurrency int
-		if err := rows.Scan(&id, &token, &st, &rt, &clientID, &isActive, &remark, &proxyURL, &imageEnabled, &videoEnabled, &imageConcurrency, &videoConcurrency, &statusJSON, &planType, &errorMessage, &createdAt); err != nil {
-			continue
-		}
-		email := ""
-		remainingCount := 0
-		resetInSeconds := 0
-		if statusJSON.String != "" {
-			var status struct {
-				Email string `json:"email"`
-				Rate  struct {
-					AccessResetsInSeconds         int  `json:"access_resets_in_seconds"`
-					EstimatedNumVideosRemaining   int  `json:"estimated_num_videos_remaining"`
-					EstimatedNumPurchasedRemain   int  `json:"estimated_num_purchased_videos_remaining"`
-					CreditRemaining               int  `json:"credit_remaining"`
-					RateLimitReached              bool `json:"rate_limit_reached"`
-				} `json:"rate_limit_and_credit_balance"`
-			}
-			_ = json.Unmarshal([]byte(statusJSON.String), &status)
-			email = status.Email
-			remainingCount = status.Rate.EstimatedNumVideosRemaining
-			resetInSeconds = status.Rate.AccessResetsInSeconds
-		}
-		planTypeStr := ""
-		if planType.Valid {
-			planTypeStr = planType.String
-		}
-		item := map[string]interface{}{
-			"id":                      id,
-			"token":                   token.String,
-			"st":                      st.String,
-			"rt":                      rt.String,
-			"client_id":               clientID.String,
-			"is_active":               isActive == 1,
-			"remark":                  remark.String,
-			"proxy_url":                proxyURL.String,
-			"image_enabled":            imageEnabled == 1,
-			"video_enabled":            videoEnabled == 1,
-			"image_concurrency":       imageConcurrency,
-			"video_concurrency":       videoConcurrency,
-			"email":                   email,
-			"is_expired":              false,
-			"plan_type":               planTypeStr,
-			"error_message":           errorMessage.String,
-			"sora2_remaining_count":   remainingCount,
-			"sora2_total_count":       0,
-			"access_resets_in_seconds": resetInSeconds,
-			"image_count":             0,
-			"video_count":             0,
-			"error_count":             0,
-			"expiry_time":             nil,
-		}
-		list = append(list, item)
-	}
-
-	out := map[string]interface{}{"result": list, "total": total}
-	return jsonMarshal(out)
-}
-
 func (a *App) localTokenCreate(body string) (string, error) {
 	var input struct {
-		Token            string `json:"token"`
-		St               string `json:"st"`
-		Rt               string `json:"rt"`
-		ClientID         string `json:"client_id"`
-		ProxyURL         string `json:"proxy_url"`
-		Remark           string `json:"remark"`
-		ImageEnabled     bool   `json:"image_enabled"`
-		VideoEnabled     bool   `json:"video_enabled"`
-		ImageConcurrency int    `json:"image_concurrency"`
-		VideoConcurrency int    `json:"video_concurrency"`
-		StatusResponse   string `json:"status_response"`
+		Token            string   `json:"token"`
+		St               string   `json:"st"`
+		Rt               string   `json:"rt"`
+		ClientID         string   `json:"client_id"`
+		ProxyURL         string   `json:"proxy_url"`
+		Remark           string   `json:"remark"`
+		ImageEnabled     bool     `json:"image_enabled"`
+		VideoEnabled     bool     `json:"video_enabled"`
+		ImageConcurrency int      `json:"image_concurrency"`
+		VideoConcurrency int      `json:"video_concurrency"`
+		StatusResponse   string   `json:"status_response"`
+		Groups           []string `json:"groups"`
 	}
 	if err := json.Unmarshal([]byte(body), &input); err != nil {
 		return jsonFail("请求体解析失败")
@@ -840,37 +777,65 @@ func (a *App) localTokenCreate(body string) (string, error) {
 	if strings.TrimSpace(input.Token) == "" {
 		return jsonFail("token 不能为空")
 	}
-	now := time.Now()
+	if _, err := parseProxyURL(input.ProxyURL); err != nil {
+		return jsonFail(err.Error())
+	}
+	groups, err := validateTokenGroups(input.Groups)
+	if err != nil {
+		return jsonFail(err.Error())
+	}
 	statusJSON := strings.TrimSpace(input.StatusResponse)
-	res, err := a.db.Exec(
-		`INSERT INTO tokens (token, st, rt, client_id, is_active, remark, proxy_url, image_enabled, video_enabled, image_concurrency, video_concurrency, status_json, created_at, updated_at) VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		strings.TrimSpace(input.Token),
-		nullStr(input.St),
-		nullStr(input.Rt),
-		nullStr(input.ClientID),
-		nullStr(input.Remark),
-		nullStr(input.ProxyURL),
-		boolToInt(input.ImageEnabled),
-		boolToInt(input.VideoEnabled),
-		input.ImageConcurrency,
-		input.VideoConcurrency,
-		nullStr(statusJSON),
-		now, now,
-	)
+	email := a.tokenRecordEmail(tokenRecord{Token: strings.TrimSpace(input.Token), StatusJSON: statusJSON})
+	if dupID, reason, err := a.findDuplicateToken(input.Token, email, 0); err != nil {
+		return jsonFail("检查重复账号失败: " + err.Error())
+	} else if dupID > 0 {
+		return jsonMarshal(map[string]interface{}{"success": false, "message": reason, "duplicate_id": dupID})
+	}
+	encToken, encSt, encRt, err := a.sealTokenFields(strings.TrimSpace(input.Token), input.St, input.Rt)
+	if err != nil {
+		return jsonFail("加密 token 失败: " + err.Error())
+	}
+	now := time.Now()
+	id, err := a.store.InsertToken(tokenRecord{
+		Token:            encToken,
+		TokenHash:        a.tokenHash(input.Token),
+		St:               encSt,
+		Rt:               encRt,
+		ClientID:         input.ClientID,
+		IsActive:         true,
+		Remark:           input.Remark,
+		ProxyURL:         input.ProxyURL,
+		ImageEnabled:     input.ImageEnabled,
+		VideoEnabled:     input.VideoEnabled,
+		ImageConcurrency: input.ImageConcurrency,
+		VideoConcurrency: input.VideoConcurrency,
+		StatusJSON:       statusJSON,
+		Groups:           groups,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	})
 	if err != nil {
 		return jsonFail("写入失败: " + err.Error())
 	}
-	id, _ := res.LastInsertId()
 	// 请求 /account/subscriptions 获取 plan_type（free/plus 等）
-	if subBody, err := a.AccountSubscriptions(strings.TrimSpace(input.Token)); err == nil {
+	if subBody, err := a.accountSubscriptions(id, strings.TrimSpace(input.Token)); err == nil {
 		planType := parsePlanTypeFromSubscriptions(subBody)
 		if planType != "" {
-			_, _ = a.db.Exec(`UPDATE tokens SET plan_type=?, updated_at=? WHERE id=?`, planType, time.Now(), id)
+			a.setTokenPlanType(id, planType)
 		}
 	}
 	return jsonMarshal(map[string]interface{}{"success": true, "id": id})
 }
 
+// setTokenPlanType 更新账号的 plan_type（free/plus 等）
+func (a *App) setTokenPlanType(id int64, planType string) {
+	_ = a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.PlanType = planType
+		t.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
 func nullStr(s string) interface{} {
 	if s == "" {
 		return nil
@@ -887,16 +852,17 @@ func boolToInt(b bool) int {
 
 func (a *App) localTokenUpdate(id int64, body string) (string, error) {
 	var input struct {
-		Token             string `json:"token"`
-		St                string `json:"st"`
-		Rt                string `json:"rt"`
-		ClientID          string `json:"client_id"`
-		ProxyURL          string `json:"proxy_url"`
-		Remark            string `json:"remark"`
-		ImageEnabled      bool   `json:"image_enabled"`
-		VideoEnabled      bool   `json:"video_enabled"`
-		ImageConcurrency  *int   `json:"image_concurrency"`
-		VideoConcurrency  *int   `json:"video_concurrency"`
+		Token            string    `json:"token"`
+		St               string    `json:"st"`
+		Rt               string    `json:"rt"`
+		ClientID         string    `json:"client_id"`
+		ProxyURL         string    `json:"proxy_url"`
+		Remark           string    `json:"remark"`
+		ImageEnabled     bool      `json:"image_enabled"`
+		VideoEnabled     bool      `json:"video_enabled"`
+		ImageConcurrency *int      `json:"image_concurrency"`
+		VideoConcurrency *int      `json:"video_concurrency"`
+		Groups           *[]string `json:"groups"` // 不传时保持原分组
 	}
 	if err := json.Unmarshal([]byte(body), &input); err != nil {
 		return jsonFail("请求体解析失败")
@@ -904,6 +870,9 @@ func (a *App) localTokenUpdate(id int64, body string) (string, error) {
 	if strings.TrimSpace(input.Token) == "" {
 		return jsonFail("token 不能为空")
 	}
+	if _, err := parseProxyURL(input.ProxyURL); err != nil {
+		return jsonFail(err.Error())
+	}
 	imgConc := -1
 	if input.ImageConcurrency != nil {
 		imgConc = *input.ImageConcurrency
@@ -912,31 +881,74 @@ func (a *App) localTokenUpdate(id int64, body string) (string, error) {
 	if input.VideoConcurrency != nil {
 		vidConc = *input.VideoConcurrency
 	}
-	_, err := a.db.Exec(
-		`UPDATE tokens SET token=?, st=?, rt=?, client_id=?, remark=?, proxy_url=?, image_enabled=?, video_enabled=?, image_concurrency=?, video_concurrency=?, updated_at=? WHERE id=?`,
-		strings.TrimSpace(input.Token), nullStr(input.St), nullStr(input.Rt), nullStr(input.ClientID), nullStr(input.Remark), nullStr(input.ProxyURL),
-		boolToInt(input.ImageEnabled), boolToInt(input.VideoEnabled), imgConc, vidConc, time.Now(), id,
-	)
+	var groups []string
+	if input.Groups != nil {
+		var err error
+		if groups, err = validateTokenGroups(*input.Groups); err != nil {
+			return jsonFail(err.Error())
+		}
+	}
+	// 换成另一个账号的 token 时同样不允许与已有账号重复；邮箱与新增时一样按 tokenRecordEmail 取，
+	// 原 status_json 属于旧 token，换了 token 时不再参考
+	rec, err := a.store.GetToken(id)
+	if err != nil {
+		return jsonFail("Token 不存在")
+	}
+	candidate := tokenRecord{Token: strings.TrimSpace(input.Token)}
+	if a.openSecret(rec.Token) == candidate.Token {
+		candidate.StatusJSON = rec.StatusJSON
+	}
+	if dupID, reason, err := a.findDuplicateToken(input.Token, a.tokenRecordEmail(candidate), id); err != nil {
+		return jsonFail("检查重复账号失败: " + err.Error())
+	} else if dupID > 0 {
+		return jsonMarshal(map[string]interface{}{"success": false, "message": reason, "duplicate_id": dupID})
+	}
+	encToken, encSt, encRt, err := a.sealTokenFields(strings.TrimSpace(input.Token), input.St, input.Rt)
+	if err != nil {
+		return jsonFail("加密 token 失败: " + err.Error())
+	}
+	err = a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.Token, t.TokenHash, t.St, t.Rt = encToken, a.tokenHash(input.Token), encSt, encRt
+		t.ClientID, t.Remark, t.ProxyURL = input.ClientID, input.Remark, input.ProxyURL
+		t.ImageEnabled, t.VideoEnabled = input.ImageEnabled, input.VideoEnabled
+		t.ImageConcurrency, t.VideoConcurrency = imgConc, vidConc
+		if input.Groups != nil {
+			t.Groups = groups
+		}
+		t.UpdatedAt = time.Now()
+		return nil
+	})
 	if err != nil {
 		return jsonFail("更新失败: " + err.Error())
 	}
+	// 并发上限可能被调高，唤醒排队中的任务
+	if a.scheduler != nil {
+		a.scheduler.notify()
+	}
 	return jsonMarshal(map[string]interface{}{"success": true})
 }
 
 func (a *App) localTokenDelete(id int64) (string, error) {
-	_, err := a.db.Exec(`DELETE FROM tokens WHERE id=?`, id)
+	results, err := a.batchDeleteTokens([]int64{id})
 	if err != nil {
 		return jsonFail("删除失败: " + err.Error())
 	}
+	if r := results[0]; !r.Success {
+		return jsonFail(r.Message)
+	}
 	return jsonMarshal(map[string]interface{}{"success": true})
 }
 
 func (a *App) localTokenTest(id int64) (string, error) {
-	var bearer string
-	if err := a.db.QueryRow(`SELECT token FROM tokens WHERE id=?`, id).Scan(&bearer); err != nil {
+	rec, err := a.store.GetToken(id)
+	if err != nil {
 		return jsonFail("Token 不存在")
 	}
-	respBody, err := a.CheckAccountAndSave(bearer)
+	bearer := a.openSecret(rec.Token)
+	if bearer == "" {
+		return jsonFail("Token 解密失败")
+	}
+	email, err := a.probeToken(id, bearer)
 	if err != nil {
 		return jsonMarshal(map[string]interface{}{
 			"success": false,
@@ -944,149 +956,236 @@ func (a *App) localTokenTest(id int64) (string, error) {
 			"status":  "failed",
 		})
 	}
+	return jsonMarshal(map[string]interface{}{
+		"success": true,
+		"status":  "success",
+		"email":   email,
+	})
+}
+
+// refreshTokenStatus 请求 /account/status 更新账号的 status_json 与冷却时间，再请求 /account/subscriptions 更新 plan_type；
+// 返回状态中的邮箱。失败计入账号的连续失败次数
+func (a *App) refreshTokenStatus(id int64, bearer string) (string, error) {
+	respBody, err := a.fetchAccountStatus(id, bearer)
+	if err != nil {
+		a.recordTokenFailure(id, failureStageStatus, "", "状态检查失败: "+err.Error())
+		return "", err
+	}
 	// 更新该记录的 status_json
 	var status map[string]interface{}
 	_ = json.Unmarshal([]byte(respBody), &status)
 	statusJSON, _ := json.Marshal(status)
-	_, _ = a.db.Exec(`UPDATE tokens SET status_json=?, error_message=?, updated_at=? WHERE id=?`, string(statusJSON), "", time.Now(), id)
+	_ = a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.StatusJSON, t.ErrorMessage, t.UpdatedAt = string(statusJSON), "", time.Now()
+		return nil
+	})
+	a.recordTokenSuccess(id)
+	a.applyTokenCooldownFromStatus(id, string(statusJSON))
+	a.recordTokenSnapshotFromStatus(id, snapshotSourceStatus, string(statusJSON))
 	email, _ := status["email"].(string)
 	// 请求 /account/subscriptions 更新 plan_type（free/plus 等）
-	if subBody, err := a.AccountSubscriptions(bearer); err == nil {
+	if subBody, err := a.accountSubscriptions(id, bearer); err == nil {
 		planType := parsePlanTypeFromSubscriptions(subBody)
 		if planType != "" {
-			_, _ = a.db.Exec(`UPDATE tokens SET plan_type=?, updated_at=? WHERE id=?`, planType, time.Now(), id)
+			a.setTokenPlanType(id, planType)
 		}
 	}
-	return jsonMarshal(map[string]interface{}{
-		"success": true,
-		"status":  "success",
-		"email":   email,
-	})
+	return email, nil
 }
 
 func (a *App) localTokenSetActive(id int64, active bool) (string, error) {
-	v := 0
-	if active {
-		v = 1
-	}
-	_, err := a.db.Exec(`UPDATE tokens SET is_active=?, updated_at=? WHERE id=?`, v, time.Now(), id)
+	err := a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.IsActive, t.UpdatedAt = active, time.Now()
+		return nil
+	})
 	if err != nil {
 		return jsonFail("更新失败: " + err.Error())
 	}
+	if active {
+		// 手动启用视为重新开始计数，避免刚启用又因旧的失败次数被禁用
+		a.recordTokenSuccess(id)
+	}
+	if active && a.scheduler != nil {
+		a.scheduler.notify()
+	}
 	return jsonMarshal(map[string]interface{}{"success": true})
 }
 
-// GetRandomVideoToken 从数据库随机返回一个可用于视频生成的 token：状态正常、已启用视频、有剩余次数
-// requirePro=true 时仅允许 Pro/Plus 账号
+// GetRandomVideoToken 按当前账号选择策略（默认随机）返回一个可用于视频生成的 token：状态正常、已启用视频、有剩余次数，且未达到 video_concurrency 并发上限
+// model 为模型目录中的名称，只分配账号类型满足该模型要求的账号（为空时不限账号类型）；group 非空时只使用该分组的账号。
+// 所有账号并发占满时直接返回错误（排队由后台任务引擎负责）
 // 返回 JSON：{"bearer_token": "xxx", "token_id": 123} 或 {"error": "..."}
-func (a *App) GetRandomVideoToken(requirePro bool) (string, error) {
-	rows, err := a.db.Query(
-		`SELECT id, token, status_json, plan_type FROM tokens WHERE is_active=1 AND video_enabled=1`)
-	if err != nil {
-		return jsonMarshal(map[string]interface{}{"error": "查询 Token 失败: " + err.Error()})
-	}
-	defer rows.Close()
-
-	type tokenRow struct {
-		id         int64
-		token      string
-		statusJSON sql.NullString
-		planType   sql.NullString
-	}
-	var candidates []tokenRow
-	for rows.Next() {
-		var id int64
-		var token string
-		var statusJSON sql.NullString
-		var planType sql.NullString
-		if err := rows.Scan(&id, &token, &statusJSON, &planType); err != nil {
+func (a *App) GetRandomVideoToken(model string, group string) (string, error) {
+	requiredPlan := ""
+	if strings.TrimSpace(model) != "" {
+		m, err := lookupVideoModel(model)
+		if err != nil {
+			return jsonMarshal(map[string]interface{}{"error": err.Error()})
+		}
+		requiredPlan = m.RequiredPlan
+	}
+	id, bearer, err := a.pickVideoToken(requiredPlan, group)
+	if err != nil {
+		return jsonMarshal(map[string]interface{}{"error": err.Error()})
+	}
+	return jsonMarshal(map[string]interface{}{"bearer_token": bearer, "token_id": id})
+}
+
+// pickVideoToken 通过调度器按当前选择策略挑选一个可用于视频生成、且未达到并发上限的账号，返回 token_id 与 bearer
+func (a *App) pickVideoToken(requiredPlan string, group string) (int64, string, error) {
+	if a.scheduler == nil {
+		return 0, "", errStoreUnavailable
+	}
+	c, err := a.scheduler.tryAcquire(requiredPlan, group, false)
+	if err != nil {
+		return 0, "", err
+	}
+	return c.id, c.token, nil
+}
+
+// videoTokenCandidate 可用于视频生成的账号
+type videoTokenCandidate struct {
+	id          int64
+	token       string
+	concurrency int   // video_concurrency，<=0 表示不限
+	remaining   int   // 剩余视频次数，-1 表示未知
+	lastUsedAt  int64 // 最近一次被调度器分配的时间（unix 纳秒），0 表示从未使用
+}
+
+// videoTokenCandidates 查询状态正常、已启用视频、有剩余次数且不在冷却中的账号（不考虑并发）
+// requiredPlan 为模型要求的账号类型（free/plus/pro，空值不限）；group 非空时仅允许属于该分组的账号
+func (a *App) videoTokenCandidates(requiredPlan string, group string) ([]videoTokenCandidate, error) {
+	if a.store == nil {
+		return nil, errStoreUnavailable
+	}
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		return nil, fmt.Errorf("查询 Token 失败: %v", err)
+	}
+
+	group = strings.TrimSpace(group)
+	now := time.Now().Unix()
+	var candidates []videoTokenCandidate
+	for _, t := range tokens {
+		if !t.IsActive || !t.VideoEnabled {
 			continue
 		}
+		if group != "" && !hasTokenGroup(t.Groups, group) {
+			continue
+		}
+		token := a.openSecret(t.Token)
 		if strings.TrimSpace(token) == "" {
 			continue
 		}
-		if requirePro && (!planType.Valid || !isProPlan(planType.String)) {
+		if !planSatisfies(t.PlanType, requiredPlan) {
+			continue
+		}
+		// 冷却中（次数耗尽或被限流、尚未到恢复时间）的账号跳过
+		if t.CooldownUntil > now {
 			continue
 		}
 		remaining := -1
-		if statusJSON.Valid && statusJSON.String != "" {
+		if t.StatusJSON != "" {
 			var status struct {
 				Rate struct {
 					EstimatedNumVideosRemaining int `json:"estimated_num_videos_remaining"`
 				} `json:"rate_limit_and_credit_balance"`
 			}
-			if json.Unmarshal([]byte(statusJSON.String), &status) == nil {
+			if json.Unmarshal([]byte(t.StatusJSON), &status) == nil {
 				remaining = status.Rate.EstimatedNumVideosRemaining
 			}
 		}
 		// 无 status 时也加入候选（由上游判断）；有 status 时要求剩余次数 > 0
 		if remaining < 0 || remaining > 0 {
-			candidates = append(candidates, tokenRow{id: id, token: token, statusJSON: statusJSON, planType: planType})
+			candidates = append(candidates, videoTokenCandidate{id: t.ID, token: token, concurrency: t.VideoConcurrency, remaining: remaining, lastUsedAt: t.LastUsedAt})
 		}
 	}
-	if len(candidates) == 0 {
-		if requirePro {
-			return jsonMarshal(map[string]interface{}{"error": "无可用 Pro Token（需状态正常、已启用视频且有剩余次数）"})
-		}
-		return jsonMarshal(map[string]interface{}{"error": "无可用 Token（需状态正常、已启用视频且有剩余次数）"})
-	}
-	idx := rand.Intn(len(candidates))
-	c := candidates[idx]
-	return jsonMarshal(map[string]interface{}{"bearer_token": c.token, "token_id": c.id})
+	return candidates, nil
 }
 
 // GetBearerByTokenID 根据 token_id 查询该账号的 bearer token，供 pending 轮询时使用「创建任务时的同一账号」
 // 返回 JSON：{"bearer_token": "xxx"} 或 {"error": "..."}
 func (a *App) GetBearerByTokenID(tokenId int64) (string, error) {
-	var bearer string
-	if err := a.db.QueryRow(`SELECT token FROM tokens WHERE id=?`, tokenId).Scan(&bearer); err != nil {
-		return jsonMarshal(map[string]interface{}{"error": "Token 不存在或已删除"})
-	}
-	if strings.TrimSpace(bearer) == "" {
-		return jsonMarshal(map[string]interface{}{"error": "Token 为空"})
+	bearer, err := a.bearerForToken(tokenId)
+	if err != nil {
+		return jsonMarshal(map[string]interface{}{"error": err.Error()})
 	}
 	return jsonMarshal(map[string]interface{}{"bearer_token": bearer})
 }
 
+// bearerForToken 读取指定 token_id 的 bearer token
+func (a *App) bearerForToken(tokenId int64) (string, error) {
+	if a.store == nil {
+		return "", errStoreUnavailable
+	}
+	rec, err := a.store.GetToken(tokenId)
+	if err != nil {
+		return "", fmt.Errorf("Token 不存在或已删除")
+	}
+	bearer := strings.TrimSpace(a.openSecret(rec.Token))
+	if bearer == "" {
+		return "", fmt.Errorf("Token 为空")
+	}
+	return bearer, nil
+}
+
 // GetTokenEmailByID 根据 token_id 读取该账号邮箱（来自 status_json）
 // 返回 JSON：{"email": "xxx"} 或 {"error": "..."}
 func (a *App) GetTokenEmailByID(tokenId int64) (string, error) {
-	var statusJSON sql.NullString
-	if err := a.db.QueryRow(`SELECT status_json FROM tokens WHERE id=?`, tokenId).Scan(&statusJSON); err != nil {
-		return jsonMarshal(map[string]interface{}{"error": "Token 不存在或已删除"})
+	email, err := a.tokenEmail(tokenId)
+	if err != nil {
+		return jsonMarshal(map[string]interface{}{"error": err.Error()})
+	}
+	return jsonMarshal(map[string]interface{}{"email": email})
+}
+
+// tokenEmail 从 tokens.status_json 中读取账号邮箱
+func (a *App) tokenEmail(tokenId int64) (string, error) {
+	if a.store == nil {
+		return "", errStoreUnavailable
+	}
+	rec, err := a.store.GetToken(tokenId)
+	if err != nil {
+		return "", fmt.Errorf("Token 不存在或已删除")
 	}
-	if !statusJSON.Valid || statusJSON.String == "" {
-		return jsonMarshal(map[string]interface{}{"error": "未找到邮箱"})
+	if rec.StatusJSON == "" {
+		return "", fmt.Errorf("未找到邮箱")
 	}
 	var status struct {
 		Email string `json:"email"`
 	}
-	if err := json.Unmarshal([]byte(statusJSON.String), &status); err != nil || status.Email == "" {
-		return jsonMarshal(map[string]interface{}{"error": "未找到邮箱"})
+	if err := json.Unmarshal([]byte(rec.StatusJSON), &status); err != nil || status.Email == "" {
+		return "", fmt.Errorf("未找到邮箱")
 	}
-	return jsonMarshal(map[string]interface{}{"email": status.Email})
+	return status.Email, nil
 }
 
 // SetTokenError 根据 token_id 保存错误信息（用于前端标记账号失效等问题）
 // 如果错误信息包含"账号失效"，同时禁用该 token
 // 返回 JSON：{"success": true} 或 {"error": "..."}
 func (a *App) SetTokenError(tokenId int64, message string) (string, error) {
-	if a.db == nil {
-		return jsonMarshal(map[string]interface{}{"error": "SQLite 未初始化"})
+	if a.store == nil {
+		return jsonMarshal(map[string]interface{}{"error": errStoreUnavailable.Error()})
 	}
-	msg := strings.TrimSpace(message)
+	msg := redactSecrets(strings.TrimSpace(message))
 	if tokenId <= 0 {
 		return jsonMarshal(map[string]interface{}{"error": "无效的 token_id"})
 	}
-	if _, err := a.db.Exec(`UPDATE tokens SET error_message=?, updated_at=? WHERE id=?`, msg, time.Now(), tokenId); err != nil {
+	if err := a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+		t.ErrorMessage, t.UpdatedAt = msg, time.Now()
+		return nil
+	}); err != nil {
 		return jsonMarshal(map[string]interface{}{"error": "保存错误信息失败: " + err.Error()})
 	}
 	// 如果错误信息包含"账号失效"，同时禁用该 token
 	if strings.Contains(msg, "账号失效") {
-		if _, err := a.db.Exec(`UPDATE tokens SET is_active=0, updated_at=? WHERE id=?`, time.Now(), tokenId); err != nil {
-			runtime.LogError(a.ctx, fmt.Sprintf("禁用 token %d 失败: %v", tokenId, err))
+		if err := a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+			t.IsActive, t.UpdatedAt = false, time.Now()
+			return nil
+		}); err != nil {
+			a.logError(fmt.Sprintf("禁用 token %d 失败: %v", tokenId, err))
 		} else {
-			runtime.LogInfo(a.ctx, fmt.Sprintf("已禁用失效的 token %d", tokenId))
+			a.logInfo(fmt.Sprintf("已禁用失效的 token %d", tokenId))
 		}
 	}
 	return jsonMarshal(map[string]interface{}{"success": true})
@@ -1094,29 +1193,24 @@ func (a *App) SetTokenError(tokenId int64, message string) (string, error) {
 
 // CreateVideo 调用与 testsh/create.sh 相同的接口：POST {apiBaseURL}/videos，请求体为 bearer_token、prompt、orientation、size、n_frames、model
 // 用于「立即生成」视频任务，并在控制台打印 CREATE 请求/响应
-// orientation: portrait / landscape；nFrames: 300(10s) / 450(15s) / 750(25s)
-func (a *App) CreateVideo(apiBaseURL string, bearerToken string, prompt string, orientation string, nFrames string, model string, size string) (string, error) {
+// model 为模型目录中的名称（如 sora2-landscape-10s），由目录换算成上游参数，见 model_catalog.go
+func (a *App) CreateVideo(apiBaseURL string, bearerToken string, prompt string, model string) (string, error) {
+	m, err := lookupVideoModel(model)
+	if err != nil {
+		return "", err
+	}
+	return a.createVideo(apiBaseURL, a.tokenIDByBearer(bearerToken), bearerToken, prompt, m)
+}
+
+// createVideo 按目录中的模型参数调用 POST /videos；tokenID 为 bearer 所属账号，用于选择代理与记录日志
+func (a *App) createVideo(apiBaseURL string, tokenID int64, bearerToken string, prompt string, m videoModel) (string, error) {
 	apiBaseURL = strings.TrimRight(apiBaseURL, "/")
 	videoURL := apiBaseURL + "/videos"
-	nFramesInt := 300
-	if n := strings.TrimSpace(nFrames); n != "" {
-		if v, err := strconv.Atoi(n); err == nil && v > 0 {
-			nFramesInt = v
-		}
-	}
-	if orientation == "" {
-		orientation = "portrait"
-	}
-	if strings.TrimSpace(model) == "" {
-		model = "sy_8"
-	}
-	if strings.TrimSpace(size) == "" {
-		size = "small"
-	}
+	orientation, nFramesInt, model, size := m.Orientation, m.NFrames, m.UpstreamModel, m.Size
 	body := map[string]interface{}{
 		"bearer_token": bearerToken,
 		"prompt":       prompt,
-		"orientation": orientation,
+		"orientation":  orientation,
 		"size":         size,
 		"n_frames":     nFramesInt,
 		"model":        model,
@@ -1126,38 +1220,33 @@ func (a *App) CreateVideo(apiBaseURL string, bearerToken string, prompt string,
 		return "", err
 	}
 
-	runtime.LogInfo(a.ctx, "========== CREATE 请求 (POST /videos) ==========")
-	runtime.LogInfo(a.ctx, "  "+videoURL)
-	runtime.LogInfo(a.ctx, "  prompt="+prompt+" orientation="+orientation+" n_frames="+strconv.Itoa(nFramesInt)+" model="+model+" size="+size)
-	runtime.LogInfo(a.ctx, "----------------------------------------")
+	a.logInfo("========== CREATE 请求 (POST /videos) ==========")
+	a.logInfo("  " + videoURL)
+	a.logInfo("  prompt=" + prompt + " orientation=" + orientation + " n_frames=" + strconv.Itoa(nFramesInt) + " model=" + model + " size=" + size)
+	a.logInfo("----------------------------------------")
 
 	req, err := http.NewRequest(http.MethodPost, videoURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		runtime.LogError(a.ctx, "CREATE 请求失败: "+err.Error())
-		return "", err
-	}
-	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
+	client := a.httpClientForToken(tokenID, 60*time.Second)
+	statusCode, respBody, err := a.doLoggedRequest(client, req, "CreateVideo", tokenID, bodyBytes)
 	if err != nil {
+		a.logError("CREATE 请求失败: " + err.Error())
 		return "", err
 	}
 	respStr := string(respBody)
-	runtime.LogInfo(a.ctx, "========== CREATE 响应 (HTTP "+strconv.Itoa(resp.StatusCode)+") ==========")
+	a.logInfo("========== CREATE 响应 (HTTP " + strconv.Itoa(statusCode) + ") ==========")
 	if len(respStr) > 2000 {
-		runtime.LogInfo(a.ctx, respStr[:2000]+"...(truncated)")
+		a.logInfo(respStr[:2000] + "...(truncated)")
 	} else {
-		runtime.LogInfo(a.ctx, respStr)
+		a.logInfo(respStr)
 	}
-	runtime.LogInfo(a.ctx, "========================================")
+	a.logInfo("========================================")
 
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, respStr)
+	if statusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", statusCode, respStr)
 	}
 	return respStr, nil
 }
@@ -1165,6 +1254,11 @@ func (a *App) CreateVideo(apiBaseURL string, bearerToken string, prompt string,
 // PollPending 调用与 testsh/test_pending.sh 相同的接口：POST {apiBaseURL}/pending，请求体为 bearer_token
 // 返回 pending 列表 JSON；返回 [] 表示任务已完成。用于 CreateVideo 成功后每 10s 轮询一次
 func (a *App) PollPending(apiBaseURL string, bearerToken string) (string, error) {
+	return a.pollPending(apiBaseURL, a.tokenIDByBearer(bearerToken), bearerToken)
+}
+
+// pollPending 同 PollPending，由已知账号 id 的后台任务引擎调用
+func (a *App) pollPending(apiBaseURL string, tokenID int64, bearerToken string) (string, error) {
 	apiBaseURL = strings.TrimRight(apiBaseURL, "/")
 	pendingURL := apiBaseURL + "/pending"
 	body := map[string]string{"bearer_token": bearerToken}
@@ -1173,37 +1267,32 @@ func (a *App) PollPending(apiBaseURL string, bearerToken string) (string, error)
 		return "", err
 	}
 
-	runtime.LogInfo(a.ctx, "========== PENDING 请求 (POST /pending) ==========")
-	runtime.LogInfo(a.ctx, "  "+pendingURL)
-	runtime.LogInfo(a.ctx, "----------------------------------------")
+	a.logInfo("========== PENDING 请求 (POST /pending) ==========")
+	a.logInfo("  " + pendingURL)
+	a.logInfo("----------------------------------------")
 
 	req, err := http.NewRequest(http.MethodPost, pendingURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		runtime.LogError(a.ctx, "PENDING 请求失败: "+err.Error())
-		return "", err
-	}
-	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
+	client := a.httpClientForToken(tokenID, 30*time.Second)
+	statusCode, respBody, err := a.doLoggedRequest(client, req, "PollPending", tokenID, bodyBytes)
 	if err != nil {
+		a.logError("PENDING 请求失败: " + err.Error())
 		return "", err
 	}
 	respStr := string(respBody)
-	runtime.LogInfo(a.ctx, "========== PENDING 响应 (HTTP "+strconv.Itoa(resp.StatusCode)+") ==========")
+	a.logInfo("========== PENDING 响应 (HTTP " + strconv.Itoa(statusCode) + ") ==========")
 	if len(respStr) > 2000 {
-		runtime.LogInfo(a.ctx, respStr[:2000]+"...(truncated)")
+		a.logInfo(respStr[:2000] + "...(truncated)")
 	} else {
-		runtime.LogInfo(a.ctx, respStr)
+		a.logInfo(respStr)
 	}
-	runtime.LogInfo(a.ctx, "========================================")
+	a.logInfo("========================================")
 
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, respStr)
+	if statusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", statusCode, respStr)
 	}
 	return respStr, nil
 }
@@ -1211,6 +1300,11 @@ func (a *App) PollPending(apiBaseURL string, bearerToken string) (string, error)
 // FetchDrafts 调用与 testsh/test_drafts.sh 相同的接口：POST {apiBaseURL}/drafts，请求体为 bearer_token、limit、offset
 // 返回 drafts 响应 JSON（含 items），当 pending 返回 [] 后拉取草稿并下载
 func (a *App) FetchDrafts(apiBaseURL string, bearerToken string) (string, error) {
+	return a.fetchDrafts(apiBaseURL, a.tokenIDByBearer(bearerToken), bearerToken)
+}
+
+// fetchDrafts 同 FetchDrafts，由已知账号 id 的后台任务引擎调用
+func (a *App) fetchDrafts(apiBaseURL string, tokenID int64, bearerToken string) (string, error) {
 	apiBaseURL = strings.TrimRight(apiBaseURL, "/")
 	draftsURL := apiBaseURL + "/drafts"
 	body := map[string]interface{}{
@@ -1223,37 +1317,32 @@ func (a *App) FetchDrafts(apiBaseURL string, bearerToken string) (string, error)
 		return "", err
 	}
 
-	runtime.LogInfo(a.ctx, "========== DRAFTS 请求 (POST /drafts) ==========")
-	runtime.LogInfo(a.ctx, "  "+draftsURL)
-	runtime.LogInfo(a.ctx, "----------------------------------------")
+	a.logInfo("========== DRAFTS 请求 (POST /drafts) ==========")
+	a.logInfo("  " + draftsURL)
+	a.logInfo("----------------------------------------")
 
 	req, err := http.NewRequest(http.MethodPost, draftsURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		runtime.LogError(a.ctx, "DRAFTS 请求失败: "+err.Error())
-		return "", err
-	}
-	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
+	client := a.httpClientForToken(tokenID, 30*time.Second)
+	statusCode, respBody, err := a.doLoggedRequest(client, req, "FetchDrafts", tokenID, bodyBytes)
 	if err != nil {
+		a.logError("DRAFTS 请求失败: " + err.Error())
 		return "", err
 	}
 	respStr := string(respBody)
-	runtime.LogInfo(a.ctx, "========== DRAFTS 响应 (HTTP "+strconv.Itoa(resp.StatusCode)+") ==========")
+	a.logInfo("========== DRAFTS 响应 (HTTP " + strconv.Itoa(statusCode) + ") ==========")
 	if len(respStr) > 2000 {
-		runtime.LogInfo(a.ctx, respStr[:2000]+"...(truncated)")
+		a.logInfo(respStr[:2000] + "...(truncated)")
 	} else {
-		runtime.LogInfo(a.ctx, respStr)
+		a.logInfo(respStr)
 	}
-	runtime.LogInfo(a.ctx, "========================================")
+	a.logInfo("========================================")
 
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, respStr)
+	if statusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", statusCode, respStr)
 	}
 	return respStr, nil
 }
@@ -1261,51 +1350,67 @@ func (a *App) FetchDrafts(apiBaseURL string, bearerToken string) (string, error)
 // SaveVideoTaskResult 保存视频创建成功后的结果：写入 video_task_results，并用 estimated_num_videos_remaining、access_resets_in_seconds 更新对应 token 的 status_json
 // resultJson 格式示例：{"id":"task_01kg...","rate_limit_and_credit_balance":{"estimated_num_videos_remaining":29,"access_resets_in_seconds":85511,...},...}
 func (a *App) SaveVideoTaskResult(tokenId int64, resultJson string, prompt string) (string, error) {
+	taskID, err := a.saveVideoTaskResult(tokenId, resultJson, prompt)
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "task_id": taskID})
+}
+
+// saveVideoTaskResult 写入 video_task_results 并合并 token 的 rate_limit 信息，返回远程 task_id
+func (a *App) saveVideoTaskResult(tokenId int64, resultJson string, prompt string) (string, error) {
+	if a.store == nil {
+		return "", errStoreUnavailable
+	}
 	var result struct {
-		ID                      string `json:"id"`
-		RateLimitAndCreditBalance *struct {
-			EstimatedNumVideosRemaining   int `json:"estimated_num_videos_remaining"`
-			AccessResetsInSeconds        int `json:"access_resets_in_seconds"`
-			CreditRemaining             int `json:"credit_remaining"`
-			RateLimitReached            bool `json:"rate_limit_reached"`
-		} `json:"rate_limit_and_credit_balance"`
+		ID                        string            `json:"id"`
+		RateLimitAndCreditBalance *rateLimitBalance `json:"rate_limit_and_credit_balance"`
 	}
 	if err := json.Unmarshal([]byte(resultJson), &result); err != nil {
-		return jsonFail("resultJson 解析失败: " + err.Error())
+		return "", fmt.Errorf("resultJson 解析失败: %v", err)
 	}
 	taskID := strings.TrimSpace(result.ID)
 	if taskID == "" {
-		return jsonFail("resultJson 缺少 id (task_id)")
+		return "", fmt.Errorf("resultJson 缺少 id (task_id)")
 	}
 	now := time.Now()
-	_, err := a.db.Exec(
-		`INSERT OR REPLACE INTO video_task_results (task_id, token_id, result_json, progress_pct, created_at, prompt) VALUES (?, ?, ?, 0, ?, ?)`,
-		taskID, tokenId, resultJson, now, strings.TrimSpace(prompt))
+	err := a.store.PutTask(taskRecord{
+		TaskID:     taskID,
+		TokenID:    tokenId,
+		ResultJSON: resultJson,
+		Prompt:     strings.TrimSpace(prompt),
+		Status:     videoTaskStatusRunning,
+		CreatedAt:  now,
+	})
 	if err != nil {
-		return jsonFail("写入 video_task_results 失败: " + err.Error())
+		return "", fmt.Errorf("写入 video_task_results 失败: %v", err)
 	}
 	if result.RateLimitAndCreditBalance != nil {
 		// 更新该 token 的 status_json：合并 rate_limit 信息（剩余次数、恢复时间）
 		rate := result.RateLimitAndCreditBalance
-		var status map[string]interface{}
-		var statusJSON sql.NullString
-		if err := a.db.QueryRow(`SELECT status_json FROM tokens WHERE id=?`, tokenId).Scan(&statusJSON); err == nil && statusJSON.Valid && statusJSON.String != "" {
-			_ = json.Unmarshal([]byte(statusJSON.String), &status)
-		}
-		if status == nil {
-			status = make(map[string]interface{})
-		}
 		rateMap := map[string]interface{}{
-			"estimated_num_videos_remaining":    rate.EstimatedNumVideosRemaining,
-			"access_resets_in_seconds":           rate.AccessResetsInSeconds,
-			"credit_remaining":                   rate.CreditRemaining,
-			"rate_limit_reached":                rate.RateLimitReached,
-		}
-		status["rate_limit_and_credit_balance"] = rateMap
-		newJSON, _ := json.Marshal(status)
-		_, _ = a.db.Exec(`UPDATE tokens SET status_json=?, updated_at=? WHERE id=?`, string(newJSON), now, tokenId)
+			"estimated_num_videos_remaining": rate.EstimatedNumVideosRemaining,
+			"access_resets_in_seconds":       rate.AccessResetsInSeconds,
+			"credit_remaining":               rate.CreditRemaining,
+			"rate_limit_reached":             rate.RateLimitReached,
+		}
+		_ = a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+			var status map[string]interface{}
+			if t.StatusJSON != "" {
+				_ = json.Unmarshal([]byte(t.StatusJSON), &status)
+			}
+			if status == nil {
+				status = make(map[string]interface{})
+			}
+			status["rate_limit_and_credit_balance"] = rateMap
+			newJSON, _ := json.Marshal(status)
+			t.StatusJSON, t.UpdatedAt = string(newJSON), now
+			return nil
+		})
+		a.applyTokenCooldown(tokenId, *rate)
+		a.recordTokenSnapshot(tokenId, snapshotSourceTask, *rate)
 	}
-	return jsonMarshal(map[string]interface{}{"success": true, "task_id": taskID})
+	return taskID, nil
 }
 
 // UpdateVideoTaskProgress 更新 video_task_results 中该 task_id 的进度百分比（pending 轮询得到 progress_pct 时调用）
@@ -1314,8 +1419,14 @@ func (a *App) UpdateVideoTaskProgress(taskId string, progressPct float64) (strin
 	if taskId == "" {
 		return jsonFail("task_id 不能为空")
 	}
-	_, err := a.db.Exec(`UPDATE video_task_results SET progress_pct=? WHERE task_id=?`, progressPct, taskId)
-	if err != nil {
+	if a.store == nil {
+		return jsonFail(errStoreUnavailable.Error())
+	}
+	err := a.store.UpdateTask(taskId, func(t *taskRecord) error {
+		t.ProgressPct = progressPct
+		return nil
+	})
+	if err != nil && err != errNotFound {
 		return jsonFail("更新 progress_pct 失败: " + err.Error())
 	}
 	return jsonMarshal(map[string]interface{}{"success": true})
@@ -1328,39 +1439,39 @@ func (a *App) GetTokenIDByRemoteTaskID(remoteTaskId string) (string, error) {
 	if remoteTaskId == "" {
 		return jsonMarshal(map[string]interface{}{"error": "remote_task_id 不能为空"})
 	}
-	var tokenID int64
-	if err := a.db.QueryRow(`SELECT token_id FROM video_task_results WHERE task_id=?`, remoteTaskId).Scan(&tokenID); err != nil {
+	if a.store == nil {
+		return jsonMarshal(map[string]interface{}{"error": errStoreUnavailable.Error()})
+	}
+	task, err := a.store.GetTask(remoteTaskId)
+	if err != nil || task.TokenID == 0 {
 		return jsonMarshal(map[string]interface{}{"error": "未找到该任务记录"})
 	}
-	return jsonMarshal(map[string]interface{}{"token_id": tokenID})
+	return jsonMarshal(map[string]interface{}{"token_id": task.TokenID})
 }
 
 // GetIncompleteVideoTasks 从 SQLite 查询未完成的视频任务（progress_pct < 100），供页面加载时恢复 pending 轮询
 // 返回 JSON：{"tasks": [{"task_id": "xxx", "token_id": 10}, ...]}，无数据时 tasks 为空数组；出错时 {"error": "..."}
 func (a *App) GetIncompleteVideoTasks() (string, error) {
-	if a.db == nil {
+	if a.store == nil {
 		return jsonMarshal(map[string]interface{}{"tasks": []interface{}{}})
 	}
-	rows, err := a.db.Query(`SELECT task_id, token_id FROM video_task_results WHERE progress_pct < 100 OR progress_pct IS NULL ORDER BY created_at ASC`)
+	tasks, err := a.store.ListTasks()
 	if err != nil {
 		return jsonFail("查询未完成视频任务失败: " + err.Error())
 	}
-	defer rows.Close()
 	var list []map[string]interface{}
-	for rows.Next() {
-		var taskID string
-		var tokenID int64
-		if err := rows.Scan(&taskID, &tokenID); err != nil {
+	for _, t := range tasks {
+		if !t.needsPolling() || t.TokenID == 0 {
 			continue
 		}
-		list = append(list, map[string]interface{}{"task_id": taskID, "token_id": tokenID})
+		list = append(list, map[string]interface{}{"task_id": t.TaskID, "token_id": t.TokenID})
 	}
 	out := map[string]interface{}{"tasks": list}
 	needPending := len(list) > 0
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[GetIncompleteVideoTasks] 读取结果: %d 条, 是否需要继续 pending: %v", len(list), needPending))
+	a.logInfo(fmt.Sprintf("[GetIncompleteVideoTasks] 读取结果: %d 条, 是否需要继续 pending: %v", len(list), needPending))
 	outStr, err := jsonMarshal(out)
 	if err == nil {
-		runtime.LogInfo(a.ctx, "[GetIncompleteVideoTasks] 明细: "+outStr)
+		a.logInfo("[GetIncompleteVideoTasks] 明细: " + outStr)
 	}
 	return outStr, err
 }
@@ -1369,9 +1480,9 @@ func (a *App) GetIncompleteVideoTasks() (string, error) {
 type draftsItem struct {
 	ID              string `json:"id"`
 	GenerationID    string `json:"generation_id"`
-	TaskID         string `json:"task_id"`
+	TaskID          string `json:"task_id"`
 	DownloadableURL string `json:"downloadable_url"`
-	Prompt         string `json:"prompt"`
+	Prompt          string `json:"prompt"`
 }
 
 // SaveDraftsAndDownload 解析 drafts 响应 JSON，仅下载 completedTaskId 对应的那条，写入 video_downloads 表
@@ -1381,90 +1492,81 @@ func (a *App) SaveDraftsAndDownload(draftsJson string, completedTaskId string) (
 	if completedTaskId == "" {
 		return jsonMarshal(map[string]interface{}{"success": true, "message": "未指定 completedTaskId，跳过下载", "downloaded": 0})
 	}
-	var drafts struct {
-		Items  []draftsItem `json:"items"`
-		Cursor string       `json:"cursor"`
-	}
-	if err := json.Unmarshal([]byte(draftsJson), &drafts); err != nil {
+	target, err := findDraftItem(draftsJson, completedTaskId)
+	if err != nil {
 		return jsonFail("draftsJson 解析失败: " + err.Error())
 	}
-	// 只保留 task_id 与刚完成任务一致的那条
-	var target *draftsItem
-	for i := range drafts.Items {
-		if strings.TrimSpace(drafts.Items[i].TaskID) == completedTaskId {
-			target = &drafts.Items[i]
-			break
-		}
-	}
 	if target == nil {
-		runtime.LogInfo(a.ctx, fmt.Sprintf("[SaveDraftsAndDownload] drafts 中未找到 task_id=%s，跳过下载", completedTaskId))
+		a.logInfo(fmt.Sprintf("[SaveDraftsAndDownload] drafts 中未找到 task_id=%s，跳过下载", completedTaskId))
 		return jsonMarshal(map[string]interface{}{"success": true, "message": "drafts 中无对应 task_id", "downloaded": 0})
 	}
 
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	downloadDir := filepath.Join(baseDir, "downloads")
+	downloadDir := a.downloadsDir()
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
 		return jsonFail("创建下载目录失败: " + err.Error())
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[SaveDraftsAndDownload] 下载目录: %s，仅下载 task_id=%s", downloadDir, completedTaskId))
+	a.logInfo(fmt.Sprintf("[SaveDraftsAndDownload] 下载目录: %s，仅下载 task_id=%s", downloadDir, completedTaskId))
 
-	client := &http.Client{Timeout: 120 * time.Second}
 	downloaded := 0
-	item := *target
+	if _, err := a.downloadDraftItem(*target, downloadDir); err == nil {
+		downloaded = 1
+	}
+	a.logInfo(fmt.Sprintf("[SaveDraftsAndDownload] 共下载 %d 个视频到 %s", downloaded, downloadDir))
+	return jsonMarshal(map[string]interface{}{
+		"success":      true,
+		"message":      fmt.Sprintf("已下载 %d 个视频到 %s", downloaded, downloadDir),
+		"downloaded":   downloaded,
+		"download_dir": downloadDir,
+	})
+}
+
+// findDraftItem 在 drafts 响应中查找 task_id 对应的条目，未找到时返回 nil
+func findDraftItem(draftsJson string, taskId string) (*draftsItem, error) {
+	var drafts struct {
+		Items  []draftsItem `json:"items"`
+		Cursor string       `json:"cursor"`
+	}
+	if err := json.Unmarshal([]byte(draftsJson), &drafts); err != nil {
+		return nil, err
+	}
+	// 只保留 task_id 与刚完成任务一致的那条
+	for i := range drafts.Items {
+		if strings.TrimSpace(drafts.Items[i].TaskID) == taskId {
+			return &drafts.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// downloadDraftItem 下载单条 drafts 视频到 downloadDir，并写入 video_downloads 表，返回本地路径
+func (a *App) downloadDraftItem(item draftsItem, downloadDir string) (string, error) {
 	genID := strings.TrimSpace(item.GenerationID)
 	if genID == "" {
 		genID = strings.TrimSpace(item.ID)
 	}
-	if genID != "" {
-		urlStr := strings.TrimSpace(item.DownloadableURL)
-		if urlStr != "" {
-			localPath := filepath.Join(downloadDir, genID+".mp4")
-			req, _ := http.NewRequest("GET", urlStr, nil)
-			req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-			resp, err := client.Do(req)
-			if err == nil && resp.StatusCode == http.StatusOK {
-				f, err := os.Create(localPath)
-				if err == nil {
-					_, err = io.Copy(f, resp.Body)
-					resp.Body.Close()
-					f.Close()
-					if err != nil {
-						os.Remove(localPath)
-					} else if a.db != nil {
-						taskID := strings.TrimSpace(item.TaskID)
-						_, _ = a.db.Exec(
-							`INSERT OR REPLACE INTO video_downloads (generation_id, task_id, downloadable_url, local_path, created_at) VALUES (?, ?, ?, ?, ?)`,
-							genID, nullStr(taskID), urlStr, localPath, time.Now())
-						downloaded = 1
-						runtime.LogInfo(a.ctx, fmt.Sprintf("[SaveDraftsAndDownload] 已下载: %s (task_id=%s)", localPath, taskID))
-					}
-				} else {
-					resp.Body.Close()
-				}
-			} else if resp != nil {
-				resp.Body.Close()
-			}
-		}
+	if genID == "" {
+		return "", fmt.Errorf("drafts 条目缺少 generation_id")
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[SaveDraftsAndDownload] 共下载 %d 个视频到 %s", downloaded, downloadDir))
-	return jsonMarshal(map[string]interface{}{
-		"success":     true,
-		"message":     fmt.Sprintf("已下载 %d 个视频到 %s", downloaded, downloadDir),
-		"downloaded":  downloaded,
-		"download_dir": downloadDir,
-	})
+	urlStr := strings.TrimSpace(item.DownloadableURL)
+	if urlStr == "" {
+		return "", fmt.Errorf("drafts 条目缺少 downloadable_url")
+	}
+	localPath := filepath.Join(downloadDir, genID+".mp4")
+	taskID := strings.TrimSpace(item.TaskID)
+	client := a.httpClientForToken(a.tokenIDForTask(taskID), 120*time.Second)
+	if err := downloadToFile(client, urlStr, localPath); err != nil {
+		return "", err
+	}
+	if a.store != nil {
+		_ = a.store.PutDownload(downloadRecord{GenerationID: genID, TaskID: taskID, DownloadableURL: urlStr, LocalPath: localPath, CreatedAt: time.Now()})
+	}
+	a.logInfo(fmt.Sprintf("[SaveDraftsAndDownload] 已下载: %s (task_id=%s)", localPath, taskID))
+	return localPath, nil
 }
 
 // ClearVideoDownloads 清空 video_downloads 表并删除 downloads 文件夹下所有文件（用于纠错或重置）
 func (a *App) ClearVideoDownloads() (string, error) {
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	downloadDir := filepath.Join(baseDir, "downloads")
+	downloadDir := a.downloadsDir()
 	removed := 0
 	if entries, err := os.ReadDir(downloadDir); err == nil {
 		for _, e := range entries {
@@ -1476,10 +1578,10 @@ func (a *App) ClearVideoDownloads() (string, error) {
 			}
 		}
 	}
-	if a.db != nil {
-		_, _ = a.db.Exec(`DELETE FROM video_downloads`)
+	if a.store != nil {
+		_ = a.store.DeleteDownloads("")
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[ClearVideoDownloads] 已删除 %d 个文件并清空 video_downloads 表", removed))
+	a.logInfo(fmt.Sprintf("[ClearVideoDownloads] 已删除 %d 个文件并清空 video_downloads 表", removed))
 	return jsonMarshal(map[string]interface{}{"success": true, "removed_files": removed})
 }
 
@@ -1490,18 +1592,16 @@ func (a *App) DeleteTaskData(taskId string, deleteFile bool) (string, error) {
 	if taskId == "" {
 		return jsonFail("task_id 不能为空")
 	}
-	if a.db == nil {
+	if a.store == nil {
 		return jsonMarshal(map[string]interface{}{"success": true})
 	}
 	if deleteFile {
-		var localPath sql.NullString
-		_ = a.db.QueryRow(`SELECT local_path FROM video_downloads WHERE task_id=?`, taskId).Scan(&localPath)
-		if localPath.Valid {
-			_ = os.Remove(localPath.String)
+		if d, err := latestDownload(a.store, taskId); err == nil && d.LocalPath != "" {
+			_ = os.Remove(d.LocalPath)
 		}
 	}
-	_, _ = a.db.Exec(`DELETE FROM video_downloads WHERE task_id=?`, taskId)
-	_, _ = a.db.Exec(`DELETE FROM video_task_results WHERE task_id=?`, taskId)
+	_ = a.store.DeleteDownloads(taskId)
+	_ = a.store.DeleteTask(taskId)
 	return jsonMarshal(map[string]interface{}{"success": true})
 }
 
@@ -1511,23 +1611,18 @@ func (a *App) ReDownloadVideo(taskId string) (string, error) {
 	if taskId == "" {
 		return jsonFail("task_id 不能为空")
 	}
-	if a.db == nil {
+	if a.store == nil {
 		return jsonFail("数据库不可用")
 	}
-	var urlStr, localPath, genID string
-	if err := a.db.QueryRow(`SELECT downloadable_url, local_path, generation_id FROM video_downloads WHERE task_id=? ORDER BY created_at DESC LIMIT 1`, taskId).
-		Scan(&urlStr, &localPath, &genID); err != nil {
+	rec, err := latestDownload(a.store, taskId)
+	if err != nil {
 		return jsonFail("未找到该任务的下载记录")
 	}
-	urlStr = strings.TrimSpace(urlStr)
+	urlStr, localPath, genID := strings.TrimSpace(rec.DownloadableURL), rec.LocalPath, rec.GenerationID
 	if urlStr == "" {
 		return jsonFail("downloadable_url 为空")
 	}
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	downloadDir := filepath.Join(baseDir, "downloads")
+	downloadDir := a.downloadsDir()
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
 		return jsonFail("创建下载目录失败: " + err.Error())
 	}
@@ -1537,7 +1632,7 @@ func (a *App) ReDownloadVideo(taskId string) (string, error) {
 		}
 		localPath = filepath.Join(downloadDir, strings.TrimSpace(genID)+".mp4")
 	}
-	client := &http.Client{Timeout: 120 * time.Second}
+	client := a.httpClientForToken(a.tokenIDForTask(taskId), 120*time.Second)
 	req, err := http.NewRequest("GET", urlStr, nil)
 	if err != nil {
 		return jsonFail("创建下载请求失败: " + err.Error())
@@ -1563,7 +1658,9 @@ func (a *App) ReDownloadVideo(taskId string) (string, error) {
 		_ = os.Remove(localPath)
 		return jsonFail("写入文件失败: " + err.Error())
 	}
-	_, _ = a.db.Exec(`UPDATE video_downloads SET local_path=?, created_at=? WHERE task_id=?`, localPath, time.Now(), taskId)
+	_ = a.store.UpdateDownloads(taskId, func(d *downloadRecord) {
+		d.LocalPath, d.CreatedAt = localPath, time.Now()
+	})
 	return jsonMarshal(map[string]interface{}{
 		"success":          true,
 		"local_path":       localPath,
@@ -1571,7 +1668,9 @@ func (a *App) ReDownloadVideo(taskId string) (string, error) {
 	})
 }
 
-func (a *App) simplePostJSON(urlStr string, body map[string]interface{}) (map[string]interface{}, error) {
+// simplePostJSON POST JSON 并解析响应；tokenID 为请求体中 bearer_token 所属账号，走该账号的代理，
+// 为 0 时（如第三方解析接口）走全局代理
+func (a *App) simplePostJSON(urlStr string, tokenID int64, body map[string]interface{}) (map[string]interface{}, error) {
 	b, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -1581,15 +1680,13 @@ func (a *App) simplePostJSON(urlStr string, body map[string]interface{}) (map[st
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	client := a.httpClientForToken(tokenID, 60*time.Second)
+	statusCode, raw, err := a.doLoggedRequest(client, req, "simplePostJSON", tokenID, b)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	raw, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(raw))
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(raw))
 	}
 	var out map[string]interface{}
 	if err := json.Unmarshal(raw, &out); err != nil {
@@ -1631,13 +1728,12 @@ func looksLikeDirectMediaURL(u string) bool {
 	return strings.Contains(lu, "videos.openai.com") || strings.Contains(lu, ".mp4") || strings.Contains(lu, "/raw")
 }
 
-func downloadToFile(urlStr string, localPath string) error {
+func downloadToFile(client *http.Client, urlStr string, localPath string) error {
 	req, err := http.NewRequest("GET", urlStr, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	client := &http.Client{Timeout: 120 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -1671,7 +1767,7 @@ func logSafeJSON(ctx context.Context, prefix string, v interface{}) {
 	runtime.LogInfo(ctx, prefix+s)
 }
 
-func (a *App) fetchPublishedShareURL(apiBaseURL, bearer, taskId, generationID string) (string, string, error) {
+func (a *App) fetchPublishedShareURL(apiBaseURL string, tokenID int64, bearer, taskId, generationID string) (string, string, error) {
 	apiBaseURL = strings.TrimRight(strings.TrimSpace(apiBaseURL), "/")
 	if apiBaseURL == "" {
 		return "", "", fmt.Errorf("apiBaseURL 为空")
@@ -1685,8 +1781,8 @@ func (a *App) fetchPublishedShareURL(apiBaseURL, bearer, taskId, generationID st
 	if strings.TrimSpace(taskId) != "" {
 		body["task_id"] = taskId
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] POST %s/get-published-video-url (fallback)", apiBaseURL))
-	resp, err := a.simplePostJSON(apiBaseURL+"/get-published-video-url", body)
+	a.logInfo(fmt.Sprintf("[PublishNoWM] POST %s/get-published-video-url (fallback)", apiBaseURL))
+	resp, err := a.simplePostJSON(apiBaseURL+"/get-published-video-url", tokenID, body)
 	if err != nil {
 		return "", "", err
 	}
@@ -1706,7 +1802,7 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 	if taskId == "" {
 		return jsonFail("task_id 不能为空")
 	}
-	if a.db == nil {
+	if a.store == nil {
 		return jsonFail("数据库不可用")
 	}
 	apiBaseURL = strings.TrimRight(strings.TrimSpace(apiBaseURL), "/")
@@ -1717,26 +1813,24 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 		return jsonFail("apiBaseURL 不能为空")
 	}
 
-	var tokenID int64
-	var prompt sql.NullString
-	if err := a.db.QueryRow(`SELECT token_id, prompt FROM video_task_results WHERE task_id=?`, taskId).Scan(&tokenID, &prompt); err != nil {
+	task, err := a.store.GetTask(taskId)
+	if err != nil || task.TokenID == 0 {
 		return jsonFail("未找到该任务的 token_id")
 	}
-	var bearer string
-	if err := a.db.QueryRow(`SELECT token FROM tokens WHERE id=?`, tokenID).Scan(&bearer); err != nil {
+	tokenRec, err := a.store.GetToken(task.TokenID)
+	if err != nil {
 		return jsonFail("未找到该任务的 bearer token")
 	}
-	bearer = strings.TrimSpace(bearer)
+	bearer := strings.TrimSpace(a.openSecret(tokenRec.Token))
 	if bearer == "" {
 		return jsonFail("bearer token 为空")
 	}
 
-	var generationID, localPath string
-	if err := a.db.QueryRow(`SELECT generation_id, local_path FROM video_downloads WHERE task_id=? ORDER BY created_at DESC LIMIT 1`, taskId).
-		Scan(&generationID, &localPath); err != nil {
+	dl, err := latestDownload(a.store, taskId)
+	if err != nil {
 		return jsonFail("未找到该任务的 generation_id")
 	}
-	generationID = strings.TrimSpace(generationID)
+	generationID, localPath := strings.TrimSpace(dl.GenerationID), dl.LocalPath
 	if generationID == "" {
 		return jsonFail("generation_id 为空")
 	}
@@ -1745,10 +1839,10 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 	publishBody := map[string]interface{}{
 		"bearer_token":  bearer,
 		"generation_id": generationID,
-		"prompt":        strings.TrimSpace(prompt.String),
+		"prompt":        strings.TrimSpace(task.Prompt),
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] POST %s/publish-video (generation_id=%s)", apiBaseURL, generationID))
-	pubResp, err := a.simplePostJSON(apiBaseURL+"/publish-video", publishBody)
+	a.logInfo(fmt.Sprintf("[PublishNoWM] POST %s/publish-video (generation_id=%s)", apiBaseURL, generationID))
+	pubResp, err := a.simplePostJSON(apiBaseURL+"/publish-video", task.TokenID, publishBody)
 	logSafeJSON(a.ctx, "[PublishNoWM] publish-video 响应: ", pubResp)
 	publishFailed := false
 	if err != nil {
@@ -1763,7 +1857,7 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 		}
 	}
 	if publishFailed {
-		runtime.LogInfo(a.ctx, "[PublishNoWM] publish-video 失败，继续后续流程（不作为最终失败）")
+		a.logInfo("[PublishNoWM] publish-video 失败，继续后续流程（不作为最终失败）")
 	}
 
 	// 2) get-published-video-url
@@ -1774,8 +1868,8 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 	var publishedURL string
 	postID := ""
 	if !publishFailed {
-		runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] POST %s/get-published-video-url (task_id=%s)", apiBaseURL, taskId))
-		publishedResp, err := a.simplePostJSON(apiBaseURL+"/get-published-video-url", getURLBody)
+		a.logInfo(fmt.Sprintf("[PublishNoWM] POST %s/get-published-video-url (task_id=%s)", apiBaseURL, taskId))
+		publishedResp, err := a.simplePostJSON(apiBaseURL+"/get-published-video-url", task.TokenID, getURLBody)
 		if err == nil {
 			logSafeJSON(a.ctx, "[PublishNoWM] get-published-video-url 响应: ", publishedResp)
 			if v, ok := publishedResp["post_id"].(string); ok {
@@ -1794,16 +1888,16 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 		}
 	}
 	if publishedURL == "" {
-	// 发布失败或拿不到发布地址时，改用本地服务 /get-published-video-url 获取 share_url
-	runtime.LogInfo(a.ctx, "[PublishNoWM] 未拿到发布地址，尝试通过本地服务 /get-published-video-url 获取 share_url")
-	shareURL, pid, err := a.fetchPublishedShareURL(apiBaseURL, bearer, taskId, generationID)
-	if err == nil && shareURL != "" {
-		publishedURL = shareURL
-		if postID == "" {
-			postID = pid
+		// 发布失败或拿不到发布地址时，改用本地服务 /get-published-video-url 获取 share_url
+		a.logInfo("[PublishNoWM] 未拿到发布地址，尝试通过本地服务 /get-published-video-url 获取 share_url")
+		shareURL, pid, err := a.fetchPublishedShareURL(apiBaseURL, task.TokenID, bearer, taskId, generationID)
+		if err == nil && shareURL != "" {
+			publishedURL = shareURL
+			if postID == "" {
+				postID = pid
+			}
+			a.logInfo(fmt.Sprintf("[PublishNoWM] 使用 share_url: %s", shareURL))
 		}
-		runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] 使用 share_url: %s", shareURL))
-	}
 	}
 	if publishedURL == "" {
 		return jsonFail("未解析到发布地址")
@@ -1822,8 +1916,8 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 		if parseToken == "" {
 			return jsonFail("无水印解析 token 为空")
 		}
-		runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] POST %s (get-sora-link)", parseURL))
-		parseResp, err := a.simplePostJSON(parseURL, map[string]interface{}{
+		a.logInfo(fmt.Sprintf("[PublishNoWM] POST %s (get-sora-link)", parseURL))
+		parseResp, err := a.simplePostJSON(parseURL, 0, map[string]interface{}{
 			"url":   publishedURL,
 			"token": parseToken,
 		})
@@ -1838,54 +1932,41 @@ func (a *App) PublishAndDownloadNoWatermark(apiBaseURL string, taskId string, pa
 	}
 
 	// 4) 下载覆盖本地文件
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	downloadDir := filepath.Join(baseDir, "downloads")
+	downloadDir := a.downloadsDir()
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
 		return jsonFail("创建下载目录失败: " + err.Error())
 	}
 	if strings.TrimSpace(localPath) == "" {
 		localPath = filepath.Join(downloadDir, generationID+".mp4")
 	}
-	if err := downloadToFile(noWmURL, localPath); err != nil {
+	if err := downloadToFile(a.httpClientForToken(task.TokenID, 120*time.Second), noWmURL, localPath); err != nil {
 		return jsonFail("下载无水印视频失败: " + err.Error())
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[PublishNoWM] 已下载并覆盖: %s", localPath))
+	a.logInfo(fmt.Sprintf("[PublishNoWM] 已下载并覆盖: %s", localPath))
 	if postID == "" {
 		postID = publishedURL
 	}
-	_, _ = a.db.Exec(`UPDATE video_downloads SET local_path=?, downloadable_url=?, post_id=?, created_at=? WHERE task_id=?`, localPath, noWmURL, postID, time.Now(), taskId)
+	_ = a.store.UpdateDownloads(taskId, func(d *downloadRecord) {
+		d.LocalPath, d.DownloadableURL, d.PostID, d.CreatedAt = localPath, noWmURL, postID, time.Now()
+	})
 
 	return jsonMarshal(map[string]interface{}{
-		"success":        true,
-		"published_url":  publishedURL,
-		"no_watermark":   noWmURL,
-		"local_path":     localPath,
+		"success":       true,
+		"published_url": publishedURL,
+		"no_watermark":  noWmURL,
+		"local_path":    localPath,
 	})
 }
 
 // GetTaskList 从 SQLite 读取任务列表 JSON（key="list"），并合并本地下载路径
 // 若 task_list 为空，则回退到 video_task_results 生成占位任务，便于查看已完成任务
 func (a *App) GetTaskList() (string, error) {
-	if a.db == nil {
+	if a.store == nil {
 		return "[]", nil
 	}
-	downloads := map[string]string{}
-	if drows, derr := a.db.Query(`SELECT task_id, local_path FROM video_downloads WHERE task_id IS NOT NULL`); derr == nil {
-		for drows.Next() {
-			var taskID string
-			var localPath string
-			if err := drows.Scan(&taskID, &localPath); err == nil {
-				downloads[taskID] = localPath
-			}
-		}
-		drows.Close()
-	}
+	downloads := downloadPathsByTask(a.store)
 
-	var value string
-	if err := a.db.QueryRow(`SELECT value FROM task_list WHERE key='list'`).Scan(&value); err == nil {
+	if value, err := a.store.GetTaskList(); err == nil {
 		trimmed := strings.TrimSpace(value)
 		if trimmed != "" && trimmed != "[]" && trimmed != "null" {
 			var list []map[string]interface{}
@@ -1903,9 +1984,9 @@ func (a *App) GetTaskList() (string, error) {
 						}
 					}
 				}
-				runtime.LogInfo(a.ctx, fmt.Sprintf("[GetTaskList] task_list 合并 localPath: %d 条", len(list)))
+				a.logInfo(fmt.Sprintf("[GetTaskList] task_list 合并 localPath: %d 条", len(list)))
 				if len(list) > 0 {
-					runtime.LogInfo(a.ctx, fmt.Sprintf("[GetTaskList] 示例: id=%v localPath=%v", list[0]["id"], list[0]["localPath"]))
+					a.logInfo(fmt.Sprintf("[GetTaskList] 示例: id=%v localPath=%v", list[0]["id"], list[0]["localPath"]))
 				}
 				return jsonMarshal(list)
 			}
@@ -1913,72 +1994,63 @@ func (a *App) GetTaskList() (string, error) {
 		}
 	}
 
-	rows, err := a.db.Query(`SELECT task_id, token_id, result_json, progress_pct, prompt, created_at FROM video_task_results ORDER BY created_at DESC`)
+	tasks, err := a.store.ListTasks()
 	if err != nil {
 		return "[]", nil
 	}
-	defer rows.Close()
 	var list []map[string]interface{}
-	for rows.Next() {
-		var taskID string
-		var tokenID int64
-		var resultJSON sql.NullString
-		var progressPct sql.NullFloat64
-		var prompt sql.NullString
-		var createdAt sql.NullString
-		if err := rows.Scan(&taskID, &tokenID, &resultJSON, &progressPct, &prompt, &createdAt); err != nil {
+	for i := len(tasks) - 1; i >= 0; i-- {
+		t := tasks[i]
+		if t.TokenID == 0 {
 			continue
 		}
-		pct := 0.0
-		if progressPct.Valid {
-			pct = progressPct.Float64
-		}
-		status := "running"
-		if pct >= 100 {
-			status = "done"
-		}
-		promptText := ""
-		if prompt.Valid {
-			promptText = strings.TrimSpace(prompt.String)
+		taskID, pct := t.TaskID, t.ProgressPct
+		status := t.displayStatus()
+		message := "来自数据库"
+		if strings.TrimSpace(t.Message) != "" {
+			message = t.Message
 		}
+		promptText := strings.TrimSpace(t.Prompt)
 		if promptText == "" && taskID != "" {
 			promptText = "临时提示词（待补充）"
-			_, _ = a.db.Exec(`UPDATE video_task_results SET prompt=? WHERE task_id=?`, promptText, taskID)
+			_ = a.store.UpdateTask(taskID, func(t *taskRecord) error {
+				t.Prompt = promptText
+				return nil
+			})
 		}
 		localPath := downloads[taskID]
 		createdAtVal := ""
-		if createdAt.Valid {
-			createdAtVal = createdAt.String
+		if !t.CreatedAt.IsZero() {
+			createdAtVal = t.CreatedAt.Format(time.RFC3339Nano)
 		}
 		list = append(list, map[string]interface{}{
-			"id":               taskID,
-			"model":            "sora2-unknown",
-			"prompt":           promptText,
-			"status":           status,
-			"progress":         pct,
-			"message":          "来自数据库",
-			"remoteTaskId":     taskID,
-			"tokenIdForPending": tokenID,
-			"result":           resultJSON.String,
-			"localPath":        localPath,
-			"timestamp":        createdAtVal,
+			"id":                taskID,
+			"model":             "sora2-unknown",
+			"prompt":            promptText,
+			"status":            status,
+			"progress":          pct,
+			"message":           message,
+			"remoteTaskId":      taskID,
+			"tokenIdForPending": t.TokenID,
+			"result":            t.ResultJSON,
+			"localPath":         localPath,
+			"timestamp":         createdAtVal,
 		})
 	}
 	if len(list) == 0 {
-		runtime.LogInfo(a.ctx, "[GetTaskList] 回退 video_task_results: 0 条")
+		a.logInfo("[GetTaskList] 回退 video_task_results: 0 条")
 		return "[]", nil
 	}
-	runtime.LogInfo(a.ctx, fmt.Sprintf("[GetTaskList] 回退 video_task_results: %d 条", len(list)))
+	a.logInfo(fmt.Sprintf("[GetTaskList] 回退 video_task_results: %d 条", len(list)))
 	return jsonMarshal(list)
 }
 
 // SetTaskList 将任务列表 JSON 写入 SQLite（key="list"）
 func (a *App) SetTaskList(jsonStr string) (string, error) {
-	if a.db == nil {
+	if a.store == nil {
 		return jsonMarshal(map[string]interface{}{"success": true})
 	}
-	_, err := a.db.Exec(`INSERT OR REPLACE INTO task_list (key, value) VALUES ('list', ?)`, jsonStr)
-	if err != nil {
+	if err := a.store.SetTaskList(jsonStr); err != nil {
 		return jsonFail("写入任务列表失败: " + err.Error())
 	}
 	return jsonMarshal(map[string]interface{}{"success": true})
@@ -1986,22 +2058,15 @@ func (a *App) SetTaskList(jsonStr string) (string, error) {
 
 // GetVideoDownloadsMap 返回 task_id -> local_path 的映射，用于前端显示本地预览
 func (a *App) GetVideoDownloadsMap() (string, error) {
-	if a.db == nil {
+	if a.store == nil {
 		return jsonMarshal(map[string]interface{}{"map": map[string]string{}})
 	}
-	rows, err := a.db.Query(`SELECT task_id, local_path FROM video_downloads WHERE task_id IS NOT NULL`)
-	if err != nil {
+	if _, err := a.store.ListDownloads(); err != nil {
 		return jsonFail("查询 video_downloads 失败: " + err.Error())
 	}
-	defer rows.Close()
 	m := map[string]string{}
-	for rows.Next() {
-		var taskID string
-		var localPath string
-		if err := rows.Scan(&taskID, &localPath); err != nil {
-			continue
-		}
-		if strings.TrimSpace(taskID) != "" && strings.TrimSpace(localPath) != "" {
+	for taskID, localPath := range downloadPathsByTask(a.store) {
+		if strings.TrimSpace(localPath) != "" {
 			m[taskID] = localPath
 		}
 	}
@@ -2050,11 +2115,7 @@ func (a *App) ensureLocalFileServer() (int, error) {
 				http.Error(w, "path required", http.StatusBadRequest)
 				return
 			}
-			baseDir, err := os.Getwd()
-			if err != nil {
-				baseDir = "."
-			}
-			downloadDir := filepath.Join(baseDir, "downloads")
+			downloadDir := a.downloadsDir()
 			absPath, err := filepath.Abs(p)
 			if err != nil {
 				http.Error(w, "invalid path", http.StatusBadRequest)
@@ -2105,142 +2166,100 @@ func (a *App) localTokenSetStatus(id int64, body string) (string, error) {
 	return a.localTokenSetActive(id, input.IsActive)
 }
 
-func (a *App) localTokensImport(body string) (string, error) {
-	var input struct {
-		Tokens []string `json:"tokens"`
-		Mode   string   `json:"mode"`
-	}
-	if err := json.Unmarshal([]byte(body), &input); err != nil {
-		return jsonFail("请求体解析失败")
-	}
-	now := time.Now()
-	added := 0
-	for _, t := range input.Tokens {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
-		}
-		_, err := a.db.Exec(
-			`INSERT INTO tokens (token, is_active, created_at, updated_at) VALUES (?, 1, ?, ?)`,
-			t, now, now,
-		)
-		if err != nil {
-			continue
-		}
-		added++
-	}
-	return jsonMarshal(map[string]interface{}{"success": true, "message": fmt.Sprintf("成功导入 %d 个 Token", added), "imported": added})
-}
-
-func (a *App) localTokensBatch(method string, parts []string, body string) (string, error) {
-	var tokenIDs []int64
-	if body != "" {
-		var input struct {
-			TokenIDs []int64 `json:"token_ids"`
-		}
-		if err := json.Unmarshal([]byte(body), &input); err == nil {
-			tokenIDs = input.TokenIDs
-		}
-	}
-	if len(parts) < 3 {
-		return jsonFail("缺少 batch 操作类型")
-	}
-	action := parts[2]
-	now := time.Now()
-
-	switch action {
-	case "test-update":
-		for _, id := range tokenIDs {
-			_, _ = a.localTokenTest(id)
-		}
-		return jsonMarshal(map[string]interface{}{"success": true, "message": "批量测试完成"})
-	case "enable-all":
-		for _, id := range tokenIDs {
-			_, _ = a.db.Exec(`UPDATE tokens SET is_active=1, updated_at=? WHERE id=?`, now, id)
-		}
-		return jsonMarshal(map[string]interface{}{"success": true, "message": "已批量启用"})
-	case "disable-selected":
-		for _, id := range tokenIDs {
-			_, _ = a.db.Exec(`UPDATE tokens SET is_active=0, updated_at=? WHERE id=?`, now, id)
-		}
-		return jsonMarshal(map[string]interface{}{"success": true, "message": "已批量禁用"})
-	case "delete-disabled":
-		for _, id := range tokenIDs {
-			_, _ = a.db.Exec(`DELETE FROM tokens WHERE id=?`, id)
-		}
-		return jsonMarshal(map[string]interface{}{"success": true, "message": "已批量删除"})
-	case "update-proxy":
-		var input struct {
-			TokenIDs []int64 `json:"token_ids"`
-			ProxyURL string  `json:"proxy_url"`
-		}
-		if err := json.Unmarshal([]byte(body), &input); err != nil {
-			return jsonFail("请求体解析失败")
-		}
-		for _, id := range input.TokenIDs {
-			_, _ = a.db.Exec(`UPDATE tokens SET proxy_url=?, updated_at=? WHERE id=?`, input.ProxyURL, now, id)
-		}
-		return jsonMarshal(map[string]interface{}{"success": true, "message": "代理已更新"})
-	default:
-		return jsonFail("未知的 batch 操作: " + action)
-	}
-}
-
 func (a *App) handleLocalAdmin(method string, path string, parts []string, body string) (string, error) {
 	if len(parts) < 2 {
-		return jsonMarshal(map[string]interface{}{
-			"admin_username": "", "proxy_enabled": false, "proxy_url": "", "error_ban_threshold": 5,
-			"cache_enabled": true, "cache_timeout": 7200, "cache_base_url": "", "image_timeout": 300, "video_timeout": 1500,
-			"debug_enabled": false, "watermark_enabled": true,
-		})
+		return jsonMarshal(a.loadAppConfig())
 	}
 	switch parts[1] {
 	case "config":
 		if method == http.MethodGet {
-			return jsonMarshal(map[string]interface{}{
-				"admin_username": "", "proxy_enabled": false, "proxy_url": "", "error_ban_threshold": 5,
-				"cache_enabled": true, "cache_timeout": 7200, "cache_base_url": "", "image_timeout": 300, "video_timeout": 1500,
-				"debug_enabled": false, "watermark_enabled": true,
-			})
+			return jsonMarshal(adminConfigView(a.loadAppConfig()))
 		}
 		if method == http.MethodPost {
-			return jsonMarshal(map[string]interface{}{"success": true})
+			cfg, err := a.patchAppConfig(body)
+			if err != nil {
+				return jsonFail(err.Error())
+			}
+			out := adminConfigView(cfg)
+			out["success"] = true
+			return jsonMarshal(out)
+		}
+	case "debug":
+		var input struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+		if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+			cfg.DebugEnabled = input.Enabled
+			return nil
+		}); err != nil {
+			return jsonFail(err.Error())
 		}
-	case "password", "apikey", "debug":
+		return jsonMarshal(map[string]interface{}{"success": true})
+	case "migrations":
+		return a.listSchemaMigrations()
+	case "backup", "restore":
+		return a.handleLocalBackup(method, parts[1], body)
+	case "password", "apikey":
 		return jsonMarshal(map[string]interface{}{"success": true})
 	}
 	return jsonMarshal(map[string]interface{}{})
 }
 
-func (a *App) handleLocalLogs(method string, path string, body string) (string, error) {
-	if method == http.MethodGet {
-		return jsonMarshal(map[string]interface{}{"logs": []interface{}{}, "total": 0})
-	}
+// adminConfigView /api/admin/config 的返回内容：配置字段及可选的选号策略列表
+func adminConfigView(cfg AppConfig) map[string]interface{} {
+	out := map[string]interface{}{}
+	b, _ := json.Marshal(cfg)
+	_ = json.Unmarshal(b, &out)
+	out["token_selection_strategies"] = tokenStrategyNames
+	return out
+}
+
+func (a *App) handleLocalLogs(method string, rawPath string, body string) (string, error) {
 	if method == http.MethodDelete {
-		return jsonMarshal(map[string]interface{}{"success": true})
+		return a.clearRequestLogs()
 	}
-	return jsonMarshal(map[string]interface{}{"logs": []interface{}{}, "total": 0})
+	return a.listRequestLogs(rawPath)
 }
 
 func (a *App) handleLocalStats(method string, path string) (string, error) {
-	total := 0
-	active := 0
-	if a.db != nil {
-		_ = a.db.QueryRow(`SELECT COUNT(*) FROM tokens`).Scan(&total)
-		_ = a.db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE is_active=1`).Scan(&active)
+	// GET /api/stats/quota?days=14
+	if strings.HasPrefix(path, "/api/stats/quota") {
+		return a.localQuotaStats(path)
 	}
-	return jsonMarshal(map[string]interface{}{
-		"total_tokens":   total,
-		"active_tokens":  active,
-		"today_images":   0, "total_images": 0, "today_videos": 0, "total_videos": 0, "today_errors": 0, "total_errors": 0,
-	})
+	days := 14
+	if u, err := url.Parse(path); err == nil {
+		if v, err := strconv.Atoi(u.Query().Get("days")); err == nil && v > 0 && v <= 365 {
+			days = v
+		}
+	}
+	return a.computeStats(days)
 }
 
 func (a *App) handleLocalConfigDefault(prefix string, method string, path string, body string) (string, error) {
 	switch prefix {
 	case "proxy":
 		if method == http.MethodGet {
-			return jsonMarshal(map[string]interface{}{"proxy_enabled": false, "proxy_url": ""})
+			cfg := a.loadAppConfig()
+			return jsonMarshal(map[string]interface{}{"proxy_enabled": cfg.ProxyEnabled, "proxy_url": cfg.ProxyURL})
+		}
+		if method == http.MethodPost {
+			var input struct {
+				ProxyEnabled bool   `json:"proxy_enabled"`
+				ProxyURL     string `json:"proxy_url"`
+			}
+			if err := json.Unmarshal([]byte(body), &input); err != nil {
+				return jsonFail("请求体解析失败")
+			}
+			if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+				cfg.ProxyEnabled = input.ProxyEnabled
+				cfg.ProxyURL = input.ProxyURL
+				return nil
+			}); err != nil {
+				return jsonFail(err.Error())
+			}
 		}
 		return jsonMarshal(map[string]interface{}{"success": true})
 	case "watermark-free":
@@ -2249,7 +2268,7 @@ func (a *App) handleLocalConfigDefault(prefix string, method string, path string
 			parseMethod := "third_party"
 			customURL := ""
 			customToken := ""
-			if a.db != nil {
+			if a.store != nil {
 				enabled = strings.TrimSpace(a.getSettingValue("watermark_free_enabled")) == "true"
 				if v := strings.TrimSpace(a.getSettingValue("watermark_parse_method")); v != "" {
 					parseMethod = v
@@ -2274,31 +2293,73 @@ func (a *App) handleLocalConfigDefault(prefix string, method string, path string
 			if err := json.Unmarshal([]byte(body), &input); err != nil {
 				return jsonFail("请求体解析失败")
 			}
-			if a.db != nil {
+			if a.store != nil {
 				a.setSettingValue("watermark_free_enabled", strconv.FormatBool(input.WatermarkFreeEnabled))
 				a.setSettingValue("watermark_parse_method", strings.TrimSpace(input.ParseMethod))
 				a.setSettingValue("watermark_custom_url", strings.TrimSpace(input.CustomParseURL))
 				a.setSettingValue("watermark_custom_token", strings.TrimSpace(input.CustomParseToken))
-				runtime.LogInfo(a.ctx, fmt.Sprintf("[WatermarkConfig] 保存: enabled=%v method=%s url=%s tokenLen=%d", input.WatermarkFreeEnabled, input.ParseMethod, input.CustomParseURL, len(input.CustomParseToken)))
+				a.logInfo(fmt.Sprintf("[WatermarkConfig] 保存: enabled=%v method=%s url=%s tokenLen=%d", input.WatermarkFreeEnabled, input.ParseMethod, input.CustomParseURL, len(input.CustomParseToken)))
 			}
 			return jsonMarshal(map[string]interface{}{"success": true})
 		}
 		return jsonMarshal(map[string]interface{}{"success": true})
 	case "cache":
 		if method == http.MethodGet {
-			return jsonMarshal(map[string]interface{}{"config": map[string]interface{}{"enabled": true, "timeout": 7200, "base_url": "", "effective_base_url": ""}})
+			return jsonMarshal(map[string]interface{}{"config": a.cacheConfigView(a.loadAppConfig())})
 		}
-		return jsonMarshal(map[string]interface{}{"success": true})
+		// POST /api/cache/enabled {enabled}、/api/cache/config {timeout}、/api/cache/base-url {base_url}
+		var input struct {
+			Enabled *bool   `json:"enabled"`
+			Timeout *int    `json:"timeout"`
+			BaseURL *string `json:"base_url"`
+		}
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+		cfg, err := a.updateAppConfig(func(cfg *AppConfig) error {
+			if input.Enabled != nil {
+				cfg.CacheEnabled = *input.Enabled
+			}
+			if input.Timeout != nil {
+				cfg.CacheTimeout = *input.Timeout
+			}
+			if input.BaseURL != nil {
+				cfg.CacheBaseURL = *input.BaseURL
+			}
+			return nil
+		})
+		if err != nil {
+			return jsonFail(err.Error())
+		}
+		return jsonMarshal(map[string]interface{}{"success": true, "config": a.cacheConfigView(cfg)})
 	case "generation":
 		if method == http.MethodGet {
-			return jsonMarshal(map[string]interface{}{"config": map[string]interface{}{"image_timeout": 300, "video_timeout": 1500}})
+			cfg := a.loadAppConfig()
+			return jsonMarshal(map[string]interface{}{"config": map[string]interface{}{"image_timeout": cfg.ImageTimeout, "video_timeout": cfg.VideoTimeout}})
 		}
-		return jsonMarshal(map[string]interface{}{"success": true})
-	case "token-refresh":
-		if method == http.MethodGet {
-			return jsonMarshal(map[string]interface{}{"success": true, "config": map[string]interface{}{"at_auto_refresh_enabled": false}})
+		var input struct {
+			ImageTimeout *int `json:"image_timeout"`
+			VideoTimeout *int `json:"video_timeout"`
+		}
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+		if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+			if input.ImageTimeout != nil {
+				cfg.ImageTimeout = *input.ImageTimeout
+			}
+			if input.VideoTimeout != nil {
+				cfg.VideoTimeout = *input.VideoTimeout
+			}
+			return nil
+		}); err != nil {
+			return jsonFail(err.Error())
 		}
 		return jsonMarshal(map[string]interface{}{"success": true})
+	case "token-refresh":
+		return a.handleTokenRefresh(method, path, body)
+	case "health-check":
+		return a.handleHealthCheck(method, path, body)
 	}
 	return jsonMarshal(map[string]interface{}{})
 }
@@ -2320,7 +2381,7 @@ func (a *App) ApiRequestBlob(method string, path string, token string) (string,
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := a.httpClientForBearer(token, 60*time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -2349,9 +2410,7 @@ func (a *App) TestServerHealth(baseURL string) (*HealthResult, error) {
 	base = strings.TrimRight(base, "/")
 	testURL := base + "/health"
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := a.httpClientForToken(0, 10*time.Second)
 
 	req, err := http.NewRequest(http.MethodGet, testURL, nil)
 	if err != nil {
@@ -2359,7 +2418,7 @@ func (a *App) TestServerHealth(baseURL string) (*HealthResult, error) {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	runtime.LogInfo(a.ctx, fmt.Sprintf("测试服务器健康状态: %s", testURL))
+	a.logInfo(fmt.Sprintf("测试服务器健康状态: %s", testURL))
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -2403,12 +2462,12 @@ func base64Encode(data []byte) string {
 
 // UpdateInfo 更新信息结构
 type UpdateInfo struct {
-	HasUpdate   bool   `json:"has_update"`
-	LatestVersion string `json:"latest_version"`
+	HasUpdate      bool   `json:"has_update"`
+	LatestVersion  string `json:"latest_version"`
 	CurrentVersion string `json:"current_version"`
-	DownloadURL  string `json:"download_url"`
-	ReleaseNotes string `json:"release_notes"`
-	Error        string `json:"error,omitempty"`
+	DownloadURL    string `json:"download_url"`
+	ReleaseNotes   string `json:"release_notes"`
+	Error          string `json:"error,omitempty"`
 }
 
 // GetCurrentVersion 返回当前应用版本
@@ -2420,11 +2479,9 @@ func (a *App) GetCurrentVersion() string {
 func (a *App) CheckForUpdates() (string, error) {
 	currentVersion := AppVersion
 	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", GitHubOwner, GitHubRepo)
-	
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	
+
+	client := a.httpClientForToken(0, 10*time.Second)
+
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
 		return jsonMarshal(UpdateInfo{
@@ -2435,7 +2492,7 @@ func (a *App) CheckForUpdates() (string, error) {
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("User-Agent", "sorapc-updater")
-	
+
 	resp, err := client.Do(req)
 	if err != nil {
 		return jsonMarshal(UpdateInfo{
@@ -2445,7 +2502,7 @@ func (a *App) CheckForUpdates() (string, error) {
 		})
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
 		return jsonMarshal(UpdateInfo{
 			HasUpdate:      false,
@@ -2453,17 +2510,17 @@ func (a *App) CheckForUpdates() (string, error) {
 			Error:          fmt.Sprintf("GitHub API 返回错误: HTTP %d", resp.StatusCode),
 		})
 	}
-	
+
 	var release struct {
-		TagName    string `json:"tag_name"`
-		Name       string `json:"name"`
-		Body       string `json:"body"`
-		Assets     []struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+		Assets  []struct {
 			Name               string `json:"name"`
 			BrowserDownloadURL string `json:"browser_download_url"`
 		} `json:"assets"`
 	}
-	
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return jsonMarshal(UpdateInfo{
@@ -2472,7 +2529,7 @@ func (a *App) CheckForUpdates() (string, error) {
 			Error:          "读取响应失败: " + err.Error(),
 		})
 	}
-	
+
 	if err := json.Unmarshal(body, &release); err != nil {
 		return jsonMarshal(UpdateInfo{
 			HasUpdate:      false,
@@ -2480,32 +2537,32 @@ func (a *App) CheckForUpdates() (string, error) {
 			Error:          "解析响应失败: " + err.Error(),
 		})
 	}
-	
+
 	latestVersion := strings.TrimPrefix(release.TagName, "v")
 	hasUpdate := compareVersions(latestVersion, currentVersion) > 0
-	
+
 	// 查找对应平台的安装包
 	downloadURL := ""
 	platform := goruntime.GOOS
 	arch := goruntime.GOARCH
-	
+
 	for _, asset := range release.Assets {
 		assetName := strings.ToLower(asset.Name)
-		
+
 		// Windows 平台
 		if platform == "windows" {
-			if strings.HasSuffix(assetName, ".exe") || 
-			   strings.HasSuffix(assetName, ".msi") ||
-			   strings.Contains(assetName, "windows") {
+			if strings.HasSuffix(assetName, ".exe") ||
+				strings.HasSuffix(assetName, ".msi") ||
+				strings.Contains(assetName, "windows") {
 				downloadURL = asset.BrowserDownloadURL
 				break
 			}
 		}
-		
+
 		// macOS 平台
 		if platform == "darwin" {
-		// 优先选择对应架构的版本
-		if arch == "arm64" && strings.Contains(assetName, "arm64") {
+			// 优先选择对应架构的版本
+			if arch == "arm64" && strings.Contains(assetName, "arm64") {
 				downloadURL = asset.BrowserDownloadURL
 				break
 			}
@@ -2519,12 +2576,12 @@ func (a *App) CheckForUpdates() (string, error) {
 			}
 		}
 	}
-	
+
 	// 如果没有找到特定平台的包，使用第一个资源
 	if downloadURL == "" && len(release.Assets) > 0 {
 		downloadURL = release.Assets[0].BrowserDownloadURL
 	}
-	
+
 	updateInfo := UpdateInfo{
 		HasUpdate:      hasUpdate,
 		LatestVersion:  latestVersion,
@@ -2532,7 +2589,7 @@ func (a *App) CheckForUpdates() (string, error) {
 		DownloadURL:    downloadURL,
 		ReleaseNotes:   release.Body,
 	}
-	
+
 	return jsonMarshal(updateInfo)
 }
 
@@ -2540,12 +2597,12 @@ func (a *App) CheckForUpdates() (string, error) {
 func compareVersions(v1, v2 string) int {
 	parts1 := strings.Split(strings.TrimPrefix(v1, "v"), ".")
 	parts2 := strings.Split(strings.TrimPrefix(v2, "v"), ".")
-	
+
 	maxLen := len(parts1)
 	if len(parts2) > maxLen {
 		maxLen = len(parts2)
 	}
-	
+
 	for i := 0; i < maxLen; i++ {
 		var num1, num2 int
 		if i < len(parts1) {
@@ -2554,7 +2611,7 @@ func compareVersions(v1, v2 string) int {
 		if i < len(parts2) {
 			num2, _ = strconv.Atoi(parts2[i])
 		}
-		
+
 		if num1 > num2 {
 			return 1
 		}
@@ -2570,67 +2627,61 @@ func (a *App) DownloadUpdate(downloadURL string) (string, error) {
 	if downloadURL == "" {
 		return jsonFail("下载地址为空")
 	}
-	
-	baseDir, err := os.Getwd()
-	if err != nil {
-		baseDir = "."
-	}
-	downloadDir := filepath.Join(baseDir, "updates")
+
+	downloadDir := a.dataPath("updates")
 	if err := os.MkdirAll(downloadDir, 0755); err != nil {
 		return jsonFail("创建下载目录失败: " + err.Error())
 	}
-	
+
 	// 从 URL 提取文件名
 	u, err := url.Parse(downloadURL)
 	if err != nil {
 		return jsonFail("无效的下载地址: " + err.Error())
 	}
-	
+
 	fileName := filepath.Base(u.Path)
 	if fileName == "" || fileName == "/" {
 		fileName = "update.exe"
 	}
-	
+
 	localPath := filepath.Join(downloadDir, fileName)
-	
-	runtime.LogInfo(a.ctx, fmt.Sprintf("开始下载更新: %s -> %s", downloadURL, localPath))
-	
-	client := &http.Client{
-		Timeout: 300 * time.Second, // 5分钟超时
-	}
-	
+
+	a.logInfo(fmt.Sprintf("开始下载更新: %s -> %s", downloadURL, localPath))
+
+	client := a.httpClientForToken(0, 300*time.Second) // 5分钟超时
+
 	req, err := http.NewRequest("GET", downloadURL, nil)
 	if err != nil {
 		return jsonFail("创建下载请求失败: " + err.Error())
 	}
 	req.Header.Set("User-Agent", "sorapc-updater")
-	
+
 	resp, err := client.Do(req)
 	if err != nil {
 		return jsonFail("下载失败: " + err.Error())
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
 		return jsonFail(fmt.Sprintf("下载失败: HTTP %d", resp.StatusCode))
 	}
-	
+
 	// 创建文件
 	file, err := os.Create(localPath)
 	if err != nil {
 		return jsonFail("创建文件失败: " + err.Error())
 	}
 	defer file.Close()
-	
+
 	// 写入文件
 	_, err = io.Copy(file, resp.Body)
 	if err != nil {
 		os.Remove(localPath)
 		return jsonFail("写入文件失败: " + err.Error())
 	}
-	
-	runtime.LogInfo(a.ctx, fmt.Sprintf("更新文件下载完成: %s", localPath))
-	
+
+	a.logInfo(fmt.Sprintf("更新文件下载完成: %s", localPath))
+
 	return jsonMarshal(map[string]interface{}{
 		"success":    true,
 		"local_path": localPath,
@@ -2643,17 +2694,17 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 	if installerPath == "" {
 		return jsonFail("安装程序路径为空")
 	}
-	
+
 	// 检查文件是否存在
 	if _, err := os.Stat(installerPath); os.IsNotExist(err) {
 		return jsonFail("安装程序文件不存在: " + installerPath)
 	}
-	
-	runtime.LogInfo(a.ctx, fmt.Sprintf("准备安装更新: %s", installerPath))
-	
+
+	a.logInfo(fmt.Sprintf("准备安装更新: %s", installerPath))
+
 	var cmd *exec.Cmd
 	var message string
-	
+
 	if goruntime.GOOS == "windows" {
 		// Windows 安装
 		if strings.HasSuffix(strings.ToLower(installerPath), ".msi") {
@@ -2671,13 +2722,13 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 			extractDir := filepath.Join(filepath.Dir(installerPath), "extracted")
 			os.RemoveAll(extractDir)
 			os.MkdirAll(extractDir, 0755)
-			
+
 			// 使用 unzip 解压
 			cmd = exec.Command("unzip", "-q", installerPath, "-d", extractDir)
 			if err := cmd.Run(); err != nil {
 				return jsonFail("解压失败: " + err.Error())
 			}
-			
+
 			// 查找 .app 文件
 			appPath := ""
 			err := filepath.Walk(extractDir, func(path string, info os.FileInfo, err error) error {
@@ -2687,19 +2738,19 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 				}
 				return nil
 			})
-			
+
 			if appPath == "" || err != nil {
 				return jsonFail("未找到 .app 文件")
 			}
-			
+
 			// 复制到 Applications 目录
 			appsDir := "/Applications"
 			appName := filepath.Base(appPath)
 			targetPath := filepath.Join(appsDir, appName)
-			
+
 			// 删除旧版本
 			os.RemoveAll(targetPath)
-			
+
 			// 复制新版本
 			cmd = exec.Command("cp", "-R", appPath, targetPath)
 			message = "应用已安装到 /Applications，请手动启动新版本"
@@ -2708,7 +2759,7 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 			appsDir := "/Applications"
 			appName := filepath.Base(installerPath)
 			targetPath := filepath.Join(appsDir, appName)
-			
+
 			os.RemoveAll(targetPath)
 			cmd = exec.Command("cp", "-R", installerPath, targetPath)
 			message = "应用已安装到 /Applications，请手动启动新版本"
@@ -2718,15 +2769,15 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 	} else {
 		return jsonFail("当前系统不支持自动安装")
 	}
-	
+
 	// 执行安装命令
 	err := cmd.Run()
 	if err != nil {
 		return jsonFail("安装失败: " + err.Error())
 	}
-	
-	runtime.LogInfo(a.ctx, message)
-	
+
+	a.logInfo(message)
+
 	// Windows 上延迟关闭，macOS 上立即提示
 	if goruntime.GOOS == "windows" {
 		go func() {
@@ -2734,7 +2785,7 @@ func (a *App) InstallUpdate(installerPath string) (string, error) {
 			runtime.Quit(a.ctx)
 		}()
 	}
-	
+
 	return jsonMarshal(map[string]interface{}{
 		"success": true,
 		"message": message,
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 系统配置：/api/admin/config、/api/proxy、/api/cache、/api/generation、/api/token-refresh、/api/health-check 共用一份 AppConfig，
+// 以 JSON 保存在 settings 表 app_config 中，缺失字段取默认值；保存前统一校验。
+
+// appConfigSettingKey settings 表中保存系统配置的 key
+const appConfigSettingKey = "app_config"
+
+// AppConfig 管理端系统配置
+type AppConfig struct {
+	AdminUsername          string `json:"admin_username"`
+	ProxyEnabled           bool   `json:"proxy_enabled"`
+	ProxyURL               string `json:"proxy_url"`
+	ErrorBanThreshold      int    `json:"error_ban_threshold"` // 连续失败多少次后自动禁用账号，0 表示不自动禁用
+	CacheEnabled           bool   `json:"cache_enabled"`
+	CacheTimeout           int    `json:"cache_timeout"` // 秒
+	CacheBaseURL           string `json:"cache_base_url"`
+	ImageTimeout           int    `json:"image_timeout"` // 秒
+	VideoTimeout           int    `json:"video_timeout"` // 秒，视频任务从提交到完成的最长时间
+	DebugEnabled           bool   `json:"debug_enabled"`
+	WatermarkEnabled       bool   `json:"watermark_enabled"`
+	TokenSelectionStrategy string `json:"token_selection_strategy"`
+	ATAutoRefreshEnabled   bool   `json:"at_auto_refresh_enabled"`
+	ATRefreshBeforeMinutes int    `json:"at_refresh_before_minutes"` // AT 距过期不足该分钟数时自动刷新
+	AuthSessionURL         string `json:"auth_session_url"`          // ST→AT：携带 session token cookie 请求的会话接口
+	AuthTokenURL           string `json:"auth_token_url"`            // RT→AT：OAuth refresh_token 换取接口
+	AuthClientID           string `json:"auth_client_id"`            // 账号未设置 client_id 时 RT→AT 使用的默认值
+	HealthCheckEnabled     bool   `json:"health_check_enabled"`
+	HealthCheckInterval    int    `json:"health_check_interval_minutes"` // 两轮定时健康检查的间隔（分钟）
+	HealthCheckConcurrency int    `json:"health_check_concurrency"`      // 同时检查的账号数
+}
+
+// appConfigMu 串行化配置的读-改-写与缓存的填充
+var appConfigMu sync.Mutex
+
+func defaultAppConfig() AppConfig {
+	return AppConfig{
+		ErrorBanThreshold:      5,
+		CacheEnabled:           true,
+		CacheTimeout:           7200,
+		ImageTimeout:           300,
+		VideoTimeout:           1500,
+		WatermarkEnabled:       true,
+		TokenSelectionStrategy: tokenStrategyRandom,
+		ATRefreshBeforeMinutes: 60,
+		AuthSessionURL:         defaultAuthSessionURL,
+		AuthTokenURL:           defaultAuthTokenURL,
+		AuthClientID:           defaultAuthClientID,
+		HealthCheckInterval:    60,
+		HealthCheckConcurrency: 4,
+	}
+}
+
+// validate 去除首尾空白并校验各字段取值范围
+func (c *AppConfig) validate() error {
+	c.AdminUsername = strings.TrimSpace(c.AdminUsername)
+	c.ProxyURL = strings.TrimSpace(c.ProxyURL)
+	c.CacheBaseURL = strings.TrimRight(strings.TrimSpace(c.CacheBaseURL), "/")
+
+	if _, err := parseProxyURL(c.ProxyURL); err != nil {
+		return err
+	}
+	if c.ProxyEnabled && c.ProxyURL == "" {
+		return fmt.Errorf("启用代理时代理地址不能为空")
+	}
+	if c.ErrorBanThreshold < 0 || c.ErrorBanThreshold > 1000 {
+		return fmt.Errorf("error_ban_threshold 需在 0-1000 之间（0 表示不自动禁用）")
+	}
+	if c.CacheTimeout < 0 || c.CacheTimeout > 30*24*3600 {
+		return fmt.Errorf("cache_timeout 需在 0-%d 秒之间", 30*24*3600)
+	}
+	if c.CacheBaseURL != "" {
+		u, err := url.Parse(c.CacheBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("cache_base_url 需为 http(s) 地址")
+		}
+	}
+	if c.ImageTimeout < 10 || c.ImageTimeout > 24*3600 {
+		return fmt.Errorf("image_timeout 需在 10-%d 秒之间", 24*3600)
+	}
+	if c.VideoTimeout < 60 || c.VideoTimeout > 24*3600 {
+		return fmt.Errorf("video_timeout 需在 60-%d 秒之间", 24*3600)
+	}
+	if c.ATRefreshBeforeMinutes < 1 || c.ATRefreshBeforeMinutes > 7*24*60 {
+		return fmt.Errorf("at_refresh_before_minutes 需在 1-%d 分钟之间", 7*24*60)
+	}
+	if c.HealthCheckInterval < 5 || c.HealthCheckInterval > 24*60 {
+		return fmt.Errorf("health_check_interval_minutes 需在 5-%d 分钟之间", 24*60)
+	}
+	if c.HealthCheckConcurrency < 1 || c.HealthCheckConcurrency > 16 {
+		return fmt.Errorf("health_check_concurrency 需在 1-16 之间")
+	}
+	c.AuthSessionURL = strings.TrimSpace(c.AuthSessionURL)
+	c.AuthTokenURL = strings.TrimSpace(c.AuthTokenURL)
+	c.AuthClientID = strings.TrimSpace(c.AuthClientID)
+	if c.AuthSessionURL == "" {
+		c.AuthSessionURL = defaultAuthSessionURL
+	}
+	if c.AuthTokenURL == "" {
+		c.AuthTokenURL = defaultAuthTokenURL
+	}
+	if c.AuthClientID == "" {
+		c.AuthClientID = defaultAuthClientID
+	}
+	for name, raw := range map[string]string{"auth_session_url": c.AuthSessionURL, "auth_token_url": c.AuthTokenURL} {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s 需为 http(s) 地址", name)
+		}
+	}
+	strategy, err := normalizeTokenStrategy(c.TokenSelectionStrategy)
+	if err != nil {
+		return err
+	}
+	c.TokenSelectionStrategy = strategy
+	return nil
+}
+
+// videoTimeoutDuration 视频任务超时时间
+func (c AppConfig) videoTimeoutDuration() time.Duration {
+	return time.Duration(c.VideoTimeout) * time.Second
+}
+
+// loadAppConfig 返回当前配置；每次出站请求与调度都会调用，解析结果缓存在 a.config，
+// 由 updateAppConfig 更新、publishStore（打开或恢复存储）清空
+func (a *App) loadAppConfig() AppConfig {
+	if cfg := a.config.Load(); cfg != nil {
+		return *cfg
+	}
+	if a.store == nil {
+		return defaultAppConfig()
+	}
+	appConfigMu.Lock()
+	defer appConfigMu.Unlock()
+	if cfg := a.config.Load(); cfg != nil {
+		return *cfg
+	}
+	cfg := a.readAppConfig()
+	a.config.Store(&cfg)
+	return cfg
+}
+
+// invalidateAppConfig 清空配置缓存，下次 loadAppConfig 重新读取 settings
+func (a *App) invalidateAppConfig() {
+	appConfigMu.Lock()
+	defer appConfigMu.Unlock()
+	a.config.Store(nil)
+}
+
+// readAppConfig 从 settings 表读取并解析配置，无记录时返回默认值；调用方需持有 appConfigMu
+func (a *App) readAppConfig() AppConfig {
+	cfg := defaultAppConfig()
+	raw := strings.TrimSpace(a.getSettingValue(appConfigSettingKey))
+	if raw == "" {
+		return cfg
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		a.logError(fmt.Sprintf("[Config] 解析 app_config 失败，使用默认配置: %v", err))
+		return defaultAppConfig()
+	}
+	return cfg
+}
+
+// updateAppConfig 读取当前配置，由 apply 修改后校验并保存，返回保存后的配置
+func (a *App) updateAppConfig(apply func(cfg *AppConfig) error) (AppConfig, error) {
+	if a.store == nil {
+		return AppConfig{}, fmt.Errorf("本地存储未初始化，无法保存配置")
+	}
+	appConfigMu.Lock()
+	defer appConfigMu.Unlock()
+	cfg := a.readAppConfig()
+	if err := apply(&cfg); err != nil {
+		return AppConfig{}, err
+	}
+	if err := cfg.validate(); err != nil {
+		return AppConfig{}, err
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return AppConfig{}, err
+	}
+	if err := a.store.SetSetting(appConfigSettingKey, string(b)); err != nil {
+		return AppConfig{}, fmt.Errorf("保存配置失败: %v", err)
+	}
+	saved := cfg
+	a.config.Store(&saved)
+	a.logInfo(fmt.Sprintf("[Config] 已保存: proxy=%v(%s) ban_threshold=%d video_timeout=%ds strategy=%s",
+		cfg.ProxyEnabled, proxyHostForLog(cfg.ProxyURL), cfg.ErrorBanThreshold, cfg.VideoTimeout, cfg.TokenSelectionStrategy))
+	return cfg, nil
+}
+
+// patchAppConfig 将请求体中出现的字段覆盖到当前配置上（未出现的字段保持不变）
+func (a *App) patchAppConfig(body string) (AppConfig, error) {
+	return a.updateAppConfig(func(cfg *AppConfig) error {
+		if strings.TrimSpace(body) == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(body), cfg); err != nil {
+			return fmt.Errorf("请求体解析失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// cacheConfigView /api/cache/config 返回的 config 字段
+func (a *App) cacheConfigView(cfg AppConfig) map[string]interface{} {
+	effective := cfg.CacheBaseURL
+	if effective == "" {
+		effective = strings.TrimRight(a.GetBaseURL(), "/")
+	}
+	return map[string]interface{}{
+		"enabled":            cfg.CacheEnabled,
+		"timeout":            cfg.CacheTimeout,
+		"base_url":           cfg.CacheBaseURL,
+		"effective_base_url": effective,
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppConfigCache(t *testing.T) {
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{store: store}
+	if got := a.loadAppConfig(); got != defaultAppConfig() {
+		t.Fatalf("loadAppConfig() without settings = %+v, want defaults", got)
+	}
+
+	if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+		cfg.ErrorBanThreshold = 9
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.loadAppConfig().ErrorBanThreshold; got != 9 {
+		t.Errorf("loadAppConfig() after update = %d, want 9", got)
+	}
+
+	// 绕过 updateAppConfig 写入的配置在缓存清空前不可见
+	cfg := defaultAppConfig()
+	cfg.ErrorBanThreshold = 3
+	raw, _ := json.Marshal(cfg)
+	if err := store.SetSetting(appConfigSettingKey, string(raw)); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.loadAppConfig().ErrorBanThreshold; got != 9 {
+		t.Errorf("loadAppConfig() should serve the cached config, got %d", got)
+	}
+	a.invalidateAppConfig()
+	if got := a.loadAppConfig().ErrorBanThreshold; got != 3 {
+		t.Errorf("loadAppConfig() after invalidate = %d, want 3", got)
+	}
+}
+
+func TestAppConfigIgnoresLegacyKeys(t *testing.T) {
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"proxy_enabled": "true", "proxy_url": "http://p:1", "token_selection_strategy": "least_used"} {
+		if err := store.SetSetting(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &App{store: store}
+	if got := a.loadAppConfig(); got != defaultAppConfig() {
+		t.Errorf("loadAppConfig() = %+v, want defaults", got)
+	}
+}
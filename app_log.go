@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 运行时日志与事件：经 Wails runtime 输出（日志由 redactingLogger 脱敏）。
+// 没有 Wails 生命周期上下文时（单元测试、启动前）runtime 会直接退出进程，此时日志改为脱敏后写到标准 log，事件丢弃。
+
+func (a *App) logInfo(message string)    { a.logTo(runtime.LogInfo, "INF", message) }
+func (a *App) logWarning(message string) { a.logTo(runtime.LogWarning, "WRN", message) }
+func (a *App) logError(message string)   { a.logTo(runtime.LogError, "ERR", message) }
+
+func (a *App) logTo(fn func(context.Context, string), level, message string) {
+	if !a.hasRuntime() {
+		log.Printf("%s | %s", level, redactSecrets(message))
+		return
+	}
+	fn(a.ctx, message)
+}
+
+// emitEvent 向前端推送事件
+func (a *App) emitEvent(name string, data ...interface{}) {
+	if a.hasRuntime() {
+		runtime.EventsEmit(a.ctx, name, data...)
+	}
+}
+
+// hasRuntime a.ctx 是否为 Wails 生命周期上下文
+func (a *App) hasRuntime() bool {
+	return a.ctx != nil && a.ctx.Value("logger") != nil
+}
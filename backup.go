@@ -0,0 +1,509 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 备份与恢复：备份文件为 zip，包含 manifest.json、存储快照（SQLite 用 VACUUM INTO 生成 accounts.db，JSON 存储为 store.json）
+// 以及可选的 downloads/。数据密钥默认不打包（拿到备份即可解密全部 token），显式指定 include_key 时才导出为 secret.key；
+// 不含密钥的备份只能在仍保有原密钥（同一台电脑的系统钥匙串或 secret.key）或设置了相同口令时恢复。
+// 恢复时先校验 manifest 与 schema 版本，当前数据移到 backups/pre-restore-<时间>-* 后再替换，重新打开存储失败（含密钥不匹配）时自动回滚。
+
+const (
+	backupManifestName = "manifest.json"
+	backupDirName      = "backups"
+	backupTimeLayout   = "20060102-150405"
+
+	backupEngineStopTimeout = 15 * time.Second // 恢复前等待视频任务 goroutine 退出的最长时间
+)
+
+// backupManifest 备份包说明
+type backupManifest struct {
+	App           string   `json:"app"`
+	AppVersion    string   `json:"app_version"`
+	Storage       string   `json:"storage"`        // sqlite / json
+	SchemaVersion int      `json:"schema_version"` // JSON 存储为 0
+	CreatedAt     string   `json:"created_at"`
+	DownloadsDir  string   `json:"downloads_dir"` // 备份时的下载目录，恢复时用于改写 local_path
+	Downloads     int      `json:"downloads"`     // 包含的下载文件数
+	Files         []string `json:"files"`
+}
+
+// storeFileNames 各存储类型在数据目录中的文件（含 SQLite 的 WAL/SHM）
+func storeFileNames(kind string) []string {
+	if kind == "json" {
+		return []string{jsonStoreFile}
+	}
+	return []string{dbFileName, dbFileName + "-wal", dbFileName + "-shm", dbFileName + "-journal"}
+}
+
+func (a *App) backupsDir() string {
+	return a.dataPath(backupDirName)
+}
+
+// BackupData 生成备份 zip；dst 为空时写入数据目录下的 backups/；includeKey 为 true 时同时导出数据密钥，
+// 返回 JSON：{"success": true, "path": "...", "size": 123, "warning": "...", ...}
+func (a *App) BackupData(dst string, includeDownloads bool, includeKey bool) (string, error) {
+	m, p, size, err := a.createBackup(strings.TrimSpace(dst), includeDownloads, includeKey)
+	if err != nil {
+		return jsonFail("备份失败: " + err.Error())
+	}
+	warning := "备份不含数据密钥，恢复到其它电脑时需要原来的口令或密钥文件"
+	if includeKey {
+		warning = "备份中包含数据密钥，持有备份文件即可解密全部 token，请妥善保管"
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "path": p, "size": size, "manifest": m, "warning": warning})
+}
+
+// RestoreData 从备份 zip 恢复全部本地数据，返回 JSON：{"success": true, "manifest": {...}, "previous": "..."}
+func (a *App) RestoreData(src string) (string, error) {
+	m, prev, err := a.restoreBackup(strings.TrimSpace(src))
+	if err != nil {
+		return jsonFail("恢复失败: " + err.Error())
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "manifest": m, "previous": prev})
+}
+
+// SelectBackupFile 弹出文件对话框：save=true 时选择备份保存位置，否则选择要恢复的备份，取消时返回空串
+func (a *App) SelectBackupFile(save bool) (string, error) {
+	filters := []runtime.FileFilter{{DisplayName: "SoraPC 备份 (*.zip)", Pattern: "*.zip"}}
+	if save {
+		return runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+			Title:            "保存备份",
+			DefaultDirectory: a.backupsDir(),
+			DefaultFilename:  "sorapc-backup-" + time.Now().Format(backupTimeLayout) + ".zip",
+			Filters:          filters,
+		})
+	}
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "选择备份文件",
+		DefaultDirectory: a.backupsDir(),
+		Filters:          filters,
+	})
+}
+
+// handleLocalBackup 处理 POST /api/admin/backup {"path": "", "include_downloads": true, "include_key": false} 与 POST /api/admin/restore {"path": "..."}
+func (a *App) handleLocalBackup(method string, action string, body string) (string, error) {
+	if method != http.MethodPost {
+		return jsonFail("仅支持 POST")
+	}
+	var input struct {
+		Path             string `json:"path"`
+		IncludeDownloads bool   `json:"include_downloads"`
+		IncludeKey       bool   `json:"include_key"`
+	}
+	if strings.TrimSpace(body) != "" {
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+	}
+	if action == "restore" {
+		if strings.TrimSpace(input.Path) == "" {
+			return jsonFail("path 不能为空")
+		}
+		return a.RestoreData(input.Path)
+	}
+	return a.BackupData(input.Path, input.IncludeDownloads, input.IncludeKey)
+}
+
+// createBackup 写出备份 zip，返回 manifest、文件路径与大小
+func (a *App) createBackup(dst string, includeDownloads, includeKey bool) (*backupManifest, string, int64, error) {
+	if a.store == nil {
+		return nil, "", 0, errStoreUnavailable
+	}
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+
+	if err := os.MkdirAll(a.backupsDir(), 0700); err != nil {
+		return nil, "", 0, err
+	}
+	if dst == "" {
+		dst = filepath.Join(a.backupsDir(), "sorapc-backup-"+time.Now().Format(backupTimeLayout)+".zip")
+	}
+	m := &backupManifest{
+		App:          dataDirAppName,
+		AppVersion:   AppVersion,
+		Storage:      a.store.Kind(),
+		CreatedAt:    time.Now().Format(time.RFC3339),
+		DownloadsDir: a.downloadsDir(),
+	}
+	db := a.db.Load()
+	if db != nil {
+		v, err := currentSchemaVersion(db)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("读取 schema 版本失败: %v", err)
+		}
+		m.SchemaVersion = v
+	}
+
+	// 先生成快照，zip 中的数据库是同一时刻的一致副本
+	snapName := storeFileNames(m.Storage)[0]
+	tmpDir, err := os.MkdirTemp(a.backupsDir(), "snapshot-")
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+	snapPath := filepath.Join(tmpDir, snapName)
+	if err := a.store.Snapshot(snapPath); err != nil {
+		return nil, "", 0, fmt.Errorf("生成快照失败: %v", err)
+	}
+	keyPath := ""
+	if includeKey {
+		k, err := exportKeyringKey(a.store, a.dataPath())
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("导出密钥失败: %v", err)
+		}
+		keyPath = filepath.Join(tmpDir, secretKeyFile)
+		if err := (fileKeyring{path: keyPath}).Set(k); err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	tmp := dst + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	zw := zip.NewWriter(f)
+	err = func() error {
+		if err := zipAddFile(zw, snapPath, snapName, zip.Deflate); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, snapName)
+		if keyPath != "" {
+			if err := zipAddFile(zw, keyPath, secretKeyFile, zip.Deflate); err != nil {
+				return err
+			}
+			m.Files = append(m.Files, secretKeyFile)
+		}
+		if includeDownloads {
+			n, err := zipAddDir(zw, a.downloadsDir(), "downloads")
+			if err != nil {
+				return fmt.Errorf("打包 downloads 失败: %v", err)
+			}
+			m.Downloads = n
+		}
+		w, err := zw.Create(backupManifestName)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}()
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, "", 0, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return nil, "", 0, err
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	a.logInfo(fmt.Sprintf("[Backup] 已备份到 %s（%s，schema v%d，下载文件 %d 个）", dst, m.Storage, m.SchemaVersion, m.Downloads))
+	if keyPath != "" {
+		a.logWarning("[Backup] 备份中包含数据密钥 secret.key，持有备份文件即可解密全部 token")
+	}
+	return m, dst, info.Size(), nil
+}
+
+func zipAddFile(zw *zip.Writer, src, name string, method uint16) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name, hdr.Method = name, method
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// zipAddDir 递归打包目录下的文件；视频本身已压缩，直接 Store；目录不存在时返回 0
+func zipAddDir(zw *zip.Writer, dir, prefix string) (int, error) {
+	n := 0
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if err := zipAddFile(zw, p, path.Join(prefix, filepath.ToSlash(rel)), zip.Store); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// restoreBackup 校验并恢复备份，返回 manifest 与恢复前数据的保存目录
+func (a *App) restoreBackup(src string) (*backupManifest, string, error) {
+	if src == "" {
+		return nil, "", fmt.Errorf("未指定备份文件")
+	}
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开备份失败: %v", err)
+	}
+	defer zr.Close()
+	m, err := readBackupManifest(&zr.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	if a.store == nil {
+		// 存储从未成功打开时后台任务与接口都未使用存储，无法在线替换；可手动把备份解压到数据目录后重启
+		return nil, "", errStoreUnavailable
+	}
+	if current := a.store.Kind(); m.Storage != current {
+		return nil, "", fmt.Errorf("备份为 %s 存储，当前程序使用 %s 存储，无法恢复", m.Storage, current)
+	}
+	if m.SchemaVersion > latestSchemaVersion() {
+		return nil, "", fmt.Errorf("备份的数据库版本 %d 高于当前程序支持的版本 %d，请先升级程序", m.SchemaVersion, latestSchemaVersion())
+	}
+
+	if err := os.MkdirAll(a.backupsDir(), 0700); err != nil {
+		return nil, "", err
+	}
+	staging, err := os.MkdirTemp(a.backupsDir(), "restore-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(staging)
+	if err := extractZip(&zr.Reader, staging); err != nil {
+		return nil, "", fmt.Errorf("解压备份失败: %v", err)
+	}
+	snapName := storeFileNames(m.Storage)[0]
+	if _, err := os.Stat(filepath.Join(staging, snapName)); err != nil {
+		return nil, "", fmt.Errorf("备份中缺少 %s", snapName)
+	}
+	if m.Storage == "sqlite" {
+		if err := checkSQLiteSnapshot(filepath.Join(staging, snapName), m.SchemaVersion); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// 停止任务引擎并等待任务 goroutine 退出，再暂停定时任务（dataMu），之后才能关闭并替换存储；
+	// 期间 Wails 接口仍可能被调用，a.store 始终指向 swapStore，替换过程中的调用返回存储已关闭的错误
+	if a.engine != nil && !a.engine.stopAndWait(backupEngineStopTimeout) {
+		a.logWarning("[Backup] 等待视频任务退出超时，继续恢复")
+	}
+	a.dataMu.Lock()
+	defer a.dataMu.Unlock()
+	_ = a.store.Close()
+	prev, err := os.MkdirTemp(a.backupsDir(), "pre-restore-"+time.Now().Format(backupTimeLayout)+"-")
+	if err != nil {
+		a.reopenStore()
+		return nil, "", err
+	}
+	// 备份带密钥时才替换 secret.key；不带密钥时保留本机的密钥文件，恢复同一份数据仍能解密
+	replaced := storeFileNames(m.Storage)
+	if _, err := os.Stat(filepath.Join(staging, secretKeyFile)); err == nil {
+		replaced = append(replaced, secretKeyFile)
+	}
+	restore := func(from, to string) error {
+		for _, name := range replaced {
+			p := filepath.Join(from, name)
+			if _, err := os.Stat(p); err != nil {
+				continue
+			}
+			if err := movePath(p, filepath.Join(to, name)); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	}
+	err = restore(a.dataDir, prev)
+	if err == nil {
+		err = restore(staging, a.dataDir)
+	}
+	if err == nil {
+		err = a.reopenStore()
+	}
+	if err != nil {
+		// 回滚：删除放进来的文件，移回原数据
+		for _, name := range replaced {
+			_ = os.Remove(a.dataPath(name))
+		}
+		_ = restore(prev, a.dataDir)
+		a.reopenStore()
+		return nil, "", err
+	}
+
+	if m.Downloads > 0 {
+		if _, err := copyDownloads(filepath.Join(staging, "downloads"), a.downloadsDir()); err != nil {
+			a.logError(fmt.Sprintf("[Backup] 恢复 downloads 失败: %v", err))
+		}
+	}
+	if n := a.rewriteDownloadPaths(m.DownloadsDir); n > 0 {
+		a.logInfo(fmt.Sprintf("[Backup] 已更新 %d 条下载记录的本地路径", n))
+	}
+	a.logInfo(fmt.Sprintf("[Backup] 已从 %s 恢复（%s，schema v%d），原数据保存在 %s", src, m.Storage, m.SchemaVersion, prev))
+	return m, prev, nil
+}
+
+// reopenStore 重新打开存储并重启任务引擎；打开失败时 a.store 仍指向已关闭的旧存储，调用返回错误而不是空指针
+func (a *App) reopenStore() error {
+	err := a.initDB()
+	if err != nil {
+		a.dbInitErr.Store(err.Error())
+	} else {
+		a.dbInitErr.Store("")
+	}
+	if a.engine != nil {
+		a.engine.start()
+	}
+	if a.scheduler != nil {
+		a.scheduler.notify()
+	}
+	return err
+}
+
+func readBackupManifest(zr *zip.Reader) (*backupManifest, error) {
+	for _, f := range zr.File {
+		if f.Name != backupManifestName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var m backupManifest
+		if err := json.NewDecoder(rc).Decode(&m); err != nil {
+			return nil, fmt.Errorf("manifest.json 解析失败: %v", err)
+		}
+		if m.App != dataDirAppName || (m.Storage != "sqlite" && m.Storage != "json") {
+			return nil, fmt.Errorf("不是有效的 SoraPC 备份")
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("备份中缺少 manifest.json")
+}
+
+// extractZip 解压到 dst，拒绝指向 dst 之外的条目
+func extractZip(zr *zip.Reader, dst string) error {
+	for _, f := range zr.File {
+		name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("非法的文件路径 %q", f.Name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			rc.Close()
+			return err
+		}
+		_, err = io.Copy(out, rc)
+		rc.Close()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSQLiteSnapshot 确认备份中的数据库可以打开、未损坏，且 schema 版本与 manifest 一致
+func checkSQLiteSnapshot(p string, want int) error {
+	db, err := sql.Open("sqlite3", p)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return fmt.Errorf("备份数据库无法读取: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("备份数据库已损坏: %s", result)
+	}
+	v, err := currentSchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("读取备份数据库版本失败: %v", err)
+	}
+	if v != want {
+		return fmt.Errorf("备份数据库版本 %d 与 manifest 记录的 %d 不一致", v, want)
+	}
+	return nil
+}
+
+// copyDownloads 将备份中的视频复制到下载目录，已存在的同名文件覆盖
+func copyDownloads(src, dst string) (int, error) {
+	if _, err := os.Stat(src); err != nil {
+		return 0, nil
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if err := movePath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newBackupTestApp 在临时数据目录中用口令 passphrase 打开存储（无 CGO 时为 JSON 文件存储）
+func newBackupTestApp(t *testing.T, passphrase string) *App {
+	t.Helper()
+	t.Setenv(passphraseEnv, passphrase)
+	a := NewApp(t.TempDir(), "test")
+	if err := a.initDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.store.Close() })
+	return a
+}
+
+// insertSealedTokens 按 names 插入加密后的账号
+func insertSealedTokens(t *testing.T, a *App, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		enc, err := a.sealSecret(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.store.InsertToken(tokenRecord{Token: enc, TokenHash: a.tokenHash(name), IsActive: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func openedTokenNames(t *testing.T, a *App) []string {
+	t.Helper()
+	list, err := a.store.ListTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tok := range list {
+		names = append(names, a.openSecret(tok.Token))
+	}
+	return names
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	a := newBackupTestApp(t, "pw")
+	insertSealedTokens(t, a, "before")
+	if err := os.MkdirAll(a.downloadsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.downloadsDir(), "v.mp4"), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, path, size, err := a.createBackup("", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSchema := 0
+	if m.Storage == "sqlite" {
+		wantSchema = latestSchemaVersion()
+	}
+	if size == 0 || m.Storage != a.store.Kind() || m.Downloads != 1 || m.SchemaVersion != wantSchema {
+		t.Errorf("createBackup() manifest = %+v, size %d", m, size)
+	}
+
+	// 备份之后的修改在恢复后消失，恢复前的数据保存在 pre-restore 目录
+	list, err := a.store.ListTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.DeleteToken(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	insertSealedTokens(t, a, "after")
+	if err := os.Remove(filepath.Join(a.downloadsDir(), "v.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	got, prev, err := a.restoreBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CreatedAt != m.CreatedAt {
+		t.Errorf("restoreBackup() manifest = %+v", got)
+	}
+	if names := openedTokenNames(t, a); !reflect.DeepEqual(names, []string{"before"}) {
+		t.Errorf("tokens after restore = %v, want [before]", names)
+	}
+	if _, err := os.Stat(filepath.Join(prev, storeFileNames(m.Storage)[0])); err != nil {
+		t.Errorf("previous data not kept in %s: %v", prev, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(a.downloadsDir(), "v.mp4")); err != nil || string(data) != "video" {
+		t.Errorf("downloads not restored: %q, %v", data, err)
+	}
+}
+
+func TestRestoreRollsBackOnKeyMismatch(t *testing.T) {
+	src := newBackupTestApp(t, "first")
+	insertSealedTokens(t, src, "from-backup")
+	_, path, _, err := src.createBackup("", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用另一个口令的数据目录恢复：重新打开时密钥不匹配，应回滚到原数据
+	a := newBackupTestApp(t, "second")
+	insertSealedTokens(t, a, "local")
+	if _, _, err := a.restoreBackup(path); err == nil || !strings.Contains(err.Error(), "不匹配") {
+		t.Fatalf("restoreBackup() error = %v, want key mismatch", err)
+	}
+	if names := openedTokenNames(t, a); !reflect.DeepEqual(names, []string{"local"}) {
+		t.Errorf("tokens after rollback = %v, want [local]", names)
+	}
+	insertSealedTokens(t, a, "still-writable")
+	if names := openedTokenNames(t, a); len(names) != 2 {
+		t.Errorf("store not usable after rollback: %v", names)
+	}
+}
+
+// writeTestZip 写出一个只含 files 的 zip
+func writeTestZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "backup.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return p
+}
+
+func TestRestoreRejectsInvalidBackups(t *testing.T) {
+	a := newBackupTestApp(t, "pw")
+	insertSealedTokens(t, a, "keep")
+	manifest := func(m backupManifest) string {
+		b, _ := json.Marshal(m)
+		return string(b)
+	}
+	kind := a.store.Kind()
+	other := map[string]string{"sqlite": "json", "json": "sqlite"}[kind]
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"no manifest", map[string]string{"x.txt": "x"}, "缺少 manifest.json"},
+		{"foreign manifest", map[string]string{backupManifestName: manifest(backupManifest{App: "other", Storage: kind})}, "不是有效"},
+		{"other storage", map[string]string{backupManifestName: manifest(backupManifest{App: dataDirAppName, Storage: other})}, "无法恢复"},
+		{"newer schema", map[string]string{backupManifestName: manifest(backupManifest{App: dataDirAppName, Storage: kind, SchemaVersion: latestSchemaVersion() + 1})}, "请先升级"},
+		{"missing snapshot", map[string]string{backupManifestName: manifest(backupManifest{App: dataDirAppName, Storage: kind})}, "缺少"},
+		{"path traversal", map[string]string{
+			backupManifestName: manifest(backupManifest{App: dataDirAppName, Storage: kind}),
+			"../evil":          "x",
+		}, "非法的文件路径"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := a.restoreBackup(writeTestZip(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("restoreBackup() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if names := openedTokenNames(t, a); !reflect.DeepEqual(names, []string{"keep"}) {
+		t.Errorf("rejected restore changed data: %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(a.dataDir), "evil")); err == nil {
+		t.Error("path traversal entry was extracted")
+	}
+}
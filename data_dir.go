@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+)
+
+// 数据目录：accounts.db、secret.key、config.json、downloads/、updates/ 统一放在同一目录下，
+// 按 命令行 -data-dir > 环境变量 SORAPC_DATA_DIR > 系统默认目录 的顺序确定，不再依赖启动时的工作目录。
+// 系统默认目录：Linux 为 $XDG_DATA_HOME/sorapc（默认 ~/.local/share/sorapc），Windows 为 %AppData%\sorapc，
+// macOS 为 ~/Library/Application Support/sorapc。旧版本写在工作目录/程序目录下的数据会在首次启动时迁移过来。
+
+const (
+	dataDirEnv     = "SORAPC_DATA_DIR"
+	dataDirAppName = "sorapc"
+	dbFileName     = "accounts.db"
+)
+
+// legacyDataFiles 旧版本写在工作目录下、需要迁移到数据目录的文件与目录。
+// accounts.db 必须放在最后：数据目录中出现 accounts.db 即视为迁移完成，之后不再重试
+var legacyDataFiles = []string{secretKeyFile, "config.json", "downloads", dbFileName + "-wal", dbFileName + "-shm", dbFileName + "-journal", dbFileName}
+
+// resolveDataDir 返回数据目录的绝对路径及其来源（flag/env/default/cwd）
+func resolveDataDir(flagValue string) (string, string, error) {
+	dir, source := strings.TrimSpace(flagValue), "flag"
+	if dir == "" {
+		dir, source = strings.TrimSpace(os.Getenv(dataDirEnv)), "env"
+	}
+	if dir == "" {
+		dir, source = defaultDataDir(), "default"
+	}
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			wd = "."
+		}
+		dir, source = wd, "cwd"
+	}
+	if strings.HasPrefix(dir, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", source, fmt.Errorf("数据目录无效 %q: %v", dir, err)
+	}
+	return abs, source, nil
+}
+
+// defaultDataDir 系统默认的应用数据目录，无法确定时返回空串
+func defaultDataDir() string {
+	switch goruntime.GOOS {
+	case "windows", "darwin":
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, dataDirAppName)
+		}
+	default:
+		if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, dataDirAppName)
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "share", dataDirAppName)
+		}
+	}
+	return ""
+}
+
+// dataPath 返回数据目录下的路径；未设置数据目录时相对于工作目录
+func (a *App) dataPath(elem ...string) string {
+	return filepath.Join(append([]string{a.dataDir}, elem...)...)
+}
+
+// downloadsDir 视频下载目录
+func (a *App) downloadsDir() string {
+	return a.dataPath("downloads")
+}
+
+// prepareDataDir 创建数据目录，并在数据目录还没有数据库时迁移工作目录/程序目录下的旧数据
+func (a *App) prepareDataDir() {
+	if a.dataDir == "" {
+		return
+	}
+	if err := os.MkdirAll(a.dataDir, 0700); err != nil {
+		a.logError(fmt.Sprintf("创建数据目录失败: %v", err))
+		return
+	}
+	a.logInfo(fmt.Sprintf("[DataDir] 数据目录: %s（来源: %s）", a.dataDir, a.dataDirSource))
+	if _, err := os.Stat(a.dataPath(dbFileName)); err == nil {
+		return
+	}
+	for _, dir := range legacyDataDirs() {
+		if sameDir(dir, a.dataDir) {
+			continue
+		}
+		// 只迁移确实存放过本程序数据的目录（以 accounts.db 为准），避免误搬无关的 downloads 等目录
+		if _, err := os.Stat(filepath.Join(dir, dbFileName)); err != nil {
+			continue
+		}
+		moved, err := moveDataFiles(dir, a.dataDir)
+		if err != nil {
+			a.logError(fmt.Sprintf("[DataDir] 从 %s 迁移数据失败: %v", dir, err))
+			return
+		}
+		a.legacyDataDir = dir
+		a.logInfo(fmt.Sprintf("[DataDir] 已从 %s 迁移 %s", dir, strings.Join(moved, ", ")))
+		return
+	}
+}
+
+// legacyDataDirs 旧版本可能写入数据的目录：工作目录与程序所在目录
+func legacyDataDirs() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		if dir := filepath.Dir(exe); len(dirs) == 0 || !sameDir(dir, dirs[0]) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if goruntime.GOOS == "windows" {
+		return strings.EqualFold(absA, absB)
+	}
+	return absA == absB
+}
+
+// moveDataFiles 将 legacyDataFiles 从 src 移到 dst，目标已存在的项跳过；返回已迁移的项。
+// 任一项失败时把已迁移的项移回 src，下次启动会重新迁移
+func moveDataFiles(src, dst string) ([]string, error) {
+	var moved []string
+	for _, name := range legacyDataFiles {
+		from, to := filepath.Join(src, name), filepath.Join(dst, name)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			continue
+		}
+		if err := movePath(from, to); err != nil {
+			err = fmt.Errorf("%s: %v", name, err)
+			for i := len(moved) - 1; i >= 0; i-- {
+				if rerr := movePath(filepath.Join(dst, moved[i]), filepath.Join(src, moved[i])); rerr != nil {
+					err = fmt.Errorf("%v；回滚 %s 失败: %v", err, moved[i], rerr)
+				}
+			}
+			return nil, err
+		}
+		moved = append(moved, name)
+	}
+	return moved, nil
+}
+
+// movePath 优先 rename，跨磁盘时复制后删除源文件
+func movePath(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyPath(from, to); err != nil {
+		_ = os.RemoveAll(to)
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+func copyPath(from, to string) error {
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(to, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(from)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyPath(filepath.Join(from, e.Name()), filepath.Join(to, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// rewriteLegacyDownloadPaths 旧数据迁移后，把 video_downloads.local_path 中的旧 downloads 路径改为新路径
+func (a *App) rewriteLegacyDownloadPaths() {
+	if a.legacyDataDir == "" {
+		return
+	}
+	if n := a.rewriteDownloadPaths(filepath.Join(a.legacyDataDir, "downloads")); n > 0 {
+		a.logInfo(fmt.Sprintf("[DataDir] 已更新 %d 条下载记录的本地路径", n))
+	}
+}
+
+// rewriteDownloadPaths 把位于 oldDir 下的 local_path 改到当前下载目录，返回更新条数；
+// 两边的路径分隔符统一按 / 比较，从 Windows 备份恢复到其它系统时同样适用
+func (a *App) rewriteDownloadPaths(oldDir string) int {
+	if a.store == nil || oldDir == "" || sameDir(oldDir, a.downloadsDir()) {
+		return 0
+	}
+	list, err := a.store.ListDownloads()
+	if err != nil {
+		a.logError(fmt.Sprintf("[DataDir] 读取下载记录失败: %v", err))
+		return 0
+	}
+	prefix := strings.TrimRight(strings.ReplaceAll(oldDir, "\\", "/"), "/") + "/"
+	n := 0
+	for _, d := range list {
+		p := strings.ReplaceAll(d.LocalPath, "\\", "/")
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		d.LocalPath = filepath.Join(a.downloadsDir(), filepath.FromSlash(p[len(prefix):]))
+		if err := a.store.PutDownload(d); err != nil {
+			a.logError(fmt.Sprintf("[DataDir] 更新下载记录路径失败: %v", err))
+			return n
+		}
+		n++
+	}
+	return n
+}
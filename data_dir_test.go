@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	goruntime "runtime"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMoveDataFiles(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFiles(t, src, dbFileName, dbFileName+"-wal", secretKeyFile, "unrelated.txt")
+	if err := os.Mkdir(filepath.Join(src, "downloads"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, filepath.Join(src, "downloads"), "a.mp4")
+	writeTestFiles(t, dst, "config.json")
+
+	moved, err := moveDataFiles(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{secretKeyFile, "downloads", dbFileName + "-wal", dbFileName}
+	if !reflect.DeepEqual(moved, want) {
+		t.Errorf("moveDataFiles() = %v, want %v", moved, want)
+	}
+	for _, name := range append(want, "config.json", filepath.Join("downloads", "a.mp4")) {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Errorf("%s missing in dst: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(src, "unrelated.txt")); err != nil {
+		t.Errorf("unrelated file should stay in src: %v", err)
+	}
+}
+
+func TestMoveDataFilesRollback(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("needs symlinks")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFiles(t, src, dbFileName, secretKeyFile, "config.json")
+	if err := os.Mkdir(filepath.Join(src, "downloads"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// 目标 downloads 是指向不存在路径的符号链接：Stat 判定不存在，但目录既不能 rename 也不能创建
+	if err := os.Symlink(filepath.Join(dst, "missing"), filepath.Join(dst, "downloads")); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := moveDataFiles(src, dst)
+	if err == nil {
+		t.Fatalf("moveDataFiles() = %v, want error", moved)
+	}
+	for _, name := range []string{dbFileName, secretKeyFile, "config.json", "downloads"} {
+		if _, err := os.Stat(filepath.Join(src, name)); err != nil {
+			t.Errorf("%s should be back in src: %v", name, err)
+		}
+	}
+	for _, name := range []string{dbFileName, secretKeyFile, "config.json"} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err == nil {
+			t.Errorf("%s should not remain in dst", name)
+		}
+	}
+}
+
+func TestResolveDataDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	envDir := t.TempDir()
+	tests := []struct {
+		name       string
+		flag, env  string
+		wantDir    string
+		wantSource string
+	}{
+		{name: "flag wins", flag: "  /tmp/flag-dir ", env: envDir, wantDir: "/tmp/flag-dir", wantSource: "flag"},
+		{name: "env", env: envDir, wantDir: envDir, wantSource: "env"},
+		{name: "tilde expands", flag: "~/sorapc-data", wantDir: filepath.Join(home, "sorapc-data"), wantSource: "flag"},
+		{name: "default", wantDir: defaultDataDir(), wantSource: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(dataDirEnv, tt.env)
+			dir, source, err := resolveDataDir(tt.flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != filepath.Clean(tt.wantDir) || source != tt.wantSource {
+				t.Errorf("resolveDataDir(%q) = %q, %q; want %q, %q", tt.flag, dir, source, tt.wantDir, tt.wantSource)
+			}
+		})
+	}
+}
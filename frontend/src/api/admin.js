@@ -2,8 +2,14 @@ import { apiRequest, postJson } from './client'
 import { ApiRequestBlob } from '../../wailsjs/go/main/App'
 
 // Token Management
-export const fetchTokens = (page = 1, limit = 20) =>
-  apiRequest(`/api/tokens?page=${page}&limit=${limit}`)
+// filters: status(expired|expiring|valid|unknown)、within_hours、plan、group、q、sort(id|email|expiry|plan|error_count)、order(asc|desc)
+export const fetchTokens = (page = 1, limit = 20, filters = {}) => {
+  const params = new URLSearchParams({ page, limit })
+  Object.entries(filters).forEach(([k, v]) => {
+    if (v !== undefined && v !== null && v !== '') params.set(k, v)
+  })
+  return apiRequest(`/api/tokens?${params.toString()}`)
+}
 
 export const addToken = (data) => postJson('/api/tokens', data)
 
@@ -17,24 +23,58 @@ export const enableToken = (id) => postJson(`/api/tokens/${id}/enable`)
 export const disableToken = (id) => postJson(`/api/tokens/${id}/disable`)
 export const updateTokenStatus = (id, isActive) => postJson(`/api/tokens/${id}/status`, { is_active: isActive }, 'PUT')
 
-// Conversion
-export const convertST2AT = (st) => postJson('/api/tokens/st2at', { st })
-export const convertRT2AT = (rt, clientId) => postJson('/api/tokens/rt2at', { rt, client_id: clientId })
+// Conversion（tokenId 指定时换到的 AT 写回该账号；否则按 ST/RT 匹配已保存的账号）
+export const convertST2AT = (st, tokenId) => postJson('/api/tokens/st2at', { st, token_id: tokenId || 0 })
+export const convertRT2AT = (rt, clientId, tokenId) =>
+  postJson('/api/tokens/rt2at', { rt, client_id: clientId, token_id: tokenId || 0 })
+// 用账号保存的 RT（优先）或 ST 刷新 AT
+export const refreshTokenAT = (id) => postJson(`/api/tokens/${id}/refresh-at`)
 
 // Batch Operations
 // Backend expects { token_ids: [...] }
+// Batch：返回 { message, succeeded, failed, results: [{ id, success, message }] }，results 与 tokenIds 顺序一致
 export const batchTestUpdateTokens = (tokenIds) => postJson('/api/tokens/batch/test-update', { token_ids: tokenIds })
 export const batchDeleteTokens = (tokenIds) => postJson('/api/tokens/batch/delete-disabled', { token_ids: tokenIds })
 export const batchEnableTokens = (tokenIds) => postJson('/api/tokens/batch/enable-all', { token_ids: tokenIds })
 export const batchDisableTokens = (tokenIds) => postJson('/api/tokens/batch/disable-selected', { token_ids: tokenIds })
 export const batchUpdateProxy = (tokenIds, proxyUrl) => postJson('/api/tokens/batch/update-proxy', { token_ids: tokenIds, proxy_url: proxyUrl })
+export const batchAddGroup = (tokenIds, group) => postJson('/api/tokens/batch/add-group', { token_ids: tokenIds, group })
+export const batchRemoveGroup = (tokenIds, group) => postJson('/api/tokens/batch/remove-group', { token_ids: tokenIds, group })
+
+// 账号分组：返回 { groups: [{ name, total, active }], ungrouped }
+export const fetchTokenGroups = () => apiRequest('/api/tokens/groups')
+
+// 合并重复账号（相同 token 或邮箱）；dryRun 为 true 时只返回分组
+export const dedupeTokens = (dryRun = false) => postJson('/api/tokens/dedupe', { dry_run: dryRun })
 
 // Import/Export
-export const importTokens = (tokens, mode) => postJson('/api/tokens/import', { tokens, mode })
+// format: json | csv | txt；ids 为空时导出全部
+export const exportTokens = (format = 'json', ids = []) => {
+  const params = new URLSearchParams({ format })
+  if (ids.length) params.set('ids', ids.join(','))
+  return apiRequest(`/api/tokens/export?${params.toString()}`)
+}
+// mode: append（追加，跳过已有）| replace（替换全部）| merge（按邮箱/token 合并更新）
+export const importTokens = (content, format, mode) =>
+  postJson('/api/tokens/import', { content, format, mode })
 
 // Auto Refresh
 export const fetchATAutoRefreshConfig = () => apiRequest('/api/token-refresh/config')
-export const toggleATAutoRefresh = (enabled) => postJson('/api/token-refresh/enabled', { enabled })
+// refreshBeforeMinutes：AT 距过期不足该分钟数时自动刷新（省略则不修改）
+export const toggleATAutoRefresh = (enabled, refreshBeforeMinutes) =>
+  postJson('/api/token-refresh/enabled', { enabled, refresh_before_minutes: refreshBeforeMinutes })
+export const runATAutoRefresh = () => postJson('/api/token-refresh/run')
+
+// Health Check（run 在后台执行，结束后推送 token-health:summary 事件）
+export const fetchHealthCheckConfig = () => apiRequest('/api/health-check/config')
+export const updateHealthCheckConfig = (enabled, intervalMinutes, concurrency) =>
+  postJson('/api/health-check/config', { enabled, interval_minutes: intervalMinutes, concurrency })
+export const runHealthCheck = () => postJson('/api/health-check/run')
+export const fetchTokenHealth = (id) => apiRequest(`/api/tokens/${id}/health`)
+
+// Quota History（额度快照，仅 SQLite 存储下记录）
+export const fetchTokenSnapshots = (id, days = 14) => apiRequest(`/api/tokens/${id}/snapshots?days=${days}`)
+export const fetchQuotaStats = (days = 14) => apiRequest(`/api/stats/quota?days=${days}`)
 
 // System Settings
 export const fetchSettings = () => apiRequest('/api/admin/config') // Backend path is /api/admin/config
@@ -43,10 +83,22 @@ export const updateAdminPassword = (username, oldPassword, newPassword) =>
   postJson('/api/admin/password', { username, old_password: oldPassword, new_password: newPassword })
 export const updateAPIKey = (newAPIKey) => postJson('/api/admin/apikey', { new_api_key: newAPIKey })
 export const updateDebugConfig = (enabled) => postJson('/api/admin/debug', { enabled })
+export const fetchSchemaMigrations = () => apiRequest('/api/admin/migrations')
+
+// Backup & Restore（path 为空时备份到数据目录下的 backups/）
+export const backupData = (path, includeDownloads, includeKey) =>
+  postJson('/api/admin/backup', { path, include_downloads: includeDownloads, include_key: includeKey })
+export const restoreData = (path) => postJson('/api/admin/restore', { path })
 
 // Logs
-export const fetchLogs = (page = 1, limit = 50) =>
-  apiRequest(`/api/logs?page=${page}&limit=${limit}`)
+// filters: { token_id, method, source, status: 'success' | 'error' | code, q, since, until }
+export const fetchLogs = (page = 1, limit = 50, filters = {}) => {
+  const params = new URLSearchParams({ page, limit })
+  Object.entries(filters).forEach(([k, v]) => {
+    if (v !== undefined && v !== null && v !== '') params.set(k, v)
+  })
+  return apiRequest(`/api/logs?${params.toString()}`)
+}
 
 export const clearLogs = () => apiRequest('/api/logs', { method: 'DELETE' })
 
@@ -18,11 +18,24 @@ import {
   batchEnableTokens,
   batchDisableTokens,
   batchUpdateProxy,
+  batchAddGroup,
+  batchRemoveGroup,
+  fetchTokenGroups,
   importTokens,
+  dedupeTokens,
+  exportTokens,
   convertST2AT,
   convertRT2AT,
+  refreshTokenAT,
   fetchATAutoRefreshConfig,
   toggleATAutoRefresh,
+  runATAutoRefresh,
+  fetchHealthCheckConfig,
+  updateHealthCheckConfig,
+  runHealthCheck,
+  fetchTokenHealth,
+  fetchTokenSnapshots,
+  fetchQuotaStats,
   clearLogs,
   updateAdminPassword,
   updateAPIKey,
@@ -37,7 +50,10 @@ import {
   fetchGenerationTimeout,
   updateGenerationTimeout,
   downloadLogs,
-  cancelTask
+  cancelTask,
+  fetchSchemaMigrations,
+  backupData,
+  restoreData
 } from '../api/admin'
 
 export const useAdminStore = defineStore('admin', () => {
@@ -47,12 +63,20 @@ export const useAdminStore = defineStore('admin', () => {
   const currentPage = ref(1)
   const loadingTokens = ref(false)
   const pageSize = ref(20)
+  const tokenFilters = ref({ status: '', plan: '', group: '', q: '', sort: 'id', order: 'asc' })
+  const expiredTokens = ref(0)
+  const tokenGroups = ref([])
 
   const settings = ref({})
   const loadingSettings = ref(false)
 
   const logs = ref([])
   const loadingLogs = ref(false)
+  const logsTotal = ref(0)
+  const logsPage = ref(1)
+  const logsPageSize = ref(50)
+  const logsAvailable = ref(true) // JSON 文件存储下为 false
+  const logFilters = ref({ status: '', source: '', token_id: '', q: '' })
 
   const stats = ref({
     total: 0,
@@ -65,7 +89,12 @@ export const useAdminStore = defineStore('admin', () => {
     todayVideos: 0,
     totalVideos: 0,
     todayErrors: 0,
-    totalErrors: 0
+    totalErrors: 0,
+    runningTasks: 0,
+    failureRate: 0,
+    avgGenerationSeconds: 0,
+    daily: [],
+    perToken: []
   })
 
   // Helpers：从后端映射；邮箱/过期时间缺省时从 JWT 解析；剩余次数/恢复秒数来自 account/status
@@ -89,12 +118,17 @@ export const useAdminStore = defineStore('admin', () => {
       usage: t.sora2_remaining_count ?? t.estimated_num_videos_remaining ?? 0,
       limit: t.sora2_total_count ?? null,
       accessResetsInSeconds: t.access_resets_in_seconds ?? null,
+      cooldownUntil: t.cooldown_until ?? null,
       imageCount: t.image_count,
       videoCount: t.video_count,
       errorCount: t.error_count,
       errorMessage: t.error_message,
+      lastCheckAt: t.last_check_at ?? null,
+      lastCheckOk: t.last_check_ok ?? null,
+      lastCheckLatencyMs: t.last_check_latency_ms ?? null,
       remark: t.remark,
       proxyUrl: t.proxy_url,
+      groups: t.groups || [],
       imageEnabled: t.image_enabled,
       videoEnabled: t.video_enabled,
       imageConcurrency: t.image_concurrency,
@@ -109,6 +143,7 @@ export const useAdminStore = defineStore('admin', () => {
     client_id: t.clientId || null,
     proxy_url: t.proxyUrl || '',
     remark: t.remark || null,
+    groups: t.groups || [],
     image_enabled: t.imageEnabled,
     video_enabled: t.videoEnabled,
     image_concurrency: t.imageConcurrency,
@@ -119,7 +154,7 @@ export const useAdminStore = defineStore('admin', () => {
   const loadTokens = async (page = 1) => {
     loadingTokens.value = true
     try {
-      const response = await fetchTokens(page, pageSize.value)
+      const response = await fetchTokens(page, pageSize.value, tokenFilters.value)
       const payload = response?.data != null ? response.data : response
       if (Array.isArray(payload)) {
           const start = (page - 1) * pageSize.value
@@ -129,6 +164,7 @@ export const useAdminStore = defineStore('admin', () => {
       } else if (payload && Array.isArray(payload.result)) {
           tokens.value = payload.result.map(mapTokenFromBackend)
           totalTokens.value = payload.total ?? 0
+          expiredTokens.value = payload.expired_total ?? 0
       } else {
         tokens.value = []
         totalTokens.value = 0
@@ -146,13 +182,13 @@ export const useAdminStore = defineStore('admin', () => {
     if (statusResponse != null && statusResponse !== '') {
       payload.status_response = statusResponse
     }
-    await addToken(payload)
-    await loadTokens(currentPage.value)
+    ensureSaved(await addToken(payload))
+    await Promise.all([loadTokens(currentPage.value), loadTokenGroups()])
   }
 
   const editToken = async (id, tokenData) => {
-    await updateToken(id, mapTokenToBackend(tokenData))
-    await loadTokens(currentPage.value)
+    ensureSaved(await updateToken(id, mapTokenToBackend(tokenData)))
+    await Promise.all([loadTokens(currentPage.value), loadTokenGroups()])
   }
 
   const removeToken = async (id) => {
@@ -176,42 +212,84 @@ export const useAdminStore = defineStore('admin', () => {
 
   // Batch
   const batchCheckTokens = async (ids) => {
-    await batchTestUpdateTokens(ids)
+    const payload = ensureSaved(await batchTestUpdateTokens(ids))
     await loadTokens(currentPage.value)
+    return payload
   }
 
   const handleBatchEnable = async (ids) => {
-    await batchEnableTokens(ids)
+    const payload = ensureSaved(await batchEnableTokens(ids))
     await loadTokens(currentPage.value)
+    return payload
   }
 
   const handleBatchDisable = async (ids) => {
-    await batchDisableTokens(ids)
+    const payload = ensureSaved(await batchDisableTokens(ids))
     await loadTokens(currentPage.value)
+    return payload
   }
 
   const handleBatchDelete = async (ids) => {
-    await batchDeleteTokens(ids)
+    const payload = ensureSaved(await batchDeleteTokens(ids))
     await loadTokens(currentPage.value)
+    return payload
   }
 
   const handleBatchProxy = async (ids, proxyUrl) => {
-      await batchUpdateProxy(ids, proxyUrl)
+      const payload = ensureSaved(await batchUpdateProxy(ids, proxyUrl))
       await loadTokens(currentPage.value)
+      return payload
+  }
+
+  const handleBatchGroup = async (ids, group, remove = false) => {
+      const payload = ensureSaved(await (remove ? batchRemoveGroup(ids, group) : batchAddGroup(ids, group)))
+      await Promise.all([loadTokens(currentPage.value), loadTokenGroups()])
+      return payload
+  }
+
+  const loadTokenGroups = async () => {
+    try {
+      const res = await fetchTokenGroups()
+      const data = res?.data != null ? res.data : res
+      if (data?.success) tokenGroups.value = data.groups || []
+    } catch (e) {
+      console.error('Failed to load token groups', e)
+    }
+  }
+
+  const handleDedupeTokens = async (dryRun) => {
+      const payload = ensureSaved(await dedupeTokens(dryRun))
+      if (!dryRun) await loadTokens(currentPage.value)
+      return payload
   }
 
   // Import/Convert
-  const handleImportTokens = async (data, mode) => {
-      return await importTokens(data, mode)
+  // 返回后端结果（含 success/message），失败时由组件提示
+  const handleImportTokens = async (content, format, mode) => {
+      const res = await importTokens(content, format, mode)
+      return res?.data != null ? res.data : res
       // We often reload tokens after this in the component
   }
 
-  const convertST = async (st) => {
-      return await convertST2AT(st)
+  const handleExportTokens = async (format, ids = []) => {
+      const res = await exportTokens(format, ids)
+      return res?.data != null ? res.data : res
+  }
+
+  const convertST = async (st, tokenId) => {
+      const res = await convertST2AT(st, tokenId)
+      return res?.data != null ? res.data : res
+  }
+
+  const convertRT = async (rt, clientId, tokenId) => {
+      const res = await convertRT2AT(rt, clientId, tokenId)
+      return res?.data != null ? res.data : res
   }
 
-  const convertRT = async (rt, clientId) => {
-      return await convertRT2AT(rt, clientId)
+  const refreshAT = async (id) => {
+      const payload = ensureSaved(await refreshTokenAT(id))
+      await loadTokens(currentPage.value)
+      return payload
   }
 
 
@@ -229,6 +307,11 @@ export const useAdminStore = defineStore('admin', () => {
       videoTimeout: s.video_timeout,
       debugEnabled: s.debug_enabled,
       watermarkEnabled: s.watermark_enabled !== false, // Default true if missing? or s.watermark_enabled
+      tokenSelectionStrategy: s.token_selection_strategy || 'random',
+      tokenSelectionStrategies: s.token_selection_strategies || [],
+      authSessionUrl: s.auth_session_url || '',
+      authTokenUrl: s.auth_token_url || '',
+      authClientId: s.auth_client_id || '',
       // API Key usually not returned or masked
   })
 
@@ -244,6 +327,10 @@ export const useAdminStore = defineStore('admin', () => {
       ...(s.videoTimeout !== undefined && { video_timeout: s.videoTimeout }),
       ...(s.debugEnabled !== undefined && { debug_enabled: s.debugEnabled }),
       ...(s.watermarkEnabled !== undefined && { watermark_enabled: s.watermarkEnabled }),
+      ...(s.tokenSelectionStrategy !== undefined && { token_selection_strategy: s.tokenSelectionStrategy }),
+      ...(s.authSessionUrl !== undefined && { auth_session_url: s.authSessionUrl }),
+      ...(s.authTokenUrl !== undefined && { auth_token_url: s.authTokenUrl }),
+      ...(s.authClientId !== undefined && { auth_client_id: s.authClientId }),
 
       // Special cases for password/apikey if passed here, though usually separate endpoints
       ...(s.apiKey && { new_api_key: s.apiKey }),
@@ -263,14 +350,16 @@ export const useAdminStore = defineStore('admin', () => {
         watermarkRes,
         cacheRes,
         timeoutRes,
-        atRefreshRes
+        atRefreshRes,
+        healthRes
       ] = await Promise.all([
         fetchSettings(),
         fetchProxyConfig(),
         fetchWatermarkConfig(),
         fetchCacheConfig(),
         fetchGenerationTimeout(),
-        fetchATAutoRefreshConfig()
+        fetchATAutoRefreshConfig(),
+        fetchHealthCheckConfig()
       ])
       const unwrap = (r) => (r?.data != null ? r.data : r)
       const general = unwrap(generalRes)
@@ -279,6 +368,7 @@ export const useAdminStore = defineStore('admin', () => {
       const cache = unwrap(cacheRes)
       const timeout = unwrap(timeoutRes)
       const atRefresh = unwrap(atRefreshRes)
+      const health = unwrap(healthRes)
 
       settings.value = {
         ...mapSettingsFromBackend(general || {}),
@@ -294,8 +384,15 @@ export const useAdminStore = defineStore('admin', () => {
         cacheEffectiveUrl: cache?.config?.effective_base_url ?? '',
         imageTimeout: timeout?.config?.image_timeout ?? 300,
         videoTimeout: timeout?.config?.video_timeout ?? 1500,
-        atAutoRefreshEnabled: atRefresh?.config?.at_auto_refresh_enabled ?? false
+        atAutoRefreshEnabled: atRefresh?.config?.at_auto_refresh_enabled ?? false,
+        atRefreshBeforeMinutes: atRefresh?.config?.at_refresh_before_minutes ?? 60,
+        atRefreshLastRun: atRefresh?.config?.last_run ?? null,
+        healthCheckEnabled: health?.config?.enabled ?? false,
+        healthCheckInterval: health?.config?.interval_minutes ?? 60,
+        healthCheckConcurrency: health?.config?.concurrency ?? 4,
+        healthCheckLastRun: health?.config?.last_run ?? null
       }
+      healthLastRun.value = settings.value.healthCheckLastRun
 
       // Update local ref as well
       atAutoRefreshEnabled.value = settings.value.atAutoRefreshEnabled
@@ -307,14 +404,23 @@ export const useAdminStore = defineStore('admin', () => {
     }
   }
 
+  // 后端校验失败时返回 { success: false, message }，转成异常交给调用方提示
+  const ensureSaved = (res) => {
+      const payload = res?.data != null ? res.data : res
+      if (payload && payload.success === false) {
+          throw new Error(payload.message || '保存失败')
+      }
+      return payload
+  }
+
   const saveSettings = async (newSettings) => {
       // General config (ban threshold, debug)
-      await updateSettings(mapSettingsToBackend(newSettings))
+      ensureSaved(await updateSettings(mapSettingsToBackend(newSettings)))
       await loadSettings()
   }
 
   const saveProxyConfig = async (enabled, url) => {
-      await updateProxyConfig(enabled, url)
+      ensureSaved(await updateProxyConfig(enabled, url))
       await loadSettings()
   }
 
@@ -324,15 +430,15 @@ export const useAdminStore = defineStore('admin', () => {
   }
 
   const saveCacheConfig = async (enabled, timeout, baseUrl) => {
-      await updateCacheEnabled(enabled)
+      ensureSaved(await updateCacheEnabled(enabled))
       // Only update timeout/url if they are valid or we want to update them
-      if (timeout !== undefined) await updateCacheTimeout(timeout)
-      if (baseUrl !== undefined) await updateCacheBaseUrl(baseUrl)
+      if (timeout !== undefined) ensureSaved(await updateCacheTimeout(timeout))
+      if (baseUrl !== undefined) ensureSaved(await updateCacheBaseUrl(baseUrl))
       await loadSettings()
   }
 
   const saveGenerationTimeout = async (image, video) => {
-      await updateGenerationTimeout(image, video)
+      ensureSaved(await updateGenerationTimeout(image, video))
       await loadSettings()
   }
 
@@ -360,13 +466,17 @@ export const useAdminStore = defineStore('admin', () => {
   const loadLogs = async (page = 1) => {
     loadingLogs.value = true
     try {
-      const result = await fetchLogs(page)
+      const result = await fetchLogs(page, logsPageSize.value, logFilters.value)
       const payload = result?.data != null ? result.data : result
       if (Array.isArray(payload)) {
          logs.value = payload
+         logsTotal.value = payload.length
       } else {
          logs.value = payload?.logs || []
+         logsTotal.value = payload?.total ?? logs.value.length
+         logsAvailable.value = payload?.available !== false
       }
+      logsPage.value = page
     } catch (e) {
       console.error('Failed to load logs', e)
     } finally {
@@ -393,7 +503,13 @@ export const useAdminStore = defineStore('admin', () => {
               todayVideos: data.today_videos || 0,
               totalVideos: data.total_videos || 0,
               todayErrors: data.today_errors || 0,
-              totalErrors: data.total_errors || 0
+              totalErrors: data.total_errors || 0,
+              errorsAvailable: data.errors_available !== false,
+              runningTasks: data.running_tasks || 0,
+              failureRate: data.failure_rate || 0,
+              avgGenerationSeconds: data.avg_generation_seconds || 0,
+              daily: data.daily || [],
+              perToken: data.per_token || []
           }
       }
     } catch (e) {
@@ -401,6 +517,28 @@ export const useAdminStore = defineStore('admin', () => {
     }
   }
 
+  // Actions - Schema migrations（数据库版本及启动时的迁移错误）
+  const schemaInfo = ref(null)
+
+  const loadSchemaInfo = async () => {
+    try {
+      const res = await fetchSchemaMigrations()
+      schemaInfo.value = res?.data != null ? res.data : res
+    } catch (e) {
+      console.error('Failed to load schema migrations', e)
+    }
+  }
+
+  // Actions - Backup & Restore（失败时抛出后端返回的 message）
+  const createBackup = async (path, includeDownloads, includeKey) =>
+      ensureSaved(await backupData(path, includeDownloads, includeKey))
+
+  const restoreBackup = async (path) => {
+      const payload = ensureSaved(await restoreData(path))
+      await Promise.all([loadSettings(), loadSchemaInfo()])
+      return payload
+  }
+
   // Actions - Auto Refresh
   const atAutoRefreshEnabled = ref(false)
 
@@ -416,6 +554,65 @@ export const useAdminStore = defineStore('admin', () => {
     }
   }
 
+  // 设置页保存开关与提前量，失败时抛出后端返回的 message
+  const saveATRefreshConfig = async (enabled, refreshBeforeMinutes) => {
+      ensureSaved(await toggleATAutoRefresh(enabled, refreshBeforeMinutes))
+      atAutoRefreshEnabled.value = enabled
+  }
+
+  // 立即执行一轮自动刷新（忽略失败重试间隔），返回本轮结果
+  const runATRefreshNow = async () => {
+      const payload = ensureSaved(await runATAutoRefresh())
+      await Promise.all([loadSettings(), loadTokens(currentPage.value)])
+      return payload.result
+  }
+
+  // Actions - Quota History：账号池每日消耗与耗尽预估；单个账号的快照序列
+  const quota = ref(null)
+
+  const loadQuota = async (days = 14) => {
+    try {
+      const res = await fetchQuotaStats(days)
+      const data = res?.data != null ? res.data : res
+      if (data?.success) quota.value = data
+    } catch (e) {
+      console.error('Failed to load quota stats', e)
+    }
+  }
+
+  const loadTokenSnapshots = async (id, days = 14) => ensureSaved(await fetchTokenSnapshots(id, days))
+
+  // Actions - Health Check
+  const healthLastRun = ref(null)
+  const healthCheckRunning = ref(false)
+
+  const saveHealthCheckConfig = async (enabled, intervalMinutes, concurrency) => {
+      ensureSaved(await updateHealthCheckConfig(enabled, intervalMinutes, concurrency))
+  }
+
+  // 后台开始一轮检查，结果由 onHealthSummary 处理
+  const startHealthCheck = async () => {
+      ensureSaved(await runHealthCheck())
+      healthCheckRunning.value = true
+  }
+
+  const loadTokenHealth = async (id) => {
+      const payload = ensureSaved(await fetchTokenHealth(id))
+      return payload.history || []
+  }
+
+  // 定时或手动检查结束后刷新列表与统计
+  const onHealthSummary = (summary) => {
+      healthLastRun.value = summary
+      healthCheckRunning.value = false
+      loadTokens(currentPage.value)
+      loadStats()
+      loadQuota()
+  }
+  if (window.runtime?.EventsOn) {
+      window.runtime.EventsOn('token-health:summary', onHealthSummary)
+  }
+
   const setATAutoRefresh = async (enabled) => {
     try {
       const response = await toggleATAutoRefresh(enabled)
@@ -437,6 +634,10 @@ export const useAdminStore = defineStore('admin', () => {
     currentPage,
     loadingTokens,
     pageSize,
+    tokenFilters,
+    tokenGroups,
+    loadTokenGroups,
+    expiredTokens,
 
     loadTokens,
     createToken,
@@ -450,10 +651,14 @@ export const useAdminStore = defineStore('admin', () => {
     batchDisableTokens: handleBatchDisable,
     batchDeleteTokens: handleBatchDelete,
     batchUpdateProxy: handleBatchProxy,
+    batchUpdateGroup: handleBatchGroup,
+    dedupeTokens: handleDedupeTokens,
 
     importTokens: handleImportTokens,
+    exportTokens: handleExportTokens,
     convertST2AT: convertST,
     convertRT2AT: convertRT,
+    refreshAT,
 
     settings,
     loadingSettings,
@@ -470,14 +675,35 @@ export const useAdminStore = defineStore('admin', () => {
 
     logs,
     loadingLogs,
+    logsTotal,
+    logsPage,
+    logsPageSize,
+    logsAvailable,
+    logFilters,
     loadLogs,
     clearAllLogs,
 
     stats,
     loadStats,
+    quota,
+    loadQuota,
+    loadTokenSnapshots,
+
+    schemaInfo,
+    loadSchemaInfo,
+    createBackup,
+    restoreBackup,
 
     atAutoRefreshEnabled,
     loadATAutoRefreshConfig,
-    setATAutoRefresh
+    setATAutoRefresh,
+    saveATRefreshConfig,
+    runATRefreshNow,
+
+    healthLastRun,
+    healthCheckRunning,
+    saveHealthCheckConfig,
+    startHealthCheck,
+    loadTokenHealth
   }
 })
@@ -13,179 +13,6 @@ const fileToDataUrl = (file) => {
   })
 }
 
-const isTokenInvalidatedError = (err) => {
-  const msg = (err && err.message) ? err.message : String(err || '')
-  return msg.includes('token_invalidated') || msg.includes('Please try signing in again') || msg.includes('signing in again')
-}
-
-const getErrorMessage = (payload) => {
-  if (!payload) return ''
-  if (typeof payload === 'string') return payload
-  if (payload.error) {
-    if (typeof payload.error === 'string') return payload.error
-    if (payload.error.message) return payload.error.message
-    // 如果 error 是对象，尝试提取嵌套的错误信息
-    if (typeof payload.error === 'object') {
-      const nested = payload.error.error
-      if (nested && nested.message) return nested.message
-      if (nested && nested.code) return nested.code
-    }
-  }
-  return ''
-}
-
-// 检查响应中是否包含 token 失效错误（包括嵌套的 JSON 字符串）
-const checkTokenInvalidatedInResponse = (parsed, rawText) => {
-  console.log('[checkTokenInvalidated] 开始检查，rawText 类型:', typeof rawText, 'parsed 类型:', typeof parsed)
-  
-  // 首先检查原始文本（整个响应字符串）- 这是最可靠的方法
-  if (rawText) {
-    const rawStr = String(rawText)
-    console.log('[checkTokenInvalidated] rawText 前500字符:', rawStr.substring(0, 500))
-    const hasTokenInvalidated = rawStr.includes('token_invalidated')
-    const hasSigningInAgain = rawStr.includes('Please try signing in again') || rawStr.includes('signing in again')
-    console.log('[checkTokenInvalidated] rawText 检查结果 - token_invalidated:', hasTokenInvalidated, 'signing in again:', hasSigningInAgain)
-    if (hasTokenInvalidated || hasSigningInAgain) {
-      console.log('[checkTokenInvalidated] ✅ 在 rawText 中发现 token_invalidated，返回 true')
-      return true
-    }
-  }
-  
-  // 检查解析后的错误信息
-  if (parsed) {
-    console.log('[checkTokenInvalidated] parsed:', parsed)
-    // 如果 parsed.error 是字符串，直接检查
-    if (parsed.error && typeof parsed.error === 'string') {
-      const errorStr = parsed.error
-      console.log('[checkTokenInvalidated] parsed.error 前500字符:', errorStr.substring(0, 500))
-      const hasTokenInvalidated = errorStr.includes('token_invalidated')
-      const hasSigningInAgain = errorStr.includes('Please try signing in again') || errorStr.includes('signing in again')
-      console.log('[checkTokenInvalidated] parsed.error 检查结果 - token_invalidated:', hasTokenInvalidated, 'signing in again:', hasSigningInAgain)
-      if (hasTokenInvalidated || hasSigningInAgain) {
-        console.log('[checkTokenInvalidated] ✅ 在 parsed.error 字符串中发现 token_invalidated，返回 true')
-        return true
-      }
-    }
-    
-    // 使用 getErrorMessage 提取错误信息
-    const errMsg = getErrorMessage(parsed)
-    if (errMsg) {
-      const errStr = String(errMsg)
-      console.log('[checkTokenInvalidated] errMsg 前500字符:', errStr.substring(0, 500))
-      const hasTokenInvalidated = errStr.includes('token_invalidated')
-      const hasSigningInAgain = errStr.includes('Please try signing in again') || errStr.includes('signing in again')
-      console.log('[checkTokenInvalidated] errMsg 检查结果 - token_invalidated:', hasTokenInvalidated, 'signing in again:', hasSigningInAgain)
-      if (hasTokenInvalidated || hasSigningInAgain) {
-        console.log('[checkTokenInvalidated] ✅ 在 errMsg 中发现 token_invalidated，返回 true')
-        return true
-      }
-    }
-  }
-  
-  console.log('[checkTokenInvalidated] ❌ 未发现 token_invalidated，返回 false')
-  return false
-}
-
-const parsePendingBody = (body) => {
-  const rawText = typeof body === 'string' ? body : JSON.stringify(body)
-  if (typeof body !== 'string') return { rawText, parsed: body }
-  try {
-    return { rawText, parsed: JSON.parse(body) }
-  } catch {
-    return { rawText, parsed: null }
-  }
-}
-
-const getTokenEmailById = async (tokenId) => {
-  if (tokenId == null || !window.go?.main?.App?.GetTokenEmailByID) return ''
-  try {
-    const res = await window.go.main.App.GetTokenEmailByID(tokenId)
-    const data = typeof res === 'string' ? JSON.parse(res) : res
-    return data?.email || ''
-  } catch {
-    return ''
-  }
-}
-
-// 统一的 token 失效处理函数
-const handleTokenInvalidated = async (tokenId, intervalKey, pendingIntervals, updateTaskFn, taskIdOrRemoteId, addLogFn, isOrphan = false) => {
-  console.log('[handleTokenInvalidated] 开始处理，tokenId:', tokenId, 'intervalKey:', intervalKey)
-  const email = await getTokenEmailById(tokenId)
-  console.log('[handleTokenInvalidated] 获取到的邮箱:', email)
-  const who = email ? `${email}` : (tokenId != null ? `token_id=${tokenId}` : '未知账号')
-  const message = `账号失效（${who}），停止 pending`
-  console.log('[handleTokenInvalidated] 错误信息:', message)
-  
-  if (tokenId != null && window.go?.main?.App?.SetTokenError) {
-    try {
-      console.log('[handleTokenInvalidated] 调用 SetTokenError，tokenId:', tokenId, 'message:', message)
-      await window.go.main.App.SetTokenError(tokenId, message)
-      console.log('[handleTokenInvalidated] SetTokenError 成功')
-    } catch (e) {
-      console.error('[handleTokenInvalidated] SetTokenError 失败:', e)
-      // ignore: don't block pending stop on error persistence
-    }
-  }
-  
-  const logPrefix = isOrphan ? `孤儿任务 ${taskIdOrRemoteId}` : `Task ${taskIdOrRemoteId}`
-  const logMessage = `${logPrefix} pending 失败: ${message}`
-  console.log('[handleTokenInvalidated]', logMessage)
-  if (addLogFn) {
-    addLogFn(logMessage, 'warning')
-  }
-  
-  const id = pendingIntervals.get(intervalKey)
-  console.log('[handleTokenInvalidated] 清除 interval，id:', id, 'intervalKey:', intervalKey)
-  if (id != null) clearInterval(id)
-  pendingIntervals.delete(intervalKey)
-  console.log('[handleTokenInvalidated] 已清除 interval')
-  
-  if (updateTaskFn && !isOrphan) {
-    console.log('[handleTokenInvalidated] 更新任务状态为 failed')
-    updateTaskFn(taskIdOrRemoteId, { status: 'failed', message })
-  }
-  
-  console.log('[handleTokenInvalidated] 处理完成')
-  return { stopped: true, message }
-}
-
-const getVideoRequestParams = (modelValue) => {
-  const table = {
-    'sora2-landscape-10s':        { orientation: 'landscape', nFrames: '300', soraModel: 'sy_8',  size: 'small', requirePro: false },
-    'sora2-portrait-10s':         { orientation: 'portrait',  nFrames: '300', soraModel: 'sy_8',  size: 'small', requirePro: false },
-    'sora2-landscape-15s':        { orientation: 'landscape', nFrames: '450', soraModel: 'sy_8',  size: 'small', requirePro: false },
-    'sora2-portrait-15s':         { orientation: 'portrait',  nFrames: '450', soraModel: 'sy_8',  size: 'small', requirePro: false },
-    'sora2-landscape-25s':        { orientation: 'landscape', nFrames: '750', soraModel: 'sy_8',  size: 'small', requirePro: true  },
-    'sora2-portrait-25s':         { orientation: 'portrait',  nFrames: '750', soraModel: 'sy_8',  size: 'small', requirePro: true  },
-
-    'sora2pro-landscape-10s':     { orientation: 'landscape', nFrames: '300', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-    'sora2pro-portrait-10s':      { orientation: 'portrait',  nFrames: '300', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-    'sora2pro-landscape-15s':     { orientation: 'landscape', nFrames: '450', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-    'sora2pro-portrait-15s':      { orientation: 'portrait',  nFrames: '450', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-    'sora2pro-landscape-25s':     { orientation: 'landscape', nFrames: '750', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-    'sora2pro-portrait-25s':      { orientation: 'portrait',  nFrames: '750', soraModel: 'sy_ore', size: 'small', requirePro: true  },
-
-    'sora2pro-hd-landscape-10s':  { orientation: 'landscape', nFrames: '300', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-    'sora2pro-hd-portrait-10s':   { orientation: 'portrait',  nFrames: '300', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-    'sora2pro-hd-landscape-15s':  { orientation: 'landscape', nFrames: '450', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-    'sora2pro-hd-portrait-15s':   { orientation: 'portrait',  nFrames: '450', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-    // 25s Pro HD not listed in 说明.md; assuming same pattern with 750 frames and large size.
-    'sora2pro-hd-landscape-25s':  { orientation: 'landscape', nFrames: '750', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-    'sora2pro-hd-portrait-25s':   { orientation: 'portrait',  nFrames: '750', soraModel: 'sy_ore', size: 'large', requirePro: true  },
-  }
-
-  if (table[modelValue]) return table[modelValue]
-
-  const orientation = modelValue.includes('portrait') ? 'portrait' : 'landscape'
-  const nFrames = modelValue.includes('25s') ? '750' : (modelValue.includes('15s') ? '450' : '300')
-  const requirePro = modelValue.startsWith('sora2pro-') || modelValue.startsWith('sora2pro-hd-') || modelValue.includes('25s')
-  const soraModel = modelValue.startsWith('sora2pro-') || modelValue.startsWith('sora2pro-hd-') ? 'sy_ore' : 'sy_8'
-  const size = modelValue.startsWith('sora2pro-hd-') ? 'large' : 'small'
-  return { orientation, nFrames, soraModel, size, requirePro }
-}
-
-
-
 export const useGenerateStore = defineStore('generate', () => {
   // ========== Configuration ==========
   const apiKey = ref(localStorage.getItem('sora_api_key') || '')
@@ -254,6 +81,20 @@ export const useGenerateStore = defineStore('generate', () => {
     { value: 'sora2pro-hd-portrait-10s', label: '竖屏视频 10s (Pro HD)', group: 'Pro HD版视频' },
   ]
 
+  // Go 侧模型目录（GetVideoModels）：每个模型需要的账号类型与当前可用账号数；非 Wails 环境为空
+  const videoModels = ref([])
+  const loadVideoModels = async () => {
+      if (!window.go?.main?.App?.GetVideoModels) return
+      try {
+          const res = await window.go.main.App.GetVideoModels()
+          const data = typeof res === 'string' ? JSON.parse(res) : res
+          if (data?.success && Array.isArray(data.models)) videoModels.value = data.models
+      } catch (e) {
+          console.warn('Load video models failed', e)
+      }
+  }
+  const videoModelInfo = (name) => videoModels.value.find(m => m.name === name) || null
+
   const modelGroups = computed(() => {
     const groups = {}
     models.forEach(m => {
@@ -371,287 +212,39 @@ export const useGenerateStore = defineStore('generate', () => {
 
   // ========== Execution ==========
   const abortControllers = new Map() // taskId -> AbortController
-  const pendingIntervals = new Map() // taskId -> intervalId（CreateVideo 成功后每 10s 轮询 pending）
-
-  // 为指定任务启动 pending 轮询（每 10s 一次，用该任务记录的账号）；页面加载时可为未完成任务恢复轮询
-  const startPendingInterval = (taskId) => {
-     if (!window.go?.main?.App?.PollPending || pendingIntervals.has(taskId)) return
-     const apiBase = baseUrl.value.replace(/\/$/, '')
-     const POLL_INTERVAL_MS = 10 * 1000
-     const tick = async () => {
-         const current = tasks.value.find(x => x.id === taskId)
-         if (!current || current.status === 'done' || current.status === 'failed') {
-             const id = pendingIntervals.get(taskId)
-             if (id != null) clearInterval(id)
-             pendingIntervals.delete(taskId)
-             return
-         }
-         let tokenId = current.tokenIdForPending
-         try {
-             let bearer = ''
-             // 若任务未记录 tokenIdForPending（如旧任务或刷新前创建的），从 video_task_results 按 remoteTaskId 查 token_id
-             if (tokenId == null && current.remoteTaskId && window.go?.main?.App?.GetTokenIDByRemoteTaskID) {
-                 const res = await window.go.main.App.GetTokenIDByRemoteTaskID(current.remoteTaskId)
-                 const data = typeof res === 'string' ? JSON.parse(res) : res
-                 if (data?.token_id != null) {
-                     tokenId = data.token_id
-                     updateTask(taskId, { tokenIdForPending: tokenId })
-                 }
-             }
-             if (tokenId != null && window.go?.main?.App?.GetBearerByTokenID) {
-                 const res = await window.go.main.App.GetBearerByTokenID(tokenId)
-                 const data = typeof res === 'string' ? JSON.parse(res) : res
-                 if (data?.bearer_token) bearer = data.bearer_token
-             }
-             if (!bearer) {
-                 addLog(`Task ${taskId} 无法获取账号 bearer，跳过 pending`, 'warning')
-                 return
-             }
-             const body = await window.go.main.App.PollPending(apiBase, bearer)
-            console.log('[startPendingInterval] PollPending 返回:', typeof body, body?.substring?.(0, 200) || body)
-            const { rawText, parsed } = parsePendingBody(body)
-            console.log('[startPendingInterval] parsed:', parsed, 'rawText 前200字符:', rawText?.substring?.(0, 200))
-            const isInvalid = checkTokenInvalidatedInResponse(parsed, rawText)
-            console.log('[startPendingInterval] checkTokenInvalidatedInResponse 结果:', isInvalid)
-            if (isInvalid) {
-                console.log('[startPendingInterval] 检测到 token 失效，调用 handleTokenInvalidated')
-                await handleTokenInvalidated(tokenId, taskId, pendingIntervals, updateTask, taskId, addLog, false)
-                return
-            }
-            const list = parsed ?? {}
-            const tasksArr = Array.isArray(list) ? list : (list?.tasks || [])
-            const remoteId = current.remoteTaskId
-            const matched = remoteId ? tasksArr.find(t => t?.id === remoteId || t?.task_id === remoteId) : null
-
-            // 如果 pending 中不存在该任务（即已完成/消失），才去拉取 drafts
-            if (!matched) {
-                 const id = pendingIntervals.get(taskId)
-                 if (id != null) clearInterval(id)
-                 pendingIntervals.delete(taskId)
-                 if (remoteId && window.go?.main?.App?.UpdateVideoTaskProgress) {
-                     window.go.main.App.UpdateVideoTaskProgress(remoteId, 100).catch(() => {})
-                 }
-                updateTask(taskId, { status: 'done', progress: 100, message: '已完成（pending 中不存在该任务）' })
-                addLog(`Task ${taskId} pending 中不存在该任务，转入 drafts`, 'info')
-                 // pending 返回空后执行 drafts，仅下载该 task_id 对应的那条
-                 if (window.go?.main?.App?.FetchDrafts && window.go?.main?.App?.SaveDraftsAndDownload) {
-                     try {
-                         const draftsBody = await window.go.main.App.FetchDrafts(apiBase, bearer)
-                         const draftsJson = typeof draftsBody === 'string' ? draftsBody : JSON.stringify(draftsBody)
-                         await window.go.main.App.SaveDraftsAndDownload(draftsJson, remoteId)
-                         addLog(`Task ${taskId} 已拉取 drafts 并下载`, 'info')
-                     } catch (e) {
-                         addLog(`Task ${taskId} drafts 拉取/下载失败: ${e?.message || e}`, 'warning')
-                     }
-                 }
-                 return
-             }
-            // 有任务：取当前任务的 progress
-            const rawPct = matched.progress_pct != null ? matched.progress_pct : 0
-             // progress_pct 可能是 0-1 的小数或 0-100 的整数
-             const pct = rawPct <= 1 ? rawPct * 100 : rawPct
-             if (remoteId && window.go?.main?.App?.UpdateVideoTaskProgress) {
-                 window.go.main.App.UpdateVideoTaskProgress(remoteId, pct).catch(() => {})
-             }
-             updateTask(taskId, { progress: pct, message: `pending 进度 ${pct.toFixed(0)}%` })
-             
-             // 如果进度达到 100%（progress_pct >= 1 或 >= 100），停止轮询并去草稿箱拉取
-            if (rawPct >= 1 || pct >= 100) {
-                 const id = pendingIntervals.get(taskId)
-                 if (id != null) clearInterval(id)
-                 pendingIntervals.delete(taskId)
-                updateTask(taskId, { status: 'done', progress: 100, message: '已完成（progress_pct=100%）' })
-                 addLog(`Task ${taskId} progress_pct=100%，任务完成，去草稿箱拉取`, 'info')
-                 // 去草稿箱拉取结果
-                 if (window.go?.main?.App?.FetchDrafts && window.go?.main?.App?.SaveDraftsAndDownload) {
-                     try {
-                         const draftsBody = await window.go.main.App.FetchDrafts(apiBase, bearer)
-                         const draftsJson = typeof draftsBody === 'string' ? draftsBody : JSON.stringify(draftsBody)
-                         await window.go.main.App.SaveDraftsAndDownload(draftsJson, remoteId)
-                         addLog(`Task ${taskId} 已拉取 drafts 并下载`, 'info')
-                     } catch (e) {
-                         addLog(`Task ${taskId} drafts 拉取/下载失败: ${e?.message || e}`, 'warning')
-                     }
-                 }
-                 return
-             }
-        } catch (err) {
-            const errStr = String(err?.message || err || '')
-            if (isTokenInvalidatedError(errStr)) {
-                // 如果 tokenId 仍未定义，尝试从任务或数据库获取
-                let finalTokenId = tokenId
-                if (finalTokenId == null) {
-                    const current = tasks.value.find(x => x.id === taskId)
-                    if (current) {
-                        finalTokenId = current.tokenIdForPending
-                        // 如果还是没有，尝试从数据库获取
-                        if (finalTokenId == null && current.remoteTaskId && window.go?.main?.App?.GetTokenIDByRemoteTaskID) {
-                            try {
-                                const res = await window.go.main.App.GetTokenIDByRemoteTaskID(current.remoteTaskId)
-                                const data = typeof res === 'string' ? JSON.parse(res) : res
-                                if (data?.token_id != null) {
-                                    finalTokenId = data.token_id
-                                }
-                            } catch (_) {}
-                        }
-                    }
-                }
-                console.log('[startPendingInterval catch] 检测到 token 失效，tokenId:', finalTokenId)
-                await handleTokenInvalidated(finalTokenId, taskId, pendingIntervals, updateTask, taskId, addLog, false)
-                return
-            }
-            addLog(`Task ${taskId} pending 轮询失败: ${err?.message || err}`, 'warning')
-        }
-     }
-     tick()
-     pendingIntervals.set(taskId, setInterval(tick, POLL_INTERVAL_MS))
-  }
 
-  // 孤儿任务：仅存在于 SQLite，无本地 UI 任务；只轮询 pending、更新 DB、完成后拉取 drafts
-  const startOrphanPending = (remoteTaskId, tokenId) => {
-     const key = 'remote:' + remoteTaskId
-     if (!window.go?.main?.App?.PollPending || pendingIntervals.has(key)) return
-     const apiBase = baseUrl.value.replace(/\/$/, '')
-     const POLL_INTERVAL_MS = 10 * 1000
-     const tick = async () => {
-         try {
-             let bearer = ''
-             if (tokenId != null && window.go?.main?.App?.GetBearerByTokenID) {
-                 const res = await window.go.main.App.GetBearerByTokenID(tokenId)
-                 const data = typeof res === 'string' ? JSON.parse(res) : res
-                 if (data?.bearer_token) bearer = data.bearer_token
-             }
-             if (!bearer) {
-                 addLog(`孤儿任务 ${remoteTaskId} 无法获取 bearer，停止轮询`, 'warning')
-                 const id = pendingIntervals.get(key)
-                 if (id != null) clearInterval(id)
-                 pendingIntervals.delete(key)
-                 return
-             }
-             const body = await window.go.main.App.PollPending(apiBase, bearer)
-            console.log('[startOrphanPending] PollPending 返回:', typeof body, body?.substring?.(0, 200) || body)
-            const { rawText, parsed } = parsePendingBody(body)
-            console.log('[startOrphanPending] parsed:', parsed, 'rawText 前200字符:', rawText?.substring?.(0, 200))
-            const isInvalid = checkTokenInvalidatedInResponse(parsed, rawText)
-            console.log('[startOrphanPending] checkTokenInvalidatedInResponse 结果:', isInvalid)
-            if (isInvalid) {
-                console.log('[startOrphanPending] 检测到 token 失效，调用 handleTokenInvalidated')
-                await handleTokenInvalidated(tokenId, key, pendingIntervals, null, remoteTaskId, addLog, true)
-                return
-            }
-            const list = parsed ?? {}
-            const tasksArr = Array.isArray(list) ? list : (list?.tasks || [])
-            const matched = tasksArr.find(t => t?.id === remoteTaskId || t?.task_id === remoteTaskId)
-            if (!matched) {
-                 const id = pendingIntervals.get(key)
-                 if (id != null) clearInterval(id)
-                 pendingIntervals.delete(key)
-                 if (window.go?.main?.App?.UpdateVideoTaskProgress) {
-                     window.go.main.App.UpdateVideoTaskProgress(remoteTaskId, 100).catch(() => {})
-                 }
-                addLog(`孤儿任务 ${remoteTaskId} pending 中不存在，拉取 drafts`, 'info')
-                 if (window.go?.main?.App?.FetchDrafts && window.go?.main?.App?.SaveDraftsAndDownload) {
-                     try {
-                         const draftsBody = await window.go.main.App.FetchDrafts(apiBase, bearer)
-                         const draftsJson = typeof draftsBody === 'string' ? draftsBody : JSON.stringify(draftsBody)
-                         await window.go.main.App.SaveDraftsAndDownload(draftsJson, remoteTaskId)
-                     } catch (e) {
-                         addLog(`孤儿任务 ${remoteTaskId} drafts 失败: ${e?.message || e}`, 'warning')
-                     }
-                 }
-                 return
-             }
-            const rawPct = matched.progress_pct != null ? matched.progress_pct : 0
-             // progress_pct 可能是 0-1 的小数或 0-100 的整数
-             const pct = rawPct <= 1 ? rawPct * 100 : rawPct
-             if (window.go?.main?.App?.UpdateVideoTaskProgress) {
-                 window.go.main.App.UpdateVideoTaskProgress(remoteTaskId, pct).catch(() => {})
-             }
-             
-             // 如果进度达到 100%（progress_pct >= 1 或 >= 100），停止轮询并去草稿箱拉取
-             if (rawPct >= 1 || pct >= 100) {
-                 const id = pendingIntervals.get(key)
-                 if (id != null) clearInterval(id)
-                 pendingIntervals.delete(key)
-                 addLog(`孤儿任务 ${remoteTaskId} progress_pct=100%，任务完成，去草稿箱拉取`, 'info')
-                 // 去草稿箱拉取结果
-                 if (window.go?.main?.App?.FetchDrafts && window.go?.main?.App?.SaveDraftsAndDownload) {
-                     try {
-                         const draftsBody = await window.go.main.App.FetchDrafts(apiBase, bearer)
-                         const draftsJson = typeof draftsBody === 'string' ? draftsBody : JSON.stringify(draftsBody)
-                         await window.go.main.App.SaveDraftsAndDownload(draftsJson, remoteTaskId)
-                         addLog(`孤儿任务 ${remoteTaskId} 已拉取 drafts 并下载`, 'info')
-                     } catch (e) {
-                         addLog(`孤儿任务 ${remoteTaskId} drafts 拉取/下载失败: ${e?.message || e}`, 'warning')
-                     }
-                 }
-                 return
-             }
-        } catch (err) {
-            const errStr = String(err?.message || err || '')
-            if (isTokenInvalidatedError(errStr)) {
-                await handleTokenInvalidated(tokenId, key, pendingIntervals, null, remoteTaskId, addLog, true)
-                return
-            }
-            addLog(`孤儿任务 ${remoteTaskId} pending 轮询失败: ${err?.message || err}`, 'warning')
-        }
-     }
-     tick()
-     pendingIntervals.set(key, setInterval(tick, POLL_INTERVAL_MS))
+  // 视频任务由 Go 后台任务引擎执行（选号、创建、轮询 pending、下载），这里只订阅进度事件更新 UI
+  const engineStatusMap = { submitting: 'running', queued: 'running', running: 'running', done: 'done', failed: 'failed', cancelled: 'failed' }
+  const onVideoTaskEvent = (ev) => {
+      if (!ev) return
+      const local = tasks.value.find(t => (ev.local_id && t.id === ev.local_id) || (ev.task_id && t.remoteTaskId === ev.task_id))
+      const label = local ? `Task ${local.id}` : `孤儿任务 ${ev.task_id}`
+      if (ev.status === 'done' || ev.status === 'failed' || ev.status === 'cancelled') {
+          addLog(`${label} ${ev.message}`, ev.status === 'done' ? 'info' : 'warning')
+      }
+      if (!local) return
+      const updates = { status: engineStatusMap[ev.status] || local.status, message: ev.message }
+      if (ev.task_id) updates.remoteTaskId = ev.task_id
+      if (ev.token_id) updates.tokenIdForPending = ev.token_id
+      if (ev.progress >= 0) updates.progress = ev.progress
+      if (ev.local_path) updates.localPath = ev.local_path
+      updateTask(local.id, updates)
+  }
+  if (window.runtime?.EventsOn) {
+      window.runtime.EventsOn('video-task:update', onVideoTaskEvent)
   }
 
-  // 页面加载时：从 SQLite 读取未完成视频任务，恢复 pending 轮询（不依赖 localStorage 任务列表）
+  // 页面加载时：让后台引擎重新扫描 SQLite 中未完成的视频任务（引擎启动时已自动恢复，这里用于补漏）
   const startPendingForExistingTasks = async () => {
-     if (!window.go?.main?.App?.PollPending || !window.go?.main?.App?.GetIncompleteVideoTasks) return
+     if (!window.go?.main?.App?.ResumeVideoTasks) return
      try {
-         const res = await window.go.main.App.GetIncompleteVideoTasks()
+         const res = await window.go.main.App.ResumeVideoTasks()
          const data = typeof res === 'string' ? JSON.parse(res) : res
-         if (data?.error) {
-             addLog('获取未完成视频任务失败: ' + data.error, 'warning')
+         if (data?.success === false) {
+             addLog('恢复未完成视频任务失败: ' + data.message, 'warning')
              return
          }
-         const list = data?.tasks || []
-         addLog(`[SQLite] 读取未完成视频任务: ${JSON.stringify(list)}`, 'info')
-         addLog(`[SQLite] 共 ${list.length} 条，是否需要继续 pending: ${list.length > 0 ? '是' : '否'}`, 'info')
-         for (const item of list) {
-             const remoteTaskId = item?.task_id
-             const tokenId = item?.token_id
-             if (!remoteTaskId || tokenId == null) {
-                 addLog(`[pending] 跳过无效项: task_id=${remoteTaskId} token_id=${tokenId}`, 'info')
-                 continue
-             }
-             const key = 'remote:' + remoteTaskId
-             if (pendingIntervals.has(key)) {
-                 addLog(`[pending] ${remoteTaskId} 已在轮询中，跳过`, 'info')
-                 continue
-             }
-             const local = tasks.value.find(t => t.remoteTaskId === remoteTaskId)
-             if (local) {
-                 if (local.status === 'done' || local.status === 'failed') {
-                     addLog(`[pending] ${remoteTaskId} 本地状态已为 ${local.status}，跳过`, 'info')
-                     continue
-                 }
-                 if (pendingIntervals.has(local.id)) {
-                     addLog(`[pending] ${remoteTaskId} 本地任务 ${local.id} 已在轮询，跳过`, 'info')
-                     continue
-                 }
-                 if (local.tokenIdForPending == null) updateTask(local.id, { tokenIdForPending: tokenId })
-                 addLog(`[pending] ${remoteTaskId} 继续 pending（本地任务 ${local.id}）`, 'info')
-                 startPendingInterval(local.id)
-             } else {
-                 addLog(`[pending] ${remoteTaskId} 继续 pending（孤儿任务，无本地条目）`, 'info')
-                 startOrphanPending(remoteTaskId, tokenId)
-             }
-         }
-         // 本地任务存在但 SQLite 未记录未完成（progress_pct>=100 或缺失）时，也继续 pending
-         for (const t of tasks.value) {
-             if (!t || !t.remoteTaskId) continue
-             if (t.status === 'failed') continue
-             if (t.url) continue
-             if (pendingIntervals.has(t.id)) continue
-             addLog(`[pending] ${t.remoteTaskId} 本地任务补充继续 pending（无下载）`, 'info')
-             startPendingInterval(t.id)
-         }
+         if (data?.resumed) addLog(`[pending] 后台引擎新接管 ${data.resumed} 个未完成视频任务`, 'info')
      } catch (e) {
          addLog('恢复 pending 失败: ' + (e?.message || e), 'warning')
      }
@@ -710,27 +303,7 @@ export const useGenerateStore = defineStore('generate', () => {
          messages: [ { role: 'user', content: contentArr.length ? contentArr : t.prompt } ]
      }
 
-     // 视频任务：从数据库随机取一个状态正常、有剩余次数的 token 作为 bearer，并记下 token_id 供收到 create 结果时写入 SQLite
-     let bearerForRequest = apiKey.value
-     let videoTokenId = null
-    if (typeof t.model === 'string' && t.model.startsWith('sora2') && window.go?.main?.App?.GetRandomVideoToken) {
-         try {
-            const { requirePro } = getVideoRequestParams(t.model)
-            const res = await window.go.main.App.GetRandomVideoToken(requirePro)
-             const data = typeof res === 'string' ? JSON.parse(res) : res
-             if (data?.error) {
-                 updateTask(taskId, { status: 'failed', message: data.error })
-                 addLog(`Task ${taskId}: ${data.error}`, 'error')
-                 return
-             }
-             if (data?.bearer_token) bearerForRequest = data.bearer_token
-             if (data?.token_id != null) videoTokenId = data.token_id
-         } catch (e) {
-             updateTask(taskId, { status: 'failed', message: e?.message || '获取视频 Token 失败' })
-             addLog(`Task ${taskId} 获取 Token 异常: ${e?.message}`, 'error')
-             return
-         }
-     }
+     const bearerForRequest = apiKey.value
 
      const controller = new AbortController()
      abortControllers.set(taskId, controller)
@@ -738,64 +311,21 @@ export const useGenerateStore = defineStore('generate', () => {
     const isVideoTask = typeof t.model === 'string' && t.model.startsWith('sora2')
 
      try {
-        if (isVideoTask && window.go?.main?.App?.CreateVideo) {
-            // 视频任务：调用与 testsh/create.sh 相同的接口 POST /videos（由 Go 发起，控制台打 CREATE 请求/响应）
-            const { orientation, nFrames, soraModel, size } = getVideoRequestParams(t.model)
-            const apiBase = baseUrl.value.replace(/\/$/, '')
-            
-            // 打印请求信息到控制台
-            console.log('========== 创建视频请求 (POST /videos via Go) ==========')
-            console.log('API Base:', apiBase)
-            console.log('Bearer Token:', bearerForRequest ? bearerForRequest.substring(0, 20) + '...' : '(empty)')
-            console.log('Prompt:', finalPrompt)
-            console.log('Orientation:', orientation)
-            console.log('N Frames:', nFrames)
-            console.log('Model:', soraModel)
-            console.log('Size:', size)
-            console.log('Task ID:', taskId)
-            console.log('Token ID:', videoTokenId)
-            console.log('----------------------------------------')
-            
-            const res = await window.go.main.App.CreateVideo(
-                apiBase,
-                bearerForRequest,
-                finalPrompt,
-                orientation,
-                nFrames,
-                soraModel,
-                size
-            )
-            
-            console.log('========== 创建视频响应 (via Go) ==========')
-            console.log('Response:', typeof res === 'string' ? res.substring(0, 500) : res)
-            console.log('=======================================')
-             const data = typeof res === 'string' ? JSON.parse(res) : res
-             const taskIdRemote = data?.id || data?.task_id
-             if (taskIdRemote) {
-                 // 记录创建该任务时使用的账号（token_id），pending 时用同一账号
-                 updateTask(taskId, {
-                     remoteTaskId: taskIdRemote,
-                     tokenIdForPending: videoTokenId != null ? videoTokenId : undefined,
-                     message: '已提交，每 10s 轮询 pending…'
-                 })
-                if (videoTokenId != null && window.go?.main?.App?.SaveVideoTaskResult) {
-                    try {
-                        await window.go.main.App.SaveVideoTaskResult(
-                            videoTokenId,
-                            typeof res === 'string' ? res : JSON.stringify(data),
-                            finalPrompt || ''
-                        )
-                     } catch (err) {
-                         addLog(`保存视频任务结果失败: ${err}`, 'warning')
-                     }
-                 }
-                 // 每 10s 轮询 pending（与 test_pending.sh 一致），返回 [] 表示任务完成
-                 if (window.go?.main?.App?.PollPending) {
-                     startPendingInterval(taskId)
-                 }
-             } else {
-                 updateTask(taskId, { status: 'failed', message: data?.error || '未返回 task_id' })
-             }
+        if (isVideoTask && window.go?.main?.App?.SubmitVideoTask) {
+            // 视频任务：交给 Go 后台任务引擎（按模型目录换算参数、选号、POST /videos、轮询 pending、下载），进度通过 video-task:update 事件回传
+            const res = await window.go.main.App.SubmitVideoTask(JSON.stringify({
+                local_id: taskId,
+                prompt: finalPrompt,
+                model: t.model,
+                group: t.tokenGroup || ''
+            }))
+            const data = typeof res === 'string' ? JSON.parse(res) : res
+            if (data?.success === false) {
+                updateTask(taskId, { status: 'failed', message: data.message || '提交视频任务失败' })
+                addLog(`Task ${taskId}: ${data.message}`, 'error')
+            } else {
+                updateTask(taskId, { message: '已提交到后台任务引擎…' })
+            }
          } else {
              // 非视频任务（图片等）：仍走流式 /v1/chat/completions
              await streamCompletion(payload, bearerForRequest, baseUrl.value, {
@@ -859,10 +389,8 @@ export const useGenerateStore = defineStore('generate', () => {
   const cancelTask = (taskId) => {
       const c = abortControllers.get(taskId)
       if (c) c.abort()
-      const pendingId = pendingIntervals.get(taskId)
-      if (pendingId != null) {
-          clearInterval(pendingId)
-          pendingIntervals.delete(taskId)
+      if (window.go?.main?.App?.CancelVideoTask) {
+          window.go.main.App.CancelVideoTask(taskId).catch(() => {})
       }
       updateTask(taskId, { status: 'failed', message: 'Cancelled manually' })
   }
@@ -874,6 +402,9 @@ export const useGenerateStore = defineStore('generate', () => {
     setBaseUrl,
     models,
     modelGroups,
+    videoModels,
+    loadVideoModels,
+    videoModelInfo,
     selectedModel,
     batchMode,
     tasks,
@@ -10,13 +10,17 @@ export function ApiRequest(arg1:string,arg2:string,arg3:string,arg4:string):Prom
 
 export function ApiRequestBlob(arg1:string,arg2:string,arg3:string):Promise<string>;
 
+export function BackupData(arg1:string,arg2:boolean,arg3:boolean):Promise<string>;
+
+export function CancelVideoTask(arg1:string):Promise<string>;
+
 export function CheckAccountAndSave(arg1:string):Promise<string>;
 
 export function CheckForUpdates():Promise<string>;
 
 export function ClearVideoDownloads():Promise<string>;
 
-export function CreateVideo(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string):Promise<string>;
+export function CreateVideo(arg1:string,arg2:string,arg3:string,arg4:string):Promise<string>;
 
 export function DeleteTaskData(arg1:string,arg2:boolean):Promise<string>;
 
@@ -36,7 +40,7 @@ export function GetLocalFileDataURL(arg1:string):Promise<string>;
 
 export function GetLocalFileURL(arg1:string):Promise<string>;
 
-export function GetRandomVideoToken(arg1:boolean):Promise<string>;
+export function GetRandomVideoToken(arg1:string,arg2:string):Promise<string>;
 
 export function GetTaskList():Promise<string>;
 
@@ -46,6 +50,8 @@ export function GetTokenIDByRemoteTaskID(arg1:string):Promise<string>;
 
 export function GetVideoDownloadsMap():Promise<string>;
 
+export function GetVideoModels():Promise<string>;
+
 export function Greet(arg1:string):Promise<string>;
 
 export function InstallUpdate(arg1:string):Promise<string>;
@@ -58,16 +64,24 @@ export function PublishAndDownloadNoWatermark(arg1:string,arg2:string,arg3:strin
 
 export function ReDownloadVideo(arg1:string):Promise<string>;
 
+export function RestoreData(arg1:string):Promise<string>;
+
+export function ResumeVideoTasks():Promise<string>;
+
 export function SaveDraftsAndDownload(arg1:string,arg2:string):Promise<string>;
 
 export function SaveVideoTaskResult(arg1:number,arg2:string,arg3:string):Promise<string>;
 
+export function SelectBackupFile(arg1:boolean):Promise<string>;
+
 export function SetBaseURL(arg1:string):Promise<void>;
 
 export function SetTaskList(arg1:string):Promise<string>;
 
 export function SetTokenError(arg1:number,arg2:string):Promise<string>;
 
+export function SubmitVideoTask(arg1:string):Promise<string>;
+
 export function TestServerHealth(arg1:string):Promise<main.HealthResult>;
 
 export function UpdateVideoTaskProgress(arg1:string,arg2:number):Promise<string>;
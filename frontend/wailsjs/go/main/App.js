@@ -18,6 +18,14 @@ export function ApiRequestBlob(arg1, arg2, arg3) {
   return window['go']['main']['App']['ApiRequestBlob'](arg1, arg2, arg3);
 }
 
+export function BackupData(arg1, arg2, arg3) {
+  return window['go']['main']['App']['BackupData'](arg1, arg2, arg3);
+}
+
+export function CancelVideoTask(arg1) {
+  return window['go']['main']['App']['CancelVideoTask'](arg1);
+}
+
 export function CheckAccountAndSave(arg1) {
   return window['go']['main']['App']['CheckAccountAndSave'](arg1);
 }
@@ -30,8 +38,8 @@ export function ClearVideoDownloads() {
   return window['go']['main']['App']['ClearVideoDownloads']();
 }
 
-export function CreateVideo(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
-  return window['go']['main']['App']['CreateVideo'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+export function CreateVideo(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['CreateVideo'](arg1, arg2, arg3, arg4);
 }
 
 export function DeleteTaskData(arg1, arg2) {
@@ -70,8 +78,8 @@ export function GetLocalFileURL(arg1) {
   return window['go']['main']['App']['GetLocalFileURL'](arg1);
 }
 
-export function GetRandomVideoToken(arg1) {
-  return window['go']['main']['App']['GetRandomVideoToken'](arg1);
+export function GetRandomVideoToken(arg1, arg2) {
+  return window['go']['main']['App']['GetRandomVideoToken'](arg1, arg2);
 }
 
 export function GetTaskList() {
@@ -90,6 +98,10 @@ export function GetVideoDownloadsMap() {
   return window['go']['main']['App']['GetVideoDownloadsMap']();
 }
 
+export function GetVideoModels() {
+  return window['go']['main']['App']['GetVideoModels']();
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
@@ -114,6 +126,14 @@ export function ReDownloadVideo(arg1) {
   return window['go']['main']['App']['ReDownloadVideo'](arg1);
 }
 
+export function RestoreData(arg1) {
+  return window['go']['main']['App']['RestoreData'](arg1);
+}
+
+export function ResumeVideoTasks() {
+  return window['go']['main']['App']['ResumeVideoTasks']();
+}
+
 export function SaveDraftsAndDownload(arg1, arg2) {
   return window['go']['main']['App']['SaveDraftsAndDownload'](arg1, arg2);
 }
@@ -122,6 +142,10 @@ export function SaveVideoTaskResult(arg1, arg2, arg3) {
   return window['go']['main']['App']['SaveVideoTaskResult'](arg1, arg2, arg3);
 }
 
+export function SelectBackupFile(arg1) {
+  return window['go']['main']['App']['SelectBackupFile'](arg1);
+}
+
 export function SetBaseURL(arg1) {
   return window['go']['main']['App']['SetBaseURL'](arg1);
 }
@@ -134,6 +158,10 @@ export function SetTokenError(arg1, arg2) {
   return window['go']['main']['App']['SetTokenError'](arg1, arg2);
 }
 
+export function SubmitVideoTask(arg1) {
+  return window['go']['main']['App']['SubmitVideoTask'](arg1);
+}
+
 export function TestServerHealth(arg1) {
   return window['go']['main']['App']['TestServerHealth'](arg1);
 }
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 定时健康检查：按 health_check_interval_minutes 的间隔，以最多 health_check_concurrency 个并发
+// 测试所有已启用的账号（与单个账号“验证”相同：刷新 status_json、冷却时间与 plan_type）。
+// 每个账号保留最近 healthHistoryLimit 次检查的结果与耗时（含手动验证），逐条写入 token_health_checks 表
+// （只有 SQLite 存储记录）；每轮结束后向前端推送 healthCheckEventName 事件。开关与参数通过 /api/health-check 配置。
+
+const (
+	// healthCheckEventName 一轮检查结束后推送的事件名，数据为 healthCheckSummary
+	healthCheckEventName = "token-health:summary"
+	// legacyHealthHistoryKey 旧版本在 settings 表中保存检查历史的 key，迁移 7 搬入 token_health_checks 后删除
+	legacyHealthHistoryKey  = "token_health_history"
+	healthHistoryLimit      = 20
+	healthCheckPollInterval = time.Minute
+)
+
+// tokenHealthEntry 单个账号的一次检查结果
+type tokenHealthEntry struct {
+	TokenID   int64  `json:"-"`
+	CheckedAt int64  `json:"checked_at"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	PlanType  string `json:"plan_type,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthCheckSummary 一轮检查的汇总
+type healthCheckSummary struct {
+	Trigger      string  `json:"trigger"` // scheduled | manual
+	StartedAt    int64   `json:"started_at"`
+	FinishedAt   int64   `json:"finished_at"`
+	DurationMs   int64   `json:"duration_ms"`
+	Total        int     `json:"total"`
+	OK           int     `json:"ok"`
+	Failed       int     `json:"failed"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+	FailedIDs    []int64 `json:"failed_ids"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// healthChecker 健康检查的运行状态
+type healthChecker struct {
+	mu      sync.Mutex
+	running bool
+	lastRun time.Time
+	last    *healthCheckSummary
+	wake    chan struct{}
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{wake: make(chan struct{}, 1)}
+}
+
+// trigger 唤醒后台循环重新判断是否到期（配置变更后调用）
+func (h *healthChecker) trigger() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// recordHealthCheck 写入一条检查结果并只保留该账号最近 healthHistoryLimit 条；JSON 文件存储不记录
+func (a *App) recordHealthCheck(e tokenHealthEntry) {
+	err := a.store.InsertHealthCheck(e)
+	if err == nil {
+		err = a.store.PruneHealthChecks(e.TokenID, healthHistoryLimit)
+	}
+	if err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("[HealthCheck] 写入检查记录失败: %v", err))
+	}
+}
+
+// latestHealthChecks 每个账号最近一次检查结果；读取失败或 JSON 文件存储时返回空
+func (a *App) latestHealthChecks() map[int64]tokenHealthEntry {
+	latest, err := a.store.LatestHealthChecks()
+	if err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("[HealthCheck] 读取检查记录失败: %v", err))
+	}
+	return latest
+}
+
+// probeToken 检查一个账号并记录耗时与结果，返回状态中的邮箱
+func (a *App) probeToken(id int64, bearer string) (string, error) {
+	start := time.Now()
+	email, err := a.refreshTokenStatus(id, bearer)
+	e := tokenHealthEntry{TokenID: id, CheckedAt: start.Unix(), OK: err == nil, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		e.Error = truncateRunes(redactSecrets(err.Error()), 200)
+	}
+	if rec, gerr := a.store.GetToken(id); gerr == nil {
+		e.PlanType = rec.PlanType
+	}
+	a.recordHealthCheck(e)
+	return email, err
+}
+
+// forEachBounded 以最多 limit 个 goroutine 并发执行 fn(0)…fn(n-1)，全部完成后返回
+func forEachBounded(n, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// runHealthChecker 后台循环：每分钟（或配置变更时）判断是否已到检查间隔，未启用时跳过
+func (a *App) runHealthChecker() {
+	ticker := time.NewTicker(healthCheckPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.health.wake:
+		}
+		cfg := a.loadAppConfig()
+		if !cfg.HealthCheckEnabled {
+			continue
+		}
+		a.health.mu.Lock()
+		due := time.Since(a.health.lastRun) >= time.Duration(cfg.HealthCheckInterval)*time.Minute
+		a.health.mu.Unlock()
+		if due {
+			a.runHealthCheck("scheduled")
+		}
+	}
+}
+
+// runHealthCheck 检查所有已启用的账号；已有一轮在进行时直接返回带 Error 的汇总
+func (a *App) runHealthCheck(trigger string) healthCheckSummary {
+	h := a.health
+	start := time.Now()
+	sum := healthCheckSummary{Trigger: trigger, StartedAt: start.Unix(), FailedIDs: []int64{}}
+	h.mu.Lock()
+	if h.running {
+		h.mu.Unlock()
+		sum.Error = "上一轮健康检查仍在进行"
+		return sum
+	}
+	h.running = true
+	h.lastRun = start
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		h.running = false
+		h.last = &sum
+		h.mu.Unlock()
+		a.emitEvent(healthCheckEventName, sum)
+	}()
+	// 恢复备份会等本轮检查结束后再替换存储
+	a.dataMu.RLock()
+	defer a.dataMu.RUnlock()
+
+	if a.store == nil {
+		sum.Error = errStoreUnavailable.Error()
+		return sum
+	}
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		sum.Error = err.Error()
+		a.logError(fmt.Sprintf("[HealthCheck] 查询账号失败: %v", err))
+		return sum
+	}
+	type target struct {
+		id     int64
+		bearer string
+	}
+	var targets []target
+	for _, t := range tokens {
+		if !t.IsActive {
+			continue
+		}
+		if bearer := a.openSecret(t.Token); strings.TrimSpace(bearer) != "" {
+			targets = append(targets, target{t.ID, bearer})
+		}
+	}
+
+	var mu sync.Mutex
+	var totalLatency int64
+	forEachBounded(len(targets), a.loadAppConfig().HealthCheckConcurrency, func(i int) {
+		if a.ctx.Err() != nil {
+			return
+		}
+		t := targets[i]
+		began := time.Now()
+		_, err := a.probeToken(t.id, t.bearer)
+		latency := time.Since(began).Milliseconds()
+		mu.Lock()
+		defer mu.Unlock()
+		sum.Total++
+		totalLatency += latency
+		if latency > sum.MaxLatencyMs {
+			sum.MaxLatencyMs = latency
+		}
+		if err != nil {
+			sum.Failed++
+			sum.FailedIDs = append(sum.FailedIDs, t.id)
+		} else {
+			sum.OK++
+		}
+	})
+	sort.Slice(sum.FailedIDs, func(i, j int) bool { return sum.FailedIDs[i] < sum.FailedIDs[j] })
+	if sum.Total > 0 {
+		sum.AvgLatencyMs = totalLatency / int64(sum.Total)
+	}
+	sum.FinishedAt = time.Now().Unix()
+	sum.DurationMs = time.Since(start).Milliseconds()
+	if a.scheduler != nil {
+		a.scheduler.notify()
+	}
+	a.logInfo(fmt.Sprintf("[HealthCheck] 检查 %d 个账号，正常 %d 个，失败 %d 个，平均耗时 %dms",
+		sum.Total, sum.OK, sum.Failed, sum.AvgLatencyMs))
+	return sum
+}
+
+// healthCheckConfigView /api/health-check/config 返回的 config 字段
+func (a *App) healthCheckConfigView(cfg AppConfig) map[string]interface{} {
+	out := map[string]interface{}{
+		"enabled":          cfg.HealthCheckEnabled,
+		"interval_minutes": cfg.HealthCheckInterval,
+		"concurrency":      cfg.HealthCheckConcurrency,
+	}
+	a.health.mu.Lock()
+	if a.health.last != nil {
+		out["last_run"] = *a.health.last
+	}
+	if cfg.HealthCheckEnabled {
+		next := a.health.lastRun.Add(time.Duration(cfg.HealthCheckInterval) * time.Minute)
+		if next.Before(time.Now()) {
+			next = time.Now().Add(healthCheckPollInterval)
+		}
+		out["next_run_at"] = next.Unix()
+	}
+	out["running"] = a.health.running
+	a.health.mu.Unlock()
+	return out
+}
+
+// handleHealthCheck /api/health-check/*：
+// GET config 查看配置与上一轮结果；POST enabled|config 修改 {"enabled", "interval_minutes", "concurrency"}；
+// POST run 在后台立即执行一轮，结果通过 healthCheckEventName 事件推送
+func (a *App) handleHealthCheck(method string, path string, body string) (string, error) {
+	if method == http.MethodGet {
+		return jsonMarshal(map[string]interface{}{"success": true, "config": a.healthCheckConfigView(a.loadAppConfig())})
+	}
+	if strings.HasSuffix(strings.SplitN(path, "?", 2)[0], "/run") {
+		a.health.mu.Lock()
+		running := a.health.running
+		a.health.mu.Unlock()
+		if running {
+			return jsonFail("上一轮健康检查仍在进行")
+		}
+		go a.runHealthCheck("manual")
+		return jsonMarshal(map[string]interface{}{"success": true, "message": "健康检查已开始"})
+	}
+	var input struct {
+		Enabled         *bool `json:"enabled"`
+		IntervalMinutes *int  `json:"interval_minutes"`
+		Concurrency     *int  `json:"concurrency"`
+	}
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		return jsonFail("请求体解析失败")
+	}
+	cfg, err := a.updateAppConfig(func(cfg *AppConfig) error {
+		if input.Enabled != nil {
+			cfg.HealthCheckEnabled = *input.Enabled
+		}
+		if input.IntervalMinutes != nil {
+			cfg.HealthCheckInterval = *input.IntervalMinutes
+		}
+		if input.Concurrency != nil {
+			cfg.HealthCheckConcurrency = *input.Concurrency
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+	a.health.trigger()
+	return jsonMarshal(map[string]interface{}{"success": true, "config": a.healthCheckConfigView(cfg)})
+}
+
+// localTokenHealthHistory GET /api/tokens/:id/health，返回该账号的检查历史（最新在前）；
+// JSON 文件存储下不记录，返回空列表与 available:false
+func (a *App) localTokenHealthHistory(id int64) (string, error) {
+	if _, err := a.store.GetToken(id); err != nil {
+		return jsonFail("Token 不存在")
+	}
+	list, err := a.store.ListHealthChecks(id)
+	if errors.Is(err, errHistoryUnavailable) {
+		return jsonMarshal(map[string]interface{}{"success": true, "token_id": id, "history": []tokenHealthEntry{}, "available": false})
+	}
+	if err != nil {
+		return jsonFail("读取检查记录失败: " + err.Error())
+	}
+	if list == nil {
+		list = []tokenHealthEntry{}
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "token_id": id, "history": list, "available": true})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 出站请求统一通过 httpClientForToken 创建 http.Client：优先使用该账号 tokens.proxy_url，
+// 否则回退到全局代理（/api/proxy/config，AppConfig.ProxyEnabled / ProxyURL）。支持 http/https/socks5/socks5h 代理。
+
+var (
+	transportMu    sync.Mutex
+	transportCache = map[string]*http.Transport{} // key: 代理地址（空串表示直连），复用连接池
+)
+
+// parseProxyURL 校验代理地址，仅允许 http/https/socks5/socks5h
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址无效: %v", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s（仅支持 http/https/socks5）", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机: %s", raw)
+	}
+	return u, nil
+}
+
+// proxyHostForLog 代理地址只保留协议与主机，用于日志（不输出 user:pass@ 凭证）
+func proxyHostForLog(raw string) string {
+	u, err := parseProxyURL(raw)
+	if err != nil || u == nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// transportFor 返回指定代理的 Transport（按代理地址缓存）；访问本机地址时不走代理
+func transportFor(proxyURL string) *http.Transport {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if t, ok := transportCache[proxyURL]; ok {
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	if u, err := parseProxyURL(proxyURL); err == nil && u != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if isLoopbackHost(req.URL.Hostname()) {
+				return nil, nil
+			}
+			return u, nil
+		}
+	}
+	transportCache[proxyURL] = t
+	return t
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// globalProxyURL 返回已启用的全局代理地址，未启用时为空
+func (a *App) globalProxyURL() string {
+	cfg := a.loadAppConfig()
+	if !cfg.ProxyEnabled {
+		return ""
+	}
+	return strings.TrimSpace(cfg.ProxyURL)
+}
+
+// proxyURLForToken 返回账号应使用的代理：tokens.proxy_url 优先，其次全局代理；tokenID<=0 时直接使用全局代理
+func (a *App) proxyURLForToken(tokenID int64) string {
+	if tokenID > 0 && a.store != nil {
+		if t, err := a.store.GetToken(tokenID); err == nil {
+			if p := strings.TrimSpace(t.ProxyURL); p != "" {
+				return p
+			}
+		}
+	}
+	return a.globalProxyURL()
+}
+
+// httpClientForToken 创建走该账号代理的 http.Client
+func (a *App) httpClientForToken(tokenID int64, timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: transportFor(a.proxyURLForToken(tokenID))}
+}
+
+// httpClientForBearer 按 bearer token 找到对应账号后创建 http.Client；找不到账号时使用全局代理。
+// 只用于前端直接传 bearer 的旧接口，已知账号 id 时用 httpClientForToken
+func (a *App) httpClientForBearer(bearer string, timeout time.Duration) *http.Client {
+	return a.httpClientForToken(a.tokenIDByBearer(bearer), timeout)
+}
+
+// tokenIDByBearer 根据 bearer token 反查 tokens.id，未找到时返回 0（JSON 存储下为线性查找，只用于前端传入 bearer 的接口）
+func (a *App) tokenIDByBearer(bearer string) int64 {
+	bearer = strings.TrimSpace(bearer)
+	if bearer == "" || a.store == nil {
+		return 0
+	}
+	// token 加密存储后按 token_hash 查找，同时兼容尚未迁移的明文行
+	id, err := a.store.FindTokenID(a.tokenHash(bearer), bearer)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// tokenIDForTask 根据 task_id 查找创建该任务的账号，未找到时返回 0
+func (a *App) tokenIDForTask(taskID string) int64 {
+	taskID = strings.TrimSpace(taskID)
+	if taskID == "" || a.store == nil {
+		return 0
+	}
+	t, err := a.store.GetTask(taskID)
+	if err != nil {
+		return 0
+	}
+	return t.TokenID
+}
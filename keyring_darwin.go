@@ -0,0 +1,60 @@
+//go:build darwin
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// macOS：通过系统自带的 /usr/bin/security 读写登录钥匙串中的通用密码。
+// 写入走 security -i 从标准输入读取命令，避免密钥出现在进程参数中。
+
+const (
+	securityCmd = "/usr/bin/security"
+	// securityItemNotFound find-generic-password 找不到条目时的退出码（errSecItemNotFound）
+	securityItemNotFound = 44
+)
+
+type keychainKeyring struct {
+	account string
+}
+
+func systemKeyring(account string) keyring {
+	return keychainKeyring{account: account}
+}
+
+func (k keychainKeyring) Name() string { return "keychain:" + keyringService + "/" + k.account }
+
+func (k keychainKeyring) Get() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, securityCmd, "find-generic-password", "-s", keyringService, "-a", k.account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() == securityItemNotFound {
+				return nil, errKeyNotFound
+			}
+			return nil, fmt.Errorf("读取钥匙串失败: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("读取钥匙串失败: %v", err)
+	}
+	return decodeKeyringSecret(string(out))
+}
+
+func (k keychainKeyring) Set(key []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, securityCmd, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		strconv.Quote(keyringService), strconv.Quote(k.account), strconv.Quote(encodeKeyringSecret(key))))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("写入钥匙串失败: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
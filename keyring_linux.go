@@ -0,0 +1,66 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Linux：通过 libsecret 的 secret-tool 命令访问 Secret Service（GNOME Keyring、KWallet 等）。
+// 没有安装 secret-tool 或会话中没有 Secret Service 时退回 secret.key 文件。
+
+type secretServiceKeyring struct {
+	account string
+}
+
+func systemKeyring(account string) keyring {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil
+	}
+	return secretServiceKeyring{account: account}
+}
+
+func (k secretServiceKeyring) Name() string {
+	return "secret-service:" + keyringService + "/" + k.account
+}
+
+func (k secretServiceKeyring) Get() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "secret-tool", "lookup", "service", keyringService, "account", k.account).Output()
+	if err != nil {
+		// 找不到条目时 secret-tool 以非 0 退出且不输出错误信息；其余情况（无 D-Bus 会话、服务未运行等）视为不可用
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.TrimSpace(string(exitErr.Stderr)) == "" {
+			return nil, errKeyNotFound
+		}
+		return nil, secretToolError(err)
+	}
+	if strings.TrimSpace(string(out)) == "" {
+		return nil, errKeyNotFound
+	}
+	return decodeKeyringSecret(string(out))
+}
+
+func (k secretServiceKeyring) Set(key []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "secret-tool", "store", "--label=sorapc 数据加密密钥", "service", keyringService, "account", k.account)
+	cmd.Stdin = strings.NewReader(encodeKeyringSecret(key))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func secretToolError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("secret-tool: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return fmt.Errorf("secret-tool: %v", err)
+}
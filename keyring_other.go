@@ -0,0 +1,6 @@
+//go:build !windows && !darwin && !linux
+
+package main
+
+// 其他平台没有接入系统钥匙串，只使用 secret.key 文件
+func systemKeyring(account string) keyring { return nil }
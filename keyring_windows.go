@@ -0,0 +1,95 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Windows：通过 advapi32 的 CredReadW / CredWriteW 把密钥保存为凭据管理器中的“普通凭据”。
+
+var (
+	modAdvapi32    = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = modAdvapi32.NewProc("CredReadW")
+	procCredWriteW = modAdvapi32.NewProc("CredWriteW")
+	procCredFree   = modAdvapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errCredNotFound         = syscall.Errno(1168) // ERROR_NOT_FOUND
+)
+
+// winCredential 对应 Win32 CREDENTIALW
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+type winCredKeyring struct {
+	target string
+}
+
+func systemKeyring(account string) keyring {
+	return winCredKeyring{target: keyringService + ":" + account}
+}
+
+func (k winCredKeyring) Name() string { return "wincred:" + k.target }
+
+func (k winCredKeyring) Get() ([]byte, error) {
+	target, err := syscall.UTF16PtrFromString(k.target)
+	if err != nil {
+		return nil, err
+	}
+	var cred *winCredential
+	r, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(callErr, errCredNotFound) {
+			return nil, errKeyNotFound
+		}
+		return nil, fmt.Errorf("读取凭据管理器失败: %v", callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return nil, errKeyNotFound
+	}
+	return decodeKeyringSecret(string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)))
+}
+
+func (k winCredKeyring) Set(key []byte) error {
+	target, err := syscall.UTF16PtrFromString(k.target)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(keyringService)
+	if err != nil {
+		return err
+	}
+	blob := []byte(encodeKeyringSecret(key))
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if r, _, callErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return fmt.Errorf("写入凭据管理器失败: %v", callErr)
+	}
+	return nil
+}
@@ -17,10 +17,17 @@ func main() {
 	// 解析命令行参数
 	devMode := flag.Bool("dev", false, "启用开发模式（连接到开发服务器）")
 	debugMode := flag.Bool("debug", false, "启用调试模式")
+	dataDirFlag := flag.String("data-dir", "", "数据目录（数据库、下载、配置），默认读取环境变量 "+dataDirEnv+"，再退回系统应用数据目录")
 	flag.Parse()
 
+	dataDir, dataDirSource, err := resolveDataDir(*dataDirFlag)
+	if err != nil {
+		println("Error:", err.Error())
+		os.Exit(1)
+	}
+
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(dataDir, dataDirSource)
 
 	// 配置 AssetServer
 	assetServerOptions := &assetserver.Options{
@@ -41,14 +48,16 @@ func main() {
 	}
 
 	// Create application with options（Windows 默认最大化）
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:            "sorapc",
 		Width:            1024,
 		Height:           768,
 		WindowStartState: options.Maximised,
 		AssetServer:      assetServerOptions,
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
+		Logger:           newRedactingLogger(),
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 数据库迁移：schema 变更按版本号登记在 schemaMigrations 中，已执行的版本记录在 schema_migrations 表。
+// 启动时按顺序执行未应用的迁移，每个迁移及其登记在同一事务内完成，失败即回滚并中止启动流程（不再静默忽略）。
+// 新增表/列时在列表末尾追加一个版本，不要修改已发布的迁移。
+
+// schemaMigration 一个版本的 schema 变更
+type schemaMigration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+var schemaMigrations = []schemaMigration{
+	{1, "initial_schema", migrateInitialSchema},
+	{2, "legacy_columns", migrateLegacyColumns},
+	{3, "token_hash", func(tx *sql.Tx) error {
+		if err := addColumnIfMissing(tx, "tokens", "token_hash", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_tokens_token_hash ON tokens(token_hash)`)
+		return err
+	}},
+	{4, "token_snapshots", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS token_snapshots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token_id INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	source TEXT DEFAULT '',
+	videos_remaining INTEGER DEFAULT 0,
+	credit_remaining INTEGER DEFAULT 0,
+	access_resets_in_seconds INTEGER DEFAULT 0,
+	rate_limit_reached INTEGER DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_token_snapshots_token_created ON token_snapshots(token_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_token_snapshots_created_at ON token_snapshots(created_at);`)
+		return err
+	}},
+	{5, "token_groups", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS token_groups (
+	token_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (token_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_token_groups_name ON token_groups(name);`)
+		return err
+	}},
+	{6, "task_token_index", func(tx *sql.Tx) error {
+		// 调度器按账号统计未结束的任务数
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_video_task_results_token_id ON video_task_results(token_id)`)
+		return err
+	}},
+	{7, "token_health_checks", migrateTokenHealthChecks},
+}
+
+// migrateTokenHealthChecks 建立健康检查记录表，并把旧版本保存在 settings.token_health_history 中的记录搬进来
+func migrateTokenHealthChecks(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS token_health_checks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token_id INTEGER NOT NULL,
+	checked_at INTEGER NOT NULL,
+	ok INTEGER DEFAULT 0,
+	latency_ms INTEGER DEFAULT 0,
+	plan_type TEXT DEFAULT '',
+	error TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_token_health_checks_token_id ON token_health_checks(token_id, id);`); err != nil {
+		return err
+	}
+	var raw string
+	err := tx.QueryRow(`SELECT value FROM settings WHERE key = ?`, legacyHealthHistoryKey).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 旧数据无法解析时直接丢弃，不影响启动
+	var legacy map[int64][]tokenHealthEntry
+	if json.Unmarshal([]byte(raw), &legacy) == nil {
+		for id, list := range legacy {
+			for _, e := range list {
+				if _, err := tx.Exec(`INSERT INTO token_health_checks (token_id, checked_at, ok, latency_ms, plan_type, error)
+					SELECT ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM tokens WHERE id = ?)`,
+					id, e.CheckedAt, boolToInt(e.OK), e.LatencyMs, e.PlanType, e.Error, id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err = tx.Exec(`DELETE FROM settings WHERE key = ?`, legacyHealthHistoryKey)
+	return err
+}
+
+// latestSchemaVersion 当前程序支持的最高 schema 版本
+func latestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+func migrateInitialSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bearer_token TEXT NOT NULL,
+	host TEXT,
+	port INTEGER,
+	status_json TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token TEXT NOT NULL,
+	st TEXT,
+	rt TEXT,
+	client_id TEXT,
+	is_active INTEGER DEFAULT 1,
+	remark TEXT,
+	proxy_url TEXT,
+	image_enabled INTEGER DEFAULT 1,
+	video_enabled INTEGER DEFAULT 1,
+	image_concurrency INTEGER DEFAULT -1,
+	video_concurrency INTEGER DEFAULT 3,
+	status_json TEXT,
+	plan_type TEXT,
+	error_message TEXT DEFAULT '',
+	cooldown_until INTEGER DEFAULT 0,
+	last_used_at INTEGER DEFAULT 0,
+	error_count INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS video_task_results (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id TEXT UNIQUE NOT NULL,
+	token_id INTEGER,
+	result_json TEXT,
+	progress_pct REAL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	prompt TEXT DEFAULT '',
+	status TEXT DEFAULT '',
+	message TEXT DEFAULT '',
+	finished_at INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS video_downloads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	generation_id TEXT UNIQUE NOT NULL,
+	task_id TEXT,
+	post_id TEXT,
+	downloadable_url TEXT,
+	local_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS request_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at INTEGER NOT NULL,
+	source TEXT DEFAULT '',
+	method TEXT DEFAULT '',
+	url TEXT DEFAULT '',
+	status_code INTEGER DEFAULT 0,
+	duration_ms INTEGER DEFAULT 0,
+	token_id INTEGER,
+	request_body TEXT DEFAULT '',
+	response_body TEXT DEFAULT '',
+	error TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_request_logs_created_at ON request_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_request_logs_token_id ON request_logs(token_id);
+
+CREATE TABLE IF NOT EXISTS failure_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at INTEGER NOT NULL,
+	token_id INTEGER,
+	task_id TEXT DEFAULT '',
+	stage TEXT DEFAULT '',
+	message TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_failure_events_created_at ON failure_events(created_at);
+
+CREATE TABLE IF NOT EXISTS task_list (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`)
+	return err
+}
+
+// migrateLegacyColumns 引入迁移表之前的旧库可能缺少这些列，缺失时补齐
+func migrateLegacyColumns(tx *sql.Tx) error {
+	columns := []struct{ table, column, def string }{
+		{"tokens", "plan_type", "TEXT DEFAULT ''"},
+		{"tokens", "error_message", "TEXT DEFAULT ''"},
+		{"tokens", "cooldown_until", "INTEGER DEFAULT 0"},
+		{"tokens", "last_used_at", "INTEGER DEFAULT 0"},
+		{"tokens", "error_count", "INTEGER DEFAULT 0"},
+		{"video_task_results", "progress_pct", "REAL DEFAULT 0"},
+		{"video_task_results", "prompt", "TEXT DEFAULT ''"},
+		{"video_task_results", "token_id", "INTEGER"},
+		{"video_task_results", "status", "TEXT DEFAULT ''"},
+		{"video_task_results", "message", "TEXT DEFAULT ''"},
+		{"video_task_results", "finished_at", "INTEGER DEFAULT 0"},
+		{"video_downloads", "post_id", "TEXT DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing 列不存在时执行 ALTER TABLE ADD COLUMN，已存在则跳过
+func addColumnIfMissing(tx *sql.Tx, table, column, def string) error {
+	rows, err := tx.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	exists := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if strings.EqualFold(name, column) {
+			exists = true
+		}
+	}
+	rows.Close()
+	if exists {
+		return nil
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def)); err != nil {
+		return fmt.Errorf("%s 添加列 %s 失败: %v", table, column, err)
+	}
+	return nil
+}
+
+// appliedSchemaMigration schema_migrations 中的一行
+type appliedSchemaMigration struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt int64  `json:"applied_at"`
+}
+
+func loadAppliedMigrations(db *sql.DB) ([]appliedSchemaMigration, error) {
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []appliedSchemaMigration
+	for rows.Next() {
+		var m appliedSchemaMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// currentSchemaVersion 数据库已应用的最高 schema 版本，尚无 schema_migrations 表时为 0
+func currentSchemaVersion(db *sql.DB) (int, error) {
+	var v sql.NullInt64
+	err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&v)
+	if err != nil && strings.Contains(err.Error(), "no such table") {
+		return 0, nil
+	}
+	return int(v.Int64), err
+}
+
+// runSchemaMigrations 依次执行未应用的迁移，返回本次执行的版本号
+func runSchemaMigrations(db *sql.DB) ([]int, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`); err != nil {
+		return nil, err
+	}
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %v", err)
+	}
+	done := map[int]bool{}
+	for _, m := range applied {
+		done[m.Version] = true
+		if m.Version > latestSchemaVersion() {
+			return nil, fmt.Errorf("数据库版本 %d 高于当前程序支持的版本 %d，请升级程序", m.Version, latestSchemaVersion())
+		}
+	}
+
+	var ran []int
+	for _, m := range schemaMigrations {
+		if done[m.Version] {
+			continue
+		}
+		if err := applySchemaMigration(db, m); err != nil {
+			return ran, fmt.Errorf("迁移 %03d_%s 失败: %v", m.Version, m.Name, err)
+		}
+		ran = append(ran, m.Version)
+	}
+	return ran, nil
+}
+
+func applySchemaMigration(db *sql.DB, m schemaMigration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listSchemaMigrations 处理 GET /api/admin/migrations：已应用与待应用的迁移，以及启动时数据库初始化的错误
+func (a *App) listSchemaMigrations() (string, error) {
+	out := map[string]interface{}{
+		"latest_version":  latestSchemaVersion(),
+		"current_version": 0,
+		"applied":         []appliedSchemaMigration{},
+		"pending":         []map[string]interface{}{},
+		"error":           a.dbInitError(),
+		"storage":         "",
+	}
+	if a.store != nil {
+		out["storage"] = a.store.Kind()
+	}
+	db := a.db.Load()
+	if db == nil {
+		return jsonMarshal(out)
+	}
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		return jsonFail("读取迁移记录失败: " + err.Error())
+	}
+	done := map[int]bool{}
+	current := 0
+	for _, m := range applied {
+		done[m.Version] = true
+		if m.Version > current {
+			current = m.Version
+		}
+	}
+	pending := []map[string]interface{}{}
+	for _, m := range schemaMigrations {
+		if !done[m.Version] {
+			pending = append(pending, map[string]interface{}{"version": m.Version, "name": m.Name})
+		}
+	}
+	if applied != nil {
+		out["applied"] = applied
+	}
+	out["current_version"] = current
+	out["pending"] = pending
+	return jsonMarshal(out)
+}
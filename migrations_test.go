@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestDB 在临时目录打开一个空的 SQLite 数据库；无 CGO 时跳过
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), dbFileName))
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "requires cgo") {
+			t.Skip("SQLite 需要 CGO")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, m := range applied {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func TestSchemaMigrationVersions(t *testing.T) {
+	for i, m := range schemaMigrations {
+		if m.Version != i+1 || m.Name == "" || m.Up == nil {
+			t.Errorf("schemaMigrations[%d] = {%d %q}, want version %d with a name and Up", i, m.Version, m.Name, i+1)
+		}
+	}
+}
+
+func TestRunSchemaMigrations(t *testing.T) {
+	db := openTestDB(t)
+	var all []int
+	for _, m := range schemaMigrations {
+		all = append(all, m.Version)
+	}
+	ran, err := runSchemaMigrations(db)
+	if err != nil || !reflect.DeepEqual(ran, all) {
+		t.Fatalf("runSchemaMigrations() = %v, %v; want %v", ran, err, all)
+	}
+	if got := appliedVersions(t, db); !reflect.DeepEqual(got, all) {
+		t.Errorf("schema_migrations = %v, want %v", got, all)
+	}
+	if v, err := currentSchemaVersion(db); err != nil || v != latestSchemaVersion() {
+		t.Errorf("currentSchemaVersion() = %d, %v; want %d", v, err, latestSchemaVersion())
+	}
+	if ran, err := runSchemaMigrations(db); err != nil || len(ran) != 0 {
+		t.Errorf("second runSchemaMigrations() = %v, %v; want nothing to run", ran, err)
+	}
+
+	// 数据库版本高于程序支持的版本时拒绝启动
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', 0)`, latestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runSchemaMigrations(db); err == nil || !strings.Contains(err.Error(), "升级程序") {
+		t.Errorf("runSchemaMigrations(newer db) error = %v, want upgrade hint", err)
+	}
+}
+
+func TestRunSchemaMigrationsRollsBackFailure(t *testing.T) {
+	saved := schemaMigrations
+	defer func() { schemaMigrations = saved }()
+	next := latestSchemaVersion() + 1
+	schemaMigrations = append(append([]schemaMigration{}, saved...),
+		schemaMigration{next, "broken", func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE half_done (id INTEGER)`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`ALTER TABLE missing_table ADD COLUMN x TEXT`)
+			return err
+		}},
+		schemaMigration{next + 1, "after_broken", func(*sql.Tx) error { return nil }})
+
+	db := openTestDB(t)
+	ran, err := runSchemaMigrations(db)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("runSchemaMigrations() error = %v, want failure in broken", err)
+	}
+	// 失败前的迁移已登记，失败的迁移整体回滚，之后的迁移不再执行
+	if len(ran) != len(saved) {
+		t.Errorf("ran = %v, want the %d migrations before the failure", ran, len(saved))
+	}
+	if got := appliedVersions(t, db); len(got) != len(saved) {
+		t.Errorf("schema_migrations = %v, want only the successful migrations", got)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("failed migration left table behind: %d, %v", n, err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+	// 引入迁移框架之前的库：没有 schema_migrations，tokens 缺少后来增加的列
+	if _, err := db.Exec(`CREATE TABLE tokens (id INTEGER PRIMARY KEY AUTOINCREMENT, token TEXT NOT NULL, is_active INTEGER DEFAULT 1);
+INSERT INTO tokens (token) VALUES ('legacy');`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runSchemaMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	var token, planType string
+	var errorCount int
+	if err := db.QueryRow(`SELECT token, plan_type, error_count FROM tokens`).Scan(&token, &planType, &errorCount); err != nil {
+		t.Fatalf("legacy tokens table not upgraded: %v", err)
+	}
+	if token != "legacy" || planType != "" || errorCount != 0 {
+		t.Errorf("legacy row = %q, %q, %d", token, planType, errorCount)
+	}
+}
+
+func TestMigrateTokenHealthChecks(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range schemaMigrations {
+		if m.Version < 7 {
+			if err := applySchemaMigration(db, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// 旧版本把检查历史整体保存在 settings 中；已删除账号（id 9）的记录不搬迁
+	if _, err := db.Exec(`INSERT INTO tokens (id, token) VALUES (1, 't1')`); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"1":[{"checked_at":100,"ok":true,"latency_ms":30,"plan_type":"plus"},{"checked_at":200,"ok":false,"latency_ms":50,"error":"401"}],` +
+		`"9":[{"checked_at":100,"ok":true}]}`
+	if _, err := db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, legacyHealthHistoryKey, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := runSchemaMigrations(db); err != nil || !reflect.DeepEqual(ran, []int{7}) {
+		t.Fatalf("runSchemaMigrations() = %v, %v; want [7]", ran, err)
+	}
+
+	s := newSQLiteStore(db)
+	got, err := s.ListHealthChecks(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []tokenHealthEntry{
+		{TokenID: 1, CheckedAt: 200, LatencyMs: 50, Error: "401"},
+		{TokenID: 1, CheckedAt: 100, OK: true, LatencyMs: 30, PlanType: "plus"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListHealthChecks(1) = %+v, want %+v", got, want)
+	}
+	if orphan, _ := s.ListHealthChecks(9); len(orphan) != 0 {
+		t.Errorf("ListHealthChecks(9) = %+v, want none", orphan)
+	}
+	if _, err := s.GetSetting(legacyHealthHistoryKey); err != errNotFound {
+		t.Errorf("legacy setting still present: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 视频模型目录：前端与任务引擎只传目录中的模型名（如 sora2pro-hd-landscape-15s），
+// 由这里换算成上游 POST /videos 所需的 model/orientation/n_frames/size，并声明该模型需要的账号类型。
+// 选号时按账号的 plan_type 判断能力，不满足 RequiredPlan 的账号不会被分配。
+
+// 账号能力等级，数值越大能力越强；模型要求的等级不高于账号等级即可使用
+const (
+	planFree = "free"
+	planPlus = "plus"
+	planPro  = "pro"
+)
+
+var planTiers = map[string]int{planFree: 0, planPlus: 1, planPro: 2}
+
+// planTier 将 plan_type（chatgpt_free/chatgpt_plus/chatgpt_pro 等）换算为能力等级；空值与无法识别的值按 free 处理
+func planTier(planType string) int {
+	p := strings.ToLower(strings.TrimSpace(planType))
+	switch {
+	case strings.Contains(p, "pro"):
+		return planTiers[planPro]
+	case strings.Contains(p, "plus"), strings.Contains(p, "team"), strings.Contains(p, "enterprise"), strings.Contains(p, "edu"):
+		return planTiers[planPlus]
+	}
+	return planTiers[planFree]
+}
+
+// planSatisfies 账号类型是否满足模型要求的 required（free/plus/pro，空值视为 free）
+func planSatisfies(planType, required string) bool {
+	return planTier(planType) >= planTiers[strings.ToLower(strings.TrimSpace(required))]
+}
+
+// videoModel 目录中的一个模型
+type videoModel struct {
+	Name          string `json:"name"`
+	Label         string `json:"label"`
+	UpstreamModel string `json:"model"` // 上游模型 id：sy_8（标准）/ sy_ore（Pro）
+	Orientation   string `json:"orientation"`
+	NFrames       int    `json:"n_frames"`
+	Size          string `json:"size"`          // small / large（HD）
+	RequiredPlan  string `json:"required_plan"` // free / plus / pro
+}
+
+// videoModelCatalog 按 版本 × 画幅 × 时长 生成；Pro HD 不提供 25s
+var videoModelCatalog = buildVideoModelCatalog()
+
+func buildVideoModelCatalog() []videoModel {
+	versions := []struct {
+		prefix, label, upstream, size string
+		durations                     []string
+	}{
+		{"sora2", "标准版", "sy_8", "small", []string{"10s", "15s", "25s"}},
+		{"sora2pro", "Pro", "sy_ore", "small", []string{"10s", "15s", "25s"}},
+		{"sora2pro-hd", "Pro HD", "sy_ore", "large", []string{"10s", "15s"}},
+	}
+	frames := map[string]int{"10s": 300, "15s": 450, "25s": 750}
+	orientations := []struct{ name, label string }{{"landscape", "横屏"}, {"portrait", "竖屏"}}
+
+	var list []videoModel
+	for _, v := range versions {
+		for _, o := range orientations {
+			for _, d := range v.durations {
+				required := planFree
+				// Pro 模型与 25s 时长需要 Pro 账号
+				if v.upstream == "sy_ore" || d == "25s" {
+					required = planPro
+				}
+				list = append(list, videoModel{
+					Name:          fmt.Sprintf("%s-%s-%s", v.prefix, o.name, d),
+					Label:         fmt.Sprintf("%s %s %s", v.label, o.label, d),
+					UpstreamModel: v.upstream,
+					Orientation:   o.name,
+					NFrames:       frames[d],
+					Size:          v.size,
+					RequiredPlan:  required,
+				})
+			}
+		}
+	}
+	return list
+}
+
+// lookupVideoModel 按名称（不区分大小写）查找模型
+func lookupVideoModel(name string) (videoModel, error) {
+	name = strings.TrimSpace(name)
+	for _, m := range videoModelCatalog {
+		if strings.EqualFold(m.Name, name) {
+			return m, nil
+		}
+	}
+	if name == "" {
+		return videoModel{}, fmt.Errorf("未指定模型")
+	}
+	return videoModel{}, fmt.Errorf("未知的模型: %s", name)
+}
+
+// GetVideoModels 返回模型目录，以及每个模型当前可用的账号数（已启用、启用视频且账号类型满足要求）
+// 返回 JSON：{"success": true, "models": [{name, label, model, orientation, n_frames, size, required_plan, eligible_tokens}]}
+func (a *App) GetVideoModels() (string, error) {
+	var tokens []tokenRecord
+	if a.store != nil {
+		tokens, _ = a.store.ListTokens()
+	}
+	type modelView struct {
+		videoModel
+		EligibleTokens int `json:"eligible_tokens"`
+	}
+	list := make([]modelView, 0, len(videoModelCatalog))
+	for _, m := range videoModelCatalog {
+		n := 0
+		for _, t := range tokens {
+			if t.IsActive && t.VideoEnabled && planSatisfies(t.PlanType, m.RequiredPlan) {
+				n++
+			}
+		}
+		list = append(list, modelView{videoModel: m, EligibleTokens: n})
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "models": list})
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPlanSatisfies(t *testing.T) {
+	tests := []struct {
+		planType, required string
+		want               bool
+	}{
+		{"", "", true},
+		{"", "free", true},
+		{"", "plus", false},
+		{"chatgpt_free", "free", true},
+		{"chatgpt_free", "plus", false},
+		{"chatgpt_plus", "plus", true},
+		{"chatgpt_plus", "pro", false},
+		{"chatgpt_team", "plus", true},
+		{"chatgpt_enterprise", "plus", true},
+		{"chatgpt_edu", "plus", true},
+		{"chatgpt_pro", "pro", true},
+		{"chatgpt_pro", "plus", true},
+		{" CHATGPT_PRO ", " Pro ", true},
+		{"unknown", "free", true},
+		{"unknown", "plus", false},
+	}
+	for _, tt := range tests {
+		if got := planSatisfies(tt.planType, tt.required); got != tt.want {
+			t.Errorf("planSatisfies(%q, %q) = %v, want %v", tt.planType, tt.required, got, tt.want)
+		}
+	}
+}
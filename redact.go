@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/logger"
+)
+
+// 日志脱敏：所有 runtime.Log* 输出经 redactingLogger 统一处理，写入 SQLite 的日志（request_logs、failure_events、
+// error_message 等）也调用 redactSecrets，屏蔽 bearer token、st/rt、custom_parse_token、URL 中的 user:pass@ 等凭证，便于直接分享日志。
+
+// sensitiveKeyPattern 需要脱敏的字段名（JSON 字段、URL 参数、key=value 形式）
+const sensitiveKeyPattern = `bearer_token|access_token|accessToken|token|st|session_token|rt|refresh_token|custom_parse_token|parse_token|password|old_password|new_password|api_key|new_api_key|authorization`
+
+var (
+	// "key": "value"（允许值中含转义字符）
+	jsonSecretRe = regexp.MustCompile(`(?i)("(?:` + sensitiveKeyPattern + `)"\s*:\s*")((?:[^"\\]|\\.)*)(")`)
+	// \"key\": \"value\"（嵌在 JSON 字符串或 %q 输出中的 JSON），值在下一个 \" 处结束
+	escapedJSONSecretRe = regexp.MustCompile(`(?i)(\\"(?:` + sensitiveKeyPattern + `)\\"\s*:\s*\\")((?:[^"\\]|\\[^"])*)(\\")`)
+	// ?key=value / &key=value / key=value（日志中常见的 URL 参数与 fmt 输出）
+	querySecretRe = regexp.MustCompile(`(?i)((?:^|[?&\s,;(])(?:` + sensitiveKeyPattern + `)=)([^&\s"',;)]+)`)
+	// Authorization: Bearer xxx
+	bearerSecretRe = regexp.MustCompile(`(?i)(bearer\s+)([A-Za-z0-9._~+/=\-]+)`)
+	// URL 中的 user:pass@（代理地址等）
+	urlUserinfoRe = regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.\-]*://)([^/\s@"']+)@`)
+	// 独立出现的 JWT（access token）
+	jwtSecretRe = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]+`)
+)
+
+// redactSecrets 脱敏任意文本中的凭证，保留首尾少量字符便于辨认是哪个账号
+func redactSecrets(s string) string {
+	if s == "" {
+		return s
+	}
+	s = jsonSecretRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := jsonSecretRe.FindStringSubmatch(m)
+		return p[1] + maskSecret(p[2]) + p[3]
+	})
+	s = escapedJSONSecretRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := escapedJSONSecretRe.FindStringSubmatch(m)
+		return p[1] + maskSecret(p[2]) + p[3]
+	})
+	s = querySecretRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := querySecretRe.FindStringSubmatch(m)
+		return p[1] + maskSecret(p[2])
+	})
+	s = urlUserinfoRe.ReplaceAllString(s, "${1}***@")
+	s = bearerSecretRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := bearerSecretRe.FindStringSubmatch(m)
+		return p[1] + maskSecret(p[2])
+	})
+	return jwtSecretRe.ReplaceAllStringFunc(s, maskSecret)
+}
+
+// maskSecret 只保留首尾各 4 个字符；过短或已脱敏的值整体替换
+func maskSecret(s string) string {
+	if s == "" || strings.Contains(s, "***") {
+		return s
+	}
+	if len(s) <= 12 {
+		return "***"
+	}
+	return s[:4] + "***" + s[len(s)-4:]
+}
+
+// redactingLogger 包装 Wails 默认 logger，runtime.Log* 及前端 runtime 日志在输出前统一脱敏
+type redactingLogger struct {
+	next logger.Logger
+}
+
+func newRedactingLogger() logger.Logger {
+	return &redactingLogger{next: logger.NewDefaultLogger()}
+}
+
+func (l *redactingLogger) Print(message string)   { l.next.Print(redactSecrets(message)) }
+func (l *redactingLogger) Trace(message string)   { l.next.Trace(redactSecrets(message)) }
+func (l *redactingLogger) Debug(message string)   { l.next.Debug(redactSecrets(message)) }
+func (l *redactingLogger) Info(message string)    { l.next.Info(redactSecrets(message)) }
+func (l *redactingLogger) Warning(message string) { l.next.Warning(redactSecrets(message)) }
+func (l *redactingLogger) Error(message string)   { l.next.Error(redactSecrets(message)) }
+func (l *redactingLogger) Fatal(message string)   { l.next.Fatal(redactSecrets(message)) }
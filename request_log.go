@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 请求日志：ApiRequest、CreateVideo、PollPending、FetchDrafts、simplePostJSON 的每次出站请求写入 request_logs，
+// 记录方法、URL、状态码、耗时、账号与脱敏（redactSecrets）截断后的请求/响应体，供 /api/logs 分页查询。
+
+const (
+	// requestLogBodyLimit 请求/响应体最多保存的字节数
+	requestLogBodyLimit = 4096
+	// requestLogMaxRows request_logs 最多保留的行数，超出后删除最早的记录
+	requestLogMaxRows = 5000
+	// requestLogPruneEvery 每写入多少条检查一次是否需要清理
+	requestLogPruneEvery = 100
+)
+
+var requestLogWrites int64
+
+// requestLogEntry 一次出站请求的记录
+type requestLogEntry struct {
+	Source       string
+	Method       string
+	URL          string
+	StatusCode   int
+	Duration     time.Duration
+	TokenID      int64
+	RequestBody  string
+	ResponseBody string
+	Error        string
+}
+
+// requestLogRecord request_logs 中的一行；TokenID 为 0 表示未关联账号
+type requestLogRecord struct {
+	ID           int64
+	CreatedAt    int64
+	Source       string
+	Method       string
+	URL          string
+	StatusCode   int
+	DurationMs   int64
+	TokenID      int64
+	RequestBody  string
+	ResponseBody string
+	Error        string
+}
+
+// requestLogFilter /api/logs 的查询条件，零值表示不过滤；StatusClass 为 success 或 error
+type requestLogFilter struct {
+	TokenID     int64
+	Method      string
+	Source      string
+	StatusClass string
+	StatusCode  int
+	Query       string
+	Since       int64
+	Until       int64
+}
+
+// doLoggedRequest 执行请求并读取完整响应体，同时写入 request_logs；reqBody 仅用于记录
+func (a *App) doLoggedRequest(client *http.Client, req *http.Request, source string, tokenID int64, reqBody []byte) (int, []byte, error) {
+	start := time.Now()
+	entry := requestLogEntry{
+		Source:      source,
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		TokenID:     tokenID,
+		RequestBody: string(reqBody),
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		entry.Duration = time.Since(start)
+		entry.Error = err.Error()
+		a.writeRequestLog(entry)
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	entry.Duration = time.Since(start)
+	entry.StatusCode = resp.StatusCode
+	entry.ResponseBody = string(body)
+	if err != nil {
+		entry.Error = "读取响应失败: " + err.Error()
+	}
+	a.writeRequestLog(entry)
+	return resp.StatusCode, body, err
+}
+
+// writeRequestLog 写入一条请求日志，失败只记录到控制台；JSON 文件存储不记录
+func (a *App) writeRequestLog(e requestLogEntry) {
+	if a.store == nil {
+		return
+	}
+	err := a.store.InsertRequestLog(requestLogRecord{
+		CreatedAt:    time.Now().Unix(),
+		Source:       e.Source,
+		Method:       e.Method,
+		URL:          redactSecrets(e.URL),
+		StatusCode:   e.StatusCode,
+		DurationMs:   e.Duration.Milliseconds(),
+		TokenID:      e.TokenID,
+		RequestBody:  limitLogBody(redactSecrets(e.RequestBody)),
+		ResponseBody: limitLogBody(redactSecrets(e.ResponseBody)),
+		Error:        redactSecrets(e.Error),
+	})
+	if errors.Is(err, errHistoryUnavailable) {
+		return
+	}
+	if err != nil {
+		a.logError(fmt.Sprintf("写入请求日志失败: %v", err))
+		return
+	}
+	if atomic.AddInt64(&requestLogWrites, 1)%requestLogPruneEvery == 0 {
+		if err := a.store.PruneRequestLogs(requestLogMaxRows); err != nil {
+			a.logError(fmt.Sprintf("清理请求日志失败: %v", err))
+		}
+	}
+}
+
+// limitLogBody 截断过长的内容，避免日志表膨胀
+func limitLogBody(s string) string {
+	if len(s) <= requestLogBodyLimit {
+		return s
+	}
+	cut := requestLogBodyLimit
+	for cut > 0 && !isRuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("...(truncated, %d bytes)", len(s))
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// listRequestLogs 处理 GET /api/logs?page=&limit=&token_id=&method=&source=&status=&q=&since=&until=
+// status 可为 success（<400）、error（>=400 或请求失败）或具体状态码；since/until 为 unix 秒。
+// JSON 文件存储下不记录日志，返回空列表与 available:false
+func (a *App) listRequestLogs(rawPath string) (string, error) {
+	u, _ := url.Parse(rawPath)
+	q := u.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	var f requestLogFilter
+	if v, err := strconv.ParseInt(q.Get("token_id"), 10, 64); err == nil && v > 0 {
+		f.TokenID = v
+	}
+	f.Method = strings.ToUpper(strings.TrimSpace(q.Get("method")))
+	f.Source = strings.TrimSpace(q.Get("source"))
+	switch v := strings.TrimSpace(q.Get("status")); v {
+	case "":
+	case "success", "error":
+		f.StatusClass = v
+	default:
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return jsonFail("status 参数无效: " + v)
+		}
+		f.StatusCode = code
+	}
+	f.Query = strings.TrimSpace(q.Get("q"))
+	if v, err := strconv.ParseInt(q.Get("since"), 10, 64); err == nil && v > 0 {
+		f.Since = v
+	}
+	if v, err := strconv.ParseInt(q.Get("until"), 10, 64); err == nil && v > 0 {
+		f.Until = v
+	}
+
+	if a.store == nil {
+		return jsonMarshal(map[string]interface{}{"logs": []interface{}{}, "total": 0, "page": page, "limit": limit, "available": false})
+	}
+	list, total, err := a.store.ListRequestLogs(f, limit, (page-1)*limit)
+	if errors.Is(err, errHistoryUnavailable) {
+		return jsonMarshal(map[string]interface{}{"logs": []interface{}{}, "total": 0, "page": page, "limit": limit, "available": false})
+	}
+	if err != nil {
+		return jsonFail("查询日志失败: " + err.Error())
+	}
+
+	logs := []map[string]interface{}{}
+	for _, l := range list {
+		path := l.URL
+		if pu, err := url.Parse(l.URL); err == nil && pu.Path != "" {
+			path = pu.Path
+		}
+		var tokenID interface{}
+		if l.TokenID > 0 {
+			tokenID = l.TokenID
+		}
+		logs = append(logs, map[string]interface{}{
+			"id":            l.ID,
+			"timestamp":     l.CreatedAt,
+			"source":        l.Source,
+			"method":        l.Method,
+			"url":           l.URL,
+			"path":          path,
+			"status":        l.StatusCode,
+			"duration":      l.DurationMs,
+			"token_id":      tokenID,
+			"request_body":  l.RequestBody,
+			"response_body": l.ResponseBody,
+			"error":         l.Error,
+		})
+	}
+	return jsonMarshal(map[string]interface{}{"logs": logs, "total": total, "page": page, "limit": limit, "available": true})
+}
+
+// clearRequestLogs 处理 DELETE /api/logs
+func (a *App) clearRequestLogs() (string, error) {
+	if a.store == nil {
+		return jsonMarshal(map[string]interface{}{"success": true, "deleted": 0, "available": false})
+	}
+	n, err := a.store.ClearRequestLogs()
+	if errors.Is(err, errHistoryUnavailable) {
+		return jsonMarshal(map[string]interface{}{"success": true, "deleted": 0, "available": false})
+	}
+	if err != nil {
+		return jsonFail("清空日志失败: " + err.Error())
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "deleted": n, "available": true})
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 令牌加密存储：tokens.token/st/rt 与 accounts.bearer_token 以 AES-256-GCM 加密后写入 accounts.db（前缀 enc:v1:），
+// 读取时透明解密；不带前缀的旧明文原样返回，并在启动时一次性迁移为密文。
+// 密钥来源：设置环境变量 SORAPC_PASSPHRASE 时由口令经 PBKDF2-SHA256 派生（盐保存在 settings 表）；
+// 否则从 keyring 读取随机密钥（首次自动生成）：优先使用系统钥匙串（Windows 凭据管理器、macOS 钥匙串、Linux Secret Service），
+// 系统钥匙串不可用时退回数据目录下的 secret.key 文件（0600）；已有 secret.key 且系统钥匙串可用时迁移进钥匙串并删除文件。
+// 因为密文带随机 nonce，按 token 查找账号改用 tokens.token_hash（HMAC-SHA256）。
+
+const (
+	encryptedPrefix  = "enc:v1:"
+	passphraseEnv    = "SORAPC_PASSPHRASE"
+	secretKeyFile    = "secret.key"
+	pbkdf2Iterations = 200000
+
+	secretSaltSettingKey   = "secret_salt"
+	secretCheckSettingKey  = "secret_check"
+	secretSourceSettingKey = "secret_key_source"
+	// secretKeyringSettingKey 系统钥匙串中的账号名（随机 id，随数据库一起迁移，换数据目录后仍能找到密钥）
+	secretKeyringSettingKey = "secret_keyring_account"
+	secretCheckPlain        = "sorapc-secret-check"
+
+	// keyringService 系统钥匙串中的服务名
+	keyringService = "sorapc"
+	// keyringCommandTimeout 调用 security / secret-tool 的超时（钥匙串加锁时可能弹出解锁提示）
+	keyringCommandTimeout = 30 * time.Second
+)
+
+var errKeyNotFound = errors.New("密钥不存在")
+
+// keyring 保存数据加密密钥的后端；各平台的系统钥匙串由 systemKeyring 提供（keyring_<os>.go）
+type keyring interface {
+	Name() string
+	Get() ([]byte, error) // 不存在时返回 errKeyNotFound
+	Set(key []byte) error
+}
+
+// keyringAccount 读取数据库对应的钥匙串账号名，没有时生成一个
+func keyringAccount(store settingsStore) (string, error) {
+	if v := settingValue(store, secretKeyringSettingKey); v != "" {
+		return v, nil
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	account := "db-" + hex.EncodeToString(b)
+	if err := store.SetSetting(secretKeyringSettingKey, account); err != nil {
+		return "", err
+	}
+	return account, nil
+}
+
+// encodeKeyringSecret / decodeKeyringSecret 密钥在钥匙串与 secret.key 中均以 base64 保存
+func encodeKeyringSecret(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func decodeKeyringSecret(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("密钥格式无效")
+	}
+	return key, nil
+}
+
+// fileKeyring 将密钥以 base64 保存在本地文件中（仅当前用户可读写）
+type fileKeyring struct {
+	path string
+}
+
+func (k fileKeyring) Name() string { return "file:" + k.path }
+
+func (k fileKeyring) Get() ([]byte, error) {
+	data, err := os.ReadFile(k.path)
+	if os.IsNotExist(err) {
+		return nil, errKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeKeyringSecret(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("密钥文件 %s 格式无效", k.path)
+	}
+	return key, nil
+}
+
+func (k fileKeyring) Set(key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, []byte(encodeKeyringSecret(key)), 0600)
+}
+
+// loadKeyringKey 按 系统钥匙串 → secret.key 的顺序读取密钥；都没有时生成新密钥，优先保存到系统钥匙串。
+// 系统钥匙串读取出错（如 Linux 下没有 Secret Service）时视为不可用，只使用 secret.key；warn 用于输出降级提示
+func loadKeyringKey(store settingsStore, dataDir string, warn func(msg string)) ([]byte, keyring, error) {
+	file := fileKeyring{path: filepath.Join(dataDir, secretKeyFile)}
+	account, err := keyringAccount(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	sys := systemKeyring(account)
+	if sys != nil {
+		k, err := sys.Get()
+		if err == nil {
+			return k, sys, nil
+		}
+		if err != errKeyNotFound {
+			warn(fmt.Sprintf("系统钥匙串 %s 不可用，使用密钥文件: %v", sys.Name(), err))
+			sys = nil
+		}
+	}
+
+	k, err := file.Get()
+	switch {
+	case err == nil:
+		if sys == nil {
+			return k, file, nil
+		}
+		// 迁移到系统钥匙串：写入并读回确认后再删除文件，任一步失败都继续使用文件
+		if err := sys.Set(k); err != nil {
+			warn(fmt.Sprintf("密钥迁移到 %s 失败，继续使用密钥文件: %v", sys.Name(), err))
+			return k, file, nil
+		}
+		if got, err := sys.Get(); err != nil || !hmac.Equal(got, k) {
+			warn(fmt.Sprintf("密钥迁移到 %s 后读回不一致，继续使用密钥文件", sys.Name()))
+			return k, file, nil
+		}
+		if err := os.Remove(file.path); err != nil {
+			warn(fmt.Sprintf("删除密钥文件 %s 失败: %v", file.path, err))
+		}
+		return k, sys, nil
+	case err != errKeyNotFound:
+		return nil, nil, err
+	}
+
+	var kr keyring = file
+	if sys != nil {
+		kr = sys
+	}
+	if settingValue(store, secretCheckSettingKey) != "" {
+		return nil, nil, fmt.Errorf("数据库已加密但找不到密钥（系统钥匙串与 %s 中均没有），如使用口令请设置环境变量 %s", file.path, passphraseEnv)
+	}
+	k = make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		return nil, nil, err
+	}
+	if err := kr.Set(k); err != nil {
+		if kr == keyring(file) {
+			return nil, nil, fmt.Errorf("保存密钥失败: %v", err)
+		}
+		warn(fmt.Sprintf("保存密钥到 %s 失败，改用密钥文件: %v", kr.Name(), err))
+		if err := file.Set(k); err != nil {
+			return nil, nil, fmt.Errorf("保存密钥失败: %v", err)
+		}
+		kr = file
+	}
+	return k, kr, nil
+}
+
+// exportKeyringKey 读出当前使用的数据密钥（系统钥匙串优先，其次 secret.key），用于备份时导出；
+// 口令派生的密钥不导出，恢复时设置相同口令即可
+func exportKeyringKey(store settingsStore, dataDir string) ([]byte, error) {
+	if os.Getenv(passphraseEnv) != "" {
+		return nil, fmt.Errorf("当前使用口令（%s）加密，密钥无需导出，恢复时设置相同口令即可", passphraseEnv)
+	}
+	if account := settingValue(store, secretKeyringSettingKey); account != "" {
+		if sys := systemKeyring(account); sys != nil {
+			if k, err := sys.Get(); err == nil {
+				return k, nil
+			}
+		}
+	}
+	return fileKeyring{path: filepath.Join(dataDir, secretKeyFile)}.Get()
+}
+
+// secretBox 负责加解密与计算查找用的哈希
+type secretBox struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func newSecretBox(key []byte) (*secretBox, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("sorapc-token-hash"))
+	return &secretBox{aead: aead, macKey: mac.Sum(nil)}, nil
+}
+
+// encrypt 加密明文；空串与已加密的值原样返回
+func (b *secretBox) encrypt(plain string) (string, error) {
+	if plain == "" || strings.HasPrefix(plain, encryptedPrefix) {
+		return plain, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt 解密带前缀的密文；不带前缀的旧明文原样返回
+func (b *secretBox) decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("密文格式无效: %v", err)
+	}
+	n := b.aead.NonceSize()
+	if len(raw) < n {
+		return "", fmt.Errorf("密文长度无效")
+	}
+	plain, err := b.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败（密钥不匹配或数据损坏）")
+	}
+	return string(plain), nil
+}
+
+// hash 计算 token 的 HMAC，用于不解密即可按 token 查找/去重
+func (b *secretBox) hash(plain string) string {
+	mac := hmac.New(sha256.New, b.macKey)
+	mac.Write([]byte(plain))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// pbkdf2Key PBKDF2（RFC 8018），prf 为 HMAC-h
+func pbkdf2Key(h func() hash.Hash, password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	out := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	for i := 1; i <= blocks; i++ {
+		buf[0], buf[1], buf[2], buf[3] = byte(i>>24), byte(i>>16), byte(i>>8), byte(i)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(buf)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+// settingValue 读取设置，不存在或出错时返回空串
+func settingValue(store settingsStore, key string) string {
+	v, _ := store.GetSetting(key)
+	return v
+}
+
+// loadSecretBox 按口令或 keyring 取得密钥，并用 settings 中的校验值确认密钥与数据库匹配
+func loadSecretBox(store settingsStore, dataDir string, warn func(msg string)) (*secretBox, string, error) {
+	var key []byte
+	var source string
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		salt, err := base64.StdEncoding.DecodeString(settingValue(store, secretSaltSettingKey))
+		if err != nil || len(salt) < 16 {
+			salt = make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return nil, "", err
+			}
+			if err := store.SetSetting(secretSaltSettingKey, base64.StdEncoding.EncodeToString(salt)); err != nil {
+				return nil, "", err
+			}
+		}
+		key = pbkdf2Key(sha256.New, []byte(pass), salt, pbkdf2Iterations, 32)
+		source = "passphrase"
+	} else {
+		k, kr, err := loadKeyringKey(store, dataDir, warn)
+		if err != nil {
+			return nil, "", err
+		}
+		key = k
+		source = "keyring:" + kr.Name()
+	}
+
+	box, err := newSecretBox(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if check := settingValue(store, secretCheckSettingKey); check != "" {
+		if plain, err := box.decrypt(check); err != nil || plain != secretCheckPlain {
+			return nil, "", fmt.Errorf("密钥与数据库不匹配（口令错误或密钥文件已更换）")
+		}
+	} else {
+		check, err := box.encrypt(secretCheckPlain)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := store.SetSetting(secretCheckSettingKey, check); err != nil {
+			return nil, "", err
+		}
+	}
+	_ = store.SetSetting(secretSourceSettingKey, strings.SplitN(source, ":", 2)[0])
+	return box, source, nil
+}
+
+// sealSecret 加密待写入数据库的凭证；加密不可用时原样返回
+func (a *App) sealSecret(plain string) (string, error) {
+	box := a.secrets.Load()
+	if box == nil {
+		return plain, nil
+	}
+	return box.encrypt(plain)
+}
+
+// openSecret 解密从数据库读出的凭证，失败时记录日志并返回空串
+func (a *App) openSecret(stored string) string {
+	box := a.secrets.Load()
+	if box == nil {
+		return stored
+	}
+	plain, err := box.decrypt(stored)
+	if err != nil {
+		a.logError("读取加密凭证失败: " + err.Error())
+		return ""
+	}
+	return plain
+}
+
+// tokenHash 返回 token 的查找哈希（加密不可用时为空）
+func (a *App) tokenHash(plain string) string {
+	box := a.secrets.Load()
+	if box == nil || plain == "" {
+		return ""
+	}
+	return box.hash(strings.TrimSpace(plain))
+}
+
+// sealTokenFields 加密 token/st/rt，任一失败即返回错误
+func (a *App) sealTokenFields(token, st, rt string) (string, string, string, error) {
+	encToken, err := a.sealSecret(token)
+	if err != nil {
+		return "", "", "", err
+	}
+	encSt, err := a.sealSecret(st)
+	if err != nil {
+		return "", "", "", err
+	}
+	encRt, err := a.sealSecret(rt)
+	if err != nil {
+		return "", "", "", err
+	}
+	return encToken, encSt, encRt, nil
+}
+
+// migrateSecretsAtRest 一次性把旧明文 token/st/rt 与 accounts.bearer_token 加密，并补齐 token_hash；
+// 已加密的值不会重复处理，中途失败后下次启动会继续
+func (a *App) migrateSecretsAtRest() error {
+	box := a.secrets.Load()
+	if a.store == nil || box == nil {
+		return nil
+	}
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		return err
+	}
+	migrated := 0
+	for _, t := range tokens {
+		needsEncryption := false
+		for _, v := range []string{t.Token, t.St, t.Rt} {
+			if v != "" && !strings.HasPrefix(v, encryptedPrefix) {
+				needsEncryption = true
+			}
+		}
+		if !needsEncryption && t.TokenHash != "" {
+			continue
+		}
+		err := a.store.UpdateToken(t.ID, func(t *tokenRecord) error {
+			plainToken, err := box.decrypt(t.Token)
+			if err != nil {
+				return err
+			}
+			if t.Token, t.St, t.Rt, err = a.sealTokenFields(t.Token, t.St, t.Rt); err != nil {
+				return err
+			}
+			t.TokenHash = a.tokenHash(plainToken)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("token %d: %v", t.ID, err)
+		}
+		migrated++
+	}
+
+	accounts, err := a.migrateAccountSecrets()
+	if err != nil {
+		return err
+	}
+	if migrated > 0 || accounts > 0 {
+		a.logInfo(fmt.Sprintf("[Secrets] 已加密迁移 %d 个 token、%d 条 accounts 记录", migrated, accounts))
+	}
+	return nil
+}
+
+// migrateAccountSecrets 加密 accounts 表中的明文 bearer_token（仅 SQLite）
+func (a *App) migrateAccountSecrets() (int, error) {
+	db := a.db.Load()
+	if db == nil {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`SELECT id, bearer_token FROM accounts WHERE bearer_token NOT LIKE ?`, encryptedPrefix+"%")
+	if err != nil {
+		return 0, err
+	}
+	type accountRow struct {
+		id     int64
+		bearer string
+	}
+	var accounts []accountRow
+	for rows.Next() {
+		var r accountRow
+		if err := rows.Scan(&r.id, &r.bearer); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("读取 accounts 记录失败: %v", err)
+		}
+		accounts = append(accounts, r)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range accounts {
+		enc, err := a.sealSecret(r.bearer)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE accounts SET bearer_token=? WHERE id=?`, enc, r.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(accounts), tx.Commit()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestSecretBox(t *testing.T, fill byte) *secretBox {
+	t.Helper()
+	box, err := newSecretBox(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	for _, plain := range []string{"eyJhbGciOi.payload.sig", "含中文的 session token", " "} {
+		sealed, err := box.encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sealed, encryptedPrefix) || strings.Contains(sealed, plain) {
+			t.Errorf("encrypt(%q) = %q, want opaque %s value", plain, sealed, encryptedPrefix)
+		}
+		again, _ := box.encrypt(plain)
+		if again == sealed {
+			t.Errorf("encrypt(%q) reused a nonce", plain)
+		}
+		if got, err := box.decrypt(sealed); err != nil || got != plain {
+			t.Errorf("decrypt(encrypt(%q)) = %q, %v", plain, got, err)
+		}
+	}
+}
+
+func TestSecretBoxPassthrough(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	sealed, _ := box.encrypt("secret")
+	tests := []struct {
+		name string
+		fn   func(string) (string, error)
+		in   string
+	}{
+		{"encrypt empty", box.encrypt, ""},
+		{"encrypt already sealed", box.encrypt, sealed},
+		{"decrypt legacy plaintext", box.decrypt, "plain-token"},
+		{"decrypt empty", box.decrypt, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.fn(tt.in); err != nil || got != tt.in {
+				t.Errorf("got %q, %v; want input unchanged", got, err)
+			}
+		})
+	}
+}
+
+func TestSecretBoxDecryptErrors(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	sealed, _ := box.encrypt("secret")
+	raw := []byte(sealed)
+	raw[len(raw)-2] ^= 1
+	tests := []struct {
+		name   string
+		box    *secretBox
+		stored string
+	}{
+		{"wrong key", newTestSecretBox(t, 2), sealed},
+		{"tampered", box, string(raw)},
+		{"bad base64", box, encryptedPrefix + "!!!"},
+		{"too short", box, encryptedPrefix + "AAAA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.box.decrypt(tt.stored); err == nil {
+				t.Errorf("decrypt() = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestSecretBoxHash(t *testing.T) {
+	a, b := newTestSecretBox(t, 1), newTestSecretBox(t, 2)
+	if a.hash("tok") != a.hash("tok") || a.hash("tok") == a.hash("tok2") || a.hash("tok") == b.hash("tok") {
+		t.Error("hash() must be deterministic per key and differ across tokens and keys")
+	}
+}
+
+func TestPBKDF2Key(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 的公开测试向量：P="password"，S="salt"
+	tests := []struct {
+		iter int
+		want string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(pbkdf2Key(sha256.New, []byte("password"), []byte("salt"), tt.iter, 32)); got != tt.want {
+			t.Errorf("pbkdf2Key(iter=%d) = %s, want %s", tt.iter, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSecretBoxPassphrase(t *testing.T) {
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warn := func(msg string) { t.Log(msg) }
+	t.Setenv(passphraseEnv, "correct horse")
+	box, source, err := loadSecretBox(store, t.TempDir(), warn)
+	if err != nil || source != "passphrase" {
+		t.Fatalf("loadSecretBox() = %q, %v", source, err)
+	}
+	sealed, _ := box.encrypt("secret")
+
+	// 同一口令再次打开得到相同密钥
+	again, _, err := loadSecretBox(store, t.TempDir(), warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := again.decrypt(sealed); err != nil || got != "secret" {
+		t.Errorf("reopened box decrypt = %q, %v", got, err)
+	}
+	t.Setenv(passphraseEnv, "wrong")
+	if _, _, err := loadSecretBox(store, t.TempDir(), warn); err == nil || !strings.Contains(err.Error(), "不匹配") {
+		t.Errorf("loadSecretBox(wrong passphrase) error = %v, want mismatch", err)
+	}
+}
+
+func TestMigrateSecretsAtRest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		a := &App{store: s}
+		if sq, ok := s.(*sqliteStore); ok {
+			a.db.Store(sq.db)
+			if _, err := sq.db.Exec(`INSERT INTO accounts (bearer_token) VALUES ('plain-bearer')`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		box := newTestSecretBox(t, 1)
+		preSealed, _ := box.encrypt("sealed-at")
+		plainID, err := s.InsertToken(tokenRecord{Token: "plain-at", St: "plain-st", IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealedID, err := s.InsertToken(tokenRecord{Token: preSealed, IsActive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.secrets.Store(box)
+		if err := a.migrateSecretsAtRest(); err != nil {
+			t.Fatal(err)
+		}
+
+		tok, _ := s.GetToken(plainID)
+		if !strings.HasPrefix(tok.Token, encryptedPrefix) || !strings.HasPrefix(tok.St, encryptedPrefix) || tok.Rt != "" {
+			t.Errorf("token fields not sealed: %+v", tok)
+		}
+		if a.openSecret(tok.Token) != "plain-at" || a.openSecret(tok.St) != "plain-st" || tok.TokenHash != box.hash("plain-at") {
+			t.Errorf("migrated token does not round-trip: %+v", tok)
+		}
+		// 已加密的值不重复加密，只补齐 token_hash
+		tok, _ = s.GetToken(sealedID)
+		if tok.Token != preSealed || tok.TokenHash != box.hash("sealed-at") {
+			t.Errorf("pre-sealed token = %+v", tok)
+		}
+		before, _ := s.ListTokens()
+		if err := a.migrateSecretsAtRest(); err != nil {
+			t.Fatal(err)
+		}
+		after, _ := s.ListTokens()
+		for i := range before {
+			if before[i].Token != after[i].Token || before[i].St != after[i].St {
+				t.Errorf("second migration rewrote token %d", before[i].ID)
+			}
+		}
+
+		if db := a.db.Load(); db != nil {
+			var bearer string
+			if err := db.QueryRow(`SELECT bearer_token FROM accounts`).Scan(&bearer); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := box.decrypt(bearer); !strings.HasPrefix(bearer, encryptedPrefix) || got != "plain-bearer" {
+				t.Errorf("accounts.bearer_token = %q", bearer)
+			}
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 统计：/api/stats 由 video_task_results、video_downloads 与 failure_events 汇总而来，
+// 包含今日/累计视频数与错误数、按天与按账号的明细，以及平均生成耗时（提交到 pending 完成）。
+
+// failure_events.stage 取值
+const (
+	failureStageCreate      = "create"
+	failureStagePoll        = "poll"
+	failureStageStatus      = "status"
+	failureStageDownload    = "download"
+	failureStageTimeout     = "timeout"
+	failureStageInvalidated = "invalidated"
+	failureStageAuth        = "auth" // ST/RT 换取 AT 失败
+)
+
+// failureEventRetention 失败记录保留时长，启动时清理更早的记录
+const failureEventRetention = 90 * 24 * time.Hour
+
+// failureEvent failure_events 中的一行；TokenID 为 0 表示未关联账号
+type failureEvent struct {
+	CreatedAt int64
+	TokenID   int64
+	TaskID    string
+	Stage     string
+	Message   string
+}
+
+// recordFailureEvent 写入一条失败记录；tokenId<=0 时不关联账号，JSON 文件存储不记录
+func (a *App) recordFailureEvent(tokenId int64, taskID string, stage string, message string) {
+	if a.store == nil {
+		return
+	}
+	err := a.store.InsertFailureEvent(failureEvent{
+		CreatedAt: time.Now().Unix(),
+		TokenID:   tokenId,
+		TaskID:    taskID,
+		Stage:     stage,
+		Message:   truncateRunes(redactSecrets(message), 300),
+	})
+	if err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("写入失败记录失败: %v", err))
+	}
+}
+
+// pruneFailureEvents 删除超过保留时长的失败记录
+func (a *App) pruneFailureEvents() {
+	if a.store == nil {
+		return
+	}
+	cutoff := time.Now().Add(-failureEventRetention).Unix()
+	if err := a.store.PruneFailureEvents(cutoff); err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("清理失败记录失败: %v", err))
+	}
+}
+
+// statsBucket 一组任务的计数，用于按天/按账号汇总
+type statsBucket struct {
+	Submitted int
+	Completed int
+	Failed    int
+	Cancelled int
+	Errors    int
+	genTotal  int64 // 已完成任务的生成耗时总和（秒）
+	genCount  int
+}
+
+func (b *statsBucket) view() map[string]interface{} {
+	return map[string]interface{}{
+		"submitted":              b.Submitted,
+		"completed":              b.Completed,
+		"failed":                 b.Failed,
+		"cancelled":              b.Cancelled,
+		"errors":                 b.Errors,
+		"avg_generation_seconds": b.avgGeneration(),
+		"failure_rate":           failureRate(b.Completed, b.Failed),
+	}
+}
+
+func (b *statsBucket) avgGeneration() float64 {
+	if b.genCount == 0 {
+		return 0
+	}
+	return float64(b.genTotal) / float64(b.genCount)
+}
+
+// failureRate 失败任务占已结束（完成+失败）任务的比例
+func failureRate(completed, failed int) float64 {
+	if completed+failed == 0 {
+		return 0
+	}
+	return float64(failed) / float64(completed+failed)
+}
+
+// countFailureEvents 按账号汇总失败记录数，计入累计与各账号的 Errors（未关联账号的只计入累计）
+func (a *App) countFailureEvents(all *statsBucket, perToken map[int64]*statsBucket) error {
+	counts, err := a.store.FailureEventCounts()
+	if err != nil {
+		return err
+	}
+	for tokenID, n := range counts {
+		all.Errors += n
+		if tokenID > 0 {
+			if perToken[tokenID] == nil {
+				perToken[tokenID] = &statsBucket{}
+			}
+			perToken[tokenID].Errors += n
+		}
+	}
+	return nil
+}
+
+// computeStats 汇总统计数据，daily 覆盖最近 days 天（含今天，按本地时区分日）
+func (a *App) computeStats(days int) (string, error) {
+	out := map[string]interface{}{
+		"total_tokens": 0, "active_tokens": 0,
+		"today_images": 0, "total_images": 0,
+		"today_videos": 0, "total_videos": 0,
+		"today_errors": 0, "total_errors": 0,
+		"errors_available": false,
+	}
+	if a.store == nil {
+		return jsonMarshal(out)
+	}
+	var totalTokens, activeTokens, totalDownloads int
+	if tokens, err := a.store.ListTokens(); err == nil {
+		totalTokens = len(tokens)
+		for _, t := range tokens {
+			if t.IsActive {
+				activeTokens++
+			}
+		}
+	}
+	if downloads, err := a.store.ListDownloads(); err == nil {
+		totalDownloads = len(downloads)
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	firstDay := now.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	daily := map[string]*statsBucket{}
+	perToken := map[int64]*statsBucket{}
+	var all, todayB statsBucket
+	running := 0
+
+	bucketsFor := func(day string, tokenID int64) []*statsBucket {
+		list := []*statsBucket{&all}
+		if day == today {
+			list = append(list, &todayB)
+		}
+		if day >= firstDay {
+			if daily[day] == nil {
+				daily[day] = &statsBucket{}
+			}
+			list = append(list, daily[day])
+		}
+		if tokenID > 0 {
+			if perToken[tokenID] == nil {
+				perToken[tokenID] = &statsBucket{}
+			}
+			list = append(list, perToken[tokenID])
+		}
+		return list
+	}
+
+	tasks, err := a.store.ListTasks()
+	if err != nil {
+		return jsonFail("查询任务统计失败: " + err.Error())
+	}
+	for _, t := range tasks {
+		status, finishedAt := t.Status, t.FinishedAt
+		day := t.CreatedAt.Local().Format("2006-01-02")
+		// 旧数据没有 status，进度达到 100 视为完成
+		done := status == videoTaskStatusDone || (status == "" && t.ProgressPct >= 100)
+		for _, b := range bucketsFor(day, t.TokenID) {
+			b.Submitted++
+			switch {
+			case done:
+				b.Completed++
+				if finishedAt > 0 && !t.CreatedAt.IsZero() {
+					if d := finishedAt - t.CreatedAt.Unix(); d >= 0 {
+						b.genTotal += d
+						b.genCount++
+					}
+				}
+			case status == videoTaskStatusFailed:
+				b.Failed++
+			case status == videoTaskStatusCancelled:
+				b.Cancelled++
+			}
+		}
+		if !done && status != videoTaskStatusFailed && status != videoTaskStatusCancelled {
+			running++
+		}
+	}
+
+	// failure_events 只写入 SQLite，JSON 文件存储下没有错误统计（errors_available 为 false）
+	errorsAvailable := true
+	if err := a.countFailureEvents(&all, perToken); errors.Is(err, errHistoryUnavailable) {
+		errorsAvailable = false
+	} else if err != nil {
+		return jsonFail("查询失败记录失败: " + err.Error())
+	}
+	if errorsAvailable {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+		times, err := a.store.FailureEventTimes(start.Unix())
+		if err != nil {
+			return jsonFail("查询失败记录失败: " + err.Error())
+		}
+		for _, createdAt := range times {
+			day := time.Unix(createdAt, 0).Format("2006-01-02")
+			if daily[day] == nil {
+				daily[day] = &statsBucket{}
+			}
+			daily[day].Errors++
+			if day == today {
+				todayB.Errors++
+			}
+		}
+	}
+
+	dailyList := []map[string]interface{}{}
+	for i := days - 1; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i).Format("2006-01-02")
+		b := daily[day]
+		if b == nil {
+			b = &statsBucket{}
+		}
+		item := b.view()
+		item["date"] = day
+		dailyList = append(dailyList, item)
+	}
+
+	tokenList := []map[string]interface{}{}
+	ids := make([]int64, 0, len(perToken))
+	for id := range perToken {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		item := perToken[id].view()
+		item["token_id"] = id
+		email, _ := a.tokenEmail(id)
+		item["email"] = email
+		tokenList = append(tokenList, item)
+	}
+
+	out["total_tokens"] = totalTokens
+	out["active_tokens"] = activeTokens
+	out["today_videos"] = todayB.Completed
+	out["total_videos"] = all.Completed
+	out["today_errors"] = todayB.Errors
+	out["total_errors"] = all.Errors
+	out["errors_available"] = errorsAvailable
+	out["today_tasks"] = todayB.Submitted
+	out["total_tasks"] = all.Submitted
+	out["today_failed"] = todayB.Failed
+	out["total_failed"] = all.Failed
+	out["running_tasks"] = running
+	out["total_downloads"] = totalDownloads
+	out["failure_rate"] = failureRate(all.Completed, all.Failed)
+	out["avg_generation_seconds"] = all.avgGeneration()
+	out["today_avg_generation_seconds"] = todayB.avgGeneration()
+	out["daily"] = dailyList
+	out["per_token"] = tokenList
+	return jsonMarshal(out)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 存储层：账号、视频任务、下载记录、settings 与 task_list 统一通过 Store 读写。
+// 默认使用 SQLite（sqliteStore，需 CGO）；go-sqlite3 报告 "requires cgo" 时退回纯 Go 的 JSON 文件存储（jsonStore），
+// 保证无 CGO 构建也能完整使用账号管理与任务功能。请求日志、失败记录、额度快照与健康检查记录（historyStore）只保存在 SQLite，
+// JSON 文件存储下这些方法返回 errHistoryUnavailable，相应接口返回 available:false。
+
+var errNotFound = errors.New("记录不存在")
+
+// tokenRecord tokens 表的一行；Token/St/Rt 为存储形式（可能是 enc:v1: 密文），读取后需 openSecret
+type tokenRecord struct {
+	ID               int64     `json:"id"`
+	Token            string    `json:"token"`
+	TokenHash        string    `json:"token_hash"`
+	St               string    `json:"st"`
+	Rt               string    `json:"rt"`
+	ClientID         string    `json:"client_id"`
+	IsActive         bool      `json:"is_active"`
+	Remark           string    `json:"remark"`
+	ProxyURL         string    `json:"proxy_url"`
+	ImageEnabled     bool      `json:"image_enabled"`
+	VideoEnabled     bool      `json:"video_enabled"`
+	ImageConcurrency int       `json:"image_concurrency"`
+	VideoConcurrency int       `json:"video_concurrency"`
+	StatusJSON       string    `json:"status_json"`
+	PlanType         string    `json:"plan_type"`
+	ErrorMessage     string    `json:"error_message"`
+	CooldownUntil    int64     `json:"cooldown_until"`
+	LastUsedAt       int64     `json:"last_used_at"`
+	ErrorCount       int       `json:"error_count"`
+	Groups           []string  `json:"groups,omitempty"` // 所属分组，SQLite 中保存在 token_groups 表
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// taskRecord video_task_results 表的一行；TokenID 为 0 表示未关联账号
+type taskRecord struct {
+	ID          int64     `json:"id"`
+	TaskID      string    `json:"task_id"`
+	TokenID     int64     `json:"token_id"`
+	ResultJSON  string    `json:"result_json"`
+	ProgressPct float64   `json:"progress_pct"`
+	Prompt      string    `json:"prompt"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message"`
+	FinishedAt  int64     `json:"finished_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// finished 任务已结束（完成、失败或取消）
+func (t taskRecord) finished() bool {
+	switch t.Status {
+	case videoTaskStatusDone, videoTaskStatusFailed, videoTaskStatusCancelled:
+		return true
+	}
+	return t.ProgressPct >= 100
+}
+
+// displayStatus 任务列表展示的状态：优先使用任务引擎写入的 Status，旧记录没有时按进度推断
+func (t taskRecord) displayStatus() string {
+	if t.Status != "" {
+		return t.Status
+	}
+	if t.ProgressPct >= 100 {
+		return videoTaskStatusDone
+	}
+	return videoTaskStatusRunning
+}
+
+// needsPolling 进度未到 100% 且未失败/取消，启动时需要恢复 pending 轮询
+func (t taskRecord) needsPolling() bool {
+	return t.ProgressPct < 100 && t.Status != videoTaskStatusFailed && t.Status != videoTaskStatusCancelled
+}
+
+// downloadRecord video_downloads 表的一行
+type downloadRecord struct {
+	ID              int64     `json:"id"`
+	GenerationID    string    `json:"generation_id"`
+	TaskID          string    `json:"task_id"`
+	PostID          string    `json:"post_id"`
+	DownloadableURL string    `json:"downloadable_url"`
+	LocalPath       string    `json:"local_path"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// settingsStore settings 键值读写，loadSecretBox 等只依赖这一部分
+type settingsStore interface {
+	GetSetting(key string) (string, error) // 不存在时返回 errNotFound
+	SetSetting(key, value string) error
+}
+
+// historyStore 请求日志、失败记录、额度快照与健康检查记录（request_logs / failure_events / token_snapshots / token_health_checks）。
+// 写入频率与数据量远高于账号和任务，JSON 文件存储不保存，所有方法返回 errHistoryUnavailable
+type historyStore interface {
+	InsertRequestLog(l requestLogRecord) error
+	PruneRequestLogs(maxRows int) error                                                     // 只保留最近 maxRows 条
+	ListRequestLogs(f requestLogFilter, limit, offset int) ([]requestLogRecord, int, error) // 按 id 倒序，返回当页与总数
+	ClearRequestLogs() (int64, error)
+
+	InsertFailureEvent(e failureEvent) error
+	FailureEventCounts() (map[int64]int, error) // 按账号汇总，未关联账号的计入 0
+	FailureEventTimes(since int64) ([]int64, error)
+	PruneFailureEvents(before int64) error
+
+	InsertTokenSnapshot(s tokenSnapshot) error
+	ListTokenSnapshots(tokenID, since int64) ([]tokenSnapshot, error) // 按账号、时间升序；tokenID<=0 时为全部账号
+	PruneTokenSnapshots(before int64) error
+
+	InsertHealthCheck(e tokenHealthEntry) error
+	ListHealthChecks(tokenID int64) ([]tokenHealthEntry, error) // 按时间倒序
+	LatestHealthChecks() (map[int64]tokenHealthEntry, error)    // 每个账号最近一次检查
+	PruneHealthChecks(tokenID int64, keep int) error            // 该账号只保留最近 keep 条
+
+	// ReassignTokenHistory 在同一事务内把 from 中各账号的日志、失败记录、快照与检查记录改挂到 to（合并重复账号时使用）
+	ReassignTokenHistory(from []int64, to int64) error
+}
+
+// Store 本地数据存储；UpdateXxx 的 apply 在同一把锁/事务内执行读-改-写，记录不存在时返回 errNotFound
+type Store interface {
+	settingsStore
+	historyStore
+	Kind() string
+	Close() error
+	Snapshot(dst string) error // 写出一份一致的完整快照到 dst（dst 不能已存在），用于备份
+
+	ListTokens() ([]tokenRecord, error) // 按 id 升序
+	GetToken(id int64) (tokenRecord, error)
+	FindTokenID(hash, token string) (int64, error) // 按 token_hash 或原始 token 查找
+	InsertToken(t tokenRecord) (int64, error)
+	UpdateToken(id int64, apply func(t *tokenRecord) error) error
+	DeleteToken(id int64) error // 同时删除分组与健康检查记录
+	// UpdateTokens/DeleteTokens 在同一事务内处理多个账号，任一失败（含记录不存在）时全部不生效
+	UpdateTokens(ids []int64, apply func(t *tokenRecord) error) error
+	DeleteTokens(ids []int64) error
+	// ReplaceTokens 在同一事务内删除全部账号并按顺序插入 list，返回新 id；失败时原有账号保持不变
+	ReplaceTokens(list []tokenRecord) ([]int64, error)
+	// MergeTokens 在同一事务内用 keep 覆盖保留账号，把 removed 中各账号的任务与历史记录改挂到 keep.ID 后删除这些账号；
+	// 任一账号不存在时返回 errNotFound，全部不生效
+	MergeTokens(keep tokenRecord, removed []int64) error
+
+	ListTasks() ([]taskRecord, error) // 按 created_at 升序
+	// InflightTaskCounts 每个账号未结束（见 taskRecord.finished）的任务数，只统计关联了账号的任务
+	InflightTaskCounts() (map[int64]int, error)
+	GetTask(taskID string) (taskRecord, error)
+	PutTask(t taskRecord) error // 按 task_id 插入或覆盖
+	UpdateTask(taskID string, apply func(t *taskRecord) error) error
+	DeleteTask(taskID string) error
+
+	ListDownloads() ([]downloadRecord, error) // 按 id 升序
+	PutDownload(d downloadRecord) error       // 按 generation_id 插入或覆盖
+	UpdateDownloads(taskID string, apply func(d *downloadRecord)) error
+	DeleteDownloads(taskID string) error // taskID 为空时清空全部
+
+	GetTaskList() (string, error)
+	SetTaskList(value string) error
+}
+
+var errStoreClosed = errors.New("存储已关闭")
+
+// errHistoryUnavailable 当前存储（JSON 文件）不保存请求日志、失败记录、额度快照与健康检查记录
+var errHistoryUnavailable = errors.New("JSON 文件存储不记录请求日志、失败记录、额度快照与健康检查记录")
+
+// errStoreUnavailable 存储未初始化（SQLite 与 JSON 文件均打开失败）
+var errStoreUnavailable = errors.New("本地存储未初始化")
+
+// latestDownload 返回该任务最近一次的下载记录
+func latestDownload(s Store, taskID string) (downloadRecord, error) {
+	list, err := s.ListDownloads()
+	if err != nil {
+		return downloadRecord{}, err
+	}
+	var found *downloadRecord
+	for i := range list {
+		if list[i].TaskID != taskID {
+			continue
+		}
+		if found == nil || !list[i].CreatedAt.Before(found.CreatedAt) {
+			found = &list[i]
+		}
+	}
+	if found == nil {
+		return downloadRecord{}, errNotFound
+	}
+	return *found, nil
+}
+
+// downloadPathsByTask task_id -> local_path（同一任务多条记录时后写入的覆盖先写入的）
+func downloadPathsByTask(s Store) map[string]string {
+	m := map[string]string{}
+	list, err := s.ListDownloads()
+	if err != nil {
+		return m
+	}
+	for _, d := range list {
+		if strings.TrimSpace(d.TaskID) != "" {
+			m[d.TaskID] = d.LocalPath
+		}
+	}
+	return m
+}
+
+func sortTasksByCreated(list []taskRecord) {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+}
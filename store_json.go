@@ -0,0 +1,503 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jsonStore 纯 Go 的 Store 实现：全部数据保存在数据目录下的 store.json，每次写入先写临时文件再 rename，
+// 用于无 CGO 构建（go-sqlite3 不可用）时的回退。数据量为账号与任务级别，整体读写即可。
+// 每次修改都经 commitLocked 写盘，写盘失败时撤销内存中的修改，保证内存与文件一致。
+type jsonStore struct {
+	path   string
+	mu     sync.Mutex
+	data   jsonStoreData
+	closed bool // Close 后不再写文件，避免覆盖恢复备份时替换进来的 store.json
+}
+
+type jsonStoreData struct {
+	NextTokenID    int64             `json:"next_token_id"`
+	NextTaskID     int64             `json:"next_task_id"`
+	NextDownloadID int64             `json:"next_download_id"`
+	Tokens         []tokenRecord     `json:"tokens"`
+	Tasks          []taskRecord      `json:"tasks"`
+	Downloads      []downloadRecord  `json:"downloads"`
+	Settings       map[string]string `json:"settings"`
+	TaskList       *string           `json:"task_list,omitempty"`
+}
+
+const jsonStoreFile = "store.json"
+
+// openJSONStore 打开（不存在时创建）JSON 文件存储
+func openJSONStore(path string) (*jsonStore, error) {
+	s := &jsonStore{path: path}
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		s.data = jsonStoreData{NextTokenID: 1, NextTaskID: 1, NextDownloadID: 1}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, err
+		}
+	}
+	if s.data.Settings == nil {
+		s.data.Settings = map[string]string{}
+	}
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *jsonStore) Kind() string { return "json" }
+
+func (s *jsonStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Snapshot 将当前数据写入 dst
+func (s *jsonStore) Snapshot(dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0600)
+}
+
+// saveLocked 写入临时文件后替换原文件，调用方需持有 mu
+func (s *jsonStore) saveLocked() error {
+	if s.closed {
+		return errStoreClosed
+	}
+	b, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// commitLocked 写盘，失败时调用 undo 撤销内存中的修改；调用方需持有 mu
+func (s *jsonStore) commitLocked(undo func()) error {
+	if err := s.saveLocked(); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+func (s *jsonStore) GetSetting(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data.Settings[key]
+	if !ok {
+		return "", errNotFound
+	}
+	return v, nil
+}
+
+func (s *jsonStore) SetSetting(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.data.Settings[key]
+	s.data.Settings[key] = value
+	return s.commitLocked(func() {
+		if existed {
+			s.data.Settings[key] = prev
+		} else {
+			delete(s.data.Settings, key)
+		}
+	})
+}
+
+func (s *jsonStore) ListTokens() ([]tokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]tokenRecord, len(s.data.Tokens))
+	for i, t := range s.data.Tokens {
+		list[i] = copyTokenRecord(t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// copyTokenRecord 复制记录的 Groups，避免调用方修改到 data 中的切片
+func copyTokenRecord(t tokenRecord) tokenRecord {
+	if t.Groups != nil {
+		t.Groups = append([]string(nil), t.Groups...)
+	}
+	return t
+}
+
+func (s *jsonStore) tokenIndex(id int64) int {
+	for i := range s.data.Tokens {
+		if s.data.Tokens[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *jsonStore) GetToken(id int64) (tokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.tokenIndex(id); i >= 0 {
+		return copyTokenRecord(s.data.Tokens[i]), nil
+	}
+	return tokenRecord{}, errNotFound
+}
+
+func (s *jsonStore) FindTokenID(hash, token string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.data.Tokens {
+		if (hash != "" && t.TokenHash == hash) || t.Token == token {
+			return t.ID, nil
+		}
+	}
+	return 0, errNotFound
+}
+
+func (s *jsonStore) InsertToken(t tokenRecord) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prevTokens, prevNext := s.data.Tokens, s.data.NextTokenID
+	t.ID = s.data.NextTokenID
+	t.Groups = normalizeTokenGroups(t.Groups)
+	s.data.NextTokenID++
+	s.data.Tokens = append(s.data.Tokens, t)
+	if err := s.commitLocked(func() { s.data.Tokens, s.data.NextTokenID = prevTokens, prevNext }); err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+func (s *jsonStore) UpdateToken(id int64, apply func(t *tokenRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tokenIndex(id)
+	if i < 0 {
+		return errNotFound
+	}
+	t := copyTokenRecord(s.data.Tokens[i])
+	if err := apply(&t); err != nil {
+		return err
+	}
+	t.ID, t.Groups = id, normalizeTokenGroups(t.Groups)
+	prev := s.data.Tokens[i]
+	s.data.Tokens[i] = t
+	return s.commitLocked(func() { s.data.Tokens[i] = prev })
+}
+
+func (s *jsonStore) DeleteToken(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tokenIndex(id)
+	if i < 0 {
+		return nil
+	}
+	tokens := append(append([]tokenRecord(nil), s.data.Tokens[:i]...), s.data.Tokens[i+1:]...)
+	return s.commitTokensLocked(tokens)
+}
+
+func (s *jsonStore) UpdateTokens(ids []int64, apply func(t *tokenRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := append([]tokenRecord(nil), s.data.Tokens...)
+	for _, id := range ids {
+		i := s.tokenIndex(id)
+		if i < 0 {
+			return errNotFound
+		}
+		t := copyTokenRecord(tokens[i])
+		if err := apply(&t); err != nil {
+			return err
+		}
+		t.ID, t.Groups = id, normalizeTokenGroups(t.Groups)
+		tokens[i] = t
+	}
+	return s.commitTokensLocked(tokens)
+}
+
+func (s *jsonStore) DeleteTokens(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if s.tokenIndex(id) < 0 {
+			return errNotFound
+		}
+		remove[id] = true
+	}
+	tokens := make([]tokenRecord, 0, len(s.data.Tokens))
+	for _, t := range s.data.Tokens {
+		if !remove[t.ID] {
+			tokens = append(tokens, t)
+		}
+	}
+	return s.commitTokensLocked(tokens)
+}
+
+func (s *jsonStore) ReplaceTokens(list []tokenRecord) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prevNext := s.data.NextTokenID
+	tokens := make([]tokenRecord, 0, len(list))
+	ids := make([]int64, 0, len(list))
+	for _, t := range list {
+		t.ID = s.data.NextTokenID
+		t.Groups = normalizeTokenGroups(t.Groups)
+		s.data.NextTokenID++
+		tokens = append(tokens, t)
+		ids = append(ids, t.ID)
+	}
+	prev := s.data.Tokens
+	s.data.Tokens = tokens
+	if err := s.commitLocked(func() { s.data.Tokens, s.data.NextTokenID = prev, prevNext }); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *jsonStore) MergeTokens(keep tokenRecord, removed []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokenIndex(keep.ID) < 0 {
+		return errNotFound
+	}
+	remove := make(map[int64]bool, len(removed))
+	for _, id := range removed {
+		if s.tokenIndex(id) < 0 {
+			return errNotFound
+		}
+		remove[id] = true
+	}
+	keep = copyTokenRecord(keep)
+	keep.Groups = normalizeTokenGroups(keep.Groups)
+	tokens := make([]tokenRecord, 0, len(s.data.Tokens))
+	for _, t := range s.data.Tokens {
+		switch {
+		case remove[t.ID]:
+		case t.ID == keep.ID:
+			tokens = append(tokens, keep)
+		default:
+			tokens = append(tokens, t)
+		}
+	}
+	tasks := append([]taskRecord(nil), s.data.Tasks...)
+	for i := range tasks {
+		if remove[tasks[i].TokenID] {
+			tasks[i].TokenID = keep.ID
+		}
+	}
+	prevTokens, prevTasks := s.data.Tokens, s.data.Tasks
+	s.data.Tokens, s.data.Tasks = tokens, tasks
+	return s.commitLocked(func() { s.data.Tokens, s.data.Tasks = prevTokens, prevTasks })
+}
+
+// commitTokensLocked 用 tokens 替换账号列表并写盘，写盘失败时恢复原列表
+func (s *jsonStore) commitTokensLocked(tokens []tokenRecord) error {
+	prev := s.data.Tokens
+	s.data.Tokens = tokens
+	return s.commitLocked(func() { s.data.Tokens = prev })
+}
+
+func (s *jsonStore) ListTasks() ([]taskRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]taskRecord(nil), s.data.Tasks...)
+	sortTasksByCreated(list)
+	return list, nil
+}
+
+func (s *jsonStore) InflightTaskCounts() (map[int64]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[int64]int{}
+	for _, t := range s.data.Tasks {
+		if t.TokenID > 0 && !t.finished() {
+			counts[t.TokenID]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *jsonStore) taskIndex(taskID string) int {
+	for i := range s.data.Tasks {
+		if s.data.Tasks[i].TaskID == taskID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *jsonStore) GetTask(taskID string) (taskRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.taskIndex(taskID); i >= 0 {
+		return s.data.Tasks[i], nil
+	}
+	return taskRecord{}, errNotFound
+}
+
+func (s *jsonStore) PutTask(t taskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	prevNext := s.data.NextTaskID
+	t.ID = s.data.NextTaskID
+	s.data.NextTaskID++
+	if i := s.taskIndex(t.TaskID); i >= 0 {
+		prev := s.data.Tasks[i]
+		s.data.Tasks[i] = t
+		return s.commitLocked(func() { s.data.Tasks[i], s.data.NextTaskID = prev, prevNext })
+	}
+	prevTasks := s.data.Tasks
+	s.data.Tasks = append(s.data.Tasks, t)
+	return s.commitLocked(func() { s.data.Tasks, s.data.NextTaskID = prevTasks, prevNext })
+}
+
+func (s *jsonStore) UpdateTask(taskID string, apply func(t *taskRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.taskIndex(taskID)
+	if i < 0 {
+		return errNotFound
+	}
+	prev := s.data.Tasks[i]
+	t := prev
+	if err := apply(&t); err != nil {
+		return err
+	}
+	s.data.Tasks[i] = t
+	return s.commitLocked(func() { s.data.Tasks[i] = prev })
+}
+
+func (s *jsonStore) DeleteTask(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.taskIndex(taskID)
+	if i < 0 {
+		return nil
+	}
+	prev := s.data.Tasks
+	s.data.Tasks = append(append([]taskRecord(nil), prev[:i]...), prev[i+1:]...)
+	return s.commitLocked(func() { s.data.Tasks = prev })
+}
+
+func (s *jsonStore) ListDownloads() ([]downloadRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]downloadRecord(nil), s.data.Downloads...)
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *jsonStore) PutDownload(d downloadRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	prev, prevNext := s.data.Downloads, s.data.NextDownloadID
+	d.ID = s.data.NextDownloadID
+	s.data.NextDownloadID++
+	kept := make([]downloadRecord, 0, len(prev)+1)
+	for _, old := range prev {
+		if old.GenerationID != d.GenerationID {
+			kept = append(kept, old)
+		}
+	}
+	s.data.Downloads = append(kept, d)
+	return s.commitLocked(func() { s.data.Downloads, s.data.NextDownloadID = prev, prevNext })
+}
+
+func (s *jsonStore) UpdateDownloads(taskID string, apply func(d *downloadRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.data.Downloads
+	list := append([]downloadRecord(nil), prev...)
+	for i := range list {
+		if list[i].TaskID == taskID {
+			apply(&list[i])
+		}
+	}
+	s.data.Downloads = list
+	return s.commitLocked(func() { s.data.Downloads = prev })
+}
+
+func (s *jsonStore) DeleteDownloads(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.data.Downloads
+	var kept []downloadRecord
+	for _, d := range prev {
+		if taskID != "" && d.TaskID != taskID {
+			kept = append(kept, d)
+		}
+	}
+	s.data.Downloads = kept
+	return s.commitLocked(func() { s.data.Downloads = prev })
+}
+
+func (s *jsonStore) GetTaskList() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.TaskList == nil {
+		return "", errNotFound
+	}
+	return *s.data.TaskList, nil
+}
+
+func (s *jsonStore) SetTaskList(value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.data.TaskList
+	s.data.TaskList = &value
+	return s.commitLocked(func() { s.data.TaskList = prev })
+}
+
+// 请求日志、失败记录、额度快照与健康检查记录只写入 SQLite
+func (s *jsonStore) InsertRequestLog(requestLogRecord) error { return errHistoryUnavailable }
+func (s *jsonStore) PruneRequestLogs(int) error              { return errHistoryUnavailable }
+func (s *jsonStore) ListRequestLogs(requestLogFilter, int, int) ([]requestLogRecord, int, error) {
+	return nil, 0, errHistoryUnavailable
+}
+func (s *jsonStore) ClearRequestLogs() (int64, error)           { return 0, errHistoryUnavailable }
+func (s *jsonStore) InsertFailureEvent(failureEvent) error      { return errHistoryUnavailable }
+func (s *jsonStore) FailureEventCounts() (map[int64]int, error) { return nil, errHistoryUnavailable }
+func (s *jsonStore) FailureEventTimes(int64) ([]int64, error)   { return nil, errHistoryUnavailable }
+func (s *jsonStore) PruneFailureEvents(int64) error             { return errHistoryUnavailable }
+func (s *jsonStore) InsertTokenSnapshot(tokenSnapshot) error    { return errHistoryUnavailable }
+func (s *jsonStore) ListTokenSnapshots(int64, int64) ([]tokenSnapshot, error) {
+	return nil, errHistoryUnavailable
+}
+func (s *jsonStore) PruneTokenSnapshots(int64) error          { return errHistoryUnavailable }
+func (s *jsonStore) InsertHealthCheck(tokenHealthEntry) error { return errHistoryUnavailable }
+func (s *jsonStore) ListHealthChecks(int64) ([]tokenHealthEntry, error) {
+	return nil, errHistoryUnavailable
+}
+func (s *jsonStore) LatestHealthChecks() (map[int64]tokenHealthEntry, error) {
+	return nil, errHistoryUnavailable
+}
+func (s *jsonStore) PruneHealthChecks(int64, int) error        { return errHistoryUnavailable }
+func (s *jsonStore) ReassignTokenHistory([]int64, int64) error { return errHistoryUnavailable }
@@ -0,0 +1,788 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqliteStore 基于 accounts.db 的 Store 实现；写操作由 mu 串行化，读-改-写不会互相覆盖
+type sqliteStore struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+func newSQLiteStore(db *sql.DB) *sqliteStore {
+	return &sqliteStore{db: db}
+}
+
+func (s *sqliteStore) Kind() string { return "sqlite" }
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
+
+// Snapshot 使用 VACUUM INTO 生成一致的数据库副本，不阻塞其它连接的读取
+func (s *sqliteStore) Snapshot(dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`VACUUM INTO ?`, dst)
+	return err
+}
+
+func (s *sqliteStore) GetSetting(key string) (string, error) {
+	var v string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&v)
+	if err == sql.ErrNoRows {
+		return "", errNotFound
+	}
+	return v, err
+}
+
+func (s *sqliteStore) SetSetting(key, value string) error {
+	_, err := s.db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
+	return err
+}
+
+const tokenColumns = `id, token, COALESCE(token_hash, ''), COALESCE(st, ''), COALESCE(rt, ''), COALESCE(client_id, ''),
+	COALESCE(is_active, 1), COALESCE(remark, ''), COALESCE(proxy_url, ''), COALESCE(image_enabled, 1), COALESCE(video_enabled, 1),
+	COALESCE(image_concurrency, -1), COALESCE(video_concurrency, 3), COALESCE(status_json, ''), COALESCE(plan_type, ''),
+	COALESCE(error_message, ''), COALESCE(cooldown_until, 0), COALESCE(last_used_at, 0), COALESCE(error_count, 0), created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(r rowScanner) (tokenRecord, error) {
+	var t tokenRecord
+	var isActive, imageEnabled, videoEnabled int
+	var createdAt, updatedAt sql.NullTime
+	err := r.Scan(&t.ID, &t.Token, &t.TokenHash, &t.St, &t.Rt, &t.ClientID, &isActive, &t.Remark, &t.ProxyURL,
+		&imageEnabled, &videoEnabled, &t.ImageConcurrency, &t.VideoConcurrency, &t.StatusJSON, &t.PlanType,
+		&t.ErrorMessage, &t.CooldownUntil, &t.LastUsedAt, &t.ErrorCount, &createdAt, &updatedAt)
+	t.IsActive, t.ImageEnabled, t.VideoEnabled = isActive == 1, imageEnabled == 1, videoEnabled == 1
+	t.CreatedAt, t.UpdatedAt = createdAt.Time, updatedAt.Time
+	return t, err
+}
+
+func (s *sqliteStore) ListTokens() ([]tokenRecord, error) {
+	rows, err := s.db.Query(`SELECT ` + tokenColumns + ` FROM tokens ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []tokenRecord
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取 tokens 记录失败: %v", err)
+		}
+		list = append(list, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	groups, err := loadTokenGroups(s.db, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Groups = groups[list[i].ID]
+	}
+	return list, nil
+}
+
+// sqlQuerier *sql.DB 与 *sql.Tx 共有的查询方法
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadTokenGroups 读取 token_groups，按账号返回分组名（升序）；tokenID<=0 时读取全部账号
+func loadTokenGroups(q sqlQuerier, tokenID int64) (map[int64][]string, error) {
+	query, args := `SELECT token_id, name FROM token_groups`, []interface{}{}
+	if tokenID > 0 {
+		query, args = query+` WHERE token_id=?`, append(args, tokenID)
+	}
+	rows, err := q.Query(query+` ORDER BY token_id, name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		groups[id] = append(groups[id], name)
+	}
+	return groups, rows.Err()
+}
+
+// getTokenTx 读取一个账号及其分组
+func getTokenTx(q sqlQuerier, id int64) (tokenRecord, error) {
+	t, err := scanToken(q.QueryRow(`SELECT `+tokenColumns+` FROM tokens WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return t, errNotFound
+	}
+	if err != nil {
+		return t, err
+	}
+	groups, err := loadTokenGroups(q, id)
+	t.Groups = groups[id]
+	return t, err
+}
+
+// saveTokenGroupsTx 用 groups 覆盖账号的分组
+func saveTokenGroupsTx(tx *sql.Tx, id int64, groups []string) error {
+	if _, err := tx.Exec(`DELETE FROM token_groups WHERE token_id=?`, id); err != nil {
+		return err
+	}
+	for _, name := range normalizeTokenGroups(groups) {
+		if _, err := tx.Exec(`INSERT INTO token_groups (token_id, name) VALUES (?, ?)`, id, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) GetToken(id int64) (tokenRecord, error) {
+	return getTokenTx(s.db, id)
+}
+
+func (s *sqliteStore) FindTokenID(hash, token string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM tokens WHERE (token_hash=? AND token_hash != '') OR token=? ORDER BY id LIMIT 1`, hash, token).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errNotFound
+	}
+	return id, err
+}
+
+func (s *sqliteStore) InsertToken(t tokenRecord) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	id, err := insertTokenTx(tx, t)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// insertTokenTx 在事务内插入一个账号（含分组），返回新 id
+func insertTokenTx(tx *sql.Tx, t tokenRecord) (int64, error) {
+	res, err := tx.Exec(`INSERT INTO tokens (token, token_hash, st, rt, client_id, is_active, remark, proxy_url, image_enabled, video_enabled,
+		image_concurrency, video_concurrency, status_json, plan_type, error_message, cooldown_until, last_used_at, error_count, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, tokenValues(t)...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := saveTokenGroupsTx(tx, id, t.Groups); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// tokenValues 与 INSERT 列顺序一致；可空列为空串时写 NULL，与旧数据保持一致
+func tokenValues(t tokenRecord) []interface{} {
+	return []interface{}{t.Token, t.TokenHash, nullStr(t.St), nullStr(t.Rt), nullStr(t.ClientID), boolToInt(t.IsActive),
+		nullStr(t.Remark), nullStr(t.ProxyURL), boolToInt(t.ImageEnabled), boolToInt(t.VideoEnabled), t.ImageConcurrency,
+		t.VideoConcurrency, nullStr(t.StatusJSON), t.PlanType, t.ErrorMessage, t.CooldownUntil, t.LastUsedAt, t.ErrorCount,
+		t.CreatedAt, t.UpdatedAt}
+}
+
+// updateTokenTx 在事务内对一个账号执行 apply 并写回（含分组）
+func updateTokenTx(tx *sql.Tx, id int64, apply func(t *tokenRecord) error) error {
+	t, err := getTokenTx(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := apply(&t); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE tokens SET token=?, token_hash=?, st=?, rt=?, client_id=?, is_active=?, remark=?, proxy_url=?,
+		image_enabled=?, video_enabled=?, image_concurrency=?, video_concurrency=?, status_json=?, plan_type=?, error_message=?,
+		cooldown_until=?, last_used_at=?, error_count=?, created_at=?, updated_at=? WHERE id=?`, append(tokenValues(t), id)...); err != nil {
+		return err
+	}
+	return saveTokenGroupsTx(tx, id, t.Groups)
+}
+
+func (s *sqliteStore) UpdateToken(id int64, apply func(t *tokenRecord) error) error {
+	return s.UpdateTokens([]int64{id}, apply)
+}
+
+// deleteTokenTx 删除账号及其分组与健康检查记录，返回删除的账号行数
+func deleteTokenTx(tx *sql.Tx, id int64) (int64, error) {
+	res, err := tx.Exec(`DELETE FROM tokens WHERE id=?`, id)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM token_groups WHERE token_id=?`, id); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM token_health_checks WHERE token_id=?`, id); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqliteStore) DeleteToken(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := deleteTokenTx(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) UpdateTokens(ids []int64, apply func(t *tokenRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if err := updateTokenTx(tx, id, apply); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteTokens(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		n, err := deleteTokenTx(tx, id)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) ReplaceTokens(list []tokenRecord) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM token_groups`); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM token_health_checks`); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM tokens`); err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(list))
+	for _, t := range list {
+		id, err := insertTokenTx(tx, t)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.Commit()
+}
+
+func (s *sqliteStore) MergeTokens(keep tokenRecord, removed []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := updateTokenTx(tx, keep.ID, func(t *tokenRecord) error {
+		*t = keep
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := reassignTokenRowsTx(tx, append([]string{"video_task_results"}, tokenHistoryTables...), removed, keep.ID); err != nil {
+		return err
+	}
+	for _, id := range removed {
+		n, err := deleteTokenTx(tx, id)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+const taskColumns = `id, task_id, COALESCE(token_id, 0), COALESCE(result_json, ''), COALESCE(progress_pct, 0), COALESCE(prompt, ''),
+	COALESCE(status, ''), COALESCE(message, ''), COALESCE(finished_at, 0), created_at`
+
+func scanTask(r rowScanner) (taskRecord, error) {
+	var t taskRecord
+	var createdAt sql.NullTime
+	err := r.Scan(&t.ID, &t.TaskID, &t.TokenID, &t.ResultJSON, &t.ProgressPct, &t.Prompt, &t.Status, &t.Message, &t.FinishedAt, &createdAt)
+	t.CreatedAt = createdAt.Time
+	return t, err
+}
+
+func (s *sqliteStore) ListTasks() ([]taskRecord, error) {
+	rows, err := s.db.Query(`SELECT ` + taskColumns + ` FROM video_task_results ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []taskRecord
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取 video_task_results 记录失败: %v", err)
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) InflightTaskCounts() (map[int64]int, error) {
+	rows, err := s.db.Query(`SELECT token_id, COUNT(*) FROM video_task_results
+		WHERE token_id > 0 AND COALESCE(progress_pct, 0) < 100 AND COALESCE(status, '') NOT IN (?, ?, ?)
+		GROUP BY token_id`, videoTaskStatusDone, videoTaskStatusFailed, videoTaskStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[int64]int{}
+	for rows.Next() {
+		var id int64
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqliteStore) GetTask(taskID string) (taskRecord, error) {
+	t, err := scanTask(s.db.QueryRow(`SELECT `+taskColumns+` FROM video_task_results WHERE task_id=?`, taskID))
+	if err == sql.ErrNoRows {
+		return t, errNotFound
+	}
+	return t, err
+}
+
+func taskValues(t taskRecord) []interface{} {
+	var tokenID interface{}
+	if t.TokenID > 0 {
+		tokenID = t.TokenID
+	}
+	return []interface{}{t.TaskID, tokenID, t.ResultJSON, t.ProgressPct, t.Prompt, t.Status, t.Message, t.FinishedAt, t.CreatedAt}
+}
+
+func (s *sqliteStore) PutTask(t taskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO video_task_results (task_id, token_id, result_json, progress_pct, prompt, status, message, finished_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, taskValues(t)...)
+	return err
+}
+
+func (s *sqliteStore) UpdateTask(taskID string, apply func(t *taskRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	if err := apply(&t); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE video_task_results SET task_id=?, token_id=?, result_json=?, progress_pct=?, prompt=?, status=?, message=?,
+		finished_at=?, created_at=? WHERE id=?`, append(taskValues(t), t.ID)...)
+	return err
+}
+
+func (s *sqliteStore) DeleteTask(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`DELETE FROM video_task_results WHERE task_id=?`, taskID)
+	return err
+}
+
+func (s *sqliteStore) ListDownloads() ([]downloadRecord, error) {
+	return queryDownloads(s.db, "")
+}
+
+// queryDownloads 按 id 升序读取下载记录；where 为空时读取全部
+func queryDownloads(q sqlQuerier, where string, args ...interface{}) ([]downloadRecord, error) {
+	rows, err := q.Query(`SELECT id, generation_id, COALESCE(task_id, ''), COALESCE(post_id, ''), COALESCE(downloadable_url, ''), local_path, created_at
+		FROM video_downloads`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []downloadRecord
+	for rows.Next() {
+		var d downloadRecord
+		var createdAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.GenerationID, &d.TaskID, &d.PostID, &d.DownloadableURL, &d.LocalPath, &createdAt); err != nil {
+			return nil, fmt.Errorf("读取 video_downloads 记录失败: %v", err)
+		}
+		d.CreatedAt = createdAt.Time
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) PutDownload(d downloadRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO video_downloads (generation_id, task_id, post_id, downloadable_url, local_path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, d.GenerationID, nullStr(d.TaskID), d.PostID, d.DownloadableURL, d.LocalPath, d.CreatedAt)
+	return err
+}
+
+func (s *sqliteStore) UpdateDownloads(taskID string, apply func(d *downloadRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	list, err := queryDownloads(tx, ` WHERE COALESCE(task_id, '') = ?`, taskID)
+	if err != nil {
+		return err
+	}
+	for _, d := range list {
+		apply(&d)
+		if _, err := tx.Exec(`UPDATE video_downloads SET generation_id=?, task_id=?, post_id=?, downloadable_url=?, local_path=?, created_at=? WHERE id=?`,
+			d.GenerationID, nullStr(d.TaskID), d.PostID, d.DownloadableURL, d.LocalPath, d.CreatedAt, d.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteDownloads(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if taskID == "" {
+		_, err := s.db.Exec(`DELETE FROM video_downloads`)
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM video_downloads WHERE task_id=?`, taskID)
+	return err
+}
+
+func (s *sqliteStore) GetTaskList() (string, error) {
+	var v string
+	err := s.db.QueryRow(`SELECT value FROM task_list WHERE key='list'`).Scan(&v)
+	if err == sql.ErrNoRows {
+		return "", errNotFound
+	}
+	return v, err
+}
+
+func (s *sqliteStore) SetTaskList(value string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO task_list (key, value) VALUES ('list', ?)`, value)
+	return err
+}
+
+// nullableID 把 <=0 的账号 id 存为 NULL
+func nullableID(id int64) interface{} {
+	if id <= 0 {
+		return nil
+	}
+	return id
+}
+
+func (s *sqliteStore) InsertRequestLog(l requestLogRecord) error {
+	_, err := s.db.Exec(`INSERT INTO request_logs (created_at, source, method, url, status_code, duration_ms, token_id, request_body, response_body, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.CreatedAt, l.Source, l.Method, l.URL, l.StatusCode, l.DurationMs, nullableID(l.TokenID), l.RequestBody, l.ResponseBody, l.Error)
+	return err
+}
+
+func (s *sqliteStore) PruneRequestLogs(maxRows int) error {
+	_, err := s.db.Exec(`DELETE FROM request_logs WHERE id <= (SELECT MAX(id) FROM request_logs) - ?`, maxRows)
+	return err
+}
+
+// requestLogWhere 把过滤条件换成 WHERE 子句与参数
+func requestLogWhere(f requestLogFilter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if f.TokenID > 0 {
+		where = append(where, "token_id = ?")
+		args = append(args, f.TokenID)
+	}
+	if f.Method != "" {
+		where = append(where, "method = ?")
+		args = append(args, f.Method)
+	}
+	if f.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, f.Source)
+	}
+	switch f.StatusClass {
+	case "success":
+		where = append(where, "status_code > 0 AND status_code < 400")
+	case "error":
+		where = append(where, "(status_code >= 400 OR status_code = 0)")
+	}
+	if f.StatusCode > 0 {
+		where = append(where, "status_code = ?")
+		args = append(args, f.StatusCode)
+	}
+	if f.Query != "" {
+		where = append(where, "(url LIKE ? OR error LIKE ?)")
+		args = append(args, "%"+f.Query+"%", "%"+f.Query+"%")
+	}
+	if f.Since > 0 {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since)
+	}
+	if f.Until > 0 {
+		where = append(where, "created_at <= ?")
+		args = append(args, f.Until)
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+func (s *sqliteStore) ListRequestLogs(f requestLogFilter, limit, offset int) ([]requestLogRecord, int, error) {
+	cond, args := requestLogWhere(f)
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM request_logs`+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(`SELECT id, created_at, COALESCE(source, ''), COALESCE(method, ''), COALESCE(url, ''), COALESCE(status_code, 0),
+		COALESCE(duration_ms, 0), COALESCE(token_id, 0), COALESCE(request_body, ''), COALESCE(response_body, ''), COALESCE(error, '')
+		FROM request_logs`+cond+` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var list []requestLogRecord
+	for rows.Next() {
+		var l requestLogRecord
+		if err := rows.Scan(&l.ID, &l.CreatedAt, &l.Source, &l.Method, &l.URL, &l.StatusCode, &l.DurationMs, &l.TokenID,
+			&l.RequestBody, &l.ResponseBody, &l.Error); err != nil {
+			return nil, 0, fmt.Errorf("读取 request_logs 记录失败: %v", err)
+		}
+		list = append(list, l)
+	}
+	return list, total, rows.Err()
+}
+
+func (s *sqliteStore) ClearRequestLogs() (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM request_logs`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqliteStore) InsertFailureEvent(e failureEvent) error {
+	_, err := s.db.Exec(`INSERT INTO failure_events (created_at, token_id, task_id, stage, message) VALUES (?, ?, ?, ?, ?)`,
+		e.CreatedAt, nullableID(e.TokenID), e.TaskID, e.Stage, e.Message)
+	return err
+}
+
+func (s *sqliteStore) FailureEventCounts() (map[int64]int, error) {
+	rows, err := s.db.Query(`SELECT COALESCE(token_id, 0), COUNT(*) FROM failure_events GROUP BY COALESCE(token_id, 0)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[int64]int{}
+	for rows.Next() {
+		var tokenID int64
+		var n int
+		if err := rows.Scan(&tokenID, &n); err != nil {
+			return nil, err
+		}
+		counts[tokenID] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqliteStore) FailureEventTimes(since int64) ([]int64, error) {
+	rows, err := s.db.Query(`SELECT created_at FROM failure_events WHERE created_at >= ?`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []int64
+	for rows.Next() {
+		var createdAt int64
+		if err := rows.Scan(&createdAt); err != nil {
+			return nil, err
+		}
+		list = append(list, createdAt)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) PruneFailureEvents(before int64) error {
+	_, err := s.db.Exec(`DELETE FROM failure_events WHERE created_at < ?`, before)
+	return err
+}
+
+func (s *sqliteStore) InsertTokenSnapshot(t tokenSnapshot) error {
+	_, err := s.db.Exec(`INSERT INTO token_snapshots (token_id, created_at, source, videos_remaining, credit_remaining,
+		access_resets_in_seconds, rate_limit_reached) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.TokenID, t.CreatedAt, t.Source, t.VideosRemaining, t.CreditRemaining, t.AccessResetsInSeconds, boolToInt(t.RateLimitReached))
+	return err
+}
+
+func (s *sqliteStore) ListTokenSnapshots(tokenID, since int64) ([]tokenSnapshot, error) {
+	query := `SELECT token_id, created_at, COALESCE(source, ''), COALESCE(videos_remaining, 0), COALESCE(credit_remaining, 0),
+		COALESCE(access_resets_in_seconds, 0), COALESCE(rate_limit_reached, 0) FROM token_snapshots WHERE created_at >= ?`
+	args := []interface{}{since}
+	if tokenID > 0 {
+		query += ` AND token_id = ?`
+		args = append(args, tokenID)
+	}
+	rows, err := s.db.Query(query+` ORDER BY token_id, created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []tokenSnapshot
+	for rows.Next() {
+		var t tokenSnapshot
+		var reached int
+		if err := rows.Scan(&t.TokenID, &t.CreatedAt, &t.Source, &t.VideosRemaining, &t.CreditRemaining,
+			&t.AccessResetsInSeconds, &reached); err != nil {
+			return nil, fmt.Errorf("读取 token_snapshots 记录失败: %v", err)
+		}
+		t.RateLimitReached = reached == 1
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) PruneTokenSnapshots(before int64) error {
+	_, err := s.db.Exec(`DELETE FROM token_snapshots WHERE created_at < ?`, before)
+	return err
+}
+
+func (s *sqliteStore) InsertHealthCheck(e tokenHealthEntry) error {
+	_, err := s.db.Exec(`INSERT INTO token_health_checks (token_id, checked_at, ok, latency_ms, plan_type, error) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.TokenID, e.CheckedAt, boolToInt(e.OK), e.LatencyMs, e.PlanType, e.Error)
+	return err
+}
+
+const healthCheckColumns = `token_id, checked_at, COALESCE(ok, 0), COALESCE(latency_ms, 0), COALESCE(plan_type, ''), COALESCE(error, '')`
+
+func (s *sqliteStore) queryHealthChecks(query string, args ...interface{}) ([]tokenHealthEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []tokenHealthEntry
+	for rows.Next() {
+		var e tokenHealthEntry
+		var ok int
+		if err := rows.Scan(&e.TokenID, &e.CheckedAt, &ok, &e.LatencyMs, &e.PlanType, &e.Error); err != nil {
+			return nil, fmt.Errorf("读取 token_health_checks 记录失败: %v", err)
+		}
+		e.OK = ok == 1
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) ListHealthChecks(tokenID int64) ([]tokenHealthEntry, error) {
+	return s.queryHealthChecks(`SELECT `+healthCheckColumns+` FROM token_health_checks WHERE token_id = ? ORDER BY id DESC`, tokenID)
+}
+
+func (s *sqliteStore) LatestHealthChecks() (map[int64]tokenHealthEntry, error) {
+	list, err := s.queryHealthChecks(`SELECT ` + healthCheckColumns + ` FROM token_health_checks
+		WHERE id IN (SELECT MAX(id) FROM token_health_checks GROUP BY token_id)`)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64]tokenHealthEntry, len(list))
+	for _, e := range list {
+		out[e.TokenID] = e
+	}
+	return out, nil
+}
+
+func (s *sqliteStore) PruneHealthChecks(tokenID int64, keep int) error {
+	_, err := s.db.Exec(`DELETE FROM token_health_checks WHERE token_id = ? AND id NOT IN
+		(SELECT id FROM token_health_checks WHERE token_id = ? ORDER BY id DESC LIMIT ?)`, tokenID, tokenID, keep)
+	return err
+}
+
+func (s *sqliteStore) ReassignTokenHistory(from []int64, to int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := reassignTokenRowsTx(tx, tokenHistoryTables, from, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tokenHistoryTables 按 token_id 关联账号的历史记录表
+var tokenHistoryTables = []string{"request_logs", "failure_events", "token_snapshots", "token_health_checks"}
+
+// reassignTokenRowsTx 把 tables 中属于 from 各账号的行改挂到 to
+func reassignTokenRowsTx(tx *sql.Tx, tables []string, from []int64, to int64) error {
+	for _, id := range from {
+		for _, table := range tables {
+			if _, err := tx.Exec(`UPDATE `+table+` SET token_id = ? WHERE token_id = ?`, to, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import "sync/atomic"
+
+// swapStore 可整体替换底层实现的 Store：a.store 初始化后只赋值一次，恢复备份时经 swap 换成新打开的存储，
+// 其他 goroutine 任何时刻读到的都是一个可用（或已关闭、调用返回 errStoreClosed）的 Store，不会读到 nil。
+// 每次调用只在开始时取一次当前实现，不持锁执行，回调（apply）里再访问 a.store 也不会死锁。
+type swapStore struct {
+	cur atomic.Pointer[storeRef]
+}
+
+// storeRef 包一层，atomic.Pointer 不能直接保存接口
+type storeRef struct{ s Store }
+
+func newSwapStore(s Store) *swapStore {
+	w := &swapStore{}
+	w.swap(s)
+	return w
+}
+
+// swap 替换底层存储，返回旧的实现（由调用方负责关闭）
+func (w *swapStore) swap(s Store) Store {
+	if old := w.cur.Swap(&storeRef{s: s}); old != nil {
+		return old.s
+	}
+	return nil
+}
+
+func (w *swapStore) inner() Store { return w.cur.Load().s }
+
+func (w *swapStore) GetSetting(key string) (string, error) { return w.inner().GetSetting(key) }
+func (w *swapStore) SetSetting(key, value string) error    { return w.inner().SetSetting(key, value) }
+func (w *swapStore) Kind() string                          { return w.inner().Kind() }
+func (w *swapStore) Close() error                          { return w.inner().Close() }
+func (w *swapStore) Snapshot(dst string) error             { return w.inner().Snapshot(dst) }
+
+func (w *swapStore) ListTokens() ([]tokenRecord, error)     { return w.inner().ListTokens() }
+func (w *swapStore) GetToken(id int64) (tokenRecord, error) { return w.inner().GetToken(id) }
+func (w *swapStore) FindTokenID(hash, token string) (int64, error) {
+	return w.inner().FindTokenID(hash, token)
+}
+func (w *swapStore) InsertToken(t tokenRecord) (int64, error) { return w.inner().InsertToken(t) }
+func (w *swapStore) UpdateToken(id int64, apply func(t *tokenRecord) error) error {
+	return w.inner().UpdateToken(id, apply)
+}
+func (w *swapStore) DeleteToken(id int64) error { return w.inner().DeleteToken(id) }
+func (w *swapStore) UpdateTokens(ids []int64, apply func(t *tokenRecord) error) error {
+	return w.inner().UpdateTokens(ids, apply)
+}
+func (w *swapStore) DeleteTokens(ids []int64) error { return w.inner().DeleteTokens(ids) }
+func (w *swapStore) ReplaceTokens(list []tokenRecord) ([]int64, error) {
+	return w.inner().ReplaceTokens(list)
+}
+func (w *swapStore) MergeTokens(keep tokenRecord, removed []int64) error {
+	return w.inner().MergeTokens(keep, removed)
+}
+
+func (w *swapStore) ListTasks() ([]taskRecord, error) { return w.inner().ListTasks() }
+func (w *swapStore) InflightTaskCounts() (map[int64]int, error) {
+	return w.inner().InflightTaskCounts()
+}
+func (w *swapStore) GetTask(taskID string) (taskRecord, error) { return w.inner().GetTask(taskID) }
+func (w *swapStore) PutTask(t taskRecord) error                { return w.inner().PutTask(t) }
+func (w *swapStore) DeleteTask(taskID string) error            { return w.inner().DeleteTask(taskID) }
+func (w *swapStore) ListDownloads() ([]downloadRecord, error)  { return w.inner().ListDownloads() }
+func (w *swapStore) PutDownload(d downloadRecord) error        { return w.inner().PutDownload(d) }
+func (w *swapStore) DeleteDownloads(taskID string) error       { return w.inner().DeleteDownloads(taskID) }
+func (w *swapStore) GetTaskList() (string, error)              { return w.inner().GetTaskList() }
+func (w *swapStore) SetTaskList(value string) error            { return w.inner().SetTaskList(value) }
+func (w *swapStore) UpdateTask(taskID string, apply func(t *taskRecord) error) error {
+	return w.inner().UpdateTask(taskID, apply)
+}
+func (w *swapStore) UpdateDownloads(taskID string, apply func(d *downloadRecord)) error {
+	return w.inner().UpdateDownloads(taskID, apply)
+}
+
+func (w *swapStore) InsertRequestLog(l requestLogRecord) error { return w.inner().InsertRequestLog(l) }
+func (w *swapStore) PruneRequestLogs(maxRows int) error        { return w.inner().PruneRequestLogs(maxRows) }
+func (w *swapStore) ListRequestLogs(f requestLogFilter, limit, offset int) ([]requestLogRecord, int, error) {
+	return w.inner().ListRequestLogs(f, limit, offset)
+}
+func (w *swapStore) ClearRequestLogs() (int64, error)        { return w.inner().ClearRequestLogs() }
+func (w *swapStore) InsertFailureEvent(e failureEvent) error { return w.inner().InsertFailureEvent(e) }
+func (w *swapStore) FailureEventCounts() (map[int64]int, error) {
+	return w.inner().FailureEventCounts()
+}
+func (w *swapStore) FailureEventTimes(since int64) ([]int64, error) {
+	return w.inner().FailureEventTimes(since)
+}
+func (w *swapStore) PruneFailureEvents(before int64) error {
+	return w.inner().PruneFailureEvents(before)
+}
+func (w *swapStore) InsertTokenSnapshot(t tokenSnapshot) error {
+	return w.inner().InsertTokenSnapshot(t)
+}
+func (w *swapStore) ListTokenSnapshots(tokenID, since int64) ([]tokenSnapshot, error) {
+	return w.inner().ListTokenSnapshots(tokenID, since)
+}
+func (w *swapStore) PruneTokenSnapshots(before int64) error {
+	return w.inner().PruneTokenSnapshots(before)
+}
+func (w *swapStore) InsertHealthCheck(e tokenHealthEntry) error {
+	return w.inner().InsertHealthCheck(e)
+}
+func (w *swapStore) ListHealthChecks(tokenID int64) ([]tokenHealthEntry, error) {
+	return w.inner().ListHealthChecks(tokenID)
+}
+func (w *swapStore) LatestHealthChecks() (map[int64]tokenHealthEntry, error) {
+	return w.inner().LatestHealthChecks()
+}
+func (w *swapStore) PruneHealthChecks(tokenID int64, keep int) error {
+	return w.inner().PruneHealthChecks(tokenID, keep)
+}
+func (w *swapStore) ReassignTokenHistory(from []int64, to int64) error {
+	return w.inner().ReassignTokenHistory(from, to)
+}
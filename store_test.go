@@ -0,0 +1,374 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestSQLiteStore 在临时目录建一个已迁移的 SQLite 存储；无 CGO 时跳过
+func openTestSQLiteStore(t *testing.T) *sqliteStore {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), dbFileName))
+	if err == nil {
+		_, err = runSchemaMigrations(db)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "requires cgo") {
+			t.Skip("SQLite 需要 CGO")
+		}
+		t.Fatal(err)
+	}
+	s := newSQLiteStore(db)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// forEachStore 对 SQLite 与 JSON 文件存储分别执行同一组断言，保证两种实现行为一致
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, openTestSQLiteStore(t)) })
+	t.Run("json", func(t *testing.T) {
+		s, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(t, s)
+	})
+}
+
+func insertTestTokens(t *testing.T, s Store, tokens ...string) []int64 {
+	t.Helper()
+	var ids []int64
+	for _, tok := range tokens {
+		id, err := s.InsertToken(tokenRecord{Token: tok, TokenHash: "h-" + tok, IsActive: true, VideoEnabled: true, VideoConcurrency: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func tokenNames(t *testing.T, s Store) []string {
+	t.Helper()
+	list, err := s.ListTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tok := range list {
+		names = append(names, tok.Token)
+	}
+	return names
+}
+
+func TestStoreSettings(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		if _, err := s.GetSetting("missing"); !errors.Is(err, errNotFound) {
+			t.Errorf("GetSetting(missing) error = %v, want errNotFound", err)
+		}
+		for _, v := range []string{"v1", "v2"} {
+			if err := s.SetSetting("k", v); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.GetSetting("k"); err != nil || got != v {
+				t.Errorf("GetSetting(k) = %q, %v; want %q", got, err, v)
+			}
+		}
+		if _, err := s.GetTaskList(); !errors.Is(err, errNotFound) {
+			t.Errorf("GetTaskList() error = %v, want errNotFound", err)
+		}
+		if err := s.SetTaskList(`[{"id":"1"}]`); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetTaskList(); got != `[{"id":"1"}]` {
+			t.Errorf("GetTaskList() = %q", got)
+		}
+	})
+}
+
+func TestStoreTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ids := insertTestTokens(t, s, "t1", "t2", "t3")
+		if ids[0] >= ids[1] || ids[1] >= ids[2] {
+			t.Fatalf("InsertToken ids not increasing: %v", ids)
+		}
+		if id, err := s.FindTokenID("h-t2", ""); err != nil || id != ids[1] {
+			t.Errorf("FindTokenID(hash) = %d, %v; want %d", id, err, ids[1])
+		}
+		if id, err := s.FindTokenID("", "t3"); err != nil || id != ids[2] {
+			t.Errorf("FindTokenID(token) = %d, %v; want %d", id, err, ids[2])
+		}
+		if _, err := s.FindTokenID("nope", "nope"); !errors.Is(err, errNotFound) {
+			t.Errorf("FindTokenID(missing) error = %v, want errNotFound", err)
+		}
+
+		if err := s.UpdateToken(ids[0], func(tok *tokenRecord) error {
+			tok.Remark, tok.Groups, tok.ErrorCount = "note", []string{"b", "a"}, 2
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetToken(ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Remark != "note" || got.ErrorCount != 2 || !reflect.DeepEqual(got.Groups, []string{"a", "b"}) || !got.IsActive {
+			t.Errorf("GetToken() after update = %+v", got)
+		}
+		if err := s.UpdateToken(999, func(*tokenRecord) error { return nil }); !errors.Is(err, errNotFound) {
+			t.Errorf("UpdateToken(missing) error = %v, want errNotFound", err)
+		}
+		if _, err := s.GetToken(999); !errors.Is(err, errNotFound) {
+			t.Errorf("GetToken(missing) error = %v, want errNotFound", err)
+		}
+
+		// 批量操作任一失败时全部不生效
+		boom := errors.New("boom")
+		err = s.UpdateTokens([]int64{ids[1], ids[2]}, func(tok *tokenRecord) error {
+			if tok.ID == ids[2] {
+				return boom
+			}
+			tok.Remark = "changed"
+			return nil
+		})
+		if !errors.Is(err, boom) {
+			t.Errorf("UpdateTokens() error = %v, want boom", err)
+		}
+		if tok, _ := s.GetToken(ids[1]); tok.Remark != "" {
+			t.Errorf("UpdateTokens() partially applied: %+v", tok)
+		}
+		if err := s.DeleteTokens([]int64{ids[1], 999}); !errors.Is(err, errNotFound) {
+			t.Errorf("DeleteTokens(missing) error = %v, want errNotFound", err)
+		}
+		if names := tokenNames(t, s); !reflect.DeepEqual(names, []string{"t1", "t2", "t3"}) {
+			t.Errorf("DeleteTokens() partially applied: %v", names)
+		}
+
+		if err := s.DeleteToken(ids[1]); err != nil {
+			t.Fatal(err)
+		}
+		if names := tokenNames(t, s); !reflect.DeepEqual(names, []string{"t1", "t3"}) {
+			t.Errorf("ListTokens() after delete = %v", names)
+		}
+
+		newIDs, err := s.ReplaceTokens([]tokenRecord{{Token: "n1", Groups: []string{"g"}}, {Token: "n2"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := tokenNames(t, s); !reflect.DeepEqual(names, []string{"n1", "n2"}) || len(newIDs) != 2 {
+			t.Errorf("ReplaceTokens() = %v, tokens %v", newIDs, names)
+		}
+		if tok, _ := s.GetToken(newIDs[0]); !reflect.DeepEqual(tok.Groups, []string{"g"}) {
+			t.Errorf("ReplaceTokens() groups = %v", tok.Groups)
+		}
+	})
+}
+
+func TestStoreMergeTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ids := insertTestTokens(t, s, "keep", "dup1", "dup2", "other")
+		for i, id := range ids {
+			if err := s.PutTask(taskRecord{TaskID: fmt.Sprintf("task%d", i), TokenID: id, ProgressPct: 100}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		keep, err := s.GetToken(ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		keep.Remark = "merged"
+
+		// 任一账号不存在时全部不生效
+		if err := s.MergeTokens(keep, []int64{ids[1], 999}); !errors.Is(err, errNotFound) {
+			t.Errorf("MergeTokens(missing) error = %v, want errNotFound", err)
+		}
+		if names := tokenNames(t, s); !reflect.DeepEqual(names, []string{"keep", "dup1", "dup2", "other"}) {
+			t.Errorf("MergeTokens(missing) partially applied: %v", names)
+		}
+		if tok, _ := s.GetToken(ids[0]); tok.Remark != "" {
+			t.Errorf("MergeTokens(missing) updated keep: %+v", tok)
+		}
+		if task, _ := s.GetTask("task1"); task.TokenID != ids[1] {
+			t.Errorf("MergeTokens(missing) moved task to %d", task.TokenID)
+		}
+
+		if err := s.MergeTokens(keep, []int64{ids[1], ids[2]}); err != nil {
+			t.Fatal(err)
+		}
+		if names := tokenNames(t, s); !reflect.DeepEqual(names, []string{"keep", "other"}) {
+			t.Errorf("ListTokens() after merge = %v", names)
+		}
+		if tok, _ := s.GetToken(ids[0]); tok.Remark != "merged" {
+			t.Errorf("GetToken(keep) after merge = %+v", tok)
+		}
+		for task, want := range map[string]int64{"task0": ids[0], "task1": ids[0], "task2": ids[0], "task3": ids[3]} {
+			if got, err := s.GetTask(task); err != nil || got.TokenID != want {
+				t.Errorf("GetTask(%s).TokenID = %d, %v; want %d", task, got.TokenID, err, want)
+			}
+		}
+	})
+}
+
+func TestStoreHealthChecks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ids := insertTestTokens(t, s, "t1", "t2", "t3")
+		for i := 1; i <= 5; i++ {
+			for _, id := range ids {
+				if err := s.InsertHealthCheck(tokenHealthEntry{TokenID: id, CheckedAt: int64(i), OK: i%2 == 1}); errors.Is(err, errHistoryUnavailable) {
+					t.Skip("JSON 文件存储不记录健康检查")
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := s.PruneHealthChecks(ids[0], 3); err != nil {
+			t.Fatal(err)
+		}
+		checkedAt := func(id int64) []int64 {
+			t.Helper()
+			list, err := s.ListHealthChecks(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out []int64
+			for _, e := range list {
+				out = append(out, e.CheckedAt)
+			}
+			return out
+		}
+		if got := checkedAt(ids[0]); !reflect.DeepEqual(got, []int64{5, 4, 3}) {
+			t.Errorf("ListHealthChecks() after prune = %v, want [5 4 3]", got)
+		}
+		if got := checkedAt(ids[1]); len(got) != 5 {
+			t.Errorf("PruneHealthChecks() touched other tokens: %v", got)
+		}
+		latest, err := s.LatestHealthChecks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := latest[ids[2]]; len(latest) != 3 || e.CheckedAt != 5 || !e.OK {
+			t.Errorf("LatestHealthChecks() = %+v", latest)
+		}
+
+		// 合并时改挂到保留账号，删除账号时一并删除
+		keep, _ := s.GetToken(ids[0])
+		if err := s.MergeTokens(keep, []int64{ids[1]}); err != nil {
+			t.Fatal(err)
+		}
+		if got := checkedAt(ids[0]); len(got) != 8 {
+			t.Errorf("ListHealthChecks() after merge = %v, want 8 entries", got)
+		}
+		if err := s.DeleteTokens([]int64{ids[0]}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteToken(ids[2]); err != nil {
+			t.Fatal(err)
+		}
+		if latest, _ := s.LatestHealthChecks(); len(latest) != 0 {
+			t.Errorf("LatestHealthChecks() after delete = %+v", latest)
+		}
+	})
+}
+
+func TestStoreTasks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		tasks := []taskRecord{
+			{TaskID: "running", TokenID: 1, ProgressPct: 40},
+			{TaskID: "queued", TokenID: 1, Status: videoTaskStatusQueued},
+			{TaskID: "done", TokenID: 1, ProgressPct: 100},
+			{TaskID: "failed", TokenID: 2, Status: videoTaskStatusFailed},
+			{TaskID: "other", TokenID: 2, ProgressPct: 10},
+			{TaskID: "unlinked", ProgressPct: 10},
+		}
+		for _, task := range tasks {
+			if err := s.PutTask(task); err != nil {
+				t.Fatal(err)
+			}
+		}
+		counts, err := s.InflightTaskCounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[int64]int{1: 2, 2: 1}; !reflect.DeepEqual(counts, want) {
+			t.Errorf("InflightTaskCounts() = %v, want %v", counts, want)
+		}
+		if err := s.UpdateTask("running", func(task *taskRecord) error {
+			task.Status, task.Message = videoTaskStatusCancelled, "已手动取消"
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetTask("running")
+		if err != nil || got.Status != videoTaskStatusCancelled || got.TokenID != 1 || got.ProgressPct != 40 {
+			t.Errorf("GetTask() after update = %+v, %v", got, err)
+		}
+		if err := s.UpdateTask("missing", func(*taskRecord) error { return nil }); !errors.Is(err, errNotFound) {
+			t.Errorf("UpdateTask(missing) error = %v, want errNotFound", err)
+		}
+		if err := s.DeleteTask("other"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetTask("other"); !errors.Is(err, errNotFound) {
+			t.Errorf("GetTask(deleted) error = %v, want errNotFound", err)
+		}
+		list, err := s.ListTasks()
+		if err != nil || len(list) != len(tasks)-1 {
+			t.Errorf("ListTasks() = %d tasks, %v", len(list), err)
+		}
+	})
+}
+
+func TestStoreDownloads(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		for _, d := range []downloadRecord{
+			{GenerationID: "g1", TaskID: "a", LocalPath: "/old/1.mp4"},
+			{GenerationID: "g2", TaskID: "a", LocalPath: "/old/2.mp4"},
+			{GenerationID: "g3", TaskID: "b", LocalPath: "/old/3.mp4"},
+		} {
+			if err := s.PutDownload(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 同一 generation_id 覆盖
+		if err := s.PutDownload(downloadRecord{GenerationID: "g3", TaskID: "b", LocalPath: "/old/3b.mp4"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdateDownloads("a", func(d *downloadRecord) {
+			d.LocalPath = strings.Replace(d.LocalPath, "/old/", "/new/", 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		paths := func() []string {
+			list, err := s.ListDownloads()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out []string
+			for _, d := range list {
+				out = append(out, d.GenerationID+":"+d.LocalPath)
+			}
+			return out
+		}
+		want := []string{"g1:/new/1.mp4", "g2:/new/2.mp4", "g3:/old/3b.mp4"}
+		if got := paths(); !reflect.DeepEqual(got, want) {
+			t.Errorf("ListDownloads() = %v, want %v", got, want)
+		}
+		if err := s.DeleteDownloads("a"); err != nil {
+			t.Fatal(err)
+		}
+		if got := paths(); !reflect.DeepEqual(got, []string{"g3:/old/3b.mp4"}) {
+			t.Errorf("ListDownloads() after DeleteDownloads(a) = %v", got)
+		}
+		if err := s.DeleteDownloads(""); err != nil {
+			t.Fatal(err)
+		}
+		if got := paths(); len(got) != 0 {
+			t.Errorf("DeleteDownloads(\"\") left %v", got)
+		}
+	})
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 后台视频任务引擎：接管视频任务从创建、pending 轮询到 drafts 下载的完整生命周期。
+// 每个任务由独立 goroutine 推进，关闭或刷新窗口不会中断任务；进度通过 runtime.EventsEmit 推送给前端。
+
+const (
+	// videoTaskEventName 前端通过 EventsOn 订阅的事件名
+	videoTaskEventName = "video-task:update"
+	// videoTaskPollInterval pending 轮询间隔（与原前端 10s 一致）
+	videoTaskPollInterval = 10 * time.Second
+	// videoTaskDraftsRetries 任务完成后拉取 drafts 的重试次数（drafts 可能稍晚才出现）
+	videoTaskDraftsRetries = 3
+)
+
+// video_task_results.status 取值
+const (
+	videoTaskStatusSubmitting = "submitting"
+	videoTaskStatusQueued     = "queued"
+	videoTaskStatusRunning    = "running"
+	videoTaskStatusDone       = "done"
+	videoTaskStatusFailed     = "failed"
+	videoTaskStatusCancelled  = "cancelled"
+)
+
+// VideoTaskEvent 推送给前端的任务状态变更
+type VideoTaskEvent struct {
+	LocalID   string  `json:"local_id"`
+	TaskID    string  `json:"task_id"`
+	TokenID   int64   `json:"token_id"`
+	Status    string  `json:"status"`
+	Progress  float64 `json:"progress"`
+	Message   string  `json:"message"`
+	LocalPath string  `json:"local_path,omitempty"`
+}
+
+// VideoTaskRequest 前端提交给引擎的视频生成参数
+type VideoTaskRequest struct {
+	LocalID string `json:"local_id"`
+	Prompt  string `json:"prompt"`
+	Model   string `json:"model"` // 模型目录中的名称，如 sora2-landscape-10s，见 model_catalog.go
+	Group   string `json:"group"` // 账号分组，非空时只使用该分组的账号
+}
+
+// videoJob 引擎中正在执行的单个任务
+type videoJob struct {
+	localID   string
+	taskID    string
+	tokenID   int64
+	startedAt time.Time // 远程任务创建时间，用于 video_timeout 超时判断
+	cancel    context.CancelFunc
+}
+
+type videoTaskEngine struct {
+	app *App
+
+	mu     sync.Mutex
+	ctx    context.Context // 由 start 设置，stop 后取消；已取消时不再接受新任务
+	stopFn context.CancelFunc
+	jobs   map[string]*videoJob // key: 前端任务 id，或 "remote:"+远程 task_id
+	wg     sync.WaitGroup       // 运行中的任务 goroutine，恢复备份前等待它们退出
+}
+
+func newVideoTaskEngine(app *App) *videoTaskEngine {
+	return &videoTaskEngine{app: app, jobs: map[string]*videoJob{}}
+}
+
+// start 启动引擎并恢复数据库中未完成的任务；stop 之后可再次调用以重新启动
+func (e *videoTaskEngine) start() {
+	parent := e.app.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	e.mu.Lock()
+	e.ctx, e.stopFn = context.WithCancel(parent)
+	e.jobs = map[string]*videoJob{}
+	e.mu.Unlock()
+	go func() {
+		n := e.resume()
+		e.app.logInfo(fmt.Sprintf("[TaskEngine] 已启动，恢复未完成任务 %d 个", n))
+	}()
+}
+
+// stop 取消所有任务的 goroutine，不等待其退出
+func (e *videoTaskEngine) stop() {
+	e.mu.Lock()
+	if e.stopFn != nil {
+		e.stopFn()
+	}
+	e.mu.Unlock()
+}
+
+// stopAndWait 取消所有任务并等待 goroutine 退出，超时返回 false
+func (e *videoTaskEngine) stopAndWait(timeout time.Duration) bool {
+	e.stop()
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runningLocked 引擎已启动且未停止，调用方需持有 e.mu
+func (e *videoTaskEngine) runningLocked() bool {
+	return e.ctx != nil && e.ctx.Err() == nil
+}
+
+// resume 从 video_task_results 读取未完成任务并接管轮询，返回新接管的任务数
+func (e *videoTaskEngine) resume() int {
+	if e.app.store == nil {
+		return 0
+	}
+	tasks, err := e.app.store.ListTasks()
+	if err != nil {
+		e.app.logError(fmt.Sprintf("[TaskEngine] 查询未完成任务失败: %v", err))
+		return 0
+	}
+
+	resumed := 0
+	for _, t := range tasks {
+		if !t.needsPolling() || t.TokenID == 0 {
+			continue
+		}
+		if e.track("", t.TaskID, t.TokenID, t.CreatedAt) {
+			resumed++
+		}
+	}
+	return resumed
+}
+
+// submit 登记一个新任务，并在后台完成选号、创建与轮询；返回实际使用的 local_id（去掉首尾空白，未提供时自动生成）
+func (e *videoTaskEngine) submit(req VideoTaskRequest) (string, error) {
+	model, err := lookupVideoModel(req.Model)
+	if err != nil {
+		return "", err
+	}
+	req.LocalID = strings.TrimSpace(req.LocalID)
+	if req.LocalID == "" {
+		req.LocalID = "local:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	e.mu.Lock()
+	if !e.runningLocked() {
+		e.mu.Unlock()
+		return "", fmt.Errorf("任务引擎未启动")
+	}
+	if e.findLocked(req.LocalID) != nil {
+		e.mu.Unlock()
+		return "", fmt.Errorf("任务 %s 已在执行", req.LocalID)
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	job := &videoJob{localID: req.LocalID, cancel: cancel}
+	e.jobs[req.LocalID] = job
+	e.wg.Add(1)
+	e.mu.Unlock()
+
+	go func() {
+		defer e.finish(req.LocalID, job)
+		e.create(ctx, job, req, model)
+	}()
+	return req.LocalID, nil
+}
+
+// track 接管一个已创建的远程任务的轮询；已在轮询中时返回 false
+func (e *videoTaskEngine) track(localID, taskID string, tokenID int64, startedAt time.Time) bool {
+	e.mu.Lock()
+	if !e.runningLocked() || e.findLocked(taskID) != nil || (localID != "" && e.findLocked(localID) != nil) {
+		e.mu.Unlock()
+		return false
+	}
+	key := localID
+	if key == "" {
+		key = "remote:" + taskID
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	job := &videoJob{localID: localID, taskID: taskID, tokenID: tokenID, startedAt: startedAt, cancel: cancel}
+	e.jobs[key] = job
+	e.wg.Add(1)
+	e.mu.Unlock()
+
+	go func() {
+		defer e.finish(key, job)
+		e.poll(ctx, job)
+	}()
+	return true
+}
+
+// cancel 按前端任务 id 或远程 task_id 取消任务
+func (e *videoTaskEngine) cancel(id string) bool {
+	e.mu.Lock()
+	job := e.findLocked(id)
+	e.mu.Unlock()
+	if job == nil {
+		return false
+	}
+	job.cancel()
+	e.setStatus(job.taskID, videoTaskStatusCancelled, "已手动取消")
+	e.emit(job, videoTaskStatusCancelled, 0, "已手动取消", "")
+	return true
+}
+
+// findLocked 按前端任务 id 或远程 task_id 查找任务，调用方需持有 e.mu
+func (e *videoTaskEngine) findLocked(id string) *videoJob {
+	if id == "" {
+		return nil
+	}
+	for _, job := range e.jobs {
+		if job.localID == id || job.taskID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// finish 任务 goroutine 退出时调用；引擎重启后同一 key 可能已是新任务，只删除自己登记的那一个
+func (e *videoTaskEngine) finish(key string, job *videoJob) {
+	job.cancel()
+	e.mu.Lock()
+	if e.jobs[key] == job {
+		delete(e.jobs, key)
+	}
+	e.mu.Unlock()
+	e.wg.Done()
+	// 任务结束后账号并发名额可能已释放，唤醒排队中的任务
+	if e.app.scheduler != nil {
+		e.app.scheduler.notify()
+	}
+}
+
+// create 按模型要求的账号类型选号并调用 POST /videos，成功后写入 video_task_results 并转入轮询
+func (e *videoTaskEngine) create(ctx context.Context, job *videoJob, req VideoTaskRequest, model videoModel) {
+	a := e.app
+	e.emit(job, videoTaskStatusSubmitting, 0, "正在选择账号…", "")
+	if a.scheduler == nil {
+		e.emit(job, videoTaskStatusFailed, 0, "SQLite 未初始化", "")
+		return
+	}
+	lease, err := a.scheduler.acquire(ctx, model.RequiredPlan, req.Group, func() {
+		e.emit(job, videoTaskStatusQueued, 0, "所有账号并发已满，排队等待空闲账号…", "")
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			e.emit(job, videoTaskStatusFailed, 0, err.Error(), "")
+		}
+		return
+	}
+	// 名额在任务写入 video_task_results 后由数据库计数接管，届时（或创建失败时）释放预占
+	reserved := true
+	releaseLease := func() {
+		if reserved {
+			reserved = false
+			a.scheduler.release(lease.id)
+		}
+	}
+	defer releaseLease()
+	tokenID, bearer := lease.id, lease.token
+	e.mu.Lock()
+	job.tokenID = tokenID
+	e.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	apiBase := strings.TrimRight(a.GetBaseURL(), "/")
+	resp, err := a.createVideo(apiBase, tokenID, bearer, req.Prompt, model)
+	if err != nil {
+		if isTokenInvalidatedText(err.Error()) {
+			e.invalidateToken(job)
+			return
+		}
+		a.recordTokenFailure(tokenID, failureStageCreate, "", "创建视频失败: "+err.Error())
+		e.emit(job, videoTaskStatusFailed, 0, err.Error(), "")
+		return
+	}
+	taskID, err := a.saveVideoTaskResult(tokenID, resp, req.Prompt)
+	if err != nil {
+		msg := "未返回 task_id: " + err.Error()
+		var data struct {
+			Error interface{} `json:"error"`
+		}
+		if json.Unmarshal([]byte(resp), &data) == nil && data.Error != nil {
+			msg = fmt.Sprint(data.Error)
+		}
+		a.recordTokenFailure(tokenID, failureStageCreate, "", "创建视频失败: "+msg)
+		e.emit(job, videoTaskStatusFailed, 0, msg, "")
+		return
+	}
+	a.recordTokenSuccess(tokenID)
+
+	e.mu.Lock()
+	job.taskID = taskID
+	job.startedAt = time.Now()
+	e.mu.Unlock()
+	if ctx.Err() != nil {
+		// 创建期间被取消：任务已在远程创建，标记为取消避免下次启动时恢复
+		e.setStatus(taskID, videoTaskStatusCancelled, "已手动取消")
+		return
+	}
+	releaseLease()
+	e.emit(job, videoTaskStatusRunning, 0, "已提交，每 10s 轮询 pending…", "")
+	e.poll(ctx, job)
+}
+
+// poll 每 videoTaskPollInterval 轮询一次 pending，直到任务完成、失败或被取消
+func (e *videoTaskEngine) poll(ctx context.Context, job *videoJob) {
+	ticker := time.NewTicker(videoTaskPollInterval)
+	defer ticker.Stop()
+	for {
+		if e.pollOnce(ctx, job) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce 执行一次 pending 轮询，返回 true 表示任务已结束
+func (e *videoTaskEngine) pollOnce(ctx context.Context, job *videoJob) bool {
+	a := e.app
+	if ctx.Err() != nil {
+		return true
+	}
+	if timeout := a.loadAppConfig().videoTimeoutDuration(); !job.startedAt.IsZero() && time.Since(job.startedAt) > timeout {
+		msg := fmt.Sprintf("任务超时（超过 %s 未完成），停止 pending", timeout)
+		a.recordFailureEvent(job.tokenID, job.taskID, failureStageTimeout, msg)
+		e.setStatus(job.taskID, videoTaskStatusFailed, msg)
+		e.emit(job, videoTaskStatusFailed, -1, msg, "")
+		return true
+	}
+	bearer, err := a.bearerForToken(job.tokenID)
+	if err != nil {
+		msg := "无法获取账号 bearer，停止 pending: " + err.Error()
+		e.setStatus(job.taskID, videoTaskStatusFailed, msg)
+		e.emit(job, videoTaskStatusFailed, 0, msg, "")
+		return true
+	}
+	apiBase := strings.TrimRight(a.GetBaseURL(), "/")
+	body, err := a.pollPending(apiBase, job.tokenID, bearer)
+	if ctx.Err() != nil {
+		return true
+	}
+	if err != nil {
+		if isTokenInvalidatedText(err.Error()) {
+			e.invalidateToken(job)
+			return true
+		}
+		if a.recordTokenFailure(job.tokenID, failureStagePoll, job.taskID, "pending 轮询失败: "+err.Error()) {
+			msg := "账号连续失败已自动禁用，停止 pending: " + err.Error()
+			e.setStatus(job.taskID, videoTaskStatusFailed, msg)
+			e.emit(job, videoTaskStatusFailed, -1, msg, "")
+			return true
+		}
+		e.emit(job, videoTaskStatusRunning, -1, "pending 轮询失败: "+err.Error(), "")
+		return false
+	}
+	if isTokenInvalidatedText(body) {
+		e.invalidateToken(job)
+		return true
+	}
+	a.recordTokenSuccess(job.tokenID)
+	found, pct, err := findPendingProgress(body, job.taskID)
+	if err != nil {
+		e.emit(job, videoTaskStatusRunning, -1, "pending 响应解析失败: "+err.Error(), "")
+		return false
+	}
+	if !found {
+		e.complete(ctx, job, bearer, "已完成（pending 中不存在该任务）")
+		return true
+	}
+	if pct >= 100 {
+		e.complete(ctx, job, bearer, "已完成（progress_pct=100%）")
+		return true
+	}
+	if a.store != nil {
+		_ = a.store.UpdateTask(job.taskID, func(t *taskRecord) error {
+			t.ProgressPct = pct
+			return nil
+		})
+	}
+	e.emit(job, videoTaskStatusRunning, pct, fmt.Sprintf("pending 进度 %.0f%%", pct), "")
+	return false
+}
+
+// complete 标记进度 100%，拉取 drafts 并下载对应视频
+func (e *videoTaskEngine) complete(ctx context.Context, job *videoJob, bearer string, message string) {
+	a := e.app
+	if a.store != nil {
+		_ = a.store.UpdateTask(job.taskID, func(t *taskRecord) error {
+			t.ProgressPct, t.FinishedAt = 100, time.Now().Unix()
+			return nil
+		})
+	}
+	e.emit(job, videoTaskStatusRunning, 100, message+"，拉取 drafts…", "")
+
+	localPath, err := e.downloadResult(ctx, job.tokenID, bearer, job.taskID)
+	if err != nil {
+		msg := message + "；drafts 拉取/下载失败: " + err.Error()
+		a.recordFailureEvent(job.tokenID, job.taskID, failureStageDownload, err.Error())
+		e.setStatus(job.taskID, videoTaskStatusDone, msg)
+		e.emit(job, videoTaskStatusDone, 100, msg, "")
+		return
+	}
+	e.setStatus(job.taskID, videoTaskStatusDone, message)
+	e.emit(job, videoTaskStatusDone, 100, message, localPath)
+}
+
+// downloadResult 拉取 drafts 并仅下载 taskID 对应的视频，drafts 暂未出现时重试
+func (e *videoTaskEngine) downloadResult(ctx context.Context, tokenID int64, bearer string, taskID string) (string, error) {
+	a := e.app
+	apiBase := strings.TrimRight(a.GetBaseURL(), "/")
+	var lastErr error
+	for attempt := 0; attempt < videoTaskDraftsRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(videoTaskPollInterval):
+			}
+		}
+		draftsBody, err := a.fetchDrafts(apiBase, tokenID, bearer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		item, err := findDraftItem(draftsBody, taskID)
+		if err != nil {
+			lastErr = fmt.Errorf("drafts 解析失败: %v", err)
+			continue
+		}
+		if item == nil {
+			lastErr = fmt.Errorf("drafts 中无对应 task_id")
+			continue
+		}
+		downloadDir := a.downloadsDir()
+		if err := os.MkdirAll(downloadDir, 0755); err != nil {
+			return "", fmt.Errorf("创建下载目录失败: %v", err)
+		}
+		localPath, err := a.downloadDraftItem(*item, downloadDir)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return localPath, nil
+	}
+	return "", lastErr
+}
+
+// invalidateToken 账号失效：记录错误并禁用 token，停止该任务
+func (e *videoTaskEngine) invalidateToken(job *videoJob) {
+	a := e.app
+	who := "未知账号"
+	if job.tokenID > 0 {
+		who = fmt.Sprintf("token_id=%d", job.tokenID)
+		if email, err := a.tokenEmail(job.tokenID); err == nil {
+			who = email
+		}
+		_, _ = a.SetTokenError(job.tokenID, fmt.Sprintf("账号失效（%s），停止 pending", who))
+	}
+	msg := fmt.Sprintf("账号失效（%s），停止 pending", who)
+	a.recordFailureEvent(job.tokenID, job.taskID, failureStageInvalidated, msg)
+	e.setStatus(job.taskID, videoTaskStatusFailed, msg)
+	e.emit(job, videoTaskStatusFailed, 0, msg, "")
+}
+
+// setStatus 记录任务终态及结束时间（已记录过完成时间的保持不变），避免下次启动重复恢复
+func (e *videoTaskEngine) setStatus(taskID, status, message string) {
+	if e.app.store == nil || taskID == "" {
+		return
+	}
+	_ = e.app.store.UpdateTask(taskID, func(t *taskRecord) error {
+		t.Status, t.Message = status, redactSecrets(message)
+		if t.FinishedAt <= 0 {
+			t.FinishedAt = time.Now().Unix()
+		}
+		return nil
+	})
+}
+
+// emit 推送任务状态给前端；progress<0 表示进度不变
+func (e *videoTaskEngine) emit(job *videoJob, status string, progress float64, message string, localPath string) {
+	e.mu.Lock()
+	ev := VideoTaskEvent{
+		LocalID:   job.localID,
+		TaskID:    job.taskID,
+		TokenID:   job.tokenID,
+		Status:    status,
+		Progress:  progress,
+		Message:   message,
+		LocalPath: localPath,
+	}
+	e.mu.Unlock()
+	id := ev.LocalID
+	if id == "" {
+		id = ev.TaskID
+	}
+	e.app.logInfo(fmt.Sprintf("[TaskEngine] %s %s: %s", id, status, message))
+	if e.app.ctx != nil {
+		e.app.emitEvent(videoTaskEventName, ev)
+	}
+}
+
+// isTokenInvalidatedText 判断上游响应/错误是否表示账号失效
+func isTokenInvalidatedText(s string) bool {
+	return strings.Contains(s, "token_invalidated") || strings.Contains(s, "signing in again")
+}
+
+// findPendingProgress 在 pending 响应中查找 taskID，返回是否存在及进度（0-100）
+// 响应可能是数组或 {"tasks": [...]}；progress_pct 可能是 0-1 的小数或 0-100 的整数
+func findPendingProgress(body string, taskID string) (bool, float64, error) {
+	var list []map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		var wrapped struct {
+			Tasks []map[string]interface{} `json:"tasks"`
+		}
+		if err2 := json.Unmarshal([]byte(body), &wrapped); err2 != nil {
+			return false, 0, err
+		}
+		list = wrapped.Tasks
+	}
+	for _, t := range list {
+		id, _ := t["id"].(string)
+		tid, _ := t["task_id"].(string)
+		if id != taskID && tid != taskID {
+			continue
+		}
+		rawPct, _ := t["progress_pct"].(float64)
+		if rawPct >= 1 {
+			if rawPct <= 1 {
+				return true, 100, nil
+			}
+			return true, rawPct, nil
+		}
+		return true, rawPct * 100, nil
+	}
+	return false, 0, nil
+}
+
+// SubmitVideoTask 将视频任务交给后台引擎执行（选号、创建、轮询 pending、下载），立即返回
+// requestJson 见 VideoTaskRequest；进度通过 "video-task:update" 事件推送
+// 返回 JSON：{"success": true, "local_id": "..."} 或 {"success": false, "message": "..."}
+func (a *App) SubmitVideoTask(requestJson string) (string, error) {
+	if a.engine == nil {
+		return jsonFail("任务引擎未启动")
+	}
+	var req VideoTaskRequest
+	if err := json.Unmarshal([]byte(requestJson), &req); err != nil {
+		return jsonFail("请求体解析失败")
+	}
+	localID, err := a.engine.submit(req)
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "local_id": localID})
+}
+
+// CancelVideoTask 按前端任务 id 或远程 task_id 取消后台任务（不再轮询，也不会在下次启动时恢复）
+func (a *App) CancelVideoTask(id string) (string, error) {
+	if a.engine == nil {
+		return jsonFail("任务引擎未启动")
+	}
+	id = strings.TrimSpace(id)
+	if !a.engine.cancel(id) {
+		return jsonFail("任务不在执行中")
+	}
+	return jsonMarshal(map[string]interface{}{"success": true})
+}
+
+// ResumeVideoTasks 重新扫描数据库中未完成的视频任务并交给引擎（已在执行的任务会跳过）
+// 返回 JSON：{"success": true, "resumed": 2}
+func (a *App) ResumeVideoTasks() (string, error) {
+	if a.engine == nil {
+		return jsonFail("任务引擎未启动")
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "resumed": a.engine.resume()})
+}
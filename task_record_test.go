@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestTaskRecordDisplayStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  taskRecord
+		want string
+	}{
+		{name: "legacy running", rec: taskRecord{ProgressPct: 40}, want: videoTaskStatusRunning},
+		{name: "legacy done", rec: taskRecord{ProgressPct: 100}, want: videoTaskStatusDone},
+		{name: "failed keeps status", rec: taskRecord{ProgressPct: 40, Status: videoTaskStatusFailed}, want: videoTaskStatusFailed},
+		{name: "cancelled keeps status", rec: taskRecord{Status: videoTaskStatusCancelled}, want: videoTaskStatusCancelled},
+		{name: "queued", rec: taskRecord{Status: videoTaskStatusQueued}, want: videoTaskStatusQueued},
+	}
+	for _, tt := range tests {
+		if got := tt.rec.displayStatus(); got != tt.want {
+			t.Errorf("%s: displayStatus() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
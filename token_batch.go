@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 批量操作：POST /api/tokens/batch/<action>，请求体 {"token_ids": [...]}（update-proxy 另带 "proxy_url"，add-group/remove-group 另带 "group"）。
+// 启用、禁用、删除、改代理、调整分组先逐个校验，再把通过校验的账号放在同一事务内写入，写入失败时这些账号都不生效；
+// test-update 需要请求远程接口，按健康检查的并发上限并行执行。每个账号的结果在 results 中按请求顺序返回。
+
+// tokenBatchResult 批量操作中单个账号的结果
+type tokenBatchResult struct {
+	ID      int64  `json:"id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+var tokenBatchLabels = map[string]string{
+	"test-update":      "批量测试",
+	"enable-all":       "批量启用",
+	"disable-selected": "批量禁用",
+	"delete-disabled":  "批量删除",
+	"update-proxy":     "批量修改代理",
+	"add-group":        "加入分组",
+	"remove-group":     "移出分组",
+}
+
+func (a *App) localTokensBatch(method string, parts []string, body string) (string, error) {
+	if len(parts) < 3 {
+		return jsonFail("缺少 batch 操作类型")
+	}
+	action := parts[2]
+	label, ok := tokenBatchLabels[action]
+	if !ok {
+		return jsonFail("未知的 batch 操作: " + action)
+	}
+	var input struct {
+		TokenIDs []int64 `json:"token_ids"`
+		ProxyURL string  `json:"proxy_url"`
+		Group    string  `json:"group"`
+	}
+	if strings.TrimSpace(body) != "" {
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+	}
+	ids := uniqueTokenIDs(input.TokenIDs)
+	if len(ids) == 0 {
+		return jsonFail("未选择账号")
+	}
+
+	now := time.Now()
+	var results []tokenBatchResult
+	switch action {
+	case "test-update":
+		results = a.batchTestTokens(ids)
+	case "enable-all":
+		// 手动启用视为重新开始计数，与单个启用一致
+		results = a.batchUpdateTokens(ids, func(t *tokenRecord) error {
+			t.IsActive, t.ErrorCount, t.ErrorMessage, t.UpdatedAt = true, 0, "", now
+			return nil
+		})
+	case "disable-selected":
+		results = a.batchUpdateTokens(ids, func(t *tokenRecord) error {
+			t.IsActive, t.UpdatedAt = false, now
+			return nil
+		})
+	case "update-proxy":
+		if _, err := parseProxyURL(input.ProxyURL); err != nil {
+			return jsonFail(err.Error())
+		}
+		results = a.batchUpdateTokens(ids, func(t *tokenRecord) error {
+			t.ProxyURL, t.UpdatedAt = input.ProxyURL, now
+			return nil
+		})
+	case "add-group", "remove-group":
+		groups, err := validateTokenGroups([]string{input.Group})
+		if err != nil {
+			return jsonFail(err.Error())
+		}
+		if len(groups) == 0 {
+			return jsonFail("分组名不能为空")
+		}
+		group := groups[0]
+		results = a.batchUpdateTokens(ids, func(t *tokenRecord) error {
+			if action == "add-group" {
+				t.Groups = normalizeTokenGroups(append(t.Groups, group))
+			} else {
+				t.Groups = removeTokenGroup(t.Groups, group)
+			}
+			t.UpdatedAt = now
+			return nil
+		})
+	case "delete-disabled":
+		var err error
+		if results, err = a.batchDeleteTokens(ids); err != nil {
+			return jsonFail(err.Error())
+		}
+	}
+	if a.scheduler != nil {
+		a.scheduler.notify()
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+		}
+	}
+	failed := len(results) - succeeded
+	return jsonMarshal(map[string]interface{}{
+		"success":   true,
+		"action":    action,
+		"message":   fmt.Sprintf("%s：成功 %d 个，失败 %d 个", label, succeeded, failed),
+		"succeeded": succeeded,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// uniqueTokenIDs 去掉重复与非法的 id，保持原顺序
+func uniqueTokenIDs(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// batchUpdateTokens 在同一事务内对存在的账号执行 apply
+func (a *App) batchUpdateTokens(ids []int64, apply func(t *tokenRecord) error) []tokenBatchResult {
+	results, valid := a.batchValidate(ids, nil)
+	if len(valid) == 0 {
+		return results
+	}
+	markBatchCommitted(results, valid, a.store.UpdateTokens(valid, apply))
+	return results
+}
+
+// batchDeleteTokens 删除账号；还有进行中任务的账号拒绝删除。删除前在调度锁内再次检查，
+// 期间新出现任务的账号从本批中剔除后重试其余账号
+func (a *App) batchDeleteTokens(ids []int64) ([]tokenBatchResult, error) {
+	var inflight map[int64]int
+	if a.scheduler != nil {
+		var err error
+		if inflight, err = a.scheduler.inflightCounts(); err != nil {
+			return nil, err
+		}
+	}
+	results, valid := a.batchValidate(ids, func(t tokenRecord) string {
+		if n := inflight[t.ID]; n > 0 {
+			return fmt.Sprintf("有 %d 个进行中的任务，未删除", n)
+		}
+		return ""
+	})
+	for len(valid) > 0 {
+		busy, err := a.withIdleTokens(valid, func() error { return a.store.DeleteTokens(valid) })
+		if len(busy) == 0 {
+			markBatchCommitted(results, valid, err)
+			break
+		}
+		valid = rejectBatchIDs(results, valid, busy, "有进行中的任务，未删除")
+	}
+	return results, nil
+}
+
+// rejectBatchIDs 把 rejected 中的账号标记为失败，返回 valid 中剩余的 id
+func rejectBatchIDs(results []tokenBatchResult, valid, rejected []int64, msg string) []int64 {
+	drop := make(map[int64]bool, len(rejected))
+	for _, id := range rejected {
+		drop[id] = true
+	}
+	for i := range results {
+		if drop[results[i].ID] {
+			results[i].Message = msg
+		}
+	}
+	rest := valid[:0:0]
+	for _, id := range valid {
+		if !drop[id] {
+			rest = append(rest, id)
+		}
+	}
+	return rest
+}
+
+// batchValidate 按请求顺序生成结果，不存在或被 check 拒绝的账号直接标记失败，返回其余账号的 id
+func (a *App) batchValidate(ids []int64, check func(t tokenRecord) string) ([]tokenBatchResult, []int64) {
+	results := make([]tokenBatchResult, len(ids))
+	all, err := a.store.ListTokens()
+	if err != nil {
+		for i, id := range ids {
+			results[i] = tokenBatchResult{ID: id, Message: "读取账号失败: " + err.Error()}
+		}
+		return results, nil
+	}
+	byID := make(map[int64]tokenRecord, len(all))
+	for _, t := range all {
+		byID[t.ID] = t
+	}
+	var valid []int64
+	for i, id := range ids {
+		results[i].ID = id
+		t, ok := byID[id]
+		if !ok {
+			results[i].Message = "Token 不存在"
+			continue
+		}
+		if check != nil {
+			if msg := check(t); msg != "" {
+				results[i].Message = msg
+				continue
+			}
+		}
+		valid = append(valid, id)
+	}
+	return results, valid
+}
+
+// markBatchCommitted 按事务结果填写通过校验的账号的结果
+func markBatchCommitted(results []tokenBatchResult, valid []int64, err error) {
+	applied := make(map[int64]bool, len(valid))
+	for _, id := range valid {
+		applied[id] = true
+	}
+	for i := range results {
+		if !applied[results[i].ID] {
+			continue
+		}
+		if err != nil {
+			results[i].Message = "写入失败，本批未生效: " + err.Error()
+		} else {
+			results[i].Success = true
+		}
+	}
+}
+
+// batchTestTokens 以健康检查的并发上限并行测试账号，结果同样计入检查历史
+func (a *App) batchTestTokens(ids []int64) []tokenBatchResult {
+	results, valid := a.batchValidate(ids, nil)
+	index := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	forEachBounded(len(valid), a.loadAppConfig().HealthCheckConcurrency, func(i int) {
+		id := valid[i]
+		r := &results[index[id]] // 每个账号只由一个 goroutine 写入
+		rec, err := a.store.GetToken(id)
+		if err != nil {
+			r.Message = "Token 不存在"
+			return
+		}
+		bearer := a.openSecret(rec.Token)
+		if bearer == "" {
+			r.Message = "Token 解密失败"
+			return
+		}
+		email, err := a.probeToken(id, bearer)
+		if err != nil {
+			r.Message = truncateRunes(redactSecrets(err.Error()), 300)
+			return
+		}
+		r.Success, r.Email = true, email
+	})
+	return results
+}
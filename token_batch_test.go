@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newBatchTestApp 使用 JSON 存储与调度器的 App，预置 names 对应的账号
+func newBatchTestApp(t *testing.T, names ...string) (*App, []int64) {
+	t.Helper()
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{store: store, health: newHealthChecker()}
+	a.scheduler = newTokenScheduler(a)
+	return a, insertTestTokens(t, store, names...)
+}
+
+func TestBatchDeleteTokens(t *testing.T) {
+	a, ids := newBatchTestApp(t, "idle", "running", "reserved")
+	if err := a.store.PutTask(taskRecord{TaskID: "task", TokenID: ids[1], ProgressPct: 10}); err != nil {
+		t.Fatal(err)
+	}
+	// 已分配但任务尚未写库的名额同样视为进行中
+	a.scheduler.reserved[ids[2]] = 1
+
+	results, err := a.batchDeleteTokens([]int64{ids[0], ids[1], ids[2], 999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ok []bool
+	for _, r := range results {
+		ok = append(ok, r.Success)
+	}
+	if want := []bool{true, false, false, false}; !reflect.DeepEqual(ok, want) {
+		t.Errorf("batchDeleteTokens() success = %v, want %v (%+v)", ok, want, results)
+	}
+	if names := tokenNames(t, a.store); !reflect.DeepEqual(names, []string{"running", "reserved"}) {
+		t.Errorf("tokens after delete = %v", names)
+	}
+}
+
+func TestLocalTokenDelete(t *testing.T) {
+	a, ids := newBatchTestApp(t, "idle", "running")
+	if err := a.store.PutTask(taskRecord{TaskID: "task", TokenID: ids[1], Status: videoTaskStatusQueued}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   int64
+		want bool
+	}{
+		{"busy", ids[1], false},
+		{"missing", 999, false},
+		{"idle", ids[0], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := a.localTokenDelete(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var resp struct {
+				Success bool `json:"success"`
+			}
+			if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Success != tt.want {
+				t.Errorf("localTokenDelete(%d) = %s, want success %v", tt.id, raw, tt.want)
+			}
+		})
+	}
+	if names := tokenNames(t, a.store); !reflect.DeepEqual(names, []string{"running"}) {
+		t.Errorf("tokens after delete = %v", names)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ST→AT / RT→AT：用账号保存的 session token 或 refresh token 换取新的 access token。
+// 两个认证接口都可在系统配置中修改（auth_session_url、auth_token_url），便于指向本地模拟服务；
+// 换到的 AT（以及轮换后的 RT/ST）写回对应账号。
+
+const (
+	defaultAuthSessionURL = "https://sora.chatgpt.com/api/auth/session"
+	defaultAuthTokenURL   = "https://auth.openai.com/oauth/token"
+	defaultAuthClientID   = "app_LlGpXReQgckcGGUo2JrYvtJK"
+
+	authRedirectURI    = "com.openai.sora://auth.openai.com/android/com.openai.sora/callback"
+	sessionTokenCookie = "__Secure-next-auth.session-token"
+	authRequestTimeout = 30 * time.Second
+)
+
+// authExchangeResult 一次换取的结果；RefreshToken/SessionToken 仅在认证接口下发了新值时非空
+type authExchangeResult struct {
+	AccessToken  string
+	RefreshToken string
+	SessionToken string
+	ExpiresAt    int64 // Unix 秒，未知时为 0
+	Email        string
+}
+
+// authEndpoints 返回当前配置的会话接口、token 接口与默认 client_id
+func (a *App) authEndpoints() (string, string, string) {
+	cfg := a.loadAppConfig()
+	sessionURL, tokenURL, clientID := cfg.AuthSessionURL, cfg.AuthTokenURL, cfg.AuthClientID
+	if sessionURL == "" {
+		sessionURL = defaultAuthSessionURL
+	}
+	if tokenURL == "" {
+		tokenURL = defaultAuthTokenURL
+	}
+	if clientID == "" {
+		clientID = defaultAuthClientID
+	}
+	return sessionURL, tokenURL, clientID
+}
+
+// authErrorBody 截取认证接口的错误响应用于提示（已脱敏）
+func authErrorBody(body []byte) string {
+	return truncateRunes(redactSecrets(strings.TrimSpace(string(body))), 300)
+}
+
+// exchangeSessionToken 携带 ST cookie 请求会话接口，返回其中的 accessToken；tokenID 用于选择账号代理
+func (a *App) exchangeSessionToken(st string, tokenID int64) (authExchangeResult, error) {
+	st = strings.TrimSpace(st)
+	if st == "" {
+		return authExchangeResult{}, fmt.Errorf("Session Token 不能为空")
+	}
+	sessionURL, _, _ := a.authEndpoints()
+	req, err := http.NewRequest(http.MethodGet, sessionURL, nil)
+	if err != nil {
+		return authExchangeResult{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionTokenCookie, Value: st})
+	resp, err := a.httpClientForToken(tokenID, authRequestTimeout).Do(req)
+	if err != nil {
+		return authExchangeResult{}, fmt.Errorf("请求会话接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return authExchangeResult{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return authExchangeResult{}, fmt.Errorf("ST 转换失败, HTTP %d: %s", resp.StatusCode, authErrorBody(body))
+	}
+	var out struct {
+		AccessToken string `json:"accessToken"`
+		Expires     string `json:"expires"`
+		User        struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return authExchangeResult{}, fmt.Errorf("解析会话响应失败: %v", err)
+	}
+	if strings.TrimSpace(out.AccessToken) == "" {
+		return authExchangeResult{}, fmt.Errorf("ST 无效或已过期（会话响应中没有 accessToken）")
+	}
+	res := authExchangeResult{AccessToken: strings.TrimSpace(out.AccessToken), Email: out.User.Email}
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionTokenCookie && c.Value != "" && c.Value != st {
+			res.SessionToken = c.Value
+		}
+	}
+	if claims, ok := parseJWTClaims(res.AccessToken); ok {
+		res.ExpiresAt = claims.Exp
+		if res.Email == "" {
+			res.Email = claims.Email
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, out.Expires); res.ExpiresAt == 0 && err == nil {
+		res.ExpiresAt = t.Unix()
+	}
+	return res, nil
+}
+
+// exchangeRefreshToken 以 refresh_token 授权方式换取 AT；clientID 为空时使用配置的默认值
+func (a *App) exchangeRefreshToken(rt, clientID string, tokenID int64) (authExchangeResult, error) {
+	rt = strings.TrimSpace(rt)
+	if rt == "" {
+		return authExchangeResult{}, fmt.Errorf("Refresh Token 不能为空")
+	}
+	_, tokenURL, defaultClientID := a.authEndpoints()
+	if clientID = strings.TrimSpace(clientID); clientID == "" {
+		clientID = defaultClientID
+	}
+	payload, _ := json.Marshal(map[string]string{
+		"client_id":     clientID,
+		"grant_type":    "refresh_token",
+		"redirect_uri":  authRedirectURI,
+		"refresh_token": rt,
+	})
+	req, err := http.NewRequest(http.MethodPost, tokenURL, bytes.NewReader(payload))
+	if err != nil {
+		return authExchangeResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.httpClientForToken(tokenID, authRequestTimeout).Do(req)
+	if err != nil {
+		return authExchangeResult{}, fmt.Errorf("请求 token 接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return authExchangeResult{}, err
+	}
+	var out struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &out)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(out.AccessToken) == "" {
+		msg := strings.TrimSpace(out.ErrorDescription)
+		if msg == "" {
+			msg = out.Error
+		}
+		if msg == "" {
+			msg = authErrorBody(body)
+		}
+		return authExchangeResult{}, fmt.Errorf("RT 转换失败, HTTP %d: %s", resp.StatusCode, msg)
+	}
+	res := authExchangeResult{AccessToken: strings.TrimSpace(out.AccessToken)}
+	if newRT := strings.TrimSpace(out.RefreshToken); newRT != rt {
+		res.RefreshToken = newRT
+	}
+	if claims, ok := parseJWTClaims(res.AccessToken); ok {
+		res.ExpiresAt, res.Email = claims.Exp, claims.Email
+	}
+	if res.ExpiresAt == 0 && out.ExpiresIn > 0 {
+		res.ExpiresAt = time.Now().Unix() + out.ExpiresIn
+	}
+	return res, nil
+}
+
+// saveExchangedToken 把换到的 AT（及轮换后的 RT/ST）写回账号；usedClientID 在账号未保存 client_id 时一并记录
+func (a *App) saveExchangedToken(id int64, res authExchangeResult, usedClientID string) error {
+	encToken, encSt, encRt, err := a.sealTokenFields(res.AccessToken, res.SessionToken, res.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("加密 token 失败: %v", err)
+	}
+	hash := a.tokenHash(res.AccessToken)
+	return a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.Token, t.TokenHash = encToken, hash
+		if res.SessionToken != "" {
+			t.St = encSt
+		}
+		if res.RefreshToken != "" {
+			t.Rt = encRt
+		}
+		if t.ClientID == "" && usedClientID != "" {
+			t.ClientID = usedClientID
+		}
+		t.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// errNoRefreshCredential 账号没有保存 ST 或 RT
+var errNoRefreshCredential = errors.New("该账号没有保存 ST 或 RT，无法刷新 AT")
+
+// refreshTokenRecord 用账号保存的 RT（优先）或 ST 换取新的 AT 并写回，返回结果与使用的方式（rt/st）；
+// 没有保存 ST/RT 时返回 errNoRefreshCredential
+func (a *App) refreshTokenRecord(id int64) (authExchangeResult, string, error) {
+	rec, err := a.store.GetToken(id)
+	if err != nil {
+		return authExchangeResult{}, "", fmt.Errorf("Token 不存在")
+	}
+	rt, st := a.openSecret(rec.Rt), a.openSecret(rec.St)
+	var (
+		res    authExchangeResult
+		method string
+	)
+	switch {
+	case strings.TrimSpace(rt) != "":
+		method = "rt"
+		res, err = a.exchangeRefreshToken(rt, rec.ClientID, id)
+		// RT 失效但还有 ST 时再试一次 ST
+		if rtErr := err; err != nil && strings.TrimSpace(st) != "" {
+			method = "st"
+			if res, err = a.exchangeSessionToken(st, id); err != nil {
+				err = fmt.Errorf("%v；%v", rtErr, err)
+			}
+		}
+	case strings.TrimSpace(st) != "":
+		method = "st"
+		res, err = a.exchangeSessionToken(st, id)
+	default:
+		return authExchangeResult{}, "", errNoRefreshCredential
+	}
+	if err != nil {
+		return authExchangeResult{}, method, err
+	}
+	clientID := ""
+	if method == "rt" {
+		_, _, clientID = a.authEndpoints()
+	}
+	if err := a.saveExchangedToken(id, res, clientID); err != nil {
+		return authExchangeResult{}, method, err
+	}
+	a.logInfo(fmt.Sprintf("[Token] 账号 %d 已通过 %s 刷新 AT", id, strings.ToUpper(method)))
+	return res, method, nil
+}
+
+// findTokenIDBySecret 按明文 ST 或 RT 查找账号（field 为 "st" 或 "rt"），未找到时返回 0
+func (a *App) findTokenIDBySecret(field, value string) int64 {
+	value = strings.TrimSpace(value)
+	list, err := a.store.ListTokens()
+	if err != nil || value == "" {
+		return 0
+	}
+	for _, t := range list {
+		stored := t.St
+		if field == "rt" {
+			stored = t.Rt
+		}
+		if stored != "" && strings.TrimSpace(a.openSecret(stored)) == value {
+			return t.ID
+		}
+	}
+	return 0
+}
+
+// exchangeResultView 转换接口的返回内容
+func exchangeResultView(res authExchangeResult, tokenID int64, saved bool) map[string]interface{} {
+	out := map[string]interface{}{
+		"success":      true,
+		"access_token": res.AccessToken,
+		"email":        res.Email,
+		"expires_at":   res.ExpiresAt,
+		"token_id":     tokenID,
+		"saved":        saved,
+	}
+	if res.RefreshToken != "" {
+		out["refresh_token"] = res.RefreshToken
+	}
+	if res.SessionToken != "" {
+		out["session_token"] = res.SessionToken
+	}
+	return out
+}
+
+// localTokenConvert POST /api/tokens/st2at {"st", "token_id"} 与 /api/tokens/rt2at {"rt", "client_id", "token_id"}；
+// 未指定 token_id 时按 ST/RT 查找已保存的账号，找到则写回
+func (a *App) localTokenConvert(kind string, body string) (string, error) {
+	var input struct {
+		St       string `json:"st"`
+		Rt       string `json:"rt"`
+		ClientID string `json:"client_id"`
+		TokenID  int64  `json:"token_id"`
+	}
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		return jsonFail("请求体解析失败")
+	}
+	secret := input.St
+	if kind == "rt" {
+		secret = input.Rt
+	}
+	tokenID := input.TokenID
+	if tokenID <= 0 {
+		tokenID = a.findTokenIDBySecret(kind, secret)
+	} else if _, err := a.store.GetToken(tokenID); err != nil {
+		return jsonFail("Token 不存在")
+	}
+
+	var (
+		res      authExchangeResult
+		err      error
+		clientID string
+	)
+	if kind == "rt" {
+		clientID = strings.TrimSpace(input.ClientID)
+		if clientID == "" {
+			_, _, clientID = a.authEndpoints()
+		}
+		res, err = a.exchangeRefreshToken(secret, clientID, tokenID)
+	} else {
+		res, err = a.exchangeSessionToken(secret, tokenID)
+	}
+	if err != nil {
+		if tokenID > 0 {
+			a.recordFailureEvent(tokenID, "", failureStageAuth, redactSecrets(err.Error()))
+		}
+		return jsonFail(err.Error())
+	}
+	saved := false
+	if tokenID > 0 {
+		// 请求中的 ST/RT 与账号保存的不同时一并写回，保证之后刷新使用的是有效凭证
+		toSave := res
+		if kind == "rt" && toSave.RefreshToken == "" {
+			toSave.RefreshToken = strings.TrimSpace(secret)
+		}
+		if kind == "st" && toSave.SessionToken == "" {
+			toSave.SessionToken = strings.TrimSpace(secret)
+		}
+		if err := a.saveExchangedToken(tokenID, toSave, clientID); err != nil {
+			return jsonFail("写回账号失败: " + err.Error())
+		}
+		saved = true
+		a.logInfo(fmt.Sprintf("[Token] 账号 %d 已通过 %s 转换 AT", tokenID, strings.ToUpper(kind)))
+	}
+	return jsonMarshal(exchangeResultView(res, tokenID, saved))
+}
+
+// localTokenRefreshAT POST /api/tokens/:id/refresh-at：用账号保存的 RT 或 ST 换取新的 AT
+func (a *App) localTokenRefreshAT(id int64) (string, error) {
+	res, method, err := a.refreshTokenRecord(id)
+	if err != nil {
+		a.recordFailureEvent(id, "", failureStageAuth, redactSecrets(err.Error()))
+		return jsonFail(err.Error())
+	}
+	out := exchangeResultView(res, id, true)
+	out["method"] = method
+	return jsonMarshal(out)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJWT 生成只带 payload 的 JWT（签名部分为占位），用于测试
+func testJWT(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// newAuthTestApp 使用临时 JSON 存储的 App，认证接口指向 srv
+func newAuthTestApp(t *testing.T, srv *httptest.Server) *App {
+	t.Helper()
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultAppConfig()
+	cfg.AuthSessionURL = srv.URL + "/api/auth/session"
+	cfg.AuthTokenURL = srv.URL + "/oauth/token"
+	raw, _ := json.Marshal(cfg)
+	if err := store.SetSetting(appConfigSettingKey, string(raw)); err != nil {
+		t.Fatal(err)
+	}
+	return &App{store: store}
+}
+
+func TestExchangeSessionToken(t *testing.T) {
+	at := testJWT(map[string]interface{}{"email": "jwt@example.com", "exp": 2000000000})
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request)
+		want    authExchangeResult
+		wantErr string
+	}{
+		{
+			name: "success with rotated session cookie",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.SetCookie(w, &http.Cookie{Name: sessionTokenCookie, Value: "st-2"})
+				w.Write([]byte(`{"accessToken": "` + at + `", "user": {"email": "user@example.com"}}`))
+			},
+			want: authExchangeResult{AccessToken: at, SessionToken: "st-2", ExpiresAt: 2000000000, Email: "user@example.com"},
+		},
+		{
+			name: "same session cookie is not a rotation",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.SetCookie(w, &http.Cookie{Name: sessionTokenCookie, Value: "st-1"})
+				w.Write([]byte(`{"accessToken": "` + at + `"}`))
+			},
+			want: authExchangeResult{AccessToken: at, ExpiresAt: 2000000000, Email: "jwt@example.com"},
+		},
+		{
+			name: "opaque access token uses expires",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"accessToken": " opaque-at ", "expires": "2030-01-02T03:04:05Z"}`))
+			},
+			want: authExchangeResult{AccessToken: "opaque-at", ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).Unix()},
+		},
+		{
+			name: "error status includes redacted body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "session expired"}`))
+			},
+			wantErr: "HTTP 401: {\"error\": \"session expired\"}",
+		},
+		{
+			name: "missing access token",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{}`))
+			},
+			wantErr: "没有 accessToken",
+		},
+		{
+			name: "invalid json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<html>`))
+			},
+			wantErr: "解析会话响应失败",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/api/auth/session" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if c, err := r.Cookie(sessionTokenCookie); err != nil || c.Value != "st-1" {
+					t.Errorf("session cookie = %v, %v; want st-1", c, err)
+				}
+				tt.handler(w, r)
+			}))
+			defer srv.Close()
+			a := newAuthTestApp(t, srv)
+			got, err := a.exchangeSessionToken(" st-1 ", 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("exchangeSessionToken() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchangeSessionToken() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("exchangeSessionToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeSessionTokenEmpty(t *testing.T) {
+	a := &App{}
+	if _, err := a.exchangeSessionToken("  ", 0); err == nil {
+		t.Fatal("exchangeSessionToken() with empty ST should fail")
+	}
+}
+
+func TestExchangeRefreshToken(t *testing.T) {
+	at := testJWT(map[string]interface{}{"email": "jwt@example.com", "exp": 2000000000})
+	tests := []struct {
+		name         string
+		clientID     string
+		wantClientID string
+		status       int
+		body         string
+		want         authExchangeResult
+		wantErr      string
+	}{
+		{
+			name:         "success with rotated refresh token and default client id",
+			wantClientID: defaultAuthClientID,
+			status:       http.StatusOK,
+			body:         `{"access_token": "` + at + `", "refresh_token": "rt-2", "expires_in": 3600}`,
+			want:         authExchangeResult{AccessToken: at, RefreshToken: "rt-2", ExpiresAt: 2000000000, Email: "jwt@example.com"},
+		},
+		{
+			name:         "same refresh token is not a rotation",
+			clientID:     " custom-client ",
+			wantClientID: "custom-client",
+			status:       http.StatusOK,
+			body:         `{"access_token": "` + at + `", "refresh_token": "rt-1"}`,
+			want:         authExchangeResult{AccessToken: at, ExpiresAt: 2000000000, Email: "jwt@example.com"},
+		},
+		{
+			name:         "error description preferred",
+			wantClientID: defaultAuthClientID,
+			status:       http.StatusBadRequest,
+			body:         `{"error": "invalid_grant", "error_description": "Refresh token has expired"}`,
+			wantErr:      "HTTP 400: Refresh token has expired",
+		},
+		{
+			name:         "error code without description",
+			wantClientID: defaultAuthClientID,
+			status:       http.StatusUnauthorized,
+			body:         `{"error": "invalid_grant"}`,
+			wantErr:      "HTTP 401: invalid_grant",
+		},
+		{
+			name:         "plain text error body",
+			wantClientID: defaultAuthClientID,
+			status:       http.StatusBadGateway,
+			body:         "upstream unavailable",
+			wantErr:      "HTTP 502: upstream unavailable",
+		},
+		{
+			name:         "ok without access token",
+			wantClientID: defaultAuthClientID,
+			status:       http.StatusOK,
+			body:         `{"refresh_token": "rt-2"}`,
+			wantErr:      "HTTP 200",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/oauth/token" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				var req map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("decode request: %v", err)
+				}
+				if req["grant_type"] != "refresh_token" || req["refresh_token"] != "rt-1" || req["client_id"] != tt.wantClientID {
+					t.Errorf("unexpected request body %v", req)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			a := newAuthTestApp(t, srv)
+			got, err := a.exchangeRefreshToken("rt-1", tt.clientID, 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("exchangeRefreshToken() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchangeRefreshToken() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("exchangeRefreshToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRefreshTokenExpiresIn(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "opaque-at", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+	a := newAuthTestApp(t, srv)
+	before := time.Now().Unix()
+	got, err := a.exchangeRefreshToken("rt-1", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ExpiresAt < before+3600 || got.ExpiresAt > time.Now().Unix()+3600 {
+		t.Errorf("ExpiresAt = %d, want about now+3600", got.ExpiresAt)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 账号冷却：把上游返回的 access_resets_in_seconds / rate_limit_reached 换算成绝对恢复时间 tokens.cooldown_until（unix 秒），
+// 冷却中的账号不参与调度；到期后由后台任务重新测试账号状态，恢复后自动回到可用池。
+
+const (
+	// cooldownCheckInterval 检查冷却到期账号的间隔
+	cooldownCheckInterval = time.Minute
+	// cooldownRetryDelay 冷却到期后重新测试失败时，再次测试前的等待时间
+	cooldownRetryDelay = 10 * time.Minute
+)
+
+// rateLimitBalance 上游 rate_limit_and_credit_balance 字段
+type rateLimitBalance struct {
+	EstimatedNumVideosRemaining int  `json:"estimated_num_videos_remaining"`
+	AccessResetsInSeconds       int  `json:"access_resets_in_seconds"`
+	CreditRemaining             int  `json:"credit_remaining"`
+	RateLimitReached            bool `json:"rate_limit_reached"`
+}
+
+// applyTokenCooldown 根据剩余次数与限流状态更新账号的 cooldown_until；未耗尽时清除冷却
+func (a *App) applyTokenCooldown(tokenId int64, rate rateLimitBalance) {
+	if a.store == nil || tokenId <= 0 {
+		return
+	}
+	until := cooldownUntil(rate, time.Now())
+	if err := a.setTokenCooldown(tokenId, until); err != nil {
+		a.logError(fmt.Sprintf("更新 token %d 冷却时间失败: %v", tokenId, err))
+		return
+	}
+	if until > 0 {
+		a.logInfo(fmt.Sprintf("[Cooldown] token %d 进入冷却，预计 %s 恢复", tokenId, time.Unix(until, 0).Format("2006-01-02 15:04:05")))
+	}
+}
+
+// cooldownUntil 计算冷却结束时间；额度耗尽或被限流但上游没给恢复时间时，按 cooldownRetryDelay 定时重新测试，
+// 否则账号既不会被调度也不会被后台任务重新测试。未耗尽时返回 0
+func cooldownUntil(rate rateLimitBalance, now time.Time) int64 {
+	if !rate.RateLimitReached && rate.EstimatedNumVideosRemaining > 0 {
+		return 0
+	}
+	if rate.AccessResetsInSeconds > 0 {
+		return now.Add(time.Duration(rate.AccessResetsInSeconds) * time.Second).Unix()
+	}
+	return now.Add(cooldownRetryDelay).Unix()
+}
+
+// applyTokenCooldownFromStatus 从完整的账号状态 JSON 中提取 rate_limit_and_credit_balance 并更新冷却时间
+func (a *App) applyTokenCooldownFromStatus(tokenId int64, statusJSON string) {
+	if rate, ok := statusRateLimit(statusJSON); ok {
+		a.applyTokenCooldown(tokenId, rate)
+	}
+}
+
+// statusRateLimit 读取 status_json 中的 rate_limit_and_credit_balance，没有该字段时返回 false
+func statusRateLimit(statusJSON string) (rateLimitBalance, bool) {
+	var status struct {
+		Rate *rateLimitBalance `json:"rate_limit_and_credit_balance"`
+	}
+	if json.Unmarshal([]byte(statusJSON), &status) != nil || status.Rate == nil {
+		return rateLimitBalance{}, false
+	}
+	return *status.Rate, true
+}
+
+func (a *App) setTokenCooldown(tokenId int64, until int64) error {
+	return a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+		t.CooldownUntil = until
+		return nil
+	})
+}
+
+// runCooldownWatcher 定期重新测试冷却到期的账号，恢复后唤醒排队中的任务
+func (a *App) runCooldownWatcher() {
+	ticker := time.NewTicker(cooldownCheckInterval)
+	defer ticker.Stop()
+	for {
+		a.recheckExpiredCooldowns()
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recheckExpiredCooldowns 对冷却已到期的账号调用 localTokenTest 刷新状态
+func (a *App) recheckExpiredCooldowns() {
+	// 恢复备份替换存储时持写锁，本轮检查期间不会被替换（见 backup.go）
+	a.dataMu.RLock()
+	defer a.dataMu.RUnlock()
+	if a.store == nil {
+		return
+	}
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		a.logError(fmt.Sprintf("[Cooldown] 查询冷却到期账号失败: %v", err))
+		return
+	}
+	now := time.Now().Unix()
+	var ids []int64
+	for _, t := range tokens {
+		if t.IsActive && t.CooldownUntil > 0 && t.CooldownUntil <= now {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	for _, id := range ids {
+		a.logInfo(fmt.Sprintf("[Cooldown] token %d 冷却到期，重新测试", id))
+		out, _ := a.localTokenTest(id)
+		var res struct {
+			Success bool   `json:"success"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal([]byte(out), &res)
+		if !res.Success {
+			// 测试失败时稍后再试，避免每分钟重复请求
+			a.logWarning(fmt.Sprintf("[Cooldown] token %d 重新测试失败: %s", id, res.Message))
+			_ = a.setTokenCooldown(id, time.Now().Add(cooldownRetryDelay).Unix())
+			continue
+		}
+		// 测试成功：applyTokenCooldownFromStatus 已按最新状态重新计算；状态中无 rate 信息时清除过期冷却
+		_ = a.store.UpdateToken(id, func(t *tokenRecord) error {
+			if t.CooldownUntil > 0 && t.CooldownUntil <= time.Now().Unix() {
+				t.CooldownUntil = 0
+			}
+			return nil
+		})
+	}
+	if len(ids) > 0 && a.scheduler != nil {
+		a.scheduler.notify()
+	}
+}
+
+// cooldownValue 将 cooldown_until 列转换为前端展示用的 unix 秒（未冷却时为 nil）
+func cooldownValue(until int64) interface{} {
+	if until <= time.Now().Unix() {
+		return nil
+	}
+	return until
+}
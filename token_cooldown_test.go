@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldownUntil(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		rate rateLimitBalance
+		want int64
+	}{
+		{name: "quota left", rate: rateLimitBalance{EstimatedNumVideosRemaining: 3, AccessResetsInSeconds: 600}, want: 0},
+		{name: "exhausted with reset", rate: rateLimitBalance{AccessResetsInSeconds: 600}, want: now.Unix() + 600},
+		{name: "rate limited with quota left", rate: rateLimitBalance{EstimatedNumVideosRemaining: 3, RateLimitReached: true, AccessResetsInSeconds: 60}, want: now.Unix() + 60},
+		{name: "exhausted without reset retests later", rate: rateLimitBalance{}, want: now.Add(cooldownRetryDelay).Unix()},
+		{name: "rate limited without reset retests later", rate: rateLimitBalance{EstimatedNumVideosRemaining: 3, RateLimitReached: true}, want: now.Add(cooldownRetryDelay).Unix()},
+	}
+	for _, tt := range tests {
+		if got := cooldownUntil(tt.rate, now); got != tt.want {
+			t.Errorf("%s: cooldownUntil() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 账号去重：同一个 access token 或同一邮箱（status_json / JWT 中的 email）只允许存在一个账号。
+// 新增、编辑与导入时检查重复；POST /api/tokens/dedupe 合并库中已有的重复账号，
+// 保留 id 最小的一条，使用最近一次更新的 token 与状态，开关与并发取各条的并集。
+
+// tokenIdentity 账号用于判重的明文 token 与小写邮箱
+type tokenIdentity struct {
+	rec   tokenRecord
+	token string
+	email string
+}
+
+func (a *App) tokenIdentities() ([]tokenIdentity, error) {
+	all, err := a.store.ListTokens()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]tokenIdentity, 0, len(all))
+	for _, t := range all {
+		out = append(out, tokenIdentity{
+			rec:   t,
+			token: a.openSecret(t.Token),
+			email: strings.ToLower(a.tokenRecordEmail(t)),
+		})
+	}
+	return out, nil
+}
+
+// findDuplicateToken 查找与 token 或 email 重复的其他账号（exclude 为正在编辑的账号 id）；
+// 返回重复账号 id 与原因说明，无重复时 id 为 0
+func (a *App) findDuplicateToken(token, email string, exclude int64) (int64, string, error) {
+	token = strings.TrimSpace(token)
+	email = strings.ToLower(strings.TrimSpace(email))
+	ids, err := a.tokenIdentities()
+	if err != nil {
+		return 0, "", err
+	}
+	for _, it := range ids {
+		if it.rec.ID == exclude {
+			continue
+		}
+		if token != "" && it.token == token {
+			return it.rec.ID, fmt.Sprintf("该 Token 已存在（ID %d）", it.rec.ID), nil
+		}
+		if email != "" && it.email == email {
+			return it.rec.ID, fmt.Sprintf("邮箱 %s 的账号已存在（ID %d），请编辑原账号或使用导入的合并模式", email, it.rec.ID), nil
+		}
+	}
+	return 0, "", nil
+}
+
+// statusEmail 读取状态响应中的 email
+func statusEmail(statusJSON string) string {
+	var status struct {
+		Email string `json:"email"`
+	}
+	_ = json.Unmarshal([]byte(statusJSON), &status)
+	return status.Email
+}
+
+// tokenDuplicateGroup 一组重复账号的合并结果
+type tokenDuplicateGroup struct {
+	KeepID     int64   `json:"keep_id"`
+	RemovedIDs []int64 `json:"removed_ids"`
+	Email      string  `json:"email,omitempty"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	Message    string  `json:"message,omitempty"`
+}
+
+// duplicateTokenGroups 按 token 或邮箱把账号连成组（A 与 B 同 token、B 与 C 同邮箱时三者为一组），只返回多于一条的组
+func duplicateTokenGroups(ids []tokenIdentity) [][]tokenIdentity {
+	parent := make([]int, len(ids))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	firstByKey := map[string]int{}
+	link := func(key string, i int) {
+		if j, ok := firstByKey[key]; ok {
+			parent[find(i)] = find(j)
+		} else {
+			firstByKey[key] = i
+		}
+	}
+	for i, it := range ids {
+		if it.token != "" {
+			link("t:"+it.token, i)
+		}
+		if it.email != "" {
+			link("e:"+it.email, i)
+		}
+	}
+	byRoot := map[int][]tokenIdentity{}
+	var roots []int
+	for i := range ids {
+		r := find(i)
+		if _, ok := byRoot[r]; !ok {
+			roots = append(roots, r)
+		}
+		byRoot[r] = append(byRoot[r], ids[i])
+	}
+	var groups [][]tokenIdentity
+	for _, r := range roots {
+		if len(byRoot[r]) > 1 {
+			groups = append(groups, byRoot[r])
+		}
+	}
+	return groups
+}
+
+// widerConcurrency 取更宽松的并发上限（<=0 表示不限制）
+func widerConcurrency(a, b int) int {
+	if a <= 0 || b <= 0 {
+		return -1
+	}
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// mergeDuplicateTokens 将一组重复账号合并到 id 最小的一条：token 与状态取最近更新的一条，
+// st/rt/client_id/代理优先取较新的非空值，备注与分组合并去重，启用开关取“任一开启”，并发取更宽松的上限
+func mergeDuplicateTokens(group []tokenIdentity) tokenRecord {
+	members := make([]tokenRecord, len(group))
+	for i, it := range group {
+		members[i] = it.rec
+	}
+	// 最近更新的排在前面
+	sort.SliceStable(members, func(i, j int) bool {
+		if !members[i].UpdatedAt.Equal(members[j].UpdatedAt) {
+			return members[i].UpdatedAt.After(members[j].UpdatedAt)
+		}
+		return members[i].ID > members[j].ID
+	})
+	newest := members[0]
+	merged := newest
+	var remarks []string
+	seenRemark := map[string]bool{}
+	var groups []string
+	statusTaken := false
+	for i, m := range members {
+		groups = append(groups, m.Groups...)
+		if m.ID < merged.ID {
+			merged.ID = m.ID
+		}
+		if m.CreatedAt.Before(merged.CreatedAt) {
+			merged.CreatedAt = m.CreatedAt
+		}
+		if !statusTaken && m.StatusJSON != "" {
+			merged.StatusJSON, merged.PlanType = m.StatusJSON, m.PlanType
+			statusTaken = true
+		}
+		if merged.PlanType == "" {
+			merged.PlanType = m.PlanType
+		}
+		if merged.St == "" {
+			merged.St = m.St
+		}
+		if merged.Rt == "" {
+			merged.Rt = m.Rt
+		}
+		if merged.ClientID == "" {
+			merged.ClientID = m.ClientID
+		}
+		if merged.ProxyURL == "" {
+			merged.ProxyURL = m.ProxyURL
+		}
+		if r := strings.TrimSpace(m.Remark); r != "" && !seenRemark[r] {
+			seenRemark[r] = true
+			remarks = append(remarks, r)
+		}
+		if m.LastUsedAt > merged.LastUsedAt {
+			merged.LastUsedAt = m.LastUsedAt
+		}
+		if i > 0 {
+			merged.IsActive = merged.IsActive || m.IsActive
+			merged.ImageEnabled = merged.ImageEnabled || m.ImageEnabled
+			merged.VideoEnabled = merged.VideoEnabled || m.VideoEnabled
+			merged.ImageConcurrency = widerConcurrency(merged.ImageConcurrency, m.ImageConcurrency)
+			merged.VideoConcurrency = widerConcurrency(merged.VideoConcurrency, m.VideoConcurrency)
+		}
+	}
+	merged.Remark = strings.Join(remarks, "；")
+	merged.Groups = normalizeTokenGroups(groups)
+	return merged
+}
+
+// localTokensDedupe POST /api/tokens/dedupe，请求体 {"dry_run": true} 时只返回重复分组不做修改。
+// 被合并掉的账号若还有进行中的任务则整组跳过；已有任务、请求日志与额度快照改挂到保留的账号上
+func (a *App) localTokensDedupe(body string) (string, error) {
+	var input struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if strings.TrimSpace(body) != "" {
+		if err := json.Unmarshal([]byte(body), &input); err != nil {
+			return jsonFail("请求体解析失败")
+		}
+	}
+	ids, err := a.tokenIdentities()
+	if err != nil {
+		return jsonFail("读取账号失败: " + err.Error())
+	}
+	var inflight map[int64]int
+	if a.scheduler != nil {
+		if inflight, err = a.scheduler.inflightCounts(); err != nil {
+			return jsonFail(err.Error())
+		}
+	}
+
+	groups := []tokenDuplicateGroup{}
+	removed := 0
+	now := time.Now()
+	for _, group := range duplicateTokenGroups(ids) {
+		merged := mergeDuplicateTokens(group)
+		g := tokenDuplicateGroup{KeepID: merged.ID, RemovedIDs: []int64{}, Email: statusEmail(merged.StatusJSON)}
+		if g.Email == "" {
+			for _, it := range group {
+				if it.email != "" {
+					g.Email = it.email
+					break
+				}
+			}
+		}
+		var busy []int64
+		for _, it := range group {
+			if it.rec.ID == merged.ID {
+				continue
+			}
+			g.RemovedIDs = append(g.RemovedIDs, it.rec.ID)
+			if inflight[it.rec.ID] > 0 {
+				busy = append(busy, it.rec.ID)
+			}
+		}
+		sort.Slice(g.RemovedIDs, func(i, j int) bool { return g.RemovedIDs[i] < g.RemovedIDs[j] })
+		if len(busy) == 0 && !input.DryRun {
+			if busy, err = a.applyTokenMerge(merged, g.RemovedIDs, now); err != nil {
+				g.Skipped, g.Message = true, "合并失败，账号未做修改: "+err.Error()
+				groups = append(groups, g)
+				continue
+			}
+		}
+		switch {
+		case len(busy) > 0:
+			g.Skipped, g.Message = true, busyTokensMessage(busy)
+		case input.DryRun:
+			g.Message = fmt.Sprintf("将合并到账号 %d", g.KeepID)
+		default:
+			removed += len(g.RemovedIDs)
+			g.Message = fmt.Sprintf("已合并到账号 %d", g.KeepID)
+		}
+		groups = append(groups, g)
+	}
+	if removed > 0 && a.scheduler != nil {
+		a.scheduler.notify()
+	}
+
+	msg := fmt.Sprintf("发现 %d 组重复账号", len(groups))
+	if !input.DryRun {
+		msg += fmt.Sprintf("，已合并删除 %d 个", removed)
+	}
+	return jsonMarshal(map[string]interface{}{
+		"success": true,
+		"dry_run": input.DryRun,
+		"groups":  groups,
+		"removed": removed,
+		"message": msg,
+	})
+}
+
+// busyTokensMessage 有进行中任务而跳过的提示
+func busyTokensMessage(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%d", id)
+	}
+	return "账号 " + strings.Join(parts, "、") + " 有进行中的任务，已跳过"
+}
+
+// applyTokenMerge 在调度锁内再次确认被合并的账号没有进行中的任务，然后由 Store.MergeTokens 在同一事务内
+// 写入合并后的账号、把其余账号的任务与历史记录改挂到保留账号并删除它们；有任务的账号通过 busy 返回
+func (a *App) applyTokenMerge(merged tokenRecord, removedIDs []int64, now time.Time) (busy []int64, err error) {
+	merged.UpdatedAt = now
+	return a.withIdleTokens(removedIDs, func() error {
+		return a.store.MergeTokens(merged, removedIDs)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateTokenGroups(t *testing.T) {
+	id := func(n int64, token, email string) tokenIdentity {
+		return tokenIdentity{rec: tokenRecord{ID: n}, token: token, email: email}
+	}
+	tests := []struct {
+		name string
+		ids  []tokenIdentity
+		want [][]int64
+	}{
+		{name: "empty"},
+		{
+			name: "no duplicates",
+			ids:  []tokenIdentity{id(1, "t1", "a@x"), id(2, "t2", "b@x")},
+		},
+		{
+			name: "same token",
+			ids:  []tokenIdentity{id(1, "t1", ""), id(2, "t2", ""), id(3, "t1", "")},
+			want: [][]int64{{1, 3}},
+		},
+		{
+			name: "same email",
+			ids:  []tokenIdentity{id(1, "t1", "a@x"), id(2, "t2", "a@x")},
+			want: [][]int64{{1, 2}},
+		},
+		{
+			name: "linked through token then email",
+			ids:  []tokenIdentity{id(1, "t1", ""), id(2, "t1", "a@x"), id(3, "t3", "a@x"), id(4, "t4", "b@x")},
+			want: [][]int64{{1, 2, 3}},
+		},
+		{
+			name: "separate groups keep input order",
+			ids:  []tokenIdentity{id(1, "t1", "a@x"), id(2, "t2", "b@x"), id(3, "t3", "b@x"), id(4, "t4", "a@x")},
+			want: [][]int64{{1, 4}, {2, 3}},
+		},
+		{
+			name: "empty token and email never link",
+			ids:  []tokenIdentity{id(1, "", ""), id(2, "", "")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]int64
+			for _, g := range duplicateTokenGroups(tt.ids) {
+				var ids []int64
+				for _, it := range g {
+					ids = append(ids, it.rec.ID)
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("duplicateTokenGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
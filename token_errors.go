@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 账号连续失败计数：创建视频、pending 轮询、状态检查失败时 tokens.error_count +1 并记录原因，成功时清零；
+// 连续失败次数达到系统配置 error_ban_threshold（>0）时自动禁用该账号。每次失败同时写入 failure_events 供统计使用。
+
+// recordTokenFailure 记录一次失败（stage 见 failureStage*，taskID 可为空），达到阈值时禁用账号；返回是否因此被禁用
+func (a *App) recordTokenFailure(tokenId int64, stage string, taskID string, reason string) bool {
+	if a.store == nil || tokenId <= 0 {
+		return false
+	}
+	reason = truncateRunes(redactSecrets(strings.TrimSpace(reason)), 300)
+	a.recordFailureEvent(tokenId, taskID, stage, reason)
+	threshold := a.loadAppConfig().ErrorBanThreshold
+	var count int
+	disabled := false
+	err := a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+		t.ErrorCount++
+		t.ErrorMessage, t.UpdatedAt = reason, time.Now()
+		count = t.ErrorCount
+		if threshold > 0 && count >= threshold && t.IsActive {
+			t.IsActive = false
+			t.ErrorMessage = fmt.Sprintf("连续失败 %d 次，已自动禁用：%s", count, reason)
+			disabled = true
+		}
+		return nil
+	})
+	if err != nil {
+		a.logError(fmt.Sprintf("记录 token %d 失败次数失败: %v", tokenId, err))
+		return false
+	}
+	a.logWarning(fmt.Sprintf("[ErrorBan] token %d 连续失败 %d/%d: %s", tokenId, count, threshold, reason))
+	if disabled {
+		a.logWarning(fmt.Sprintf("[ErrorBan] token %d 连续失败 %d 次，已自动禁用：%s", tokenId, count, reason))
+	}
+	return disabled
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
+
+// recordTokenSuccess 请求成功后清零连续失败次数及对应的失败原因
+func (a *App) recordTokenSuccess(tokenId int64) {
+	if a.store == nil || tokenId <= 0 {
+		return
+	}
+	if t, err := a.store.GetToken(tokenId); err != nil || t.ErrorCount == 0 {
+		return
+	}
+	_ = a.store.UpdateToken(tokenId, func(t *tokenRecord) error {
+		t.ErrorCount, t.ErrorMessage = 0, ""
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecordTokenFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		threshold    int
+		failures     int
+		wantDisabled bool
+	}{
+		{"below threshold", 3, 2, false},
+		{"reaches threshold", 3, 3, true},
+		{"threshold of one", 1, 1, true},
+		{"zero disables auto ban", 0, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ids := newSchedulerTestApp(t, 1)
+			if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+				cfg.ErrorBanThreshold = tt.threshold
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			disabled := false
+			for i := 0; i < tt.failures; i++ {
+				// 只有跨过阈值的那一次返回 true
+				if d := a.recordTokenFailure(ids[0], failureStageCreate, "", "HTTP 500 Bearer abc.def.ghi"); d {
+					if disabled || i != tt.failures-1 {
+						t.Errorf("recordTokenFailure() #%d reported disabled again", i+1)
+					}
+					disabled = true
+				}
+			}
+			if disabled != tt.wantDisabled {
+				t.Errorf("recordTokenFailure() disabled = %v, want %v", disabled, tt.wantDisabled)
+			}
+			tok, err := a.store.GetToken(ids[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.ErrorCount != tt.failures || tok.IsActive == tt.wantDisabled {
+				t.Errorf("token = error_count %d, active %v; want %d, %v", tok.ErrorCount, tok.IsActive, tt.failures, !tt.wantDisabled)
+			}
+			if strings.Contains(tok.ErrorMessage, "abc.def.ghi") {
+				t.Errorf("error_message not redacted: %q", tok.ErrorMessage)
+			}
+			if tt.wantDisabled && !strings.Contains(tok.ErrorMessage, "已自动禁用") {
+				t.Errorf("error_message = %q, want auto-ban reason", tok.ErrorMessage)
+			}
+		})
+	}
+}
+
+func TestRecordTokenSuccessResetsCount(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 1)
+	a.recordTokenFailure(ids[0], failureStagePoll, "task", "timeout")
+	a.recordTokenFailure(ids[0], failureStagePoll, "task", "timeout")
+	a.recordTokenSuccess(ids[0])
+	tok, err := a.store.GetToken(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.ErrorCount != 0 || tok.ErrorMessage != "" || !tok.IsActive {
+		t.Errorf("token after success = error_count %d, %q, active %v", tok.ErrorCount, tok.ErrorMessage, tok.IsActive)
+	}
+	// 未关联账号的失败不记录
+	if a.recordTokenFailure(0, failureStageCreate, "", "x") {
+		t.Error("recordTokenFailure(0) reported disabled")
+	}
+}
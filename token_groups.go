@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 账号分组：一个账号可属于多个分组（SQLite 保存在 token_groups 表，JSON 存储保存在账号记录中）。
+// 列表可按分组筛选；生成视频时可指定分组，调度器只在该分组的账号中选择，多个项目共用一个 accounts.db 时互不占用账号。
+// 分组名不区分大小写，首尾空白会被去掉。
+
+// maxTokenGroupNameLen 分组名最大长度（字符数）
+const maxTokenGroupNameLen = 32
+
+// normalizeTokenGroups 去掉空白与重复（不区分大小写，保留先出现的写法）并按名称排序；为空时返回 nil
+func normalizeTokenGroups(groups []string) []string {
+	var out []string
+	for _, g := range groups {
+		g = strings.TrimSpace(g)
+		if g == "" || hasTokenGroup(out, g) {
+			continue
+		}
+		out = append(out, g)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// parseTokenGroups 解析以逗号、分号（含全角）分隔的分组名，用于导入文件与表单输入
+func parseTokenGroups(s string) []string {
+	return normalizeTokenGroups(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '；'
+	}))
+}
+
+// validateTokenGroups 规范化分组并检查名称长度
+func validateTokenGroups(groups []string) ([]string, error) {
+	groups = normalizeTokenGroups(groups)
+	for _, g := range groups {
+		if utf8.RuneCountInString(g) > maxTokenGroupNameLen {
+			return nil, fmt.Errorf("分组名不能超过 %d 个字符: %s", maxTokenGroupNameLen, g)
+		}
+		if strings.ContainsAny(g, ",，;；") {
+			return nil, fmt.Errorf("分组名不能包含逗号或分号: %s", g)
+		}
+	}
+	return groups, nil
+}
+
+// hasTokenGroup 判断 groups 中是否包含 name（不区分大小写）
+func hasTokenGroup(groups []string, name string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(g, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTokenGroup 去掉 groups 中的 name（不区分大小写）
+func removeTokenGroup(groups []string, name string) []string {
+	var out []string
+	for _, g := range groups {
+		if !strings.EqualFold(g, name) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// localTokenGroups GET /api/tokens/groups：所有分组及其账号数、已启用账号数
+func (a *App) localTokenGroups() (string, error) {
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		return jsonFail("查询账号失败: " + err.Error())
+	}
+	type groupStat struct {
+		Name   string `json:"name"`
+		Total  int    `json:"total"`
+		Active int    `json:"active"`
+	}
+	byKey := map[string]*groupStat{}
+	ungrouped := 0
+	for _, t := range tokens {
+		if len(t.Groups) == 0 {
+			ungrouped++
+		}
+		for _, g := range t.Groups {
+			key := strings.ToLower(g)
+			st, ok := byKey[key]
+			if !ok {
+				st = &groupStat{Name: g}
+				byKey[key] = st
+			}
+			st.Total++
+			if t.IsActive {
+				st.Active++
+			}
+		}
+	}
+	list := make([]groupStat, 0, len(byKey))
+	for _, st := range byKey {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+	return jsonMarshal(map[string]interface{}{"success": true, "groups": list, "ungrouped": ungrouped})
+}
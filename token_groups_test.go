@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTokenGroups(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{" ", ""}, nil},
+		{[]string{" b ", "a", "B", "A "}, []string{"a", "b"}},
+		{[]string{"项目一", "Team", "team"}, []string{"Team", "项目一"}},
+	}
+	for _, tt := range tests {
+		if got := normalizeTokenGroups(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeTokenGroups(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTokenGroups(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"a,b;c", []string{"a", "b", "c"}},
+		{"甲，乙；甲 , ", []string{"乙", "甲"}},
+	}
+	for _, tt := range tests {
+		if got := parseTokenGroups(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTokenGroups(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTokenGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		wantErr bool
+	}{
+		{"max length in runes", []string{strings.Repeat("组", maxTokenGroupNameLen)}, false},
+		{"too long", []string{strings.Repeat("a", maxTokenGroupNameLen+1)}, true},
+		{"blank ignored", []string{"  "}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateTokenGroups(tt.in); (err != nil) != tt.wantErr {
+				t.Errorf("validateTokenGroups() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoveTokenGroup(t *testing.T) {
+	if got := removeTokenGroup([]string{"a", "B", "c"}, "b"); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("removeTokenGroup() = %q", got)
+	}
+	if got := removeTokenGroup([]string{"a"}, "a"); got != nil {
+		t.Errorf("removeTokenGroup(last) = %q, want nil", got)
+	}
+}
+
+// setTokenGroups 设置账号的分组与启用状态
+func setTokenGroups(t *testing.T, a *App, id int64, active bool, groups ...string) {
+	t.Helper()
+	if err := a.store.UpdateToken(id, func(tok *tokenRecord) error {
+		tok.Groups, tok.IsActive = groups, active
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalTokenGroups(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 1, 1, 1, 1)
+	setTokenGroups(t, a, ids[0], true, "vip", "Team")
+	setTokenGroups(t, a, ids[1], false, "VIP")
+	setTokenGroups(t, a, ids[2], true, "team")
+	raw, err := a.localTokenGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Groups []struct {
+			Name   string `json:"name"`
+			Total  int    `json:"total"`
+			Active int    `json:"active"`
+		} `json:"groups"`
+		Ungrouped int `json:"ungrouped"`
+	}
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, g := range resp.Groups {
+		got = append(got, fmt.Sprintf("%s:%d/%d", strings.ToLower(g.Name), g.Total, g.Active))
+	}
+	if want := []string{"team:2/2", "vip:2/1"}; !reflect.DeepEqual(got, want) || resp.Ungrouped != 1 {
+		t.Errorf("localTokenGroups() = %v, ungrouped %d; want %v, 1", got, resp.Ungrouped, want)
+	}
+}
+
+func TestTryAcquireWithinGroup(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 0, 0, 0)
+	setTokenGroups(t, a, ids[1], true, "VIP")
+	setTokenGroups(t, a, ids[2], false, "vip")
+	for i := 0; i < 5; i++ {
+		c, err := a.scheduler.tryAcquire("", " vip ", false)
+		if err != nil || c.id != ids[1] {
+			t.Fatalf("tryAcquire(vip) = %d, %v; want only the active vip token %d", c.id, err, ids[1])
+		}
+	}
+	if _, err := a.scheduler.tryAcquire("", "other", false); err == nil || !strings.Contains(err.Error(), "分组「other」") {
+		t.Errorf("tryAcquire(other) error = %v, want no token in group", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// jwtClaims access token（JWT）中本地用到的声明；只解码 payload，不校验签名，
+// 用于导入合并时识别邮箱、展示过期时间等，不能作为鉴权依据。
+type jwtClaims struct {
+	Email    string
+	Exp      int64  // 过期时间（Unix 秒），0 表示未携带
+	IssuedAt int64  // 签发时间（Unix 秒），0 表示未携带
+	PlanType string // 账号类型提示，统一为 chatgpt_free/chatgpt_plus 等与订阅接口一致的形式
+}
+
+// parseJWTClaims 解码 JWT payload；不是 JWT 或 payload 无法解析时返回 false
+func parseJWTClaims(token string) (jwtClaims, bool) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return jwtClaims{}, false
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return jwtClaims{}, false
+	}
+	var c jwtClaims
+	if exp, ok := payload["exp"].(float64); ok {
+		c.Exp = int64(exp)
+	}
+	if iat, ok := payload["iat"].(float64); ok {
+		c.IssuedAt = int64(iat)
+	}
+	if auth, ok := payload["https://api.openai.com/auth"].(map[string]interface{}); ok {
+		if plan, _ := auth["chatgpt_plan_type"].(string); plan != "" {
+			plan = strings.ToLower(plan)
+			if !strings.HasPrefix(plan, "chatgpt_") {
+				plan = "chatgpt_" + plan
+			}
+			c.PlanType = plan
+		}
+	}
+	// 与前端 jwtUtils 的取值顺序一致
+	if s, ok := payload["email"].(string); ok && s != "" {
+		c.Email = s
+	} else if profile, ok := payload["https://api.openai.com/profile"].(map[string]interface{}); ok {
+		c.Email, _ = profile["email"].(string)
+	}
+	for _, key := range []string{"preferred_username", "unique_name", "upn"} {
+		if c.Email != "" {
+			break
+		}
+		c.Email, _ = payload[key].(string)
+	}
+	if sub, _ := payload["sub"].(string); c.Email == "" && strings.Contains(sub, "@") {
+		c.Email = sub
+	}
+	return c, true
+}
+
+// tokenRecordEmail 账号邮箱：优先 status_json 中的 email，其次 access token 的 JWT 声明
+func (a *App) tokenRecordEmail(t tokenRecord) string {
+	if email := statusEmail(t.StatusJSON); email != "" {
+		return email
+	}
+	c, _ := parseJWTClaims(a.openSecret(t.Token))
+	return c.Email
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseJWTClaims(t *testing.T) {
+	padded := "eyJhbGciOiJub25lIn0." + base64.URLEncoding.EncodeToString([]byte(`{"email":"pad@example.com"}`)) + ".sig"
+	tests := []struct {
+		name   string
+		token  string
+		want   jwtClaims
+		wantOK bool
+	}{
+		{name: "not a jwt", token: "sk-plain-token"},
+		{name: "too many parts", token: "a.b.c.d"},
+		{name: "bad base64", token: "a.!!!.c"},
+		{name: "payload not json", token: "a." + base64.RawURLEncoding.EncodeToString([]byte("hello")) + ".c"},
+		{
+			name:   "top-level email with exp and iat",
+			token:  testJWT(map[string]interface{}{"email": "a@example.com", "exp": 2000000000, "iat": 1900000000}),
+			want:   jwtClaims{Email: "a@example.com", Exp: 2000000000, IssuedAt: 1900000000},
+			wantOK: true,
+		},
+		{
+			name:   "padded payload and surrounding spaces",
+			token:  "  " + padded + "\n",
+			want:   jwtClaims{Email: "pad@example.com"},
+			wantOK: true,
+		},
+		{
+			name: "profile email",
+			token: testJWT(map[string]interface{}{
+				"https://api.openai.com/profile": map[string]interface{}{"email": "profile@example.com"},
+				"preferred_username":             "user@example.com",
+			}),
+			want:   jwtClaims{Email: "profile@example.com"},
+			wantOK: true,
+		},
+		{
+			name:   "preferred_username fallback",
+			token:  testJWT(map[string]interface{}{"preferred_username": "user@example.com", "upn": "upn@example.com"}),
+			want:   jwtClaims{Email: "user@example.com"},
+			wantOK: true,
+		},
+		{
+			name:   "upn fallback",
+			token:  testJWT(map[string]interface{}{"upn": "upn@example.com"}),
+			want:   jwtClaims{Email: "upn@example.com"},
+			wantOK: true,
+		},
+		{
+			name:   "sub with at sign",
+			token:  testJWT(map[string]interface{}{"sub": "sub@example.com"}),
+			want:   jwtClaims{Email: "sub@example.com"},
+			wantOK: true,
+		},
+		{
+			name:   "sub without at sign is not an email",
+			token:  testJWT(map[string]interface{}{"sub": "user-123"}),
+			wantOK: true,
+		},
+		{
+			name: "plan type gets chatgpt prefix",
+			token: testJWT(map[string]interface{}{
+				"https://api.openai.com/auth": map[string]interface{}{"chatgpt_plan_type": "Plus"},
+			}),
+			want:   jwtClaims{PlanType: "chatgpt_plus"},
+			wantOK: true,
+		},
+		{
+			name: "plan type already prefixed",
+			token: testJWT(map[string]interface{}{
+				"https://api.openai.com/auth": map[string]interface{}{"chatgpt_plan_type": "chatgpt_pro"},
+			}),
+			want:   jwtClaims{PlanType: "chatgpt_pro"},
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseJWTClaims(tt.token)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseJWTClaims() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 账号列表：GET /api/tokens。过期时间、邮箱与账号类型在本地解码 access token 的 JWT 声明得到（不请求网络），
+// 支持按这些字段筛选与排序后再分页。
+//
+// 查询参数：
+//   page, limit
+//   status   expired（已过期）| expiring（within_hours 小时内过期，默认 24）| valid（未过期）| unknown（无法解析过期时间）
+//   plan     账号类型，如 chatgpt_plus；free 匹配空值与 chatgpt_free
+//   group    所属分组（不区分大小写）
+//   q        邮箱或备注包含的关键字
+//   sort     id（默认）| email | expiry | plan | error_count
+//   order    asc（默认）| desc
+
+// tokenListRow 列表中的一行及用于筛选排序的字段
+type tokenListRow struct {
+	rec      tokenRecord
+	token    string
+	email    string
+	planType string
+	exp      int64
+	status   tokenListStatus
+}
+
+// tokenListStatus status_json 中列表用到的部分
+type tokenListStatus struct {
+	Email string `json:"email"`
+	Rate  struct {
+		AccessResetsInSeconds       int  `json:"access_resets_in_seconds"`
+		EstimatedNumVideosRemaining int  `json:"estimated_num_videos_remaining"`
+		EstimatedNumPurchasedRemain int  `json:"estimated_num_purchased_videos_remaining"`
+		CreditRemaining             int  `json:"credit_remaining"`
+		RateLimitReached            bool `json:"rate_limit_reached"`
+	} `json:"rate_limit_and_credit_balance"`
+}
+
+func (a *App) tokenListRow(t tokenRecord) tokenListRow {
+	row := tokenListRow{rec: t, token: a.openSecret(t.Token), planType: t.PlanType}
+	if t.StatusJSON != "" {
+		_ = json.Unmarshal([]byte(t.StatusJSON), &row.status)
+	}
+	claims, _ := parseJWTClaims(row.token)
+	row.exp = claims.Exp
+	row.email = row.status.Email
+	if row.email == "" {
+		row.email = claims.Email
+	}
+	if row.planType == "" {
+		row.planType = claims.PlanType
+	}
+	return row
+}
+
+// expired 已过期；无法解析过期时间时返回 false
+func (r tokenListRow) expired(now int64) bool {
+	return r.exp > 0 && r.exp <= now
+}
+
+// matches 判断该行是否满足筛选条件
+func (r tokenListRow) matches(q url.Values, now int64) bool {
+	switch q.Get("status") {
+	case "expired":
+		if !r.expired(now) {
+			return false
+		}
+	case "expiring":
+		hours, err := strconv.Atoi(q.Get("within_hours"))
+		if err != nil || hours <= 0 {
+			hours = 24
+		}
+		if r.exp == 0 || r.expired(now) || r.exp > now+int64(hours)*3600 {
+			return false
+		}
+	case "valid":
+		if r.exp == 0 || r.expired(now) {
+			return false
+		}
+	case "unknown":
+		if r.exp != 0 {
+			return false
+		}
+	}
+	if plan := strings.ToLower(strings.TrimSpace(q.Get("plan"))); plan != "" {
+		got := strings.ToLower(r.planType)
+		if plan == "free" || plan == "chatgpt_free" {
+			if got != "" && got != "chatgpt_free" {
+				return false
+			}
+		} else if got != plan && got != "chatgpt_"+plan {
+			return false
+		}
+	}
+	if group := strings.TrimSpace(q.Get("group")); group != "" && !hasTokenGroup(r.rec.Groups, group) {
+		return false
+	}
+	if kw := strings.ToLower(strings.TrimSpace(q.Get("q"))); kw != "" {
+		if !strings.Contains(strings.ToLower(r.email), kw) && !strings.Contains(strings.ToLower(r.rec.Remark), kw) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortTokenListRows 按 sort/order 排序；排序字段为空（无邮箱、过期时间未知）的行始终排在最后
+func sortTokenListRows(rows []tokenListRow, field, order string) {
+	desc := strings.EqualFold(order, "desc")
+	less := func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch field {
+		case "email":
+			if x, y := strings.ToLower(a.email), strings.ToLower(b.email); x != y {
+				return x < y
+			}
+		case "plan":
+			if x, y := a.planType, b.planType; x != y {
+				return x < y
+			}
+		case "error_count":
+			if a.rec.ErrorCount != b.rec.ErrorCount {
+				return a.rec.ErrorCount < b.rec.ErrorCount
+			}
+		case "expiry":
+			if a.exp != b.exp {
+				return a.exp < b.exp
+			}
+		}
+		return a.rec.ID < b.rec.ID
+	}
+	missing := func(r tokenListRow) bool {
+		switch field {
+		case "email":
+			return r.email == ""
+		case "expiry":
+			return r.exp == 0
+		}
+		return false
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if mi, mj := missing(rows[i]), missing(rows[j]); mi != mj {
+			return mj
+		}
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+func (a *App) localTokensList(rawPath string) (string, error) {
+	u, _ := url.Parse(rawPath)
+	q := u.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	all, err := a.store.ListTokens()
+	if err != nil {
+		return jsonFail("查询列表失败: " + err.Error())
+	}
+	now := time.Now().Unix()
+	rows := make([]tokenListRow, 0, len(all))
+	expiredCount := 0
+	for _, t := range all {
+		row := a.tokenListRow(t)
+		if row.expired(now) {
+			expiredCount++
+		}
+		if row.matches(q, now) {
+			rows = append(rows, row)
+		}
+	}
+	sortTokenListRows(rows, q.Get("sort"), q.Get("order"))
+
+	total := len(rows)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		rows = rows[offset : offset+limit]
+	} else {
+		rows = rows[offset:]
+	}
+
+	checks := a.latestHealthChecks()
+	list := []map[string]interface{}{}
+	for _, r := range rows {
+		t := r.rec
+		var expiry interface{}
+		expiresIn := int64(0)
+		if r.exp > 0 {
+			expiry = r.exp
+			if r.exp > now {
+				expiresIn = r.exp - now
+			}
+		}
+		item := map[string]interface{}{
+			"id":                       t.ID,
+			"token":                    r.token,
+			"st":                       a.openSecret(t.St),
+			"rt":                       a.openSecret(t.Rt),
+			"client_id":                t.ClientID,
+			"is_active":                t.IsActive,
+			"remark":                   t.Remark,
+			"proxy_url":                t.ProxyURL,
+			"image_enabled":            t.ImageEnabled,
+			"video_enabled":            t.VideoEnabled,
+			"image_concurrency":        t.ImageConcurrency,
+			"video_concurrency":        t.VideoConcurrency,
+			"email":                    r.email,
+			"is_expired":               r.expired(now),
+			"plan_type":                r.planType,
+			"groups":                   append([]string{}, t.Groups...),
+			"error_message":            t.ErrorMessage,
+			"sora2_remaining_count":    r.status.Rate.EstimatedNumVideosRemaining,
+			"sora2_total_count":        0,
+			"access_resets_in_seconds": r.status.Rate.AccessResetsInSeconds,
+			"cooldown_until":           cooldownValue(t.CooldownUntil),
+			"image_count":              0,
+			"video_count":              0,
+			"error_count":              t.ErrorCount,
+			"expiry_time":              expiry,
+			"expires_in_seconds":       expiresIn,
+		}
+		if h, ok := checks[t.ID]; ok {
+			item["last_check_at"], item["last_check_ok"], item["last_check_latency_ms"] = h.CheckedAt, h.OK, h.LatencyMs
+		}
+		list = append(list, item)
+	}
+
+	out := map[string]interface{}{"result": list, "total": total, "all_total": len(all), "expired_total": expiredCount}
+	return jsonMarshal(out)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AT 自动刷新：后台定期解码每个已启用账号 access token 的 JWT exp，
+// 距过期不足 at_refresh_before_minutes 时用保存的 RT/ST 换取新 AT（见 token_convert.go）并写回；
+// 失败原因写入 error_message，同一账号失败后隔一段时间再重试；原因与上次相同时不重复记录。
+// 没有保存 ST/RT 的账号无法刷新，只计入跳过。开关与提前量通过 /api/token-refresh 配置。
+
+const (
+	atRefreshCheckInterval = 5 * time.Minute
+	atRefreshRetryAfter    = 30 * time.Minute
+	// atRefreshErrorPrefix 自动刷新写入 error_message 的前缀，刷新成功后只清除带该前缀的错误
+	atRefreshErrorPrefix = "AT 自动刷新失败: "
+)
+
+// atRefreshSummary 一轮检查的结果
+type atRefreshSummary struct {
+	StartedAt int64  `json:"started_at"`
+	Checked   int    `json:"checked"`   // 解析到 exp 的账号数
+	Due       int    `json:"due"`       // 进入刷新窗口的账号数
+	Refreshed int    `json:"refreshed"` // 刷新成功
+	Failed    int    `json:"failed"`    // 刷新失败
+	Skipped   int    `json:"skipped"`   // 仍在失败重试间隔内或未保存 ST/RT
+	Error     string `json:"error,omitempty"`
+}
+
+// atRefresher 自动刷新的运行状态
+type atRefresher struct {
+	mu         sync.Mutex
+	running    bool
+	last       *atRefreshSummary
+	retryAfter map[int64]time.Time // 账号失败后下次允许重试的时间
+	wake       chan struct{}
+}
+
+func newATRefresher() *atRefresher {
+	return &atRefresher{retryAfter: map[int64]time.Time{}, wake: make(chan struct{}, 1)}
+}
+
+// trigger 唤醒后台循环立即检查一次（已有待处理的唤醒时忽略）
+func (r *atRefresher) trigger() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runATRefresher 后台循环：每 atRefreshCheckInterval 或被唤醒时检查一次，未启用时跳过
+func (a *App) runATRefresher() {
+	ticker := time.NewTicker(atRefreshCheckInterval)
+	defer ticker.Stop()
+	for {
+		if a.loadAppConfig().ATAutoRefreshEnabled {
+			a.refreshExpiringTokens(false)
+		}
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.refresher.wake:
+		}
+	}
+}
+
+// refreshExpiringTokens 刷新即将过期的账号；force 为 true 时忽略失败重试间隔（手动触发）
+func (a *App) refreshExpiringTokens(force bool) atRefreshSummary {
+	r := a.refresher
+	sum := atRefreshSummary{StartedAt: time.Now().Unix()}
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		sum.Error = "上一轮刷新仍在进行"
+		return sum
+	}
+	r.running = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.last = &sum
+		r.mu.Unlock()
+	}()
+	// 与恢复备份互斥：刷新过程中存储不会被替换
+	a.dataMu.RLock()
+	defer a.dataMu.RUnlock()
+
+	if a.store == nil {
+		sum.Error = errStoreUnavailable.Error()
+		return sum
+	}
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		sum.Error = err.Error()
+		a.logError(fmt.Sprintf("[ATRefresh] 查询账号失败: %v", err))
+		return sum
+	}
+	window := time.Duration(a.loadAppConfig().ATRefreshBeforeMinutes) * time.Minute
+	now := time.Now()
+	for _, t := range tokens {
+		if !t.IsActive {
+			continue
+		}
+		claims, ok := parseJWTClaims(a.openSecret(t.Token))
+		if !ok || claims.Exp == 0 {
+			continue
+		}
+		sum.Checked++
+		if time.Unix(claims.Exp, 0).Sub(now) > window {
+			continue
+		}
+		sum.Due++
+		r.mu.Lock()
+		next := r.retryAfter[t.ID]
+		r.mu.Unlock()
+		if !force && now.Before(next) {
+			sum.Skipped++
+			continue
+		}
+		if _, method, err := a.refreshTokenRecord(t.ID); errors.Is(err, errNoRefreshCredential) {
+			sum.Skipped++
+		} else if err != nil {
+			sum.Failed++
+			a.recordATRefreshFailure(t.ID, err)
+		} else {
+			sum.Refreshed++
+			a.clearATRefreshFailure(t.ID)
+			a.logInfo(fmt.Sprintf("[ATRefresh] 账号 %d 的 AT 将于 %s 过期，已通过 %s 刷新",
+				t.ID, time.Unix(claims.Exp, 0).Format("2006-01-02 15:04"), strings.ToUpper(method)))
+		}
+	}
+	if sum.Refreshed > 0 && a.scheduler != nil {
+		a.scheduler.notify()
+	}
+	if sum.Due > 0 {
+		a.logInfo(fmt.Sprintf("[ATRefresh] 检查 %d 个账号，即将过期 %d 个，刷新成功 %d 个，失败 %d 个",
+			sum.Checked, sum.Due, sum.Refreshed, sum.Failed))
+	}
+	return sum
+}
+
+// recordATRefreshFailure 推迟该账号的下次重试，并把失败原因写入 error_message 与失败记录；
+// error_message 已是同一原因时只推迟重试，不再重复记录
+func (a *App) recordATRefreshFailure(id int64, cause error) {
+	a.refresher.mu.Lock()
+	a.refresher.retryAfter[id] = time.Now().Add(atRefreshRetryAfter)
+	a.refresher.mu.Unlock()
+	msg := truncateRunes(atRefreshErrorPrefix+redactSecrets(cause.Error()), 300)
+	if rec, err := a.store.GetToken(id); err != nil || rec.ErrorMessage == msg {
+		return
+	}
+	_ = a.store.UpdateToken(id, func(t *tokenRecord) error {
+		t.ErrorMessage, t.UpdatedAt = msg, time.Now()
+		return nil
+	})
+	a.recordFailureEvent(id, "", failureStageAuth, msg)
+	a.logWarning(fmt.Sprintf("[ATRefresh] 账号 %d %s", id, msg))
+}
+
+// clearATRefreshFailure 刷新成功后清除重试间隔与自动刷新写入的错误
+func (a *App) clearATRefreshFailure(id int64) {
+	a.refresher.mu.Lock()
+	delete(a.refresher.retryAfter, id)
+	a.refresher.mu.Unlock()
+	_ = a.store.UpdateToken(id, func(t *tokenRecord) error {
+		if strings.HasPrefix(t.ErrorMessage, atRefreshErrorPrefix) {
+			t.ErrorMessage = ""
+		}
+		return nil
+	})
+}
+
+// atRefreshConfigView /api/token-refresh/config 返回的 config 字段
+func (a *App) atRefreshConfigView(cfg AppConfig) map[string]interface{} {
+	out := map[string]interface{}{
+		"at_auto_refresh_enabled":   cfg.ATAutoRefreshEnabled,
+		"at_refresh_before_minutes": cfg.ATRefreshBeforeMinutes,
+		"check_interval_seconds":    int(atRefreshCheckInterval / time.Second),
+	}
+	a.refresher.mu.Lock()
+	if a.refresher.last != nil {
+		out["last_run"] = *a.refresher.last
+	}
+	out["running"] = a.refresher.running
+	a.refresher.mu.Unlock()
+	return out
+}
+
+// handleTokenRefresh /api/token-refresh/*：
+// GET config 查看配置与上一轮结果；POST enabled|config 修改 {"enabled", "refresh_before_minutes"}；POST run 立即执行一轮
+func (a *App) handleTokenRefresh(method string, path string, body string) (string, error) {
+	if method == http.MethodGet {
+		return jsonMarshal(map[string]interface{}{"success": true, "config": a.atRefreshConfigView(a.loadAppConfig())})
+	}
+	if strings.HasSuffix(strings.SplitN(path, "?", 2)[0], "/run") {
+		sum := a.refreshExpiringTokens(true)
+		if sum.Error != "" {
+			return jsonFail(sum.Error)
+		}
+		return jsonMarshal(map[string]interface{}{"success": true, "result": sum})
+	}
+	var input struct {
+		Enabled              *bool `json:"enabled"`
+		RefreshBeforeMinutes *int  `json:"refresh_before_minutes"`
+	}
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		return jsonFail("请求体解析失败")
+	}
+	cfg, err := a.updateAppConfig(func(cfg *AppConfig) error {
+		if input.Enabled != nil {
+			cfg.ATAutoRefreshEnabled = *input.Enabled
+		}
+		if input.RefreshBeforeMinutes != nil {
+			cfg.ATRefreshBeforeMinutes = *input.RefreshBeforeMinutes
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+	if cfg.ATAutoRefreshEnabled {
+		a.refresher.trigger()
+	}
+	return jsonMarshal(map[string]interface{}{"success": true, "config": a.atRefreshConfigView(cfg)})
+}
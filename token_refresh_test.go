@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshExpiringTokens(t *testing.T) {
+	var reason atomic.Value
+	reason.Store("session expired")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, reason.Load().(string), http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	store := openTestSQLiteStore(t)
+	cfg := defaultAppConfig()
+	cfg.AuthSessionURL = srv.URL + "/api/auth/session"
+	raw, _ := json.Marshal(cfg)
+	if err := store.SetSetting(appConfigSettingKey, string(raw)); err != nil {
+		t.Fatal(err)
+	}
+	a := &App{store: store, refresher: newATRefresher()}
+
+	expiring := testJWT(map[string]interface{}{"exp": time.Now().Add(time.Minute).Unix()})
+	noCredID, err := store.InsertToken(tokenRecord{Token: expiring, TokenHash: "h1", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stID, err := store.InsertToken(tokenRecord{Token: expiring, TokenHash: "h2", St: "st", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := func() int {
+		t.Helper()
+		counts, err := store.FailureEventCounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counts[stID]
+	}
+	tests := []struct {
+		name         string
+		reason       string
+		wantFailures int
+	}{
+		{"first failure is recorded", "session expired", 1},
+		{"same cause is not recorded again", "session expired", 1},
+		{"new cause is recorded", "account banned", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason.Store(tt.reason)
+			sum := a.refreshExpiringTokens(true)
+			if sum.Due != 2 || sum.Failed != 1 || sum.Skipped != 1 || sum.Refreshed != 0 {
+				t.Errorf("refreshExpiringTokens() = %+v, want 2 due, 1 failed, 1 skipped", sum)
+			}
+			if got := failures(); got != tt.wantFailures {
+				t.Errorf("failure events = %d, want %d", got, tt.wantFailures)
+			}
+			rec, err := store.GetToken(stID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(rec.ErrorMessage, atRefreshErrorPrefix) || !strings.Contains(rec.ErrorMessage, tt.reason) {
+				t.Errorf("error_message = %q, want refresh failure with %q", rec.ErrorMessage, tt.reason)
+			}
+		})
+	}
+
+	rec, err := store.GetToken(noCredID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts, _ := store.FailureEventCounts(); rec.ErrorMessage != "" || counts[noCredID] != 0 {
+		t.Errorf("token without ST/RT recorded a failure: %q, %d events", rec.ErrorMessage, counts[noCredID])
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 并发感知的账号调度器：按 video_task_results 统计每个账号正在执行的任务数，
+// 只分配未达到 video_concurrency 上限的账号；全部占满时排队，直到有任务结束再分配。
+
+type tokenScheduler struct {
+	app *App
+
+	mu        sync.Mutex
+	reserved  map[int64]int            // 已分配但尚未写入 video_task_results 的任务数
+	freed     chan struct{}            // 有名额释放时关闭并替换，用于唤醒排队者
+	selectors map[string]TokenSelector // 按策略名缓存的选择器实例
+}
+
+func newTokenScheduler(app *App) *tokenScheduler {
+	return &tokenScheduler{
+		app:       app,
+		reserved:  map[int64]int{},
+		freed:     make(chan struct{}),
+		selectors: map[string]TokenSelector{},
+	}
+}
+
+// inflightCounts 统计每个账号未结束的视频任务数
+func (s *tokenScheduler) inflightCounts() (map[int64]int, error) {
+	counts, err := s.app.store.InflightTaskCounts()
+	if err != nil {
+		return nil, fmt.Errorf("统计账号并发失败: %v", err)
+	}
+	return counts, nil
+}
+
+// withIdleTokens 在调度锁内确认 ids 中的账号没有进行中的任务（含已分配尚未写库的名额）后执行 fn，
+// 期间不会有新任务分配到这些账号；有任务的账号通过 busy 返回，此时不执行 fn。fn 内不能再调用调度器
+func (s *tokenScheduler) withIdleTokens(ids []int64, fn func() error) (busy []int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts, err := s.inflightCounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if counts[id]+s.reserved[id] > 0 {
+			busy = append(busy, id)
+		}
+	}
+	if len(busy) > 0 {
+		return busy, nil
+	}
+	return nil, fn()
+}
+
+// errTokensSaturated 有可用账号但都已达到并发上限
+var errTokensSaturated = fmt.Errorf("所有可用 Token 的视频并发均已占满")
+
+// tryAcquire 按当前选择策略挑选一个账号类型满足 requiredPlan、未达到并发上限的账号；group 非空时只在该分组内选择。
+// reserve=true 时占用一个名额，需在任务写库或失败后调用 release
+func (s *tokenScheduler) tryAcquire(requiredPlan string, group string, reserve bool) (videoTokenCandidate, error) {
+	candidates, err := s.app.videoTokenCandidates(requiredPlan, group)
+	if err != nil {
+		return videoTokenCandidate{}, err
+	}
+	if len(candidates) == 0 {
+		scope := ""
+		if group = strings.TrimSpace(group); group != "" {
+			scope = "分组「" + group + "」中"
+		}
+		if planTiers[requiredPlan] > planTiers[planFree] {
+			return videoTokenCandidate{}, fmt.Errorf("%s无可用 %s Token（需账号类型满足模型要求、状态正常、已启用视频且有剩余次数）", scope, strings.ToUpper(requiredPlan))
+		}
+		return videoTokenCandidate{}, fmt.Errorf("%s无可用 Token（需状态正常、已启用视频且有剩余次数）", scope)
+	}
+
+	selector := s.currentTokenSelector()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts, err := s.inflightCounts()
+	if err != nil {
+		return videoTokenCandidate{}, err
+	}
+	var free []videoTokenCandidate
+	for _, c := range candidates {
+		if c.concurrency <= 0 || counts[c.id]+s.reserved[c.id] < c.concurrency {
+			free = append(free, c)
+		}
+	}
+	if len(free) == 0 {
+		return videoTokenCandidate{}, errTokensSaturated
+	}
+	c := selector.Select(free)
+	if reserve {
+		s.reserved[c.id]++
+	}
+	_ = s.app.store.UpdateToken(c.id, func(t *tokenRecord) error {
+		t.LastUsedAt = time.Now().UnixNano()
+		return nil
+	})
+	return c, nil
+}
+
+// acquire 与 tryAcquire 相同，但所有账号占满时阻塞排队，直到有名额释放或 ctx 取消
+// onQueued 在首次进入排队时调用一次
+func (s *tokenScheduler) acquire(ctx context.Context, requiredPlan string, group string, onQueued func()) (videoTokenCandidate, error) {
+	queued := false
+	for {
+		s.mu.Lock()
+		freed := s.freed
+		s.mu.Unlock()
+
+		c, err := s.tryAcquire(requiredPlan, group, true)
+		if err != errTokensSaturated {
+			return c, err
+		}
+		if !queued && onQueued != nil {
+			onQueued()
+		}
+		queued = true
+		select {
+		case <-ctx.Done():
+			return videoTokenCandidate{}, ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+// release 释放 tryAcquire/acquire 占用的名额（任务已写入 video_task_results 或创建失败）
+func (s *tokenScheduler) release(id int64) {
+	s.mu.Lock()
+	if s.reserved[id] > 0 {
+		s.reserved[id]--
+		if s.reserved[id] == 0 {
+			delete(s.reserved, id)
+		}
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// notify 唤醒所有排队者重新尝试分配（任务结束、账号配置变更时调用）
+func (s *tokenScheduler) notify() {
+	s.mu.Lock()
+	close(s.freed)
+	s.freed = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// withIdleTokens 确认 ids 中的账号没有进行中的任务后执行 fn（见 tokenScheduler.withIdleTokens）；
+// 调度器尚未创建时直接按存储中的任务数检查
+func (a *App) withIdleTokens(ids []int64, fn func() error) ([]int64, error) {
+	if a.scheduler != nil {
+		return a.scheduler.withIdleTokens(ids, fn)
+	}
+	counts, err := a.store.InflightTaskCounts()
+	if err != nil {
+		return nil, err
+	}
+	var busy []int64
+	for _, id := range ids {
+		if counts[id] > 0 {
+			busy = append(busy, id)
+		}
+	}
+	if len(busy) > 0 {
+		return busy, nil
+	}
+	return nil, fn()
+}
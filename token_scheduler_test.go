@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSchedulerTestApp 使用 JSON 存储的 App，按 concurrency 依次创建已启用视频的账号
+func newSchedulerTestApp(t *testing.T, concurrency ...int) (*App, []int64) {
+	t.Helper()
+	store, err := openJSONStore(filepath.Join(t.TempDir(), jsonStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{store: store}
+	a.scheduler = newTokenScheduler(a)
+	var ids []int64
+	for i, n := range concurrency {
+		id, err := store.InsertToken(tokenRecord{Token: fmt.Sprintf("at%d", i), IsActive: true, VideoEnabled: true, VideoConcurrency: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return a, ids
+}
+
+func TestTokenSchedulerTryAcquire(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency []int
+		inflight    int // 第一个账号上进行中的任务数
+		reserve     int // 先占用的名额数
+		wantErr     error
+	}{
+		{"free slot", []int{1}, 0, 0, nil},
+		{"reserved slot counts", []int{1}, 0, 1, errTokensSaturated},
+		{"in-flight task counts", []int{2}, 1, 1, errTokensSaturated},
+		{"below limit", []int{3}, 1, 1, nil},
+		{"unlimited", []int{0}, 5, 5, nil},
+		{"falls back to other token", []int{1, 1}, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ids := newSchedulerTestApp(t, tt.concurrency...)
+			for i := 0; i < tt.inflight; i++ {
+				if err := a.store.PutTask(taskRecord{TaskID: fmt.Sprintf("task%d", i), TokenID: ids[0], ProgressPct: 10}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			a.scheduler.reserved[ids[0]] = tt.reserve
+			c, err := a.scheduler.tryAcquire("", "", true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("tryAcquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want, wantReserved := ids[len(ids)-1], 1
+			if len(ids) == 1 {
+				wantReserved = tt.reserve + 1
+			}
+			if c.id != want || a.scheduler.reserved[c.id] != wantReserved {
+				t.Errorf("tryAcquire() = token %d (reserved %d), want token %d (reserved %d)",
+					c.id, a.scheduler.reserved[c.id], want, wantReserved)
+			}
+		})
+	}
+}
+
+func TestTokenSchedulerRelease(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 1)
+	c, err := a.scheduler.tryAcquire("", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.scheduler.tryAcquire("", "", true); err != errTokensSaturated {
+		t.Fatalf("second tryAcquire() error = %v, want errTokensSaturated", err)
+	}
+	// 不占用名额的查询不影响计数
+	if _, err := a.scheduler.tryAcquire("", "", false); err != errTokensSaturated {
+		t.Fatalf("tryAcquire(reserve=false) error = %v, want errTokensSaturated", err)
+	}
+	a.scheduler.release(c.id)
+	if _, ok := a.scheduler.reserved[ids[0]]; ok {
+		t.Errorf("release() left reserved = %v", a.scheduler.reserved)
+	}
+	// 多余的 release 不会让计数变成负数
+	a.scheduler.release(c.id)
+	if _, err := a.scheduler.tryAcquire("", "", false); err != nil {
+		t.Errorf("tryAcquire() after release error = %v", err)
+	}
+	if tok, _ := a.store.GetToken(ids[0]); tok.LastUsedAt == 0 {
+		t.Error("tryAcquire() did not update last_used_at")
+	}
+}
+
+func TestTokenSchedulerNoCandidates(t *testing.T) {
+	a, _ := newSchedulerTestApp(t, 1)
+	_, err := a.scheduler.tryAcquire(planPro, "", true)
+	if err == nil || !strings.Contains(err.Error(), "PRO") {
+		t.Errorf("tryAcquire(pro) error = %v, want no PRO token", err)
+	}
+}
+
+func TestTokenSchedulerAcquireQueues(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 1)
+	first, err := a.scheduler.tryAcquire("", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queued := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.scheduler.acquire(context.Background(), "", "", func() { close(queued) })
+		done <- err
+	}()
+	select {
+	case <-queued:
+	case <-time.After(time.Second):
+		t.Fatal("acquire() did not queue while saturated")
+	}
+	a.scheduler.release(first.id)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("acquire() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire() not woken by release")
+	}
+	if got := a.scheduler.reserved[ids[0]]; got != 1 {
+		t.Errorf("reserved after queued acquire = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := a.scheduler.acquire(ctx, "", "", cancel)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire() after cancel error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire() ignored ctx cancellation")
+	}
+}
+
+func TestTokenSchedulerWithIdleTokens(t *testing.T) {
+	a, ids := newSchedulerTestApp(t, 2, 2)
+	a.scheduler.reserved[ids[1]] = 1
+	ran := false
+	busy, err := a.scheduler.withIdleTokens(ids, func() error { ran = true; return nil })
+	if err != nil || ran || len(busy) != 1 || busy[0] != ids[1] {
+		t.Errorf("withIdleTokens(busy) = %v, %v, ran %v; want [%d] without running", busy, err, ran, ids[1])
+	}
+	busy, err = a.scheduler.withIdleTokens(ids[:1], func() error { ran = true; return nil })
+	if err != nil || !ran || len(busy) != 0 {
+		t.Errorf("withIdleTokens(idle) = %v, %v, ran %v; want fn to run", busy, err, ran)
+	}
+}
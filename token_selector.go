@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 账号选择策略：调度器筛出未达并发上限的账号后，由当前策略决定具体使用哪一个。
+// 当前策略保存在系统配置 AppConfig.TokenSelectionStrategy 中，所有视频创建路径共用。
+
+// 可选的账号选择策略
+const (
+	tokenStrategyRandom         = "random"
+	tokenStrategyRoundRobin     = "round_robin"
+	tokenStrategyLeastRecent    = "least_recently_used"
+	tokenStrategyMostRemaining  = "most_remaining"
+	tokenStrategyWeightedRandom = "weighted_random"
+)
+
+// TokenSelector 从一组可用账号中选出一个，candidates 保证非空
+type TokenSelector interface {
+	Name() string
+	Select(candidates []videoTokenCandidate) videoTokenCandidate
+}
+
+// tokenStrategyNames 所有策略名及说明，按展示顺序排列
+var tokenStrategyNames = []struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}{
+	{tokenStrategyRandom, "随机"},
+	{tokenStrategyRoundRobin, "轮询"},
+	{tokenStrategyLeastRecent, "最久未使用优先"},
+	{tokenStrategyMostRemaining, "剩余次数最多优先"},
+	{tokenStrategyWeightedRandom, "按剩余次数加权随机"},
+}
+
+// newTokenSelector 按名称创建策略，未知名称返回 nil
+func newTokenSelector(name string) TokenSelector {
+	switch name {
+	case tokenStrategyRandom:
+		return randomSelector{}
+	case tokenStrategyRoundRobin:
+		return &roundRobinSelector{}
+	case tokenStrategyLeastRecent:
+		return leastRecentSelector{}
+	case tokenStrategyMostRemaining:
+		return mostRemainingSelector{}
+	case tokenStrategyWeightedRandom:
+		return weightedRandomSelector{}
+	}
+	return nil
+}
+
+// normalizeTokenStrategy 校验策略名，空值返回默认策略
+func normalizeTokenStrategy(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return tokenStrategyRandom, nil
+	}
+	if newTokenSelector(name) == nil {
+		return "", fmt.Errorf("未知的账号选择策略: %s", name)
+	}
+	return name, nil
+}
+
+// randomSelector 随机选择（原有行为）
+type randomSelector struct{}
+
+func (randomSelector) Name() string { return tokenStrategyRandom }
+
+func (randomSelector) Select(candidates []videoTokenCandidate) videoTokenCandidate {
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// roundRobinSelector 按 token_id 顺序依次轮换
+type roundRobinSelector struct {
+	mu     sync.Mutex
+	lastID int64
+}
+
+func (*roundRobinSelector) Name() string { return tokenStrategyRoundRobin }
+
+func (s *roundRobinSelector) Select(candidates []videoTokenCandidate) videoTokenCandidate {
+	sorted := append([]videoTokenCandidate(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := sorted[0]
+	for _, c := range sorted {
+		if c.id > s.lastID {
+			next = c
+			break
+		}
+	}
+	s.lastID = next.id
+	return next
+}
+
+// leastRecentSelector 选择最久未被分配的账号（last_used_at 最小）
+type leastRecentSelector struct{}
+
+func (leastRecentSelector) Name() string { return tokenStrategyLeastRecent }
+
+func (leastRecentSelector) Select(candidates []videoTokenCandidate) videoTokenCandidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.lastUsedAt < best.lastUsedAt {
+			best = c
+		}
+	}
+	return best
+}
+
+// mostRemainingSelector 选择剩余视频次数最多的账号；剩余次数未知的账号排在最后
+type mostRemainingSelector struct{}
+
+func (mostRemainingSelector) Name() string { return tokenStrategyMostRemaining }
+
+func (mostRemainingSelector) Select(candidates []videoTokenCandidate) videoTokenCandidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.remaining > best.remaining {
+			best = c
+		}
+	}
+	return best
+}
+
+// weightedRandomSelector 以剩余视频次数为权重随机选择；剩余次数未知的账号权重为 1
+type weightedRandomSelector struct{}
+
+func (weightedRandomSelector) Name() string { return tokenStrategyWeightedRandom }
+
+func (weightedRandomSelector) Select(candidates []videoTokenCandidate) videoTokenCandidate {
+	total := 0
+	for _, c := range candidates {
+		total += candidateWeight(c)
+	}
+	n := rand.Intn(total)
+	for _, c := range candidates {
+		n -= candidateWeight(c)
+		if n < 0 {
+			return c
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func candidateWeight(c videoTokenCandidate) int {
+	if c.remaining > 0 {
+		return c.remaining
+	}
+	return 1
+}
+
+// currentTokenSelector 返回系统配置中的策略；同名策略复用同一实例以保留轮询状态
+func (s *tokenScheduler) currentTokenSelector() TokenSelector {
+	name, err := normalizeTokenStrategy(s.app.loadAppConfig().TokenSelectionStrategy)
+	if err != nil {
+		name = tokenStrategyRandom
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sel, ok := s.selectors[name]; ok {
+		return sel
+	}
+	sel := newTokenSelector(name)
+	s.selectors[name] = sel
+	return sel
+}
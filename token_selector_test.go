@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestTokenSelectors(t *testing.T) {
+	candidates := []videoTokenCandidate{
+		{id: 3, remaining: 5, lastUsedAt: 300},
+		{id: 1, remaining: -1, lastUsedAt: 100},
+		{id: 2, remaining: 8, lastUsedAt: 0},
+	}
+	tests := []struct {
+		strategy string
+		want     []int64 // 连续选择的结果
+	}{
+		{tokenStrategyRoundRobin, []int64{1, 2, 3, 1}},
+		{tokenStrategyLeastRecent, []int64{2, 2}},
+		{tokenStrategyMostRemaining, []int64{2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			sel := newTokenSelector(tt.strategy)
+			if sel.Name() != tt.strategy {
+				t.Errorf("Name() = %q", sel.Name())
+			}
+			for i, want := range tt.want {
+				if got := sel.Select(candidates).id; got != want {
+					t.Errorf("Select() #%d = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinSelectorSkipsMissing(t *testing.T) {
+	sel := newTokenSelector(tokenStrategyRoundRobin)
+	sel.Select([]videoTokenCandidate{{id: 1}, {id: 2}, {id: 3}})
+	// 上次选中 1 后 2 已占满，应轮到 3；之后从头开始
+	if got := sel.Select([]videoTokenCandidate{{id: 1}, {id: 3}}).id; got != 3 {
+		t.Errorf("Select() = %d, want 3", got)
+	}
+	if got := sel.Select([]videoTokenCandidate{{id: 1}, {id: 2}}).id; got != 1 {
+		t.Errorf("Select() after last id = %d, want 1", got)
+	}
+}
+
+func TestRandomSelectorsStayInCandidates(t *testing.T) {
+	candidates := []videoTokenCandidate{{id: 1, remaining: 99}, {id: 2, remaining: 0}, {id: 3, remaining: -1}}
+	for _, name := range []string{tokenStrategyRandom, tokenStrategyWeightedRandom} {
+		sel := newTokenSelector(name)
+		counts := map[int64]int{}
+		for i := 0; i < 1000; i++ {
+			counts[sel.Select(candidates).id]++
+		}
+		if len(counts) > 3 || counts[1]+counts[2]+counts[3] != 1000 {
+			t.Errorf("%s picked outside candidates: %v", name, counts)
+		}
+		// 加权随机下剩余 99 次的账号权重远高于其余两个（各为 1）
+		if name == tokenStrategyWeightedRandom && counts[1] < 900 {
+			t.Errorf("%s counts = %v, want token 1 to dominate", name, counts)
+		}
+	}
+}
+
+func TestNormalizeTokenStrategy(t *testing.T) {
+	for _, s := range tokenStrategyNames {
+		if got, err := normalizeTokenStrategy(" " + s.Name + " "); err != nil || got != s.Name {
+			t.Errorf("normalizeTokenStrategy(%q) = %q, %v", s.Name, got, err)
+		}
+	}
+	if got, err := normalizeTokenStrategy(""); err != nil || got != tokenStrategyRandom {
+		t.Errorf("normalizeTokenStrategy(\"\") = %q, %v; want random", got, err)
+	}
+	if _, err := normalizeTokenStrategy("fastest"); err == nil {
+		t.Error("normalizeTokenStrategy(unknown) returned no error")
+	}
+}
+
+func TestCurrentTokenSelector(t *testing.T) {
+	a, _ := newSchedulerTestApp(t)
+	if _, err := a.updateAppConfig(func(cfg *AppConfig) error {
+		cfg.TokenSelectionStrategy = tokenStrategyRoundRobin
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	first := a.scheduler.currentTokenSelector()
+	if first.Name() != tokenStrategyRoundRobin {
+		t.Fatalf("currentTokenSelector() = %s, want round_robin", first.Name())
+	}
+	// 同名策略复用同一实例，轮询位置不会因重新读取配置而丢失
+	if a.scheduler.currentTokenSelector() != first {
+		t.Error("currentTokenSelector() created a new round-robin instance")
+	}
+}
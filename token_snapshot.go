@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 额度历史：账号状态每次更新（验证、健康检查刷新 status_json，提交视频任务返回 rate_limit 信息）时
+// 向 token_snapshots 追加一条剩余次数快照，用于查看每天的消耗并按近期平均消耗估算账号池何时用完。
+// 与 request_logs、failure_events 一样经 Store 只写入 SQLite，JSON 文件存储下不记录，接口返回 available:false。
+
+const (
+	snapshotSourceStatus = "status" // /account/status 刷新
+	snapshotSourceTask   = "task"   // 提交视频任务时返回的 rate_limit 信息
+	// tokenSnapshotRetention 快照保留时长，启动时清理更早的记录
+	tokenSnapshotRetention = 90 * 24 * time.Hour
+)
+
+// tokenSnapshot 一条额度快照
+type tokenSnapshot struct {
+	TokenID               int64  `json:"token_id"`
+	CreatedAt             int64  `json:"created_at"`
+	Source                string `json:"source"`
+	VideosRemaining       int    `json:"videos_remaining"`
+	CreditRemaining       int    `json:"credit_remaining"`
+	AccessResetsInSeconds int    `json:"access_resets_in_seconds"`
+	RateLimitReached      bool   `json:"rate_limit_reached"`
+}
+
+// recordTokenSnapshot 追加一条快照；JSON 文件存储不记录
+func (a *App) recordTokenSnapshot(tokenID int64, source string, rate rateLimitBalance) {
+	if a.store == nil || tokenID <= 0 {
+		return
+	}
+	err := a.store.InsertTokenSnapshot(tokenSnapshot{
+		TokenID:               tokenID,
+		CreatedAt:             time.Now().Unix(),
+		Source:                source,
+		VideosRemaining:       rate.EstimatedNumVideosRemaining,
+		CreditRemaining:       rate.CreditRemaining,
+		AccessResetsInSeconds: rate.AccessResetsInSeconds,
+		RateLimitReached:      rate.RateLimitReached,
+	})
+	if err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("写入额度快照失败: %v", err))
+	}
+}
+
+// recordTokenSnapshotFromStatus 从 status_json 的 rate_limit_and_credit_balance 追加快照，没有该字段时跳过
+func (a *App) recordTokenSnapshotFromStatus(tokenID int64, source string, statusJSON string) {
+	if rate, ok := statusRateLimit(statusJSON); ok {
+		a.recordTokenSnapshot(tokenID, source, rate)
+	}
+}
+
+// pruneTokenSnapshots 删除超过保留时长的快照
+func (a *App) pruneTokenSnapshots() {
+	if a.store == nil {
+		return
+	}
+	cutoff := time.Now().Add(-tokenSnapshotRetention).Unix()
+	if err := a.store.PruneTokenSnapshots(cutoff); err != nil && !errors.Is(err, errHistoryUnavailable) {
+		a.logError(fmt.Sprintf("清理额度快照失败: %v", err))
+	}
+}
+
+// loadTokenSnapshots 读取 since 之后的快照，按账号、时间升序；tokenID<=0 时读取全部账号。
+// available 为 false 表示当前存储不记录快照（JSON 文件存储），此时返回空列表
+func (a *App) loadTokenSnapshots(tokenID int64, since int64) (list []tokenSnapshot, available bool, err error) {
+	list, err = a.store.ListTokenSnapshots(tokenID, since)
+	if errors.Is(err, errHistoryUnavailable) {
+		return nil, false, nil
+	}
+	return list, err == nil, err
+}
+
+// snapshotConsumption 把同一账号按时间排序的快照换算成每天消耗的次数：
+// 相邻两次剩余次数减少的部分计入后一次所在的日期，增加（额度恢复）不计
+func snapshotConsumption(points []tokenSnapshot, into map[string]int) {
+	for i := 1; i < len(points); i++ {
+		if d := points[i-1].VideosRemaining - points[i].VideosRemaining; d > 0 {
+			into[time.Unix(points[i].CreatedAt, 0).Format("2006-01-02")] += d
+		}
+	}
+}
+
+// quotaQuery 解析 days 参数（1-365，默认 14），返回天数与窗口内第一天 0 点
+func quotaQuery(rawPath string) (int, time.Time) {
+	days := 14
+	if u, err := url.Parse(rawPath); err == nil {
+		if v, err := strconv.Atoi(u.Query().Get("days")); err == nil && v > 0 && v <= 365 {
+			days = v
+		}
+	}
+	now := time.Now()
+	first := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+	return days, first
+}
+
+// quotaForecast 按窗口内的平均每日消耗估算剩余次数可用的天数；没有消耗记录时只返回平均值。
+// hasData 为 false（既没有 status 中的额度也没有快照）时剩余次数与预估都为 null，只有确实读到 0 才算已耗尽
+func quotaForecast(out map[string]interface{}, hasData bool, remaining, consumed int, firstPoint int64) {
+	now := time.Now()
+	avg := 0.0
+	if firstPoint > 0 {
+		elapsed := math.Max(now.Sub(time.Unix(firstPoint, 0)).Hours()/24, 1)
+		avg = float64(consumed) / elapsed
+	}
+	out["current_remaining"] = nil
+	out["avg_daily_consumed"] = math.Round(avg*100) / 100
+	out["days_left"] = nil
+	out["predicted_empty_at"] = nil
+	if !hasData {
+		return
+	}
+	out["current_remaining"] = remaining
+	switch {
+	case remaining <= 0:
+		out["days_left"], out["predicted_empty_at"] = 0, now.Unix()
+	case avg > 0:
+		left := float64(remaining) / avg
+		out["days_left"] = math.Round(left*10) / 10
+		out["predicted_empty_at"] = now.Add(time.Duration(left * float64(24*time.Hour))).Unix()
+	}
+}
+
+// dailyConsumption 生成窗口内每一天的消耗（无记录的日期为 0）
+func dailyConsumption(days int, first time.Time, consumed map[string]int) ([]map[string]interface{}, int) {
+	list := make([]map[string]interface{}, 0, days)
+	total := 0
+	for i := 0; i < days; i++ {
+		day := first.AddDate(0, 0, i).Format("2006-01-02")
+		list = append(list, map[string]interface{}{"date": day, "consumed": consumed[day]})
+		total += consumed[day]
+	}
+	return list, total
+}
+
+// localTokenSnapshots GET /api/tokens/:id/snapshots?days=14：该账号的快照序列、每日消耗与耗尽预估
+func (a *App) localTokenSnapshots(id int64, rawPath string) (string, error) {
+	rec, err := a.store.GetToken(id)
+	if err != nil {
+		return jsonFail("Token 不存在")
+	}
+	days, first := quotaQuery(rawPath)
+	// 多取一天，用于计算窗口第一天相对前一天的消耗
+	points, available, err := a.loadTokenSnapshots(id, first.AddDate(0, 0, -1).Unix())
+	if err != nil {
+		return jsonFail("查询额度快照失败: " + err.Error())
+	}
+	out := map[string]interface{}{"success": true, "token_id": id, "days": days, "available": available}
+	consumed := map[string]int{}
+	snapshotConsumption(points, consumed)
+	daily, total := dailyConsumption(days, first, consumed)
+
+	inWindow := []tokenSnapshot{}
+	for _, p := range points {
+		if p.CreatedAt >= first.Unix() {
+			inWindow = append(inWindow, p)
+		}
+	}
+	var firstPoint int64
+	if len(inWindow) > 0 {
+		firstPoint = inWindow[0].CreatedAt
+	}
+	remaining, hasData := 0, true
+	if rate, ok := statusRateLimit(rec.StatusJSON); ok {
+		remaining = rate.EstimatedNumVideosRemaining
+	} else if len(points) > 0 {
+		remaining = points[len(points)-1].VideosRemaining
+	} else {
+		hasData = false
+	}
+	out["points"], out["daily"], out["total_consumed"] = inWindow, daily, total
+	quotaForecast(out, hasData, remaining, total, firstPoint)
+	return jsonMarshal(out)
+}
+
+// localQuotaStats GET /api/stats/quota?days=14：账号池（已启用账号）每日消耗合计与耗尽预估
+func (a *App) localQuotaStats(rawPath string) (string, error) {
+	if a.store == nil {
+		return jsonFail(errStoreUnavailable.Error())
+	}
+	days, first := quotaQuery(rawPath)
+	tokens, err := a.store.ListTokens()
+	if err != nil {
+		return jsonFail("查询账号失败: " + err.Error())
+	}
+	active := map[int64]bool{}
+	remaining := 0
+	hasData := false // 至少一个已启用账号有 status 额度或快照
+	perToken := []map[string]interface{}{}
+	for _, t := range tokens {
+		if !t.IsActive {
+			continue
+		}
+		active[t.ID] = true
+		if rate, ok := statusRateLimit(t.StatusJSON); ok {
+			remaining += rate.EstimatedNumVideosRemaining
+			hasData = true
+		}
+	}
+
+	points, available, err := a.loadTokenSnapshots(0, first.AddDate(0, 0, -1).Unix())
+	if err != nil {
+		return jsonFail("查询额度快照失败: " + err.Error())
+	}
+	byToken := map[int64][]tokenSnapshot{}
+	var firstPoint int64
+	for _, p := range points {
+		if !active[p.TokenID] {
+			continue
+		}
+		byToken[p.TokenID] = append(byToken[p.TokenID], p)
+		if p.CreatedAt >= first.Unix() && (firstPoint == 0 || p.CreatedAt < firstPoint) {
+			firstPoint = p.CreatedAt
+		}
+	}
+	ids := make([]int64, 0, len(byToken))
+	for id := range byToken {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	consumed := map[string]int{}
+	for _, id := range ids {
+		own := map[string]int{}
+		snapshotConsumption(byToken[id], own)
+		_, total := dailyConsumption(days, first, own)
+		for day, n := range own {
+			consumed[day] += n
+		}
+		list := byToken[id]
+		perToken = append(perToken, map[string]interface{}{
+			"token_id":       id,
+			"consumed":       total,
+			"last_remaining": list[len(list)-1].VideosRemaining,
+			"last_at":        list[len(list)-1].CreatedAt,
+		})
+	}
+	daily, total := dailyConsumption(days, first, consumed)
+	out := map[string]interface{}{
+		"success":        true,
+		"available":      available,
+		"days":           days,
+		"active_tokens":  len(active),
+		"daily":          daily,
+		"total_consumed": total,
+		"per_token":      perToken,
+	}
+	quotaForecast(out, hasData || len(byToken) > 0, remaining, total, firstPoint)
+	return jsonMarshal(out)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotConsumption(t *testing.T) {
+	at := func(day, hour int) int64 {
+		return time.Date(2026, 3, day, hour, 0, 0, 0, time.Local).Unix()
+	}
+	point := func(ts int64, remaining int) tokenSnapshot {
+		return tokenSnapshot{CreatedAt: ts, VideosRemaining: remaining}
+	}
+	tests := []struct {
+		name   string
+		points []tokenSnapshot
+		want   map[string]int
+	}{
+		{name: "no points", want: map[string]int{}},
+		{name: "single point", points: []tokenSnapshot{point(at(1, 9), 30)}, want: map[string]int{}},
+		{
+			name:   "decrease counted on the later day",
+			points: []tokenSnapshot{point(at(1, 23), 30), point(at(2, 1), 25)},
+			want:   map[string]int{"2026-03-02": 5},
+		},
+		{
+			name:   "same day adds up",
+			points: []tokenSnapshot{point(at(1, 9), 30), point(at(1, 12), 28), point(at(1, 18), 20)},
+			want:   map[string]int{"2026-03-01": 10},
+		},
+		{
+			name:   "quota reset is not consumption",
+			points: []tokenSnapshot{point(at(1, 9), 5), point(at(2, 9), 30), point(at(2, 12), 27)},
+			want:   map[string]int{"2026-03-02": 3},
+		},
+		{
+			name:   "unchanged remaining",
+			points: []tokenSnapshot{point(at(1, 9), 10), point(at(3, 9), 10)},
+			want:   map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]int{}
+			snapshotConsumption(tt.points, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("snapshotConsumption() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotConsumptionAccumulates(t *testing.T) {
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local).Unix()
+	got := map[string]int{"2026-03-01": 4}
+	snapshotConsumption([]tokenSnapshot{{CreatedAt: day, VideosRemaining: 9}, {CreatedAt: day + 60, VideosRemaining: 7}}, got)
+	if got["2026-03-01"] != 6 {
+		t.Errorf("snapshotConsumption() should add to existing totals, got %v", got)
+	}
+}
+
+func TestQuotaForecast(t *testing.T) {
+	dayAgo := time.Now().Add(-48 * time.Hour).Unix()
+	tests := []struct {
+		name          string
+		hasData       bool
+		remaining     int
+		consumed      int
+		firstPoint    int64
+		wantRemaining interface{}
+		wantEmpty     bool // days_left 为 0
+		wantForecast  bool // days_left 为正数
+	}{
+		{name: "no data point", wantRemaining: nil},
+		{name: "real zero balance", hasData: true, wantRemaining: 0, wantEmpty: true},
+		{name: "no consumption", hasData: true, remaining: 10, firstPoint: dayAgo, wantRemaining: 10},
+		{name: "linear forecast", hasData: true, remaining: 10, consumed: 4, firstPoint: dayAgo, wantRemaining: 10, wantForecast: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := map[string]interface{}{}
+			quotaForecast(out, tt.hasData, tt.remaining, tt.consumed, tt.firstPoint)
+			if out["current_remaining"] != tt.wantRemaining {
+				t.Errorf("current_remaining = %v, want %v", out["current_remaining"], tt.wantRemaining)
+			}
+			daysLeft := out["days_left"]
+			switch {
+			case tt.wantEmpty:
+				if daysLeft != 0 || out["predicted_empty_at"] == nil {
+					t.Errorf("days_left = %v, predicted_empty_at = %v; want 0 and now", daysLeft, out["predicted_empty_at"])
+				}
+			case tt.wantForecast:
+				if left, ok := daysLeft.(float64); !ok || left != 5 {
+					t.Errorf("days_left = %v, want 5", daysLeft)
+				}
+			default:
+				if daysLeft != nil || out["predicted_empty_at"] != nil {
+					t.Errorf("days_left = %v, predicted_empty_at = %v; want null", daysLeft, out["predicted_empty_at"])
+				}
+			}
+		})
+	}
+}
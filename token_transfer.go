@@ -0,0 +1,691 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 账号导入导出：导出支持 JSON（含 st/rt/client_id/代理/备注/并发/分组）、CSV（同样的列）和纯文本（每行一个 AT）；
+// 导入接受同样三种格式，按 mode 追加（append）、替换（replace）或按邮箱/token 合并（merge），
+// 同一批次内 token 或邮箱重复的条目只处理第一条，并逐行返回处理结果。
+
+// tokenTransferColumns 导出的列（CSV 表头与 JSON 字段一致），导入时也按这些列名识别
+var tokenTransferColumns = []string{
+	"email", "access_token", "session_token", "refresh_token", "client_id", "proxy_url", "remark",
+	"is_active", "image_enabled", "video_enabled", "image_concurrency", "video_concurrency", "plan_type", "groups",
+}
+
+// tokenFieldAliases 导入时可识别的列名别名；键为去掉 _ - 空格后的小写形式
+var tokenFieldAliases = map[string]string{
+	"token": "access_token",
+	"at":    "access_token",
+	"st":    "session_token",
+	"rt":    "refresh_token",
+	"proxy": "proxy_url",
+	"mail":  "email",
+	"group": "groups",
+}
+
+// normalizeTokenField 将导入文件的列名规范为 tokenTransferColumns 中的名称，无法识别时返回空串
+func normalizeTokenField(name string) string {
+	key := strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	if v, ok := tokenFieldAliases[key]; ok {
+		return v
+	}
+	for _, col := range tokenTransferColumns {
+		if strings.ReplaceAll(col, "_", "") == key {
+			return col
+		}
+	}
+	return ""
+}
+
+// tokenExportItem 导出的一行
+type tokenExportItem struct {
+	Email            string   `json:"email"`
+	AccessToken      string   `json:"access_token"`
+	SessionToken     string   `json:"session_token"`
+	RefreshToken     string   `json:"refresh_token"`
+	ClientID         string   `json:"client_id"`
+	ProxyURL         string   `json:"proxy_url"`
+	Remark           string   `json:"remark"`
+	IsActive         bool     `json:"is_active"`
+	ImageEnabled     bool     `json:"image_enabled"`
+	VideoEnabled     bool     `json:"video_enabled"`
+	ImageConcurrency int      `json:"image_concurrency"`
+	VideoConcurrency int      `json:"video_concurrency"`
+	PlanType         string   `json:"plan_type"`
+	Groups           []string `json:"groups"`
+}
+
+// csvRecord 按 tokenTransferColumns 的顺序输出；分组以逗号分隔
+func (e tokenExportItem) csvRecord() []string {
+	return []string{
+		e.Email, e.AccessToken, e.SessionToken, e.RefreshToken, e.ClientID, e.ProxyURL, e.Remark,
+		strconv.FormatBool(e.IsActive), strconv.FormatBool(e.ImageEnabled), strconv.FormatBool(e.VideoEnabled),
+		strconv.Itoa(e.ImageConcurrency), strconv.Itoa(e.VideoConcurrency), e.PlanType, strings.Join(e.Groups, ","),
+	}
+}
+
+// exportTokens 导出账号；ids 为空时导出全部。返回建议文件名、文件内容与条数
+func (a *App) exportTokens(format string, ids []int64) (string, string, int, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "txt" {
+		return "", "", 0, fmt.Errorf("不支持的导出格式: %s（可选 json、csv、txt）", format)
+	}
+	all, err := a.store.ListTokens()
+	if err != nil {
+		return "", "", 0, err
+	}
+	wanted := map[int64]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	items := []tokenExportItem{}
+	for _, t := range all {
+		if len(wanted) > 0 && !wanted[t.ID] {
+			continue
+		}
+		items = append(items, tokenExportItem{
+			Email:            a.tokenRecordEmail(t),
+			AccessToken:      a.openSecret(t.Token),
+			SessionToken:     a.openSecret(t.St),
+			RefreshToken:     a.openSecret(t.Rt),
+			ClientID:         t.ClientID,
+			ProxyURL:         t.ProxyURL,
+			Remark:           t.Remark,
+			IsActive:         t.IsActive,
+			ImageEnabled:     t.ImageEnabled,
+			VideoEnabled:     t.VideoEnabled,
+			ImageConcurrency: t.ImageConcurrency,
+			VideoConcurrency: t.VideoConcurrency,
+			PlanType:         t.PlanType,
+			Groups:           append([]string{}, t.Groups...),
+		})
+	}
+
+	var buf bytes.Buffer
+	count := len(items)
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return "", "", 0, err
+		}
+		buf.Write(b)
+	case "csv":
+		w := csv.NewWriter(&buf)
+		_ = w.Write(tokenTransferColumns)
+		for _, it := range items {
+			_ = w.Write(it.csvRecord())
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return "", "", 0, err
+		}
+	case "txt":
+		count = 0
+		for _, it := range items {
+			if it.AccessToken != "" {
+				buf.WriteString(it.AccessToken + "\n")
+				count++
+			}
+		}
+	}
+	filename := fmt.Sprintf("sora_tokens_%s.%s", time.Now().Format("2006-01-02"), format)
+	return filename, buf.String(), count, nil
+}
+
+// localTokensExport GET /api/tokens/export?format=json|csv|txt&ids=1,2,3
+func (a *App) localTokensExport(rawPath string) (string, error) {
+	u, _ := url.Parse(rawPath)
+	q := u.Query()
+	var ids []int64
+	for _, s := range strings.Split(q.Get("ids"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return jsonFail("无效的 token id: " + s)
+		}
+		ids = append(ids, id)
+	}
+	filename, content, count, err := a.exportTokens(q.Get("format"), ids)
+	if err != nil {
+		return jsonFail("导出失败: " + err.Error())
+	}
+	format := strings.TrimPrefix(filename[strings.LastIndex(filename, "."):], ".")
+	return jsonMarshal(map[string]interface{}{
+		"success":  true,
+		"format":   format,
+		"filename": filename,
+		"count":    count,
+		"content":  content,
+	})
+}
+
+// tokenImportRow 解析后的一条导入记录；Line 为文件中的行号（JSON 数组为第几个元素）
+type tokenImportRow struct {
+	Line   int
+	Fields map[string]string
+	Err    string
+}
+
+// tokenImportItem 校验后的导入条目；指针字段为 nil 表示文件中未提供
+type tokenImportItem struct {
+	Email            string
+	Token            string
+	St               string
+	Rt               string
+	ClientID         string
+	ProxyURL         string
+	Remark           string
+	IsActive         *bool
+	ImageEnabled     *bool
+	VideoEnabled     *bool
+	ImageConcurrency *int
+	VideoConcurrency *int
+	Groups           []string // nil 表示文件中未提供
+}
+
+// tokenImportResult 单行导入结果，status 为 added / updated / duplicate / failed
+type tokenImportResult struct {
+	Line    int    `json:"line"`
+	Status  string `json:"status"`
+	ID      int64  `json:"id,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// normalizeImportMode 兼容旧前端的 at/offline/st/rt（均按追加处理）
+func normalizeImportMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "append", "at", "offline", "st", "rt":
+		return "append", nil
+	case "replace":
+		return "replace", nil
+	case "merge":
+		return "merge", nil
+	}
+	return "", fmt.Errorf("不支持的导入模式: %s（可选 append、replace、merge）", mode)
+}
+
+// parseTokenImportRows 解析导入内容：tokens 为字符串或对象数组（旧接口），否则按 format 解析 content；
+// format 为空时根据内容猜测（[ 或 { 开头为 JSON，首行含 token 列名且有逗号为 CSV，否则为纯文本）
+func parseTokenImportRows(tokens json.RawMessage, content, format string) ([]tokenImportRow, error) {
+	if len(bytes.TrimSpace(tokens)) > 0 && string(bytes.TrimSpace(tokens)) != "null" {
+		return parseTokenImportJSON(tokens)
+	}
+	content = strings.TrimPrefix(content, "\ufeff")
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return nil, fmt.Errorf("导入内容为空")
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		firstLine := strings.SplitN(trimmed, "\n", 2)[0]
+		switch {
+		case strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{"):
+			format = "json"
+		case strings.Contains(firstLine, ",") && strings.Contains(strings.ToLower(firstLine), "token"):
+			format = "csv"
+		default:
+			format = "txt"
+		}
+	}
+	switch format {
+	case "json":
+		if strings.HasPrefix(trimmed, "{") {
+			trimmed = "[" + trimmed + "]"
+		}
+		return parseTokenImportJSON([]byte(trimmed))
+	case "csv":
+		return parseTokenImportCSV(content)
+	case "txt", "text":
+		var rows []tokenImportRow
+		for i, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			rows = append(rows, tokenImportRow{Line: i + 1, Fields: map[string]string{"access_token": line}})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("不支持的导入格式: %s（可选 json、csv、txt）", format)
+}
+
+func parseTokenImportJSON(raw []byte) ([]tokenImportRow, error) {
+	var list []interface{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("JSON 必须是数组格式: %v", err)
+	}
+	rows := make([]tokenImportRow, 0, len(list))
+	for i, v := range list {
+		row := tokenImportRow{Line: i + 1, Fields: map[string]string{}}
+		switch x := v.(type) {
+		case string:
+			row.Fields["access_token"] = strings.TrimSpace(x)
+		case map[string]interface{}:
+			for k, val := range x {
+				col := normalizeTokenField(k)
+				if col == "" || val == nil {
+					continue
+				}
+				switch vv := val.(type) {
+				case string:
+					row.Fields[col] = strings.TrimSpace(vv)
+				case bool:
+					row.Fields[col] = strconv.FormatBool(vv)
+				case float64:
+					row.Fields[col] = strconv.FormatFloat(vv, 'f', -1, 64)
+				case []interface{}:
+					// 分组等列表字段，合并为逗号分隔
+					var items []string
+					for _, item := range vv {
+						s, ok := item.(string)
+						if !ok {
+							row.Err = "字段 " + k + " 类型无效"
+							break
+						}
+						items = append(items, s)
+					}
+					row.Fields[col] = strings.Join(items, ",")
+				default:
+					row.Err = "字段 " + k + " 类型无效"
+				}
+			}
+		default:
+			row.Err = "条目必须是字符串或对象"
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseTokenImportCSV(content string) ([]tokenImportRow, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 表头读取失败: %v", err)
+	}
+	cols := make([]string, len(header))
+	hasToken := false
+	for i, h := range header {
+		cols[i] = normalizeTokenField(h)
+		hasToken = hasToken || cols[i] == "access_token"
+	}
+	if !hasToken {
+		return nil, fmt.Errorf("CSV 表头缺少 access_token 列")
+	}
+	var rows []tokenImportRow
+	for {
+		rec, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			line := 0
+			if pe, ok := err.(*csv.ParseError); ok {
+				line = pe.Line
+			}
+			rows = append(rows, tokenImportRow{Line: line, Err: "CSV 解析失败: " + err.Error()})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		row := tokenImportRow{Line: line, Fields: map[string]string{}}
+		empty := true
+		for i, v := range rec {
+			if i < len(cols) && cols[i] != "" {
+				row.Fields[cols[i]] = strings.TrimSpace(v)
+				empty = empty && strings.TrimSpace(v) == ""
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// tokenImportItemFromFields 校验一行导入数据
+func tokenImportItemFromFields(f map[string]string) (tokenImportItem, error) {
+	it := tokenImportItem{
+		Email:    f["email"],
+		Token:    f["access_token"],
+		St:       f["session_token"],
+		Rt:       f["refresh_token"],
+		ClientID: f["client_id"],
+		ProxyURL: f["proxy_url"],
+		Remark:   f["remark"],
+	}
+	if it.Token == "" {
+		return it, fmt.Errorf("缺少 access_token")
+	}
+	if _, err := parseProxyURL(it.ProxyURL); err != nil {
+		return it, err
+	}
+	if s, ok := f["groups"]; ok {
+		groups, err := validateTokenGroups(parseTokenGroups(s))
+		if err != nil {
+			return it, err
+		}
+		it.Groups = append([]string{}, groups...)
+	}
+	if it.Email == "" {
+		c, _ := parseJWTClaims(it.Token)
+		it.Email = c.Email
+	}
+	parseBool := func(col string, dst **bool) error {
+		if s := f[col]; s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("%s 不是有效的布尔值: %s", col, s)
+			}
+			*dst = &v
+		}
+		return nil
+	}
+	parseInt := func(col string, dst **int) error {
+		if s := f[col]; s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("%s 不是有效的整数: %s", col, s)
+			}
+			*dst = &v
+		}
+		return nil
+	}
+	for _, err := range []error{
+		parseBool("is_active", &it.IsActive),
+		parseBool("image_enabled", &it.ImageEnabled),
+		parseBool("video_enabled", &it.VideoEnabled),
+		parseInt("image_concurrency", &it.ImageConcurrency),
+		parseInt("video_concurrency", &it.VideoConcurrency),
+	} {
+		if err != nil {
+			return it, err
+		}
+	}
+	return it, nil
+}
+
+// applyTokenImportItem 将导入条目中提供的字段写入记录（未提供的字段保持不变）；
+// 尚无账号状态时把邮箱记入 status_json，便于之后按邮箱合并
+func applyTokenImportItem(t *tokenRecord, it tokenImportItem, encToken, tokenHash, encSt, encRt string) {
+	t.Token, t.TokenHash = encToken, tokenHash
+	if it.Email != "" && t.StatusJSON == "" {
+		b, _ := json.Marshal(map[string]string{"email": it.Email})
+		t.StatusJSON = string(b)
+	}
+	if it.St != "" {
+		t.St = encSt
+	}
+	if it.Rt != "" {
+		t.Rt = encRt
+	}
+	if it.ClientID != "" {
+		t.ClientID = it.ClientID
+	}
+	if it.ProxyURL != "" {
+		t.ProxyURL = it.ProxyURL
+	}
+	if it.Remark != "" {
+		t.Remark = it.Remark
+	}
+	if it.IsActive != nil {
+		t.IsActive = *it.IsActive
+	}
+	if it.ImageEnabled != nil {
+		t.ImageEnabled = *it.ImageEnabled
+	}
+	if it.VideoEnabled != nil {
+		t.VideoEnabled = *it.VideoEnabled
+	}
+	if it.ImageConcurrency != nil {
+		t.ImageConcurrency = *it.ImageConcurrency
+	}
+	if it.VideoConcurrency != nil {
+		t.VideoConcurrency = *it.VideoConcurrency
+	}
+	if it.Groups != nil {
+		t.Groups = it.Groups
+	}
+}
+
+// localTokensImport POST /api/tokens/import
+// 请求体：{"tokens": [...], "mode": "append"} 或 {"content": "文件内容", "format": "json|csv|txt", "mode": "merge"}
+func (a *App) localTokensImport(body string) (string, error) {
+	var input struct {
+		Tokens  json.RawMessage `json:"tokens"`
+		Content string          `json:"content"`
+		Format  string          `json:"format"`
+		Mode    string          `json:"mode"`
+	}
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		return jsonFail("请求体解析失败")
+	}
+	mode, err := normalizeImportMode(input.Mode)
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+	rows, err := parseTokenImportRows(input.Tokens, input.Content, input.Format)
+	if err != nil {
+		return jsonFail(err.Error())
+	}
+
+	// 先校验并做批内去重，replace 模式只有存在有效条目时才删除现有账号
+	results := make([]tokenImportResult, len(rows))
+	items := make([]*tokenImportItem, len(rows))
+	seenToken := map[string]int{}
+	seenEmail := map[string]int{}
+	valid := 0
+	for i, row := range rows {
+		results[i] = tokenImportResult{Line: row.Line}
+		if row.Err != "" {
+			results[i].Status, results[i].Message = "failed", row.Err
+			continue
+		}
+		it, err := tokenImportItemFromFields(row.Fields)
+		results[i].Email = it.Email
+		if err != nil {
+			results[i].Status, results[i].Message = "failed", err.Error()
+			continue
+		}
+		if first, ok := seenToken[it.Token]; ok {
+			results[i].Status, results[i].Message = "duplicate", fmt.Sprintf("与第 %d 行的 access_token 重复", first)
+			continue
+		}
+		emailKey := strings.ToLower(it.Email)
+		if first, ok := seenEmail[emailKey]; ok && emailKey != "" {
+			results[i].Status, results[i].Message = "duplicate", fmt.Sprintf("与第 %d 行的邮箱重复", first)
+			continue
+		}
+		seenToken[it.Token] = row.Line
+		if emailKey != "" {
+			seenEmail[emailKey] = row.Line
+		}
+		items[i] = &it
+		valid++
+	}
+
+	now := time.Now()
+	removed := 0
+	if mode == "replace" {
+		if valid == 0 {
+			return jsonFail("没有可导入的有效 Token，未删除现有账号")
+		}
+		if removed, err = a.replaceImportedTokens(items, results, now); err != nil {
+			return jsonFail(err.Error())
+		}
+	} else if err := a.importTokenItems(items, results, mode, now); err != nil {
+		return jsonFail(err.Error())
+	}
+	if a.scheduler != nil {
+		a.scheduler.notify()
+	}
+
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	msg := fmt.Sprintf("新增 %d 个，更新 %d 个，重复跳过 %d 个，失败 %d 个", counts["added"], counts["updated"], counts["duplicate"], counts["failed"])
+	if mode == "replace" {
+		msg = fmt.Sprintf("已删除原有 %d 个账号；", removed) + msg
+	}
+	return jsonMarshal(map[string]interface{}{
+		"success":   true,
+		"mode":      mode,
+		"message":   msg,
+		"added":     counts["added"],
+		"imported":  counts["added"],
+		"updated":   counts["updated"],
+		"duplicate": counts["duplicate"],
+		"failed":    counts["failed"],
+		"removed":   removed,
+		"results":   results,
+	})
+}
+
+// importTokenItems append/merge 模式：与现有账号按 access_token 或邮箱匹配，merge 时更新已有账号，否则跳过
+func (a *App) importTokenItems(items []*tokenImportItem, results []tokenImportResult, mode string, now time.Time) error {
+	existing, err := a.store.ListTokens()
+	if err != nil {
+		return fmt.Errorf("读取现有账号失败: %v", err)
+	}
+	byToken := map[string]int64{}
+	byEmail := map[string]int64{}
+	for _, t := range existing {
+		if plain := a.openSecret(t.Token); plain != "" {
+			byToken[plain] = t.ID
+		}
+		if email := strings.ToLower(a.tokenRecordEmail(t)); email != "" {
+			if _, ok := byEmail[email]; !ok {
+				byEmail[email] = t.ID
+			}
+		}
+	}
+
+	for i, it := range items {
+		if it == nil {
+			continue
+		}
+		res := &results[i]
+		encToken, encSt, encRt, err := a.sealTokenFields(it.Token, it.St, it.Rt)
+		if err != nil {
+			res.Status, res.Message = "failed", "加密 token 失败: "+err.Error()
+			continue
+		}
+		hash := a.tokenHash(it.Token)
+		emailKey := strings.ToLower(it.Email)
+
+		id, found := byToken[it.Token]
+		if !found && emailKey != "" {
+			id, found = byEmail[emailKey]
+		}
+		if found && mode != "merge" {
+			res.Status, res.ID, res.Message = "duplicate", id, fmt.Sprintf("已存在（ID %d），已跳过", id)
+			continue
+		}
+		if found {
+			if err := a.store.UpdateToken(id, func(t *tokenRecord) error {
+				applyTokenImportItem(t, *it, encToken, hash, encSt, encRt)
+				t.UpdatedAt = now
+				return nil
+			}); err != nil {
+				res.Status, res.Message = "failed", "更新失败: "+err.Error()
+				continue
+			}
+			res.Status, res.ID = "updated", id
+		} else {
+			newID, err := a.store.InsertToken(newImportedToken(*it, encToken, hash, encSt, encRt, now))
+			if err != nil {
+				res.Status, res.Message = "failed", "写入失败: "+err.Error()
+				continue
+			}
+			res.Status, res.ID, id = "added", newID, newID
+		}
+		byToken[it.Token] = id
+		if emailKey != "" {
+			byEmail[emailKey] = id
+		}
+	}
+	return nil
+}
+
+// replaceImportedTokens replace 模式：有进行中任务时拒绝；新账号加密后与删除现有账号在同一个存储操作中完成，
+// 失败时现有账号保持不变。返回删除的账号数
+func (a *App) replaceImportedTokens(items []*tokenImportItem, results []tokenImportResult, now time.Time) (int, error) {
+	if a.scheduler != nil {
+		inflight, err := a.scheduler.inflightCounts()
+		if err != nil {
+			return 0, err
+		}
+		busy := 0
+		for _, n := range inflight {
+			if n > 0 {
+				busy++
+			}
+		}
+		if busy > 0 {
+			return 0, fmt.Errorf("有 %d 个账号存在进行中的任务，请等待任务结束或取消后再替换导入", busy)
+		}
+	}
+	existing, err := a.store.ListTokens()
+	if err != nil {
+		return 0, fmt.Errorf("读取现有账号失败: %v", err)
+	}
+	var list []tokenRecord
+	var index []int
+	for i, it := range items {
+		if it == nil {
+			continue
+		}
+		encToken, encSt, encRt, err := a.sealTokenFields(it.Token, it.St, it.Rt)
+		if err != nil {
+			results[i].Status, results[i].Message = "failed", "加密 token 失败: "+err.Error()
+			continue
+		}
+		list = append(list, newImportedToken(*it, encToken, a.tokenHash(it.Token), encSt, encRt, now))
+		index = append(index, i)
+	}
+	if len(list) == 0 {
+		return 0, fmt.Errorf("没有可导入的有效 Token，未删除现有账号")
+	}
+	ids, err := a.store.ReplaceTokens(list)
+	if err != nil {
+		return 0, fmt.Errorf("替换导入失败，现有账号未改动: %v", err)
+	}
+	for k, i := range index {
+		results[i].Status, results[i].ID = "added", ids[k]
+	}
+	return len(existing), nil
+}
+
+// newImportedToken 新导入账号的默认设置，再用导入条目覆盖
+func newImportedToken(it tokenImportItem, encToken, hash, encSt, encRt string, now time.Time) tokenRecord {
+	rec := tokenRecord{
+		IsActive:         true,
+		ImageEnabled:     true,
+		VideoEnabled:     true,
+		ImageConcurrency: -1,
+		VideoConcurrency: 3,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	applyTokenImportItem(&rec, it, encToken, hash, encSt, encRt)
+	return rec
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTokenImportRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  string
+		content string
+		format  string
+		want    []tokenImportRow
+		wantErr string
+	}{
+		{
+			name:   "legacy tokens array of strings",
+			tokens: `[" at-1 ", "at-2"]`,
+			want: []tokenImportRow{
+				{Line: 1, Fields: map[string]string{"access_token": "at-1"}},
+				{Line: 2, Fields: map[string]string{"access_token": "at-2"}},
+			},
+		},
+		{
+			name:   "legacy tokens objects with aliases and typed values",
+			tokens: `[{"token": "at-1", "Mail": "a@x", "is_active": false, "video_concurrency": 2, "group": ["g1", "g2"], "unknown": "x"}]`,
+			want: []tokenImportRow{{Line: 1, Fields: map[string]string{
+				"access_token": "at-1", "email": "a@x", "is_active": "false", "video_concurrency": "2", "groups": "g1,g2",
+			}}},
+		},
+		{
+			name:   "invalid entries are reported per row",
+			tokens: `[1, {"token": {"nested": true}}]`,
+			want: []tokenImportRow{
+				{Line: 1, Fields: map[string]string{}, Err: "条目必须是字符串或对象"},
+				{Line: 2, Fields: map[string]string{}, Err: "字段 token 类型无效"},
+			},
+		},
+		{
+			name:    "null tokens falls back to content",
+			tokens:  `null`,
+			content: "at-1\n",
+			want:    []tokenImportRow{{Line: 1, Fields: map[string]string{"access_token": "at-1"}}},
+		},
+		{
+			name:    "json content guessed from single object",
+			content: `{"access_token": "at-1", "st": "st-1"}`,
+			want:    []tokenImportRow{{Line: 1, Fields: map[string]string{"access_token": "at-1", "session_token": "st-1"}}},
+		},
+		{
+			name:    "csv guessed from header with bom and blank rows",
+			content: "\ufeffemail,Access Token,remark\na@x,at-1, hello \n,,\nb@x,at-2,\n",
+			want: []tokenImportRow{
+				{Line: 2, Fields: map[string]string{"email": "a@x", "access_token": "at-1", "remark": "hello"}},
+				{Line: 4, Fields: map[string]string{"email": "b@x", "access_token": "at-2", "remark": ""}},
+			},
+		},
+		{
+			name:    "csv without token column",
+			content: "email,remark\na@x,hi\n",
+			format:  "csv",
+			wantErr: "缺少 access_token",
+		},
+		{
+			name:    "text skips blank and comment lines",
+			content: "# exported\nat-1\n\n  at-2  \n",
+			want: []tokenImportRow{
+				{Line: 2, Fields: map[string]string{"access_token": "at-1"}},
+				{Line: 4, Fields: map[string]string{"access_token": "at-2"}},
+			},
+		},
+		{
+			name:    "explicit txt format keeps commas",
+			content: "token,with,commas\n",
+			format:  "TXT",
+			want:    []tokenImportRow{{Line: 1, Fields: map[string]string{"access_token": "token,with,commas"}}},
+		},
+		{name: "empty content", content: "  \n", wantErr: "导入内容为空"},
+		{name: "unknown format", content: "at-1", format: "xml", wantErr: "不支持的导入格式"},
+		{name: "json content must be an array", content: `"at-1"`, format: "json", wantErr: "JSON 必须是数组格式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTokenImportRows(json.RawMessage(tt.tokens), tt.content, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseTokenImportRows() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTokenImportRows() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTokenImportRows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}